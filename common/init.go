@@ -19,13 +19,15 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+	// EncryptSecrets 加密数据库中尚未加密的敏感数据并迁移到当前主密钥后退出
+	EncryptSecrets = flag.Bool("encrypt-secrets", false, "encrypt stored secrets with the active key and exit")
 )
 
 func printHelp() {
 	fmt.Println("NewAPI(Based OneAPI) " + Version + " - The next-generation LLM gateway and AI asset management system supports multiple languages.")
 	fmt.Println("Original Project: OneAPI by JustSong - https://github.com/songquanpeng/one-api")
 	fmt.Println("Maintainer: QuantumNous - https://github.com/QuantumNous/new-api")
-	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--encrypt-secrets] [--version] [--help]")
 }
 
 func InitEnv() {
@@ -61,6 +63,9 @@ func InitEnv() {
 	} else {
 		CryptoSecret = SessionSecret
 	}
+	if err := InitSecretEncryption(); err != nil {
+		log.Fatal("failed to initialize secret encryption: " + err.Error())
+	}
 	if os.Getenv("SQLITE_PATH") != "" {
 		SQLitePath = os.Getenv("SQLITE_PATH")
 	}
@@ -147,6 +152,18 @@ func initConstantEnv() {
 	constant.TaskQueryLimit = GetEnvOrDefault("TASK_QUERY_LIMIT", 1000)
 	// 异步任务超时时间（分钟），超过此时间未完成的任务将被标记为失败并退款。0 表示禁用。
 	constant.TaskTimeoutMinutes = GetEnvOrDefault("TASK_TIMEOUT_MINUTES", 1440)
+	// 网关自行生成的文件（如批处理结果）的本地存储目录，多节点部署时需使用共享存储
+	constant.FileStoragePath = GetEnvOrDefaultString("FILE_STORAGE_PATH", "./data/files")
+	// Prometheus 指标端点，需配置访问令牌或 IP 白名单之一，否则拒绝所有抓取请求
+	constant.MetricsEnabled = GetEnvOrDefaultBool("METRICS_ENABLED", false)
+	constant.MetricsToken = GetEnvOrDefaultString("METRICS_TOKEN", "")
+	var metricsAllowedIps []string
+	for _, ip := range strings.Split(GetEnvOrDefaultString("METRICS_ALLOWED_IPS", ""), ",") {
+		if trimmedIp := strings.TrimSpace(ip); trimmedIp != "" {
+			metricsAllowedIps = append(metricsAllowedIps, trimmedIp)
+		}
+	}
+	constant.MetricsAllowedIps = metricsAllowedIps
 
 	soraPatchStr := GetEnvOrDefaultString("TASK_PRICE_PATCH", "")
 	if soraPatchStr != "" {
@@ -40,32 +40,46 @@ func New(ctx context.Context, r *redis.Client) *RedisLimiter {
 }
 
 func (rl *RedisLimiter) Allow(ctx context.Context, key string, opts ...Option) (bool, error) {
-	// 默认配置
-	config := &Config{
-		Capacity:  10,
-		Rate:      1,
-		Requested: 1,
+	result, err := rl.Take(ctx, key, opts...)
+	if err != nil {
+		return false, err
 	}
+	return result.Allowed, nil
+}
 
-	// 应用选项模式
-	for _, opt := range opts {
-		opt(config)
-	}
+// Take 执行限流并返回扣减后桶内剩余的令牌数
+func (rl *RedisLimiter) Take(ctx context.Context, key string, opts ...Option) (Result, error) {
+	config := newConfig(opts...)
 
+	force := 0
+	if config.Force {
+		force = 1
+	}
 	// 执行限流
-	result, err := rl.client.EvalSha(
+	values, err := rl.client.EvalSha(
 		ctx,
 		rl.limitScriptSHA,
 		[]string{key},
 		config.Requested,
 		config.Rate,
 		config.Capacity,
-	).Int()
+		force,
+	).Int64Slice()
 
 	if err != nil {
-		return false, fmt.Errorf("rate limit failed: %w", err)
+		return Result{}, fmt.Errorf("rate limit failed: %w", err)
 	}
-	return result == 1, nil
+	if len(values) < 2 {
+		return Result{}, fmt.Errorf("rate limit failed: unexpected result %v", values)
+	}
+	return Result{Allowed: values[0] == 1, Remaining: values[1]}, nil
+}
+
+// Result 单次限流的结果
+type Result struct {
+	Allowed bool
+	// 扣减后桶内剩余令牌数，强制扣减时可能为负
+	Remaining int64
 }
 
 // Config 配置选项模式
@@ -73,6 +87,22 @@ type Config struct {
 	Capacity  int64
 	Rate      int64
 	Requested int64
+	Force     bool
+}
+
+func newConfig(opts ...Option) *Config {
+	// 默认配置
+	config := &Config{
+		Capacity:  10,
+		Rate:      1,
+		Requested: 1,
+	}
+
+	// 应用选项模式
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
 }
 
 type Option func(*Config)
@@ -88,3 +118,8 @@ func WithRate(r int64) Option {
 func WithRequested(n int64) Option {
 	return func(cfg *Config) { cfg.Requested = n }
 }
+
+// WithForce 令牌不足时仍然扣减，用于按实际用量事后结算
+func WithForce() Option {
+	return func(cfg *Config) { cfg.Force = true }
+}
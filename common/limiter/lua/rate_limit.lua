@@ -3,11 +3,14 @@
 -- ARGV[1]: 请求令牌数 (通常为1)
 -- ARGV[2]: 令牌生成速率 (每秒)
 -- ARGV[3]: 桶容量
+-- ARGV[4]: 是否强制扣减 (可选，为 1 时令牌不足也扣减，桶可为负，用于请求结束后按实际用量结算)
+-- 返回: {是否允许, 扣减后剩余令牌数}
 
 local key = KEYS[1]
 local requested = tonumber(ARGV[1])
 local rate = tonumber(ARGV[2])
 local capacity = tonumber(ARGV[3])
+local force = tonumber(ARGV[4]) == 1
 
 -- 获取当前时间（Redis服务器时间）
 local now = redis.call('TIME')
@@ -35,10 +38,19 @@ local allowed = false
 if tokens >= requested then
     tokens = tokens - requested
     allowed = true
+elseif force then
+    tokens = tokens - requested
 end
 
----- 更新桶状态并设置过期时间
+-- 更新桶状态并设置过期时间：桶回满所需时间之后的状态与新建桶一致，可以直接丢弃
 redis.call('HMSET', key, 'tokens', tokens, 'last_time', last_time)
---redis.call('EXPIRE', key, math.ceil(capacity / rate) + 60) -- 适当延长过期时间
+if rate > 0 then
+    local ttl = math.ceil(capacity / rate) + 60
+    -- 强制扣减后桶可能为负，需要更久才能回满
+    if tokens < 0 then
+        ttl = ttl + math.ceil(-tokens / rate)
+    end
+    redis.call('EXPIRE', key, ttl)
+end
 
-return allowed and 1 or 0
\ No newline at end of file
+return {allowed and 1 or 0, math.floor(tokens)}
@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter 未启用 Redis 时使用的进程内令牌桶，语义与 lua/rate_limit.lua 一致
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	now     func() time.Time
+	takes   int
+}
+
+type memoryBucket struct {
+	tokens   float64
+	lastTime time.Time
+	capacity float64
+	rate     float64
+}
+
+// 每执行 memorySweepInterval 次限流清理一次已回满的桶
+const memorySweepInterval = 1024
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+func (ml *MemoryLimiter) Take(key string, opts ...Option) Result {
+	config := newConfig(opts...)
+
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := ml.now()
+	bucket, ok := ml.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(config.Capacity), lastTime: now}
+		ml.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.lastTime).Seconds()
+		if elapsed > 0 {
+			bucket.tokens = math.Min(float64(config.Capacity), bucket.tokens+elapsed*float64(config.Rate))
+			bucket.lastTime = now
+		}
+	}
+	bucket.capacity = float64(config.Capacity)
+	bucket.rate = float64(config.Rate)
+
+	allowed := false
+	requested := float64(config.Requested)
+	if bucket.tokens >= requested {
+		bucket.tokens -= requested
+		allowed = true
+	} else if config.Force {
+		bucket.tokens -= requested
+	}
+	remaining := int64(math.Floor(bucket.tokens))
+
+	ml.takes++
+	if ml.takes%memorySweepInterval == 0 {
+		ml.sweep(now)
+	}
+	return Result{Allowed: allowed, Remaining: remaining}
+}
+
+// sweep 删除已回满的桶，回满的桶与新建的桶等价
+func (ml *MemoryLimiter) sweep(now time.Time) {
+	for key, bucket := range ml.buckets {
+		if bucket.rate <= 0 {
+			continue
+		}
+		if bucket.tokens+now.Sub(bucket.lastTime).Seconds()*bucket.rate >= bucket.capacity {
+			delete(ml.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterTake(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	ml := NewMemoryLimiter()
+	ml.now = func() time.Time { return now }
+	opts := []Option{WithCapacity(120), WithRate(2), WithRequested(60)}
+
+	for i := 0; i < 2; i++ {
+		if result := ml.Take("k", opts...); !result.Allowed {
+			t.Fatalf("take %d should be allowed", i)
+		}
+	}
+	result := ml.Take("k", opts...)
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("bucket should be empty, got %+v", result)
+	}
+
+	now = now.Add(30 * time.Second)
+	if result = ml.Take("k", opts...); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("bucket should refill 60 tokens in 30s, got %+v", result)
+	}
+
+	// 强制扣减允许桶变为负数，之后需等待补充
+	result = ml.Take("k", WithCapacity(120), WithRate(2), WithRequested(100), WithForce())
+	if result.Allowed || result.Remaining != -100 {
+		t.Fatalf("force take should debit the bucket, got %+v", result)
+	}
+	now = now.Add(40 * time.Second)
+	if result = ml.Take("k", WithCapacity(120), WithRate(2), WithRequested(0)); result.Remaining != -20 {
+		t.Fatalf("expected -20 remaining, got %+v", result)
+	}
+}
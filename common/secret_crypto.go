@@ -0,0 +1,317 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 敏感数据（渠道密钥、OAuth client secret、支付密钥等）的信封加密：
+// 每个值使用随机生成的数据密钥（DEK）以 AES-256-GCM 加密，DEK 再由主密钥（KEK）加密后与密文一起保存。
+// 密文格式：enc:v1:<KEK ID>:<加密后的 DEK>:<nonce + 密文>，均为 base64（无填充）。
+// 轮换主密钥时只需重新加密 DEK，旧主密钥保留在密钥环中即可继续解密尚未迁移的数据。
+
+const (
+	SecretEnvelopePrefix = "enc:v1:"
+	secretKeySize        = 32
+)
+
+var (
+	ErrSecretKeyNotFound  = errors.New("secret encryption key not found")
+	ErrSecretMalformed    = errors.New("malformed encrypted secret")
+	ErrSecretKeyringEmpty = errors.New("secret encryption keyring is empty")
+)
+
+var (
+	// SecretEncryptionEnabled 为 true 时写入数据库的敏感数据会被加密；解密始终可用
+	SecretEncryptionEnabled = false
+
+	secretKeyring     = map[string][]byte{}
+	secretActiveKeyId = ""
+	secretKeyringLock sync.RWMutex
+)
+
+// deriveSecretKey 由任意长度的密钥材料派生主密钥，ID 取派生结果的摘要前缀以区分不同来源
+func deriveSecretKey(material string) (string, []byte) {
+	h := hmac.New(sha256.New, []byte(material))
+	h.Write([]byte("new-api secret encryption key"))
+	key := h.Sum(nil)
+	digest := sha256.Sum256(key)
+	return "cs-" + hex.EncodeToString(digest[:4]), key
+}
+
+// parseSecretKeyFile 解析本地密钥文件，每行一个 "<ID>=<base64 编码的 32 字节密钥>"，# 开头为注释，
+// 第一个有效行为默认的当前主密钥
+func parseSecretKeyFile(content string) (map[string][]byte, string, error) {
+	keys := make(map[string][]byte)
+	firstId := ""
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, "=")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, "", fmt.Errorf("invalid key at line %d", i+1)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != secretKeySize {
+			return nil, "", fmt.Errorf("key %s at line %d must be %d bytes encoded in base64", id, i+1, secretKeySize)
+		}
+		keys[id] = key
+		if firstId == "" {
+			firstId = id
+		}
+	}
+	return keys, firstId, nil
+}
+
+// InitSecretEncryption 根据环境变量初始化主密钥环：
+// SECRET_ENCRYPTION_KEY_FILE 指定本地密钥文件（支持多把密钥以便轮换），未指定时由 CRYPTO_SECRET 派生；
+// CRYPTO_SECRET_PREVIOUS 为逗号分隔的旧 CRYPTO_SECRET，仅用于解密；
+// SECRET_ENCRYPTION_ACTIVE_KEY 指定用于加密的主密钥 ID，默认为密钥文件的第一把或 CRYPTO_SECRET 派生的密钥。
+func InitSecretEncryption() error {
+	keys := make(map[string][]byte)
+	activeId := ""
+	if path := os.Getenv("SECRET_ENCRYPTION_KEY_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read secret encryption key file: %w", err)
+		}
+		fileKeys, firstId, err := parseSecretKeyFile(string(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse secret encryption key file: %w", err)
+		}
+		for id, key := range fileKeys {
+			keys[id] = key
+		}
+		activeId = firstId
+	}
+	// 仅当显式配置了 CRYPTO_SECRET 或 SESSION_SECRET 时才派生主密钥，否则每次启动随机生成的密钥会导致数据无法解密
+	if os.Getenv("CRYPTO_SECRET") != "" || os.Getenv("SESSION_SECRET") != "" {
+		id, key := deriveSecretKey(CryptoSecret)
+		keys[id] = key
+		if activeId == "" {
+			activeId = id
+		}
+	}
+	for _, previous := range strings.Split(os.Getenv("CRYPTO_SECRET_PREVIOUS"), ",") {
+		if previous = strings.TrimSpace(previous); previous != "" {
+			id, key := deriveSecretKey(previous)
+			keys[id] = key
+		}
+	}
+	if id := strings.TrimSpace(os.Getenv("SECRET_ENCRYPTION_ACTIVE_KEY")); id != "" {
+		if _, ok := keys[id]; !ok {
+			return fmt.Errorf("SECRET_ENCRYPTION_ACTIVE_KEY %s is not in the keyring", id)
+		}
+		activeId = id
+	}
+	enabled := GetEnvOrDefaultBool("SECRET_ENCRYPTION_ENABLED", false)
+	if enabled && activeId == "" {
+		return errors.New("SECRET_ENCRYPTION_ENABLED requires SECRET_ENCRYPTION_KEY_FILE, CRYPTO_SECRET or SESSION_SECRET")
+	}
+	SetSecretKeyring(keys, activeId)
+	SecretEncryptionEnabled = enabled
+	return nil
+}
+
+// SetSecretKeyring 替换主密钥环，activeId 为加密使用的主密钥
+func SetSecretKeyring(keys map[string][]byte, activeId string) {
+	secretKeyringLock.Lock()
+	defer secretKeyringLock.Unlock()
+	secretKeyring = keys
+	secretActiveKeyId = activeId
+}
+
+// GetSecretActiveKeyId 返回当前用于加密的主密钥 ID
+func GetSecretActiveKeyId() string {
+	secretKeyringLock.RLock()
+	defer secretKeyringLock.RUnlock()
+	return secretActiveKeyId
+}
+
+func getSecretKey(id string) ([]byte, error) {
+	secretKeyringLock.RLock()
+	defer secretKeyringLock.RUnlock()
+	key, ok := secretKeyring[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSecretKeyNotFound, id)
+	}
+	return key, nil
+}
+
+func sealSecret(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openSecret(key []byte, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrSecretMalformed
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+// IsEncryptedSecret 判断值是否为信封加密格式
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, SecretEnvelopePrefix)
+}
+
+type secretEnvelope struct {
+	keyId      string
+	wrappedKey []byte
+	ciphertext []byte
+}
+
+func parseSecretEnvelope(value string) (*secretEnvelope, error) {
+	parts := strings.Split(strings.TrimPrefix(value, SecretEnvelopePrefix), ":")
+	if len(parts) != 3 {
+		return nil, ErrSecretMalformed
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrSecretMalformed
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrSecretMalformed
+	}
+	return &secretEnvelope{keyId: parts[0], wrappedKey: wrappedKey, ciphertext: ciphertext}, nil
+}
+
+func (e *secretEnvelope) String() string {
+	return SecretEnvelopePrefix + e.keyId + ":" +
+		base64.RawStdEncoding.EncodeToString(e.wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(e.ciphertext)
+}
+
+// unwrapDataKey 使用主密钥解出数据密钥
+func (e *secretEnvelope) unwrapDataKey() ([]byte, error) {
+	kek, err := getSecretKey(e.keyId)
+	if err != nil {
+		return nil, err
+	}
+	return openSecret(kek, e.wrappedKey)
+}
+
+// EncryptSecret 使用当前主密钥加密，已加密的值与空值原样返回
+func EncryptSecret(plaintext string) (string, error) {
+	if plaintext == "" || IsEncryptedSecret(plaintext) {
+		return plaintext, nil
+	}
+	activeId := GetSecretActiveKeyId()
+	kek, err := getSecretKey(activeId)
+	if err != nil {
+		return "", ErrSecretKeyringEmpty
+	}
+	dek := make([]byte, secretKeySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", err
+	}
+	ciphertext, err := sealSecret(dek, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := sealSecret(kek, dek)
+	if err != nil {
+		return "", err
+	}
+	return (&secretEnvelope{keyId: activeId, wrappedKey: wrappedKey, ciphertext: ciphertext}).String(), nil
+}
+
+// DecryptSecret 解密信封格式的值，未加密的值原样返回
+func DecryptSecret(value string) (string, error) {
+	if !IsEncryptedSecret(value) {
+		return value, nil
+	}
+	envelope, err := parseSecretEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dek, err := envelope.unwrapDataKey()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := openSecret(dek, envelope.ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// EncryptSecretIfEnabled 在启用加密时加密，否则原样返回
+func EncryptSecretIfEnabled(plaintext string) (string, error) {
+	if !SecretEncryptionEnabled {
+		return plaintext, nil
+	}
+	return EncryptSecret(plaintext)
+}
+
+// RewrapSecret 将值迁移到当前主密钥：明文会被加密，旧主密钥加密的值只重新加密数据密钥，
+// 返回的 changed 表示值是否发生变化
+func RewrapSecret(value string) (result string, changed bool, err error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !IsEncryptedSecret(value) {
+		encrypted, err := EncryptSecret(value)
+		if err != nil {
+			return "", false, err
+		}
+		return encrypted, true, nil
+	}
+	envelope, err := parseSecretEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	activeId := GetSecretActiveKeyId()
+	if envelope.keyId == activeId {
+		return value, false, nil
+	}
+	dek, err := envelope.unwrapDataKey()
+	if err != nil {
+		return "", false, err
+	}
+	kek, err := getSecretKey(activeId)
+	if err != nil {
+		return "", false, ErrSecretKeyringEmpty
+	}
+	wrappedKey, err := sealSecret(kek, dek)
+	if err != nil {
+		return "", false, err
+	}
+	envelope.keyId = activeId
+	envelope.wrappedKey = wrappedKey
+	return envelope.String(), true, nil
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewXLSXWriter(&buf, "logs")
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRow([]any{"model", "quota"}))
+	require.NoError(t, writer.WriteRow([]any{"a<b>&c", 42}))
+	require.NoError(t, writer.Close())
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	names := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		names[f.Name] = f
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		require.Contains(t, names, name)
+	}
+	rc, err := names["xl/worksheets/sheet1.xml"].Open()
+	require.NoError(t, err)
+	defer rc.Close()
+	sheet, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Contains(t, string(sheet), `<t xml:space="preserve">a&lt;b&gt;&amp;c</t>`)
+	require.Contains(t, string(sheet), `<c><v>42</v></c>`)
+}
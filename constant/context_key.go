@@ -19,6 +19,8 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenRateLimit         ContextKey = "token_rate_limit"
+	ContextKeyTokenOrganizationId    ContextKey = "token_organization_id"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -36,6 +38,7 @@ const (
 	ContextKeyChannelStatusCodeMapping ContextKey = "status_code_mapping"
 	ContextKeyChannelIsMultiKey        ContextKey = "channel_is_multi_key"
 	ContextKeyChannelMultiKeyIndex     ContextKey = "channel_multi_key_index"
+	ContextKeyChannelKeyRelease        ContextKey = "channel_key_release" // 释放多 Key 渠道所选 key 并发计数的函数
 	ContextKeyChannelKey               ContextKey = "channel_key"
 
 	ContextKeyAutoGroup           ContextKey = "auto_group"
@@ -54,6 +57,18 @@ const (
 
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
+	// ContextKeyBatchId marks a request fanned out from a local batch (/v1/batches); batch pricing applies
+	ContextKeyBatchId ContextKey = "batch_id"
+	// ContextKeySettledQuota stores the final quota settled for the request
+	ContextKeySettledQuota ContextKey = "settled_quota"
+	// ContextKeyConsumedTokens stores prompt + completion tokens of the settled request, used by token TPM limits
+	ContextKeyConsumedTokens ContextKey = "consumed_tokens"
+	// ContextKeyResponseCacheHit marks a request served from the response cache
+	ContextKeyResponseCacheHit ContextKey = "response_cache_hit"
+
+	// ContextKeyPayloadCapture stores the payload capture session of an opted-in request
+	ContextKeyPayloadCapture ContextKey = "payload_capture"
+
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
 
 	// ContextKeyFileSourcesToCleanup stores file sources that need cleanup when request ends
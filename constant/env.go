@@ -17,6 +17,10 @@ var GenerateDefaultToken bool
 var ErrorLogEnabled bool
 var TaskQueryLimit int
 var TaskTimeoutMinutes int
+var FileStoragePath string
+var MetricsEnabled bool
+var MetricsToken string
+var MetricsAllowedIps []string
 
 // temporary variable for sora patch, will be removed in future
 var TaskPricePatches []string
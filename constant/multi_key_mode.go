@@ -5,4 +5,6 @@ type MultiKeyMode string
 const (
 	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
 	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	// 最少负载：跳过因限流冷却中的 key，优先选择剩余额度最多、并发请求最少的 key
+	MultiKeyModeLeastLoaded MultiKeyMode = "least_loaded"
 )
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单次导出的最大条数，更多记录可按时间范围分批导出
+const auditLogExportLimit = 50000
+
+func auditLogQueryFromRequest(c *gin.Context) *model.AuditLogQuery {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	return &model.AuditLogQuery{
+		Action:         c.Query("action"),
+		TargetType:     c.Query("target_type"),
+		TargetId:       c.Query("target_id"),
+		ActorName:      c.Query("actor"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+}
+
+func GetAuditLogs(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	logs, total, err := model.GetAuditLogs(auditLogQueryFromRequest(c), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ExportAuditLogs 以 CSV 导出审计日志，format=json 时导出 JSON，包含 prev_hash 与 hash 便于离线校验
+func ExportAuditLogs(c *gin.Context) {
+	logs, err := model.ExportAuditLogs(auditLogQueryFromRequest(c), auditLogExportLimit)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("audit-logs-%s", time.Now().Format("20060102150405"))
+	if c.Query("format") == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", filename))
+		common.ApiSuccess(c, logs)
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write([]string{"id", "created_at", "actor_id", "actor_name", "ip", "action", "target_type", "target_id", "diff", "prev_hash", "hash"})
+	for _, log := range logs {
+		_ = writer.Write([]string{
+			strconv.Itoa(log.Id),
+			time.Unix(log.CreatedAt, 0).Format(time.RFC3339),
+			strconv.Itoa(log.ActorId),
+			log.ActorName,
+			log.Ip,
+			log.Action,
+			log.TargetType,
+			log.TargetId,
+			log.Diff,
+			log.PrevHash,
+			log.Hash,
+		})
+	}
+	writer.Flush()
+}
+
+// VerifyAuditLogs 校验审计日志哈希链是否完整
+func VerifyAuditLogs(c *gin.Context) {
+	result, err := model.VerifyAuditLogChain()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, result)
+}
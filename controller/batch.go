@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getRequestBatch 查询当前用户的批处理，不存在时写出 404
+func getRequestBatch(c *gin.Context) (*model.Task, bool) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		fileRelayError(c, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry()))
+		return nil, false
+	}
+	if !exist || task.Platform != constant.TaskPlatformBatch {
+		fileRelayError(c, types.NewErrorWithStatusCode(fmt.Errorf("No such Batch object: %s", c.Param("id")), types.ErrorCodeInvalidRequest, http.StatusNotFound, types.ErrOptionWithSkipRetry()))
+		return nil, false
+	}
+	return task, true
+}
+
+func RelayBatchCreate(c *gin.Context) {
+	var req dto.OpenAIBatchRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		fileRelayError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	batch, newAPIError := service.CreateBatch(c, &req)
+	if newAPIError != nil {
+		fileRelayError(c, newAPIError)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+func RelayBatchList(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	// 多查询一条用于判断 has_more
+	tasks, err := model.GetUserBatchTasks(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		fileRelayError(c, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	hasMore := len(tasks) > limit
+	if hasMore {
+		tasks = tasks[:limit]
+	}
+	list := dto.OpenAIBatchList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIBatch, 0, len(tasks)),
+		HasMore: hasMore,
+	}
+	for _, task := range tasks {
+		batch, err := service.GetBatchData(task)
+		if err != nil {
+			continue
+		}
+		list.Data = append(list.Data, *batch)
+	}
+	if len(list.Data) > 0 {
+		list.FirstID = list.Data[0].ID
+		list.LastID = list.Data[len(list.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func RelayBatchRetrieve(c *gin.Context) {
+	task, ok := getRequestBatch(c)
+	if !ok {
+		return
+	}
+	batch, err := service.GetBatchData(task)
+	if err != nil {
+		fileRelayError(c, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+func RelayBatchCancel(c *gin.Context) {
+	task, ok := getRequestBatch(c)
+	if !ok {
+		return
+	}
+	batch, newAPIError := service.CancelBatch(c.Request.Context(), task)
+	if newAPIError != nil {
+		fileRelayError(c, newAPIError)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// 本地批处理的单行请求通过内部 gin 引擎走完整的鉴权、分发与中继链路，
+// 计费、日志与渠道重试行为与普通请求一致
+var (
+	batchLineEngine     *gin.Engine
+	batchLineEngineOnce sync.Once
+)
+
+type batchLineCallKey struct{}
+
+type batchLineCall struct {
+	req    *service.BatchLineRequest
+	result *service.BatchLineResult
+}
+
+// batchLineContext 标记批处理请求，并在中继结束后回收结算额度
+func batchLineContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		call, ok := c.Request.Context().Value(batchLineCallKey{}).(*batchLineCall)
+		if !ok {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		common.SetContextKey(c, constant.ContextKeyBatchId, call.req.BatchId)
+		c.Next()
+		call.result.Quota = common.GetContextKeyInt(c, constant.ContextKeySettledQuota)
+		call.result.RequestId = c.GetString(common.RequestIdKey)
+	}
+}
+
+func getBatchLineEngine() *gin.Engine {
+	batchLineEngineOnce.Do(func() {
+		engine := gin.New()
+		engine.Use(gin.Recovery())
+		engine.Use(middleware.RequestId())
+		engine.Use(middleware.BodyStorageCleanup())
+		engine.Use(batchLineContext())
+		relayRouter := engine.Group("/v1")
+		relayRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+		{
+			relayRouter.POST("/chat/completions", func(c *gin.Context) {
+				Relay(c, types.RelayFormatOpenAI)
+			})
+			relayRouter.POST("/completions", func(c *gin.Context) {
+				Relay(c, types.RelayFormatOpenAI)
+			})
+			relayRouter.POST("/embeddings", func(c *gin.Context) {
+				Relay(c, types.RelayFormatEmbedding)
+			})
+			relayRouter.POST("/responses", func(c *gin.Context) {
+				Relay(c, types.RelayFormatOpenAIResponses)
+			})
+		}
+		batchLineEngine = engine
+	})
+	return batchLineEngine
+}
+
+// ExecuteBatchLine 以批处理创建者的令牌执行一行请求，注入到 service.ExecuteBatchLineFunc
+func ExecuteBatchLine(ctx context.Context, req *service.BatchLineRequest) *service.BatchLineResult {
+	result := &service.BatchLineResult{}
+	call := &batchLineCall{req: req, result: result}
+	httpReq, err := http.NewRequestWithContext(context.WithValue(ctx, batchLineCallKey{}, call), http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		result.StatusCode = http.StatusInternalServerError
+		result.Body, _ = common.Marshal(gin.H{"error": types.NewError(err, types.ErrorCodeInvalidRequest).ToOpenAIError()})
+		return result
+	}
+	if req.Token == nil {
+		result.StatusCode = http.StatusUnauthorized
+		result.Body, _ = common.Marshal(gin.H{"error": types.NewError(errors.New("token not found"), types.ErrorCodeAccessDenied).ToOpenAIError()})
+		return result
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer sk-"+req.Token.Key)
+	clientIp := req.ClientIp
+	if clientIp == "" {
+		clientIp = "127.0.0.1"
+	}
+	httpReq.RemoteAddr = net.JoinHostPort(clientIp, "0")
+
+	recorder := httptest.NewRecorder()
+	getBatchLineEngine().ServeHTTP(recorder, httpReq)
+	result.StatusCode = recorder.Code
+	result.Body = recorder.Body.Bytes()
+	return result
+}
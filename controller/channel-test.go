@@ -159,6 +159,7 @@ func testChannel(channel *model.Channel, testModel string, endpointType string,
 	c.Set("group", group)
 
 	newAPIError := middleware.SetupContextForSelectedChannel(c, channel, testModel)
+	defer middleware.ReleaseChannelKey(c)
 	if newAPIError != nil {
 		return testResult{
 			context:     c,
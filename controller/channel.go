@@ -371,6 +371,7 @@ func GetChannel(c *gin.Context) {
 	}
 	if channel != nil {
 		clearChannelInfo(channel)
+		model.FillChannelKeyCooldowns(channel)
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -655,6 +656,9 @@ func AddChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	for i := range channels {
+		model.RecordAuditLog(c, "channel.create", model.AuditTargetChannel, channels[i].Name, nil, channels[i])
+	}
 	service.ResetProxyClientCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -665,12 +669,14 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	originChannel, _ := model.GetChannelById(id, true)
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "channel.delete", model.AuditTargetChannel, id, originChannel, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -685,6 +691,7 @@ func DeleteDisabledChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "channel.delete_disabled", model.AuditTargetChannel, "", nil, gin.H{"deleted": rows})
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -721,6 +728,7 @@ func DisableTagChannels(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "channel.disable_tag", model.AuditTargetChannel, "tag:"+channelTag.Tag, nil, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -744,6 +752,7 @@ func EnableTagChannels(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "channel.enable_tag", model.AuditTargetChannel, "tag:"+channelTag.Tag, nil, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -796,6 +805,7 @@ func EditTagChannels(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "channel.edit_tag", model.AuditTargetChannel, "tag:"+channelTag.Tag, nil, channelTag)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -824,6 +834,7 @@ func DeleteChannelBatch(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "channel.batch_delete", model.AuditTargetChannel, "", gin.H{"ids": channelBatch.Ids}, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -958,8 +969,15 @@ func UpdateChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if updatedChannel, err := model.GetChannelById(channel.Id, true); err == nil {
+		model.RecordAuditLog(c, "channel.update", model.AuditTargetChannel, channel.Id, originChannel, updatedChannel)
+	}
 	model.InitChannelCache()
 	service.ResetProxyClientCache()
+	if channel.Key != "" && (channel.KeyMode == nil || *channel.KeyMode != "append") {
+		// 密钥被覆盖，下标对应关系失效
+		model.ResetChannelKeyStates(channel.Id)
+	}
 	channel.Key = ""
 	clearChannelInfo(&channel.Channel)
 	c.JSON(http.StatusOK, gin.H{
@@ -1105,6 +1123,7 @@ func BatchSetChannelTag(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "channel.batch_set_tag", model.AuditTargetChannel, "", nil, channelBatch)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -1202,6 +1221,7 @@ func CopyChannel(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": "复制渠道失败，请稍后重试"})
 		return
 	}
+	model.RecordAuditLog(c, "channel.copy", model.AuditTargetChannel, origin.Id, nil, gin.H{"suffix": suffix, "reset_balance": resetBalance})
 	model.InitChannelCache()
 	// success
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "", "data": gin.H{"id": clone.Id}})
@@ -1236,6 +1256,10 @@ type KeyStatus struct {
 	DisabledTime int64  `json:"disabled_time,omitempty"`
 	Reason       string `json:"reason,omitempty"`
 	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	// 限流冷却状态与并发请求数，为本节点的运行时数据
+	CooldownUntil  int64  `json:"cooldown_until,omitempty"`
+	CooldownReason string `json:"cooldown_reason,omitempty"`
+	InFlight       int    `json:"in_flight,omitempty"`
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1271,6 +1295,8 @@ func ManageMultiKeys(c *gin.Context) {
 	switch request.Action {
 	case "get_key_status":
 		keys := channel.GetKeys()
+		model.FillChannelKeyCooldowns(channel)
+		inFlight := model.GetChannelKeyInFlight(channel.Id)
 
 		// Default pagination parameters
 		page := request.Page
@@ -1324,11 +1350,14 @@ func ManageMultiKeys(c *gin.Context) {
 			}
 
 			allKeyStatusList = append(allKeyStatusList, KeyStatus{
-				Index:        i,
-				Status:       status,
-				DisabledTime: disabledTime,
-				Reason:       reason,
-				KeyPreview:   keyPreview,
+				Index:          i,
+				Status:         status,
+				DisabledTime:   disabledTime,
+				Reason:         reason,
+				KeyPreview:     keyPreview,
+				CooldownUntil:  channel.ChannelInfo.MultiKeyCooldownUntil[i],
+				CooldownReason: channel.ChannelInfo.MultiKeyCooldownReason[i],
+				InFlight:       inFlight[i],
 			})
 		}
 
@@ -1418,6 +1447,7 @@ func ManageMultiKeys(c *gin.Context) {
 			common.ApiError(c, err)
 			return
 		}
+		model.RecordAuditLog(c, "channel."+request.Action, model.AuditTargetChannel, channel.Id, nil, request)
 
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
@@ -1460,6 +1490,7 @@ func ManageMultiKeys(c *gin.Context) {
 			common.ApiError(c, err)
 			return
 		}
+		model.RecordAuditLog(c, "channel."+request.Action, model.AuditTargetChannel, channel.Id, nil, request)
 
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
@@ -1484,6 +1515,7 @@ func ManageMultiKeys(c *gin.Context) {
 			common.ApiError(c, err)
 			return
 		}
+		model.RecordAuditLog(c, "channel."+request.Action, model.AuditTargetChannel, channel.Id, nil, request)
 
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
@@ -1531,6 +1563,7 @@ func ManageMultiKeys(c *gin.Context) {
 			common.ApiError(c, err)
 			return
 		}
+		model.RecordAuditLog(c, "channel."+request.Action, model.AuditTargetChannel, channel.Id, nil, request)
 
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
@@ -1611,8 +1644,11 @@ func ManageMultiKeys(c *gin.Context) {
 			common.ApiError(c, err)
 			return
 		}
+		model.RecordAuditLog(c, "channel."+request.Action, model.AuditTargetChannel, channel.Id, nil, request)
 
 		model.InitChannelCache()
+		// 密钥下标已变化，运行时状态随之失效
+		model.ResetChannelKeyStates(channel.Id)
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "密钥已删除",
@@ -1679,8 +1715,10 @@ func ManageMultiKeys(c *gin.Context) {
 			common.ApiError(c, err)
 			return
 		}
+		model.RecordAuditLog(c, "channel."+request.Action, model.AuditTargetChannel, channel.Id, nil, request)
 
 		model.InitChannelCache()
+		model.ResetChannelKeyStates(channel.Id)
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": fmt.Sprintf("已删除 %d 个自动禁用的密钥", deletedCount),
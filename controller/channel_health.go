@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAllChannelsHealth 返回本节点观测到的所有渠道健康度
+func GetAllChannelsHealth(c *gin.Context) {
+	channels, err := model.GetChannelStatusList()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	snapshots := make([]model.ChannelHealthSnapshot, 0, len(channels))
+	for _, channel := range channels {
+		snapshots = append(snapshots, model.GetChannelHealthSnapshot(channel.Id))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    snapshots,
+	})
+}
+
+func GetChannelHealth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.GetChannelHealthSnapshot(id),
+	})
+}
+
+// ResetChannelHealth 清除渠道的健康度统计，立即解除摘除状态
+func ResetChannelHealth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.ResetChannelHealth(id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RelayCountTokens 统计请求的输入 token 数，不发起生成也不扣费。
+// 默认使用本地估算，开启 count_tokens_setting.upstream_enabled 后优先转发到渠道原生统计接口
+func RelayCountTokens(c *gin.Context, relayFormat types.RelayFormat) {
+	request, err := helper.GetAndValidateCountTokensRequest(c, relayFormat)
+	if err != nil {
+		countTokensError(c, relayFormat, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	info, err := relaycommon.GenRelayInfo(c, relayFormat, request, nil)
+	if err != nil {
+		countTokensError(c, relayFormat, types.NewError(err, types.ErrorCodeGenRelayInfoFailed))
+		return
+	}
+
+	if operation_setting.GetCountTokensSetting().UpstreamEnabled {
+		tokens, supported, err := relay.UpstreamCountTokens(c, info)
+		if err == nil && supported {
+			countTokensResponse(c, relayFormat, tokens)
+			return
+		}
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("upstream count tokens failed, fallback to local: %s", err.Error()))
+		}
+	}
+
+	tokens, err := service.CountRequestToken(c, request.GetTokenCountMeta(), info)
+	if err != nil {
+		countTokensError(c, relayFormat, types.NewError(err, types.ErrorCodeCountTokenFailed))
+		return
+	}
+	countTokensResponse(c, relayFormat, tokens)
+}
+
+func countTokensResponse(c *gin.Context, relayFormat types.RelayFormat, tokens int) {
+	switch relayFormat {
+	case types.RelayFormatClaude:
+		c.JSON(http.StatusOK, gin.H{"input_tokens": tokens})
+	case types.RelayFormatGemini:
+		c.JSON(http.StatusOK, gin.H{"totalTokens": tokens})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"object":       "response.input_tokens",
+			"input_tokens": tokens,
+		})
+	}
+}
+
+func countTokensError(c *gin.Context, relayFormat types.RelayFormat, newAPIError *types.NewAPIError) {
+	logger.LogError(c, fmt.Sprintf("count tokens error: %s", newAPIError.Error()))
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), c.GetString(common.RequestIdKey)))
+	if relayFormat == types.RelayFormatClaude {
+		c.JSON(newAPIError.StatusCode, gin.H{
+			"type":  "error",
+			"error": newAPIError.ToClaudeError(),
+		})
+		return
+	}
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"error": newAPIError.ToOpenAIError(),
+	})
+}
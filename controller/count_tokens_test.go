@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func performCountTokens(t *testing.T, format types.RelayFormat, path string, modelName string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, modelName)
+	RelayCountTokens(c, format)
+	return recorder
+}
+
+func TestRelayCountTokensLocal(t *testing.T) {
+	claude := performCountTokens(t, types.RelayFormatClaude, "/v1/messages/count_tokens", "claude-sonnet-4",
+		`{"model":"claude-sonnet-4","system":"be brief","messages":[{"role":"user","content":"hello world"}]}`)
+	require.Equal(t, http.StatusOK, claude.Code)
+	require.Greater(t, gjson.Get(claude.Body.String(), "input_tokens").Int(), int64(0))
+
+	gemini := performCountTokens(t, types.RelayFormatGemini, "/v1beta/models/gemini-2.5-flash:countTokens", "gemini-2.5-flash",
+		`{"generateContentRequest":{"contents":[{"role":"user","parts":[{"text":"hello world"}]}]}}`)
+	require.Equal(t, http.StatusOK, gemini.Code)
+	require.Greater(t, gjson.Get(gemini.Body.String(), "totalTokens").Int(), int64(0))
+
+	openai := performCountTokens(t, types.RelayFormatOpenAI, "/v1/chat/completions/input_tokens", "gpt-4o",
+		`{"model":"gpt-4o","messages":[{"role":"user","content":"hello world"}]}`)
+	require.Equal(t, http.StatusOK, openai.Code)
+	require.Equal(t, "response.input_tokens", gjson.Get(openai.Body.String(), "object").String())
+	require.Greater(t, gjson.Get(openai.Body.String(), "input_tokens").Int(), int64(0))
+
+	invalid := performCountTokens(t, types.RelayFormatClaude, "/v1/messages/count_tokens", "claude-sonnet-4", `{"model":"claude-sonnet-4"}`)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+	require.Equal(t, "error", gjson.Get(invalid.Body.String(), "type").String())
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func fileRelayError(c *gin.Context, newAPIError *types.NewAPIError) {
+	requestId := c.GetString(common.RequestIdKey)
+	logger.LogError(c, fmt.Sprintf("file relay error: %s", newAPIError.Error()))
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), requestId))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"error": newAPIError.ToOpenAIError(),
+	})
+}
+
+// getRequestFile 查询当前用户的文件，不存在时写出 404
+func getRequestFile(c *gin.Context) (*model.File, bool) {
+	file, err := model.GetUserFileByFileId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			fileRelayError(c, types.NewErrorWithStatusCode(fmt.Errorf("No such File object: %s", c.Param("id")), types.ErrorCodeInvalidRequest, http.StatusNotFound, types.ErrOptionWithSkipRetry()))
+		} else {
+			fileRelayError(c, types.NewError(err, types.ErrorCodeQueryDataError))
+		}
+		return nil, false
+	}
+	return file, true
+}
+
+// getFileChannel 获取文件所在渠道，文件引用必须固定在上传时的渠道上
+func getFileChannel(c *gin.Context, file *model.File) (*model.Channel, bool) {
+	channel, err := model.CacheGetChannel(file.ChannelId)
+	if err != nil {
+		fileRelayError(c, types.NewError(fmt.Errorf("channel of file %s not found", file.FileId), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry()))
+		return nil, false
+	}
+	if channel.Status != common.ChannelStatusEnabled {
+		fileRelayError(c, types.NewErrorWithStatusCode(fmt.Errorf("channel of file %s is disabled", file.FileId), types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry()))
+		return nil, false
+	}
+	return channel, true
+}
+
+func RelayFileUpload(c *gin.Context) {
+	if !strings.Contains(c.Request.Header.Get("Content-Type"), gin.MIMEMultipartPOSTForm) {
+		fileRelayError(c, types.NewErrorWithStatusCode(errors.New("content type must be multipart/form-data"), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		if common.IsRequestBodyTooLargeError(err) || errors.Is(err, common.ErrRequestBodyTooLarge) {
+			fileRelayError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusRequestEntityTooLarge, types.ErrOptionWithSkipRetry()))
+		} else {
+			fileRelayError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry()))
+		}
+		return
+	}
+	meta, err := service.ParseFileUploadMeta(storage, c.Request.Header.Get("Content-Type"))
+	if err != nil {
+		fileRelayError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	channel, err := service.SelectFileChannel(c, meta.Model)
+	if err != nil {
+		fileRelayError(c, types.NewErrorWithStatusCode(err, types.ErrorCodeGetChannelFailed, http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry()))
+		return
+	}
+	file, newAPIError := service.UploadFileToChannel(c, channel, storage, meta)
+	if newAPIError != nil {
+		fileRelayError(c, newAPIError)
+		return
+	}
+	c.JSON(http.StatusOK, service.FileToOpenAIFile(file))
+}
+
+func RelayFileList(c *gin.Context) {
+	// 与 OpenAI 一致：默认 20，最大 10000
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = 20
+	} else if limit > 10000 {
+		limit = 10000
+	}
+	ascending := c.Query("order") == "asc"
+	// 多查询一条用于判断 has_more
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1, ascending)
+	if err != nil {
+		fileRelayError(c, types.NewError(err, types.ErrorCodeQueryDataError))
+		return
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	list := dto.OpenAIFileList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIFile, 0, len(files)),
+		HasMore: hasMore,
+	}
+	for _, file := range files {
+		list.Data = append(list.Data, service.FileToOpenAIFile(file))
+	}
+	if len(list.Data) > 0 {
+		list.FirstID = list.Data[0].ID
+		list.LastID = list.Data[len(list.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func RelayFileRetrieve(c *gin.Context) {
+	file, ok := getRequestFile(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, service.FileToOpenAIFile(file))
+}
+
+func RelayFileContent(c *gin.Context) {
+	file, ok := getRequestFile(c)
+	if !ok {
+		return
+	}
+	if file.IsLocal() {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+		c.File(file.LocalPath)
+		return
+	}
+	channel, ok := getFileChannel(c, file)
+	if !ok {
+		return
+	}
+	resp, err := service.DoFileRequest(c.Request.Context(), channel, file.KeyIndex, http.MethodGet, "/files/"+file.UpstreamFileId+"/content", nil, "")
+	if err != nil {
+		fileRelayError(c, types.NewError(err, types.ErrorCodeDoRequestFailed))
+		return
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		fileRelayError(c, service.RelayErrorHandler(c.Request.Context(), resp, false))
+		return
+	}
+	for _, header := range []string{"Content-Type", "Content-Length", "Content-Disposition"} {
+		if value := resp.Header.Get(header); value != "" {
+			c.Writer.Header().Set(header, value)
+		}
+	}
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to stream file %s content: %s", file.FileId, err.Error()))
+	}
+}
+
+func RelayFileDelete(c *gin.Context) {
+	file, ok := getRequestFile(c)
+	if !ok {
+		return
+	}
+	if file.IsLocal() {
+		if err := service.DeleteLocalFile(file); err != nil {
+			fileRelayError(c, types.NewError(err, types.ErrorCodeUpdateDataError))
+			return
+		}
+		c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+			ID:      file.FileId,
+			Object:  "file",
+			Deleted: true,
+		})
+		return
+	}
+	channel, ok := getFileChannel(c, file)
+	if !ok {
+		return
+	}
+	resp, err := service.DoFileRequest(c.Request.Context(), channel, file.KeyIndex, http.MethodDelete, "/files/"+file.UpstreamFileId, nil, "")
+	if err != nil {
+		fileRelayError(c, types.NewError(err, types.ErrorCodeDoRequestFailed))
+		return
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	// 上游已不存在时同样清理本地记录
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		fileRelayError(c, service.RelayErrorHandler(c.Request.Context(), resp, false))
+		return
+	}
+	if err := file.Delete(); err != nil {
+		fileRelayError(c, types.NewError(err, types.ErrorCodeUpdateDataError))
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		ID:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
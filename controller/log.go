@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
@@ -169,3 +170,18 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+// GetPayloadCaptures 按请求 ID 查询载荷采集记录
+func GetPayloadCaptures(c *gin.Context) {
+	requestId := strings.TrimSpace(c.Param("request_id"))
+	if requestId == "" {
+		common.ApiErrorMsg(c, "请求ID不能为空")
+		return
+	}
+	captures, err := model.GetPayloadCapturesByRequestId(requestId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, captures)
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+)
+
+type ManagementKeyRequest struct {
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+	ChannelTags []string `json:"channel_tags"`
+	AllowIps    []string `json:"allow_ips"`
+	// 过期时间戳（秒），-1 表示永不过期
+	ExpiredTime int64 `json:"expired_time"`
+	Status      int   `json:"status"`
+}
+
+// managementKeyFromRequest 校验请求参数并填充到 key，返回错误提示
+func managementKeyFromRequest(c *gin.Context, req *ManagementKeyRequest, key *model.ManagementKey) string {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		return "管理密钥名称不能为空且不能超过 64 个字符"
+	}
+	scopes, err := model.NormalizeManagementScopes(req.Scopes, c.GetInt("role"))
+	if err != nil {
+		return "权限范围无效：" + err.Error()
+	}
+	tags := make([]string, 0, len(req.ChannelTags))
+	for _, tag := range req.ChannelTags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > 0 && !strings.Contains(","+scopes, ","+model.ManagementScopeChannels+":") {
+		return "限定渠道标签时需要授予渠道权限"
+	}
+	ips := make([]string, 0, len(req.AllowIps))
+	for _, ip := range req.AllowIps {
+		ip = strings.TrimSpace(ip)
+		if ip == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(ip); err != nil && !common.IsIP(ip) {
+			return "IP 白名单格式错误：" + ip
+		}
+		ips = append(ips, ip)
+	}
+	if req.ExpiredTime != -1 && req.ExpiredTime <= common.GetTimestamp() {
+		return "过期时间必须晚于当前时间，永不过期请设置为 -1"
+	}
+	if req.Status != 0 && req.Status != model.ManagementKeyStatusEnabled && req.Status != model.ManagementKeyStatusDisabled {
+		return "无效的状态"
+	}
+	key.Name = name
+	key.Scopes = scopes
+	key.ChannelTags = strings.Join(tags, ",")
+	key.AllowIps = strings.Join(ips, "\n")
+	key.ExpiredTime = req.ExpiredTime
+	if req.Status != 0 {
+		key.Status = req.Status
+	}
+	return ""
+}
+
+func GetManagementKeys(c *gin.Context) {
+	keys, err := model.GetUserManagementKeys(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"items":  keys,
+		"scopes": model.GetManagementScopeResources(),
+	})
+}
+
+func CreateManagementKey(c *gin.Context) {
+	var req ManagementKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	key := &model.ManagementKey{UserId: c.GetInt("id")}
+	if msg := managementKeyFromRequest(c, &req, key); msg != "" {
+		common.ApiErrorMsg(c, msg)
+		return
+	}
+	plain, err := model.CreateManagementKey(key)
+	if err != nil {
+		if err == model.ErrManagementKeyLimitReached {
+			common.ApiErrorMsg(c, "管理密钥数量已达上限")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	// 明文密钥仅在此返回一次
+	common.ApiSuccess(c, gin.H{
+		"management_key": key,
+		"key":            plain,
+	})
+}
+
+func UpdateManagementKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的管理密钥ID")
+		return
+	}
+	key, err := model.GetManagementKeyById(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "管理密钥不存在")
+		return
+	}
+	var req ManagementKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if msg := managementKeyFromRequest(c, &req, key); msg != "" {
+		common.ApiErrorMsg(c, msg)
+		return
+	}
+	if err := model.UpdateManagementKey(key); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, key)
+}
+
+func RotateManagementKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的管理密钥ID")
+		return
+	}
+	key, plain, err := model.RotateManagementKey(id, c.GetInt("id"))
+	if err != nil {
+		if err == model.ErrManagementKeyNotFound {
+			common.ApiErrorMsg(c, "管理密钥不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"management_key": key,
+		"key":            plain,
+	})
+}
+
+func DeleteManagementKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的管理密钥ID")
+		return
+	}
+	if err := model.DeleteManagementKey(id, c.GetInt("id")); err != nil {
+		if err == model.ErrManagementKeyNotFound {
+			common.ApiErrorMsg(c, "管理密钥不存在")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
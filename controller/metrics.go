@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var registerChannelCollectorOnce sync.Once
+
+// Metrics 输出 Prometheus 文本格式指标
+func Metrics(c *gin.Context) {
+	registerChannelCollectorOnce.Do(func() {
+		metrics.MustRegister(&channelCollector{})
+	})
+	metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
+
+var (
+	channelCountDesc = prometheus.NewDesc("newapi_channels",
+		"Number of channels by status.", []string{"status"}, nil)
+	channelEnabledDesc = prometheus.NewDesc("newapi_channel_enabled",
+		"Whether the channel is enabled (1) or disabled (0).", []string{"channel", "channel_name", "channel_type"}, nil)
+	channelKeysDesc = prometheus.NewDesc("newapi_channel_keys",
+		"Number of keys of multi-key channels by status.", []string{"channel", "status"}, nil)
+)
+
+func channelStatusName(status int) string {
+	switch status {
+	case common.ChannelStatusEnabled:
+		return "enabled"
+	case common.ChannelStatusManuallyDisabled:
+		return "manually_disabled"
+	case common.ChannelStatusAutoDisabled:
+		return "auto_disabled"
+	default:
+		return "unknown"
+	}
+}
+
+// channelCollector 在抓取时读取渠道状态，指标始终与数据库一致
+type channelCollector struct{}
+
+func (cc *channelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- channelCountDesc
+	ch <- channelEnabledDesc
+	ch <- channelKeysDesc
+}
+
+func (cc *channelCollector) Collect(ch chan<- prometheus.Metric) {
+	channels, err := model.GetChannelStatusList()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(channelCountDesc, err)
+		return
+	}
+	counts := map[string]int{
+		channelStatusName(common.ChannelStatusEnabled):          0,
+		channelStatusName(common.ChannelStatusManuallyDisabled): 0,
+		channelStatusName(common.ChannelStatusAutoDisabled):     0,
+	}
+	for _, channel := range channels {
+		counts[channelStatusName(channel.Status)]++
+		channelId := strconv.Itoa(channel.Id)
+		enabled := 0.0
+		if channel.Status == common.ChannelStatusEnabled {
+			enabled = 1
+		}
+		ch <- prometheus.MustNewConstMetric(channelEnabledDesc, prometheus.GaugeValue, enabled,
+			channelId, channel.Name, strconv.Itoa(channel.Type))
+
+		if !channel.ChannelInfo.IsMultiKey {
+			continue
+		}
+		keyCounts := map[string]int{
+			channelStatusName(common.ChannelStatusEnabled):          0,
+			channelStatusName(common.ChannelStatusManuallyDisabled): 0,
+			channelStatusName(common.ChannelStatusAutoDisabled):     0,
+		}
+		// 状态列表只记录非默认状态的 key，未出现的 key 视为启用
+		disabled := 0
+		for _, status := range channel.ChannelInfo.MultiKeyStatusList {
+			if status == common.ChannelStatusEnabled {
+				continue
+			}
+			keyCounts[channelStatusName(status)]++
+			disabled++
+		}
+		keyCounts[channelStatusName(common.ChannelStatusEnabled)] = max(channel.ChannelInfo.MultiKeySize-disabled, 0)
+		for status, count := range keyCounts {
+			ch <- prometheus.MustNewConstMetric(channelKeysDesc, prometheus.GaugeValue, float64(count), channelId, status)
+		}
+	}
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(channelCountDesc, prometheus.GaugeValue, float64(count), status)
+	}
+}
+
+// observeRelayMetrics 在响应写出后记录中继请求指标，relayInfo 可能为空（请求校验阶段失败）
+func observeRelayMetrics(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo) {
+	startTime := common.GetContextKeyTime(c, constant.ContextKeyRequestStartTime)
+	if startTime.IsZero() {
+		return
+	}
+	labels := metrics.RelayLabels{
+		RelayFormat: string(relayFormat),
+		Model:       common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		Group:       common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		ChannelId:   common.GetContextKeyInt(c, constant.ContextKeyChannelId),
+	}
+	metrics.ObserveRelayRequest(labels, c.Writer.Status(), time.Since(startTime))
+	metrics.AddRelayRetries(labels, len(c.GetStringSlice("use_channel"))-1)
+	if relayInfo != nil && relayInfo.IsStream && relayInfo.FirstResponseTime.After(relayInfo.StartTime) {
+		metrics.ObserveFirstToken(labels, relayInfo.FirstResponseTime.Sub(relayInfo.StartTime))
+	}
+}
@@ -177,17 +177,22 @@ func UpdateMidjourneyTaskBulk() {
 				if err != nil {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
 				} else if won && shouldReturnQuota {
-					err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+					if task.OrganizationId > 0 {
+						err = model.PostConsumeOrganizationQuota(task.OrganizationId, task.UserId, -task.Quota)
+					} else {
+						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+					}
 					if err != nil {
 						logger.LogError(ctx, "fail to increase user quota: "+err.Error())
 					}
 					model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
-						UserId:    task.UserId,
-						LogType:   model.LogTypeRefund,
-						Content:   "",
-						ChannelId: task.ChannelId,
-						ModelName: service.CovertMjpActionToModelName(task.Action),
-						Quota:     task.Quota,
+						UserId:         task.UserId,
+						LogType:        model.LogTypeRefund,
+						Content:        "",
+						ChannelId:      task.ChannelId,
+						ModelName:      service.CovertMjpActionToModelName(task.Action),
+						Quota:          task.Quota,
+						OrganizationId: task.OrganizationId,
 						Other: map[string]interface{}{
 							"task_id": task.MjId,
 							"reason":  "构图失败",
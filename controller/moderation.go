@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetModerationEvents 分页查询审核队列，支持按状态、阶段、检查器、用户与分组筛选
+func GetModerationEvents(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	status, _ := strconv.Atoi(c.Query("status"))
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	query := &model.ModerationEventQuery{
+		Status:  status,
+		Stage:   c.Query("stage"),
+		Checker: c.Query("checker"),
+		UserId:  userId,
+		Group:   c.Query("group"),
+	}
+	events, total, err := model.GetModerationEvents(query, pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(events)
+	common.ApiSuccess(c, pageInfo)
+}
+
+type ModerationReviewRequest struct {
+	Status int    `json:"status"`
+	Remark string `json:"remark"`
+}
+
+// ReviewModerationEvent 复核审核队列中的记录，标记为确认违规或误报
+func ReviewModerationEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "无效的记录ID")
+		return
+	}
+	var req ModerationReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	switch req.Status {
+	case model.ModerationEventStatusPending, model.ModerationEventStatusConfirmed, model.ModerationEventStatusDismissed:
+	default:
+		common.ApiErrorMsg(c, "无效的状态")
+		return
+	}
+	if len([]rune(req.Remark)) > 255 {
+		common.ApiErrorMsg(c, "备注过长")
+		return
+	}
+	if err := model.ReviewModerationEvent(id, req.Status, c.GetInt("id"), req.Remark); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -233,6 +233,24 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "BatchRatio":
+		err = ratio_setting.UpdateBatchRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "批处理倍率设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "ModelTieredRatio":
+		err = ratio_setting.UpdateModelTieredRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "分档倍率设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "ModelRequestRateLimitGroup":
 		err = setting.CheckModelRequestRateLimitGroup(option.Value.(string))
 		if err != nil {
@@ -260,6 +278,24 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "time_price_setting.windows":
+		err = operation_setting.ValidateTimePriceWindows(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "time_price_setting.timezone":
+		err = operation_setting.ValidateTimePriceTimezone(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "console_setting.api_info":
 		err = console_setting.ValidateConsoleSettings(option.Value.(string), "ApiInfo")
 		if err != nil {
@@ -297,14 +333,37 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	common.OptionMapRWMutex.RLock()
+	oldValue := common.OptionMap[option.Key]
+	common.OptionMapRWMutex.RUnlock()
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	before, after := optionAuditValues(option.Key, oldValue, option.Value.(string))
+	model.RecordAuditLog(c, "option.update", model.AuditTargetOption, option.Key, before, after)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
 	})
 	return
 }
+
+// optionAuditValues 将配置项的新旧值转换为审计差异的对象。倍率等 JSON 对象类配置按子键展开，
+// 只记录实际变化的条目，避免每次修改都保存整份配置
+func optionAuditValues(key string, oldValue string, newValue string) (map[string]any, map[string]any) {
+	var oldObject, newObject map[string]any
+	if common.UnmarshalJsonStr(oldValue, &oldObject) == nil && common.UnmarshalJsonStr(newValue, &newObject) == nil {
+		before := make(map[string]any, len(oldObject))
+		for k, v := range oldObject {
+			before[key+"."+k] = v
+		}
+		after := make(map[string]any, len(newObject))
+		for k, v := range newObject {
+			after[key+"."+k] = v
+		}
+		return before, after
+	}
+	return map[string]any{key: oldValue}, map[string]any{key: newValue}
+}
@@ -0,0 +1,431 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMemberRequest struct {
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+	ResetUsed  bool   `json:"reset_used"`
+}
+
+type OrganizationInviteRequest struct {
+	Role       string `json:"role"`
+	QuotaLimit int    `json:"quota_limit"`
+	// 有效期（秒），为 0 时使用默认 7 天
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+type OrganizationJoinRequest struct {
+	Code string `json:"code"`
+}
+
+type OrganizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+type OrganizationStatusRequest struct {
+	Status int `json:"status"`
+}
+
+// organizationMemberFromRequest 校验当前用户是路径中组织的成员，manage 为 true 时要求所有者或管理员
+func organizationMemberFromRequest(c *gin.Context, manage bool) (*model.OrganizationMember, bool) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil || orgId <= 0 {
+		common.ApiErrorMsg(c, "无效的组织ID")
+		return nil, false
+	}
+	member, err := model.GetOrganizationMember(orgId, c.GetInt("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "组织不存在或不是组织成员")
+		return nil, false
+	}
+	if manage && !member.CanManage() {
+		common.ApiErrorMsg(c, "仅组织所有者或管理员可以执行此操作")
+		return nil, false
+	}
+	return member, true
+}
+
+// ---- User APIs ----
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		common.ApiErrorMsg(c, "组织名称不能为空且不能超过 64 个字符")
+		return
+	}
+	org, err := model.CreateOrganization(name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganization(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, false)
+	if !ok {
+		return
+	}
+	org, err := model.GetOrganizationById(member.OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org.Role = member.Role
+	common.ApiSuccess(c, gin.H{
+		"organization": org,
+		"member":       member,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, true)
+	if !ok {
+		return
+	}
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		common.ApiErrorMsg(c, "组织名称不能为空且不能超过 64 个字符")
+		return
+	}
+	org, err := model.GetOrganizationById(member.OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	org.Name = name
+	if err := model.UpdateOrganization(org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func DeleteOrganization(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, true)
+	if !ok {
+		return
+	}
+	if member.Role != model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "仅组织所有者可以删除组织")
+		return
+	}
+	if err := model.DeleteOrganization(member.OrganizationId); err != nil {
+		if errors.Is(err, model.ErrOrganizationQuotaNotEmpty) {
+			common.ApiErrorMsg(c, "组织钱包仍有余额，无法删除组织")
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAuditLog(c, "organization.delete", model.AuditTargetOrganization, member.OrganizationId, nil, nil)
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, false)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(member.OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	operator, ok := organizationMemberFromRequest(c, true)
+	if !ok {
+		return
+	}
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if req.QuotaLimit < 0 {
+		common.ApiErrorMsg(c, "额度上限不能为负数")
+		return
+	}
+	target, err := model.GetOrganizationMember(operator.OrganizationId, userId)
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	if req.Role == "" {
+		req.Role = target.Role
+	}
+	// 所有者身份不可转移或修改，只有所有者可以任免管理员
+	if req.Role == model.OrganizationRoleOwner || (target.Role == model.OrganizationRoleOwner && req.Role != model.OrganizationRoleOwner) {
+		common.ApiErrorMsg(c, "不能修改组织所有者的角色")
+		return
+	}
+	if !model.IsValidOrganizationRole(req.Role) {
+		common.ApiErrorMsg(c, "无效的角色")
+		return
+	}
+	if operator.Role != model.OrganizationRoleOwner && (target.Role == model.OrganizationRoleAdmin || req.Role == model.OrganizationRoleAdmin) {
+		// 管理员可以把自己降为普通成员，但管理员（包括自己）的额度上限与已用额度只能由所有者调整
+		selfRoleOnly := target.UserId == operator.UserId && req.QuotaLimit == target.QuotaLimit && !req.ResetUsed
+		if !selfRoleOnly {
+			common.ApiErrorMsg(c, "仅组织所有者可以任免管理员或调整管理员的额度")
+			return
+		}
+	}
+	before := *target
+	target.Role = req.Role
+	target.QuotaLimit = req.QuotaLimit
+	if err := model.UpdateOrganizationMember(target, req.ResetUsed); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.ResetUsed {
+		target.UsedQuota = 0
+	}
+	model.RecordAuditLog(c, "organization.member_update", model.AuditTargetOrganization, operator.OrganizationId, before, *target)
+	common.ApiSuccess(c, target)
+}
+
+// RemoveOrganizationMember 移除成员；普通成员只能移除自己（退出组织）
+func RemoveOrganizationMember(c *gin.Context) {
+	operator, ok := organizationMemberFromRequest(c, false)
+	if !ok {
+		return
+	}
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	if userId != operator.UserId && !operator.CanManage() {
+		common.ApiErrorMsg(c, "仅组织所有者或管理员可以执行此操作")
+		return
+	}
+	target, err := model.GetOrganizationMember(operator.OrganizationId, userId)
+	if err != nil {
+		common.ApiErrorMsg(c, "成员不存在")
+		return
+	}
+	if target.Role == model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "组织所有者不能退出组织，请删除组织")
+		return
+	}
+	// 管理员可以自行退出组织，退出不涉及额度上限；移除其他管理员只能由所有者操作
+	if target.Role == model.OrganizationRoleAdmin && operator.Role != model.OrganizationRoleOwner && userId != operator.UserId {
+		common.ApiErrorMsg(c, "仅组织所有者可以移除管理员")
+		return
+	}
+	if err := model.RemoveOrganizationMember(operator.OrganizationId, userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAuditLog(c, "organization.member_remove", model.AuditTargetOrganization, operator.OrganizationId, *target, nil)
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationInvites(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, true)
+	if !ok {
+		return
+	}
+	invites, err := model.GetOrganizationInvites(member.OrganizationId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invites)
+}
+
+func CreateOrganizationInvite(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, true)
+	if !ok {
+		return
+	}
+	var req OrganizationInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	if req.Role != model.OrganizationRoleMember && req.Role != model.OrganizationRoleAdmin {
+		common.ApiErrorMsg(c, "无效的角色")
+		return
+	}
+	if req.Role == model.OrganizationRoleAdmin && member.Role != model.OrganizationRoleOwner {
+		common.ApiErrorMsg(c, "仅组织所有者可以邀请管理员")
+		return
+	}
+	if req.QuotaLimit < 0 || req.ExpiresIn < 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	invite := &model.OrganizationInvite{
+		OrganizationId: member.OrganizationId,
+		Role:           req.Role,
+		QuotaLimit:     req.QuotaLimit,
+		InviterId:      member.UserId,
+	}
+	if req.ExpiresIn > 0 {
+		invite.ExpiresAt = common.GetTimestamp() + req.ExpiresIn
+	}
+	if err := model.CreateOrganizationInvite(invite); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invite)
+}
+
+func DeleteOrganizationInvite(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, true)
+	if !ok {
+		return
+	}
+	inviteId, _ := strconv.Atoi(c.Param("invite_id"))
+	if err := model.DeleteOrganizationInvite(member.OrganizationId, inviteId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func JoinOrganization(c *gin.Context) {
+	var req OrganizationJoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	org, err := model.AcceptOrganizationInvite(strings.TrimSpace(req.Code), c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+// TransferOrganizationQuota 成员将个人钱包额度转入组织钱包
+func TransferOrganizationQuota(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, false)
+	if !ok {
+		return
+	}
+	var req OrganizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota <= 0 {
+		common.ApiErrorMsg(c, "转入额度必须大于 0")
+		return
+	}
+	if err := model.TransferUserQuotaToOrganization(member.UserId, member.OrganizationId, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(member.UserId, model.LogTypeManage, "转入组织钱包 "+logger.LogQuota(req.Quota))
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationUsage 按成员汇总组织用量
+func GetOrganizationUsage(c *gin.Context) {
+	member, ok := organizationMemberFromRequest(c, true)
+	if !ok {
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	items, err := model.GetOrganizationUsageByMember(member.OrganizationId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, items)
+}
+
+// ---- Admin APIs ----
+
+func AdminListOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// AdminAdjustOrganizationQuota 管理员调整组织钱包余额，quota 可为负数
+func AdminAdjustOrganizationQuota(c *gin.Context) {
+	orgId, _ := strconv.Atoi(c.Param("id"))
+	var req OrganizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == 0 {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.AdjustOrganizationQuota(orgId, req.Quota); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAuditLog(c, "organization.quota_adjust", model.AuditTargetOrganization, orgId,
+		gin.H{"quota": org.Quota}, gin.H{"quota": org.Quota + req.Quota})
+	common.ApiSuccess(c, nil)
+}
+
+func AdminUpdateOrganizationStatus(c *gin.Context) {
+	orgId, _ := strconv.Atoi(c.Param("id"))
+	var req OrganizationStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil ||
+		(req.Status != model.OrganizationStatusEnabled && req.Status != model.OrganizationStatusDisabled) {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	before := *org
+	org.Status = req.Status
+	if err := model.UpdateOrganization(org); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordAuditLog(c, "organization.status", model.AuditTargetOrganization, orgId, before, *org)
+	common.ApiSuccess(c, org)
+}
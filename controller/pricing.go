@@ -52,6 +52,7 @@ func GetPricing(c *gin.Context) {
 
 func ResetModelRatio(c *gin.Context) {
 	defaultStr := ratio_setting.DefaultModelRatio2JSONString()
+	oldStr := ratio_setting.ModelRatio2JSONString()
 	err := model.UpdateOption("ModelRatio", defaultStr)
 	if err != nil {
 		c.JSON(200, gin.H{
@@ -68,6 +69,8 @@ func ResetModelRatio(c *gin.Context) {
 		})
 		return
 	}
+	before, after := optionAuditValues("ModelRatio", oldStr, defaultStr)
+	model.RecordAuditLog(c, "option.reset_model_ratio", model.AuditTargetOption, "ModelRatio", before, after)
 	c.JSON(200, gin.H{
 		"success": true,
 		"message": "重置模型倍率成功",
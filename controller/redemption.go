@@ -102,6 +102,7 @@ func AddRedemption(c *gin.Context) {
 			})
 			return
 		}
+		model.RecordAuditLog(c, "redemption.create", model.AuditTargetRedemption, cleanRedemption.Id, nil, cleanRedemption)
 		keys = append(keys, key)
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -114,11 +115,13 @@ func AddRedemption(c *gin.Context) {
 
 func DeleteRedemption(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	originRedemption, _ := model.GetRedemptionById(id)
 	err := model.DeleteRedemptionById(id)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "redemption.delete", model.AuditTargetRedemption, id, originRedemption, nil)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -139,6 +142,7 @@ func UpdateRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	originRedemption := *cleanRedemption
 	if statusOnly == "" {
 		if valid, msg := validateExpiredTime(c, redemption.ExpiredTime); !valid {
 			c.JSON(http.StatusOK, gin.H{"success": false, "message": msg})
@@ -157,6 +161,7 @@ func UpdateRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "redemption.update", model.AuditTargetRedemption, cleanRedemption.Id, originRedemption, cleanRedemption)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -171,6 +176,7 @@ func DeleteInvalidRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "redemption.delete_invalid", model.AuditTargetRedemption, "", nil, gin.H{"deleted": rows})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -54,6 +54,20 @@ func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIErro
 	return err
 }
 
+// relayAttempt 向当前渠道发起一次转发
+func relayAttempt(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) *types.NewAPIError {
+	switch relayFormat {
+	case types.RelayFormatOpenAIRealtime:
+		return relay.WssHelper(c, relayInfo)
+	case types.RelayFormatClaude:
+		return relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		return geminiRelayHandler(c, relayInfo)
+	default:
+		return relayHandler(c, relayInfo)
+	}
+}
+
 func geminiRelayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
 	var err *types.NewAPIError
 	if strings.Contains(c.Request.URL.Path, "embed") {
@@ -71,10 +85,29 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	//originalModel := common.GetContextKeyString(c, constant.ContextKeyOriginalModel)
 
 	var (
-		newAPIError *types.NewAPIError
-		ws          *websocket.Conn
+		newAPIError    *types.NewAPIError
+		ws             *websocket.Conn
+		relayInfo      *relaycommon.RelayInfo
+		payloadCapture *service.PayloadCaptureSession
 	)
 
+	// 最先注册、最后执行，确保记录的是错误响应写出后的最终状态码
+	defer func() {
+		observeRelayMetrics(c, relayFormat, relayInfo)
+	}()
+
+	// 载荷采集先于错误响应的 defer 注册，从而在错误响应写出后才保存，记录客户端实际收到的内容
+	if relayFormat != types.RelayFormatOpenAIRealtime {
+		payloadCapture = service.BeginPayloadCapture(c)
+		defer func() {
+			modelName := common.GetContextKeyString(c, constant.ContextKeyOriginalModel)
+			if relayInfo != nil {
+				modelName = relayInfo.OriginModelName
+			}
+			payloadCapture.Finish(modelName)
+		}()
+	}
+
 	if relayFormat == types.RelayFormatOpenAIRealtime {
 		var err error
 		ws, err = upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -116,7 +149,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
-	relayInfo, err := relaycommon.GenRelayInfo(c, relayFormat, request, ws)
+	relayInfo, err = relaycommon.GenRelayInfo(c, relayFormat, request, ws)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeGenRelayInfoFailed)
 		return
@@ -133,14 +166,29 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 
 	if needSensitiveCheck && meta != nil {
-		contains, words := service.CheckSensitiveText(meta.CombineText)
-		if contains {
-			logger.LogWarn(c, fmt.Sprintf("user sensitive words detected: %s", strings.Join(words, ", ")))
-			newAPIError = types.NewError(err, types.ErrorCodeSensitiveWordsDetected)
-			return
+		if result := service.ModeratePrompt(c, meta.CombineText); result.Flagged() {
+			logger.LogWarn(c, fmt.Sprintf("user sensitive content detected: %s, action: %s", strings.Join(result.Labels(), ", "), result.Action))
+			switch result.Action {
+			case operation_setting.ModerationActionBlock:
+				newAPIError = types.NewErrorWithStatusCode(errors.New("sensitive content detected"), types.ErrorCodeSensitiveWordsDetected, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+				return
+			case operation_setting.ModerationActionRedact:
+				if request, err = redactPromptRequest(c, relayFormat); err != nil {
+					newAPIError = types.NewErrorWithStatusCode(err, types.ErrorCodeSensitiveWordsDetected, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+					return
+				}
+				relayInfo.Request = request
+				meta = request.GetTokenCountMeta()
+			}
 		}
 	}
 
+	// 流式输出审核，安装在重试与对冲之前，所有尝试的输出都经过审核
+	if relayInfo.IsStream && relayFormat != types.RelayFormatOpenAIRealtime && setting.ShouldCheckCompletionSensitive() {
+		streamModeration := service.BeginStreamModeration(c, relayFormat)
+		defer streamModeration.Finish()
+	}
+
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
@@ -185,6 +233,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 	relayInfo.RetryIndex = 0
 	relayInfo.LastError = nil
+	hedged := false
 
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
 		relayInfo.RetryIndex = retryParam.GetRetry()
@@ -196,6 +245,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 
 		addUsedChannel(c, channel.Id)
+		payloadCapture.ActivateForChannel(channel.Id)
 		bodyStorage, bodyErr := common.GetBodyStorage(c)
 		if bodyErr != nil {
 			// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
@@ -208,17 +258,17 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 		c.Request.Body = io.NopCloser(bodyStorage)
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
+		attemptStart := time.Now()
+		if hedgeDelay, ok := getHedgeDelay(c, relayInfo, relayFormat); ok && !hedged {
+			// 每个请求最多对冲一次，之后的重试按常规流程进行
+			hedged = true
+			payloadCapture.ActivateForHedge()
+			channel, attemptStart, newAPIError = relayWithHedge(c, relayInfo, relayFormat, channel, retryParam, hedgeDelay)
+		} else {
+			newAPIError = relayAttempt(c, relayInfo, relayFormat)
 		}
 
+		recordChannelHealth(relayInfo, channel.Id, attemptStart, newAPIError)
 		if newAPIError == nil {
 			relayInfo.LastError = nil
 			return
@@ -241,6 +291,21 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 }
 
+// redactPromptRequest 替换请求体中的命中内容后重新解析请求，替换后仍命中则拒绝
+func redactPromptRequest(c *gin.Context, relayFormat types.RelayFormat) (dto.Request, error) {
+	if err := service.RedactPromptBody(c); err != nil {
+		return nil, err
+	}
+	request, err := helper.GetAndValidateRequest(c, relayFormat)
+	if err != nil {
+		return nil, err
+	}
+	if meta := request.GetTokenCountMeta(); meta != nil && service.RedactModerationText(c, meta.CombineText) != meta.CombineText {
+		return nil, errors.New("sensitive content detected")
+	}
+	return request, nil
+}
+
 var upgrader = websocket.Upgrader{
 	Subprotocols: []string{"realtime"}, // WS 握手支持的协议，如果有使用 Sec-WebSocket-Protocol，则必须在此声明对应的 Protocol TODO add other protocol
 	CheckOrigin: func(r *http.Request) bool {
@@ -347,6 +412,21 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return operation_setting.ShouldRetryByStatusCode(code)
 }
 
+// recordChannelHealth 记录本次渠道尝试的结果，供自适应渠道选择使用
+func recordChannelHealth(relayInfo *relaycommon.RelayInfo, channelId int, attemptStart time.Time, err *types.NewAPIError) {
+	if err != nil {
+		if service.IsChannelHealthFailure(err) {
+			model.RecordChannelHealth(channelId, false, 0)
+		}
+		return
+	}
+	latency := time.Since(attemptStart)
+	if relayInfo.IsStream && relayInfo.FirstResponseTime.After(attemptStart) {
+		latency = relayInfo.FirstResponseTime.Sub(attemptStart)
+	}
+	model.RecordChannelHealth(channelId, true, latency)
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
@@ -574,6 +654,7 @@ func RelayTask(c *gin.Context) {
 		task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
 		task.PrivateData.BillingSource = relayInfo.BillingSource
 		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+		task.PrivateData.OrganizationId = relayInfo.OrganizationId
 		task.PrivateData.TokenId = relayInfo.TokenId
 		task.PrivateData.BillingContext = &model.TaskBillingContext{
 			ModelPrice:      relayInfo.PriceData.ModelPrice,
@@ -0,0 +1,304 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 为对冲挑选备用渠道时的最大尝试次数，避免与主渠道相同
+const hedgeChannelSelectAttempts = 3
+
+// getHedgeDelay 返回本次请求的对冲等待时间，仅对话类请求支持对冲
+func getHedgeDelay(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) (time.Duration, bool) {
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return 0, false
+	}
+	switch relayFormat {
+	case types.RelayFormatOpenAI:
+		if relayInfo.RelayMode != relayconstant.RelayModeChatCompletions && relayInfo.RelayMode != relayconstant.RelayModeCompletions {
+			return 0, false
+		}
+	case types.RelayFormatOpenAIResponses:
+		if relayInfo.RelayMode != relayconstant.RelayModeResponses {
+			return 0, false
+		}
+	case types.RelayFormatClaude:
+	case types.RelayFormatGemini:
+		if strings.Contains(c.Request.URL.Path, "embed") {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	return operation_setting.GetHedgeDelay(relayInfo.OriginModelName, relayInfo.UsingGroup)
+}
+
+// hedgeResponseWriter 对冲尝试使用的 Writer：首次写出响应时参与竞速，
+// 获胜后透传到真实 Writer，落败后丢弃所有写入。只在所属尝试的 goroutine 中使用
+type hedgeResponseWriter struct {
+	gin.ResponseWriter
+	attempt *relaycommon.HedgeAttempt
+	header  http.Header
+	status  int
+	won     bool
+}
+
+func newHedgeResponseWriter(w gin.ResponseWriter, attempt *relaycommon.HedgeAttempt) *hedgeResponseWriter {
+	return &hedgeResponseWriter{ResponseWriter: w, attempt: attempt, header: make(http.Header)}
+}
+
+// claim 参与竞速，获胜时把暂存的响应头和状态码写入真实 Writer
+func (w *hedgeResponseWriter) claim() bool {
+	if w.won {
+		return true
+	}
+	flushed := false
+	flush := func() {
+		flushed = true
+		dst := w.ResponseWriter.Header()
+		for key, values := range w.header {
+			dst[key] = values
+		}
+		if w.status > 0 {
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+	}
+	w.won = w.attempt.Race().Claim(w.attempt, flush)
+	if w.won && !flushed {
+		// 结算时已抢先获胜（见 ClaimHedgeSettlement），补写暂存的响应头
+		flush()
+	}
+	return w.won
+}
+
+func (w *hedgeResponseWriter) Header() http.Header {
+	if w.won {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeResponseWriter) WriteHeader(code int) {
+	if w.won {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *hedgeResponseWriter) WriteHeaderNow() {
+	if w.claim() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeResponseWriter) Write(data []byte) (int, error) {
+	if !w.claim() {
+		return 0, relaycommon.ErrHedgeLost
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hedgeResponseWriter) WriteString(s string) (int, error) {
+	if !w.claim() {
+		return 0, relaycommon.ErrHedgeLost
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *hedgeResponseWriter) Flush() {
+	if w.won {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *hedgeResponseWriter) Status() int {
+	if w.won {
+		return w.ResponseWriter.Status()
+	}
+	if w.status > 0 {
+		return w.status
+	}
+	return http.StatusOK
+}
+
+func (w *hedgeResponseWriter) Size() int {
+	if w.won {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *hedgeResponseWriter) Written() bool {
+	return w.won && w.ResponseWriter.Written()
+}
+
+type hedgeAttemptResult struct {
+	attempt *relaycommon.HedgeAttempt
+	ctx     *gin.Context
+	info    *relaycommon.RelayInfo
+	channel *model.Channel
+	start   time.Time
+	err     *types.NewAPIError
+}
+
+// startHedgeAttempt 在 c 的副本 attemptCtx 上异步发起一次转发，结束后把结果写入 results
+func startHedgeAttempt(c *gin.Context, attemptCtx *gin.Context, info *relaycommon.RelayInfo, relayFormat types.RelayFormat, channel *model.Channel, results chan<- *hedgeAttemptResult) {
+	attempt := info.Hedge
+	attemptCtx.Request = c.Request.WithContext(attempt.Ctx)
+	if storage, err := common.GetBodyStorage(attemptCtx); err == nil {
+		attemptCtx.Request.Body = io.NopCloser(storage)
+	}
+	attemptCtx.Writer = newHedgeResponseWriter(c.Writer, attempt)
+	// 对冲期间不发送自定义 Ping，避免 Ping 先于真实响应写出而决定胜负
+	info.DisablePing = true
+	start := time.Now()
+	gopool.Go(func() {
+		var newAPIError *types.NewAPIError
+		defer func() {
+			if r := recover(); r != nil {
+				newAPIError = types.NewError(fmt.Errorf("hedged relay panic: %v", r), types.ErrorCodeBadResponse)
+			}
+			if attempt.Lost() {
+				// 落败的尝试不会同步回主请求，需要自行释放占用的 key
+				middleware.ReleaseChannelKey(attemptCtx)
+			}
+			attempt.Release()
+			results <- &hedgeAttemptResult{
+				attempt: attempt,
+				ctx:     attemptCtx,
+				info:    info,
+				channel: channel,
+				start:   start,
+				err:     newAPIError,
+			}
+		}()
+		newAPIError = relayAttempt(attemptCtx, info, relayFormat)
+	})
+}
+
+// selectHedgeChannel 为对冲挑选一个不同于主渠道的备用渠道，并在 c 的副本上完成渠道上下文设置
+func selectHedgeChannel(c *gin.Context, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam, primaryChannelId int) (*gin.Context, *model.Channel) {
+	hedgeCtx := c.Copy()
+	// 副本继承了主渠道 key 的释放函数，清除以免切换渠道时提前释放主渠道占用的 key
+	common.SetContextKey(hedgeCtx, constant.ContextKeyChannelKeyRelease, nil)
+	for i := 0; i < hedgeChannelSelectAttempts; i++ {
+		param := &service.RetryParam{
+			Ctx:        hedgeCtx,
+			TokenGroup: retryParam.TokenGroup,
+			ModelName:  retryParam.ModelName,
+			Retry:      common.GetPointer(retryParam.GetRetry() + i),
+		}
+		channel, _, err := service.CacheGetRandomSatisfiedChannel(param)
+		if err != nil || channel == nil {
+			return nil, nil
+		}
+		if channel.Id == primaryChannelId {
+			continue
+		}
+		if middleware.SetupContextForSelectedChannel(hedgeCtx, channel, relayInfo.OriginModelName) != nil {
+			return nil, nil
+		}
+		return hedgeCtx, channel
+	}
+	return nil, nil
+}
+
+// relayWithHedge 向主渠道发起请求，若 delay 内仍未写出响应头或首个流式分片，
+// 则向备用渠道发起同一请求，采用最先写出响应的尝试并取消另一个，只有获胜的尝试计费。
+// 返回最终采用的尝试，其上下文已同步回 c 与 relayInfo
+func relayWithHedge(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, channel *model.Channel, retryParam *service.RetryParam, delay time.Duration) (*model.Channel, time.Time, *types.NewAPIError) {
+	race := relaycommon.NewHedgeRace()
+	results := make(chan *hedgeAttemptResult, 2)
+
+	primaryCtx := c.Copy()
+	startHedgeAttempt(c, primaryCtx, relayInfo.CloneForHedge(race.NewAttempt(c.Request.Context(), channel.Id)), relayFormat, channel, results)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var final *hedgeAttemptResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if race.Winner() != nil {
+				continue
+			}
+			hedgeCtx, hedgeChannel := selectHedgeChannel(c, relayInfo, retryParam, channel.Id)
+			if hedgeCtx == nil {
+				continue
+			}
+			// 备用尝试使用独立的请求体存储，避免与主尝试并发读取时互相影响读取位置
+			// 此处不能使用 GetBodyStorage，它会重置主尝试正在读取的位置
+			if bodyStorage, ok := c.Value(common.KeyBodyStorage).(common.BodyStorage); ok {
+				if body, err := bodyStorage.Bytes(); err == nil {
+					if hedgeStorage, err := common.CreateBodyStorage(body); err == nil {
+						hedgeCtx.Set(common.KeyBodyStorage, hedgeStorage)
+						defer hedgeStorage.Close()
+					}
+				}
+			}
+			addUsedChannel(c, hedgeChannel.Id)
+			addUsedChannel(primaryCtx, hedgeChannel.Id)
+			hedgeCtx.Set("use_channel", c.GetStringSlice("use_channel"))
+			logger.LogInfo(c, fmt.Sprintf("渠道 #%d 在 %d ms 内未响应，对冲请求渠道 #%d", channel.Id, delay.Milliseconds(), hedgeChannel.Id))
+
+			hedgeInfo := relayInfo.CloneForHedge(race.NewAttempt(c.Request.Context(), hedgeChannel.Id))
+			hedgeInfo.PriceData.GroupRatioInfo = helper.HandleGroupRatio(hedgeCtx, hedgeInfo)
+			startHedgeAttempt(c, hedgeCtx, hedgeInfo, relayFormat, hedgeChannel, results)
+			pending++
+		case result := <-results:
+			pending--
+			winner := race.Winner()
+			if winner != nil && winner != result.attempt {
+				// 落败的尝试已被取消，结果忽略
+				continue
+			}
+			if winner == nil && result.err != nil && pending > 0 {
+				// 尚未写出任何响应且另一尝试仍在进行，记录本次失败后继续等待
+				result.err = service.NormalizeViolationFeeError(result.err)
+				recordChannelHealth(result.info, result.channel.Id, result.start, result.err)
+				processChannelError(result.ctx, *types.NewChannelError(result.channel.Id, result.channel.Type, result.channel.Name, result.channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(result.ctx, constant.ContextKeyChannelKey), result.channel.GetAutoBan()), result.err)
+				middleware.ReleaseChannelKey(result.ctx)
+				continue
+			}
+			if winner == nil && result.err == nil {
+				// 成功但未写出任何内容，直接采用并取消其他尝试
+				race.Claim(result.attempt, nil)
+			}
+			final = result
+			pending = 0
+		}
+	}
+
+	// 同步最终采用的尝试的上下文，供后续重试、日志及中间件使用
+	useChannel := c.GetStringSlice("use_channel")
+	for key, value := range final.ctx.Keys {
+		c.Set(key, value)
+	}
+	c.Set("use_channel", useChannel)
+	*relayInfo = *final.info
+	relayInfo.Hedge = nil
+	return final.channel, final.start, final.err
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgeResponseWriterOnlyWinnerWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	race := relaycommon.NewHedgeRace()
+	primary := newHedgeResponseWriter(c.Writer, race.NewAttempt(context.Background(), 1))
+	backup := newHedgeResponseWriter(c.Writer, race.NewAttempt(context.Background(), 2))
+
+	primary.Header().Set("X-Attempt", "primary")
+	backup.Header().Set("X-Attempt", "backup")
+	backup.WriteHeader(http.StatusCreated)
+	_, err := backup.WriteString("backup")
+	require.NoError(t, err)
+	backup.Flush()
+
+	_, err = primary.Write([]byte("primary"))
+	require.ErrorIs(t, err, relaycommon.ErrHedgeLost)
+	require.False(t, primary.Written())
+
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.Equal(t, "backup", recorder.Header().Get("X-Attempt"))
+	require.Equal(t, "backup", recorder.Body.String())
+}
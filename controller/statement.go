@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// renderStatement 按 format 输出对账单：json（默认）、html 可打印文档，或 csv、xlsx、jsonl 明细
+func renderStatement(c *gin.Context, userId int) {
+	period := c.DefaultQuery("period", service.StatementPeriod(time.Now()))
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	statement, err := service.BuildStatement(userId, tokenId, period)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	filename := fmt.Sprintf("statement-%s-%d", period, statement.UserId)
+	if statement.TokenId != 0 {
+		filename = fmt.Sprintf("%s-token-%d", filename, statement.TokenId)
+	}
+	switch format {
+	case "json":
+		common.ApiSuccess(c, statement)
+		return
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.html", filename))
+		c.Status(http.StatusOK)
+		if err = service.RenderStatementHTML(c.Writer, statement); err != nil {
+			logger.LogError(c, "failed to render statement: "+err.Error())
+		}
+		return
+	}
+	contentType := service.ExportContentType(format)
+	if contentType == "" {
+		common.ApiErrorMsg(c, "不支持的导出格式")
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", filename, format))
+	c.Status(http.StatusOK)
+	writer, err := service.NewTabularWriter(c.Writer, format, "statement", service.StatementLineColumns)
+	if err != nil {
+		logger.LogError(c, "failed to render statement: "+err.Error())
+		return
+	}
+	for _, line := range statement.StatementLines() {
+		if err = writer.WriteRow(line); err != nil {
+			break
+		}
+	}
+	finishUsageExport(c, writer, err)
+}
+
+// GetSelfStatement 用户获取自己的月度对账单，token_id 指定时为单个令牌的对账单
+func GetSelfStatement(c *gin.Context) {
+	renderStatement(c, c.GetInt("id"))
+}
+
+// GetUserStatement 管理员获取指定用户的月度对账单
+func GetUserStatement(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
+		return
+	}
+	renderStatement(c, userId)
+}
@@ -162,6 +162,7 @@ func AdminCreateSubscriptionPlan(c *gin.Context) {
 		return
 	}
 	model.InvalidateSubscriptionPlanCache(req.Plan.Id)
+	model.RecordAuditLog(c, "subscription_plan.create", model.AuditTargetSubscriptionPlan, req.Plan.Id, nil, req.Plan)
 	common.ApiSuccess(c, req.Plan)
 }
 
@@ -220,6 +221,7 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 		return
 	}
 
+	originPlan, _ := model.GetSubscriptionPlanById(id)
 	err := model.DB.Transaction(func(tx *gorm.DB) error {
 		// update plan (allow zero values updates with map)
 		updateMap := map[string]interface{}{
@@ -251,6 +253,9 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 		return
 	}
 	model.InvalidateSubscriptionPlanCache(id)
+	if plan, err := model.GetSubscriptionPlanById(id); err == nil {
+		model.RecordAuditLog(c, "subscription_plan.update", model.AuditTargetSubscriptionPlan, id, originPlan, plan)
+	}
 	common.ApiSuccess(c, nil)
 }
 
@@ -274,6 +279,7 @@ func AdminUpdateSubscriptionPlanStatus(c *gin.Context) {
 		return
 	}
 	model.InvalidateSubscriptionPlanCache(id)
+	model.RecordAuditLog(c, "subscription_plan.update_status", model.AuditTargetSubscriptionPlan, id, nil, gin.H{"enabled": *req.Enabled})
 	common.ApiSuccess(c, nil)
 }
 
@@ -293,6 +299,7 @@ func AdminBindSubscription(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "user.bind_subscription", model.AuditTargetUser, req.UserId, nil, req)
 	if msg != "" {
 		common.ApiSuccess(c, gin.H{"message": msg})
 		return
@@ -337,6 +344,7 @@ func AdminCreateUserSubscription(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "user.bind_subscription", model.AuditTargetUser, userId, nil, req)
 	if msg != "" {
 		common.ApiSuccess(c, gin.H{"message": msg})
 		return
@@ -356,6 +364,7 @@ func AdminInvalidateUserSubscription(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "user_subscription.invalidate", model.AuditTargetUserSubscription, subId, nil, nil)
 	if msg != "" {
 		common.ApiSuccess(c, gin.H{"message": msg})
 		return
@@ -375,6 +384,7 @@ func AdminDeleteUserSubscription(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "user_subscription.delete", model.AuditTargetUserSubscription, subId, nil, nil)
 	if msg != "" {
 		common.ApiSuccess(c, gin.H{"message": msg})
 		return
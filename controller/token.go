@@ -23,6 +23,7 @@ func GetAllTokens(c *gin.Context) {
 		return
 	}
 	total, _ := model.CountUserTokens(userId)
+	normalizeTokenBudgets(tokens)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(tokens)
 	common.ApiSuccess(c, pageInfo)
@@ -41,12 +42,20 @@ func SearchTokens(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	normalizeTokenBudgets(tokens)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(tokens)
 	common.ApiSuccess(c, pageInfo)
 	return
 }
 
+// normalizeTokenBudgets 将已跨周期令牌的周期用量显示为 0
+func normalizeTokenBudgets(tokens []*model.Token) {
+	for _, token := range tokens {
+		token.NormalizeBudgetWindow()
+	}
+}
+
 func GetToken(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	userId := c.GetInt("id")
@@ -59,6 +68,7 @@ func GetToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	token.NormalizeBudgetWindow()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -160,6 +170,20 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	}
+	if !model.IsValidTokenBudgetPeriod(token.BudgetPeriod) || token.BudgetQuota < 0 {
+		common.ApiErrorI18n(c, i18n.MsgTokenBudgetInvalid)
+		return
+	}
+	if err := token.ValidateRateLimits(); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgTokenRateLimitInvalid, map[string]any{"Error": err.Error()})
+		return
+	}
+	if token.OrganizationId > 0 {
+		if _, err := model.GetOrganizationMember(token.OrganizationId, c.GetInt("id")); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgTokenOrganizationInvalid)
+			return
+		}
+	}
 	// 检查用户令牌数量是否已达上限
 	maxTokens := operation_setting.GetMaxUserTokens()
 	count, err := model.CountUserTokens(c.GetInt("id"))
@@ -194,6 +218,14 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		BudgetPeriod:       token.BudgetPeriod,
+		BudgetQuota:        token.BudgetQuota,
+		// 限流
+		RateLimitRPM:         token.RateLimitRPM,
+		RateLimitTPM:         token.RateLimitTPM,
+		RateLimitConcurrency: token.RateLimitConcurrency,
+		ModelRateLimits:      token.ModelRateLimits,
+		OrganizationId:       token.OrganizationId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -215,6 +247,7 @@ func DeleteToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "token.delete", model.AuditTargetToken, id, nil, nil)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -246,6 +279,20 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	if !model.IsValidTokenBudgetPeriod(token.BudgetPeriod) || token.BudgetQuota < 0 {
+		common.ApiErrorI18n(c, i18n.MsgTokenBudgetInvalid)
+		return
+	}
+	if err := token.ValidateRateLimits(); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgTokenRateLimitInvalid, map[string]any{"Error": err.Error()})
+		return
+	}
+	if token.OrganizationId > 0 {
+		if _, err := model.GetOrganizationMember(token.OrganizationId, c.GetInt("id")); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgTokenOrganizationInvalid)
+			return
+		}
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -274,6 +321,13 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.BudgetPeriod = token.BudgetPeriod
+		cleanToken.BudgetQuota = token.BudgetQuota
+		cleanToken.RateLimitRPM = token.RateLimitRPM
+		cleanToken.RateLimitTPM = token.RateLimitTPM
+		cleanToken.RateLimitConcurrency = token.RateLimitConcurrency
+		cleanToken.ModelRateLimits = token.ModelRateLimits
+		cleanToken.OrganizationId = token.OrganizationId
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -303,6 +357,7 @@ func DeleteTokenBatch(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "token.batch_delete", model.AuditTargetToken, "", gin.H{"ids": tokenBatch.Ids}, nil)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
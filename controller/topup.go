@@ -306,6 +306,7 @@ func EpayNotify(c *gin.Context) {
 			}
 			log.Printf("易支付回调更新用户成功 %v", topUp)
 			model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", logger.LogQuota(quotaToAdd), topUp.Money))
+			service.EmitTopUpCompletedWebhook(topUp.TradeNo)
 		}
 	} else {
 		log.Printf("易支付异常回调: %v", verifyInfo)
@@ -408,5 +409,6 @@ func AdminCompleteTopUp(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.EmitTopUpCompletedWebhook(req.TradeNo)
 	common.ApiSuccess(c, nil)
 }
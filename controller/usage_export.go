@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func usageExportQueryFromRequest(c *gin.Context) *model.UsageExportQuery {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	return &model.UsageExportQuery{
+		Username:       c.Query("username"),
+		TokenName:      c.Query("token_name"),
+		ModelName:      c.Query("model_name"),
+		Group:          c.Query("group"),
+		Channel:        channel,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+}
+
+func exportTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).Format(time.RFC3339)
+}
+
+// startUsageExport 校验格式并写出下载响应头，之后的写出错误只能记录日志，无法再改变响应状态
+func startUsageExport(c *gin.Context, name string, columns []string) (service.TabularWriter, bool) {
+	format := c.DefaultQuery("format", service.ExportFormatCSV)
+	contentType := service.ExportContentType(format)
+	if contentType == "" {
+		common.ApiErrorMsg(c, "不支持的导出格式")
+		return nil, false
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.%s", name, time.Now().Format("20060102150405"), format))
+	c.Status(http.StatusOK)
+	writer, err := service.NewTabularWriter(c.Writer, format, name, columns)
+	if err != nil {
+		logger.LogError(c, "failed to start usage export: "+err.Error())
+		return nil, false
+	}
+	return writer, true
+}
+
+func finishUsageExport(c *gin.Context, writer service.TabularWriter, err error) {
+	if err != nil {
+		logger.LogError(c, "usage export interrupted: "+err.Error())
+	}
+	if closeErr := writer.Close(); closeErr != nil {
+		logger.LogError(c, "failed to finish usage export: "+closeErr.Error())
+	}
+}
+
+// flushUsageExport 每批写完后刷新到客户端，保持内存占用与批大小相关而不是与总行数相关
+func flushUsageExport(c *gin.Context, writer service.TabularWriter) error {
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	if c.Request.Context().Err() != nil {
+		return errors.New("client disconnected")
+	}
+	return nil
+}
+
+func exportConsumeLogs(c *gin.Context, query *model.UsageExportQuery, admin bool) {
+	columns := []string{"id", "created_at", "username", "token_name", "model_name", "group", "quota", "amount", "prompt_tokens", "completion_tokens", "use_time", "is_stream", "request_id", "ip"}
+	if admin {
+		columns = append(columns, "channel_id", "channel_name", "upstream_cost")
+	}
+	columns = append(columns, "other")
+	writer, ok := startUsageExport(c, "consume-logs", columns)
+	if !ok {
+		return
+	}
+	err := model.ExportConsumeLogs(query, func(logs []*model.Log) error {
+		for _, log := range logs {
+			row := []any{log.Id, exportTime(log.CreatedAt), log.Username, log.TokenName, log.ModelName, log.Group,
+				log.Quota, float64(log.Quota) / common.QuotaPerUnit, log.PromptTokens, log.CompletionTokens, log.UseTime, log.IsStream, log.RequestId, log.Ip}
+			if admin {
+				row = append(row, log.ChannelId, log.ChannelName, log.UpstreamCost)
+			}
+			row = append(row, log.Other)
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return flushUsageExport(c, writer)
+	})
+	finishUsageExport(c, writer, err)
+}
+
+func exportQuotaData(c *gin.Context, query *model.UsageExportQuery, admin bool) {
+	columns := []string{"id", "created_at", "username", "model_name", "count", "token_used", "quota", "amount"}
+	if admin {
+		columns = append(columns, "user_id", "upstream_cost")
+	}
+	writer, ok := startUsageExport(c, "quota-data", columns)
+	if !ok {
+		return
+	}
+	err := model.ExportQuotaData(query, func(data []*model.QuotaData) error {
+		for _, item := range data {
+			row := []any{item.Id, exportTime(item.CreatedAt), item.Username, item.ModelName, item.Count, item.TokenUsed,
+				item.Quota, float64(item.Quota) / common.QuotaPerUnit}
+			if admin {
+				row = append(row, item.UserID, item.UpstreamCost)
+			}
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return flushUsageExport(c, writer)
+	})
+	finishUsageExport(c, writer, err)
+}
+
+// ExportAllLogs 管理员导出消费日志，format 支持 csv、xlsx、jsonl
+func ExportAllLogs(c *gin.Context) {
+	exportConsumeLogs(c, usageExportQueryFromRequest(c), true)
+}
+
+// ExportUserLogs 用户导出自己的消费日志
+func ExportUserLogs(c *gin.Context) {
+	query := usageExportQueryFromRequest(c)
+	query.UserId = c.GetInt("id")
+	query.Username = ""
+	query.Channel = 0
+	exportConsumeLogs(c, query, false)
+}
+
+// ExportAllQuotaData 管理员导出按小时汇总的用量数据
+func ExportAllQuotaData(c *gin.Context) {
+	exportQuotaData(c, usageExportQueryFromRequest(c), true)
+}
+
+// ExportUserQuotaData 用户导出自己的用量数据
+func ExportUserQuotaData(c *gin.Context) {
+	query := usageExportQueryFromRequest(c)
+	query.UserId = c.GetInt("id")
+	query.Username = ""
+	exportQuotaData(c, query, false)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func usageRollupQueryFromRequest(c *gin.Context) *model.UsageRollupQuery {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	var groupBy []string
+	for _, dimension := range strings.Split(c.Query("group_by"), ",") {
+		if dimension = strings.TrimSpace(dimension); dimension != "" {
+			groupBy = append(groupBy, dimension)
+		}
+	}
+	return &model.UsageRollupQuery{
+		Granularity:    c.DefaultQuery("granularity", model.UsageRollupHour),
+		GroupBy:        groupBy,
+		ByTime:         c.Query("by_time") == "true",
+		UserId:         userId,
+		Username:       c.Query("username"),
+		TokenId:        tokenId,
+		ModelName:      c.Query("model_name"),
+		ChannelId:      channel,
+		Group:          c.Query("group"),
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+}
+
+// GetUsageRollups 管理员按用户、令牌、模型、渠道或分组查询预聚合用量
+func GetUsageRollups(c *gin.Context) {
+	stats, err := model.QueryUsageRollups(usageRollupQueryFromRequest(c))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, stats)
+}
+
+// GetUserUsageRollups 用户查询自己的预聚合用量，不支持按渠道筛选或分组
+func GetUserUsageRollups(c *gin.Context) {
+	query := usageRollupQueryFromRequest(c)
+	query.UserId = c.GetInt("id")
+	query.Username = ""
+	query.ChannelId = 0
+	// 与 GetUserQuotaDates 一致，限制时间跨度不超过 1 个月；未指定时默认查询最近 1 个月
+	if query.EndTimestamp <= 0 {
+		query.EndTimestamp = common.GetTimestamp()
+	}
+	if query.StartTimestamp <= 0 {
+		query.StartTimestamp = query.EndTimestamp - 2592000
+	}
+	if query.EndTimestamp-query.StartTimestamp > 2592000 {
+		common.ApiErrorMsg(c, "时间跨度不能超过 1 个月")
+		return
+	}
+	for _, dimension := range query.GroupBy {
+		if dimension == "channel" || dimension == "user" {
+			common.ApiErrorMsg(c, "不支持的分组维度: "+dimension)
+			return
+		}
+	}
+	stats, err := model.QueryUsageRollups(query)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 上游成本仅管理员可见
+	for _, stat := range stats {
+		stat.UpstreamCost = 0
+	}
+	common.ApiSuccess(c, stats)
+}
+
+type UsageRollupBackfillRequest struct {
+	StartTimestamp int64 `json:"start_timestamp"`
+	EndTimestamp   int64 `json:"end_timestamp"`
+}
+
+// BackfillUsageRollups 由历史日志重建指定时间范围的预聚合数据，在后台执行
+func BackfillUsageRollups(c *gin.Context) {
+	var req UsageRollupBackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	if err := service.StartUsageRollupBackfill(req.StartTimestamp, req.EndTimestamp); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, service.GetUsageRollupBackfillStatus())
+}
+
+// GetUsageRollupBackfillStatus 查询回填任务进度
+func GetUsageRollupBackfillStatus(c *gin.Context) {
+	common.ApiSuccess(c, service.GetUsageRollupBackfillStatus())
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,7 +15,13 @@ func GetAllQuotaDates(c *gin.Context) {
 	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
 	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
 	username := c.Query("username")
-	dates, err := model.GetAllQuotaDates(startTimestamp, endTimestamp, username)
+	var dates []*model.QuotaData
+	var err error
+	if operation_setting.GetUsageRollupSetting().ServeDashboard {
+		dates, err = model.GetQuotaDatesFromRollups(0, username, startTimestamp, endTimestamp)
+	} else {
+		dates, err = model.GetAllQuotaDates(startTimestamp, endTimestamp, username)
+	}
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -39,11 +46,21 @@ func GetUserQuotaDates(c *gin.Context) {
 		})
 		return
 	}
-	dates, err := model.GetQuotaDataByUserId(userId, startTimestamp, endTimestamp)
+	var dates []*model.QuotaData
+	var err error
+	if operation_setting.GetUsageRollupSetting().ServeDashboard {
+		dates, err = model.GetQuotaDatesFromRollups(userId, "", startTimestamp, endTimestamp)
+	} else {
+		dates, err = model.GetQuotaDataByUserId(userId, startTimestamp, endTimestamp)
+	}
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	// 上游成本仅管理员可见
+	for _, date := range dates {
+		date.UpstreamCost = 0
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -51,3 +68,21 @@ func GetUserQuotaDates(c *gin.Context) {
 	})
 	return
 }
+
+// GetMarginReport 按渠道、模型、分组或天汇总收入、上游成本与毛利
+func GetMarginReport(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	tzOffset, _ := strconv.ParseInt(c.Query("tz_offset"), 10, 64)
+	groupBy := c.DefaultQuery("group_by", model.MarginGroupByChannel)
+	items, err := model.GetMarginReport(groupBy, startTimestamp, endTimestamp, tzOffset)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    items,
+	})
+}
@@ -575,6 +575,13 @@ func UpdateUser(c *gin.Context) {
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
 	}
+	if user, err := model.GetUserById(updatedUser.Id, false); err == nil {
+		if updatePassword {
+			// 只记录密码被修改，差异中会脱敏
+			user.Password = updatedUser.Password
+		}
+		model.RecordAuditLog(c, "user.update", model.AuditTargetUser, updatedUser.Id, originUser, user)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -613,6 +620,7 @@ func AdminClearUserBinding(c *gin.Context) {
 	}
 
 	model.RecordLog(user.Id, model.LogTypeManage, fmt.Sprintf("admin cleared %s binding for user %s", bindingType, user.Username))
+	model.RecordAuditLog(c, "user.clear_binding", model.AuditTargetUser, user.Id, nil, gin.H{"binding_type": bindingType})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -769,6 +777,9 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 	err = model.HardDeleteUserById(id)
+	if err == nil {
+		model.RecordAuditLog(c, "user.delete", model.AuditTargetUser, id, originUser, nil)
+	}
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -830,6 +841,11 @@ func CreateUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "user.create", model.AuditTargetUser, cleanUser.Id, nil, gin.H{
+		"username":     cleanUser.Username,
+		"display_name": cleanUser.DisplayName,
+		"role":         cleanUser.Role,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -866,6 +882,7 @@ func ManageUser(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
 		return
 	}
+	originUser := user
 	switch req.Action {
 	case "disable":
 		user.Status = common.UserStatusDisabled
@@ -913,6 +930,7 @@ func ManageUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	model.RecordAuditLog(c, "user."+req.Action, model.AuditTargetUser, user.Id, originUser, user)
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -1024,6 +1042,7 @@ func TopUp(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.EmitRedemptionUsedWebhook(id, req.Key, quota)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
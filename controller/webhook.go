@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每个用户最多可创建的普通端点数
+const maxUserWebhookEndpoints = 10
+
+type webhookEndpointRequest struct {
+	Id     int      `json:"id"`
+	Name   string   `json:"name"`
+	Url    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Status int      `json:"status"`
+}
+
+// validate 校验端点配置，system 为 false 时不允许订阅系统事件
+func (req *webhookEndpointRequest) validate(c *gin.Context, system bool) bool {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Url = strings.TrimSpace(req.Url)
+	if req.Name == "" || len(req.Name) > 64 {
+		common.ApiErrorI18n(c, i18n.MsgWebhookNameInvalid)
+		return false
+	}
+	parsed, err := url.Parse(req.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(req.Url) > 512 {
+		common.ApiErrorI18n(c, i18n.MsgWebhookUrlInvalid)
+		return false
+	}
+	if len(req.Secret) > 128 {
+		common.ApiErrorI18n(c, i18n.MsgWebhookSecretTooLong)
+		return false
+	}
+	if len(req.Events) == 0 {
+		common.ApiErrorI18n(c, i18n.MsgWebhookEventsInvalid)
+		return false
+	}
+	for _, event := range req.Events {
+		if !dto.IsValidWebhookEvent(event) || (!system && dto.IsSystemWebhookEvent(event)) {
+			common.ApiErrorI18n(c, i18n.MsgWebhookEventsInvalid)
+			return false
+		}
+	}
+	if req.Status != model.WebhookEndpointStatusDisabled {
+		req.Status = model.WebhookEndpointStatusEnabled
+	}
+	return true
+}
+
+// GetWebhookEvents 返回可订阅的事件列表，普通用户不返回系统事件
+func GetWebhookEvents(c *gin.Context) {
+	system := c.GetInt("role") >= common.RoleAdminUser
+	events := make([]dto.WebhookEventInfo, 0, len(dto.WebhookEvents))
+	for _, info := range dto.WebhookEvents {
+		if info.System && !system {
+			continue
+		}
+		events = append(events, info)
+	}
+	common.ApiSuccess(c, events)
+}
+
+func GetWebhookEndpoints(c *gin.Context) {
+	getWebhookEndpoints(c, false)
+}
+
+func GetSystemWebhookEndpoints(c *gin.Context) {
+	getWebhookEndpoints(c, true)
+}
+
+func getWebhookEndpoints(c *gin.Context, system bool) {
+	endpoints, err := model.GetWebhookEndpoints(c.GetInt("id"), system)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, endpoints)
+}
+
+func AddWebhookEndpoint(c *gin.Context) {
+	addWebhookEndpoint(c, false)
+}
+
+func AddSystemWebhookEndpoint(c *gin.Context) {
+	addWebhookEndpoint(c, true)
+}
+
+func addWebhookEndpoint(c *gin.Context, system bool) {
+	var req webhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if !req.validate(c, system) {
+		return
+	}
+	userId := c.GetInt("id")
+	if !system {
+		endpoints, err := model.GetWebhookEndpoints(userId, false)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if len(endpoints) >= maxUserWebhookEndpoints {
+			common.ApiErrorI18n(c, i18n.MsgWebhookEndpointLimit, map[string]any{"Max": maxUserWebhookEndpoints})
+			return
+		}
+	}
+	endpoint := &model.WebhookEndpoint{
+		UserId:   userId,
+		IsSystem: system,
+		Name:     req.Name,
+		Url:      req.Url,
+		Secret:   req.Secret,
+		Events:   strings.Join(req.Events, ","),
+		Status:   req.Status,
+	}
+	if err := endpoint.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, endpoint)
+}
+
+func UpdateWebhookEndpoint(c *gin.Context) {
+	updateWebhookEndpoint(c, false)
+}
+
+func UpdateSystemWebhookEndpoint(c *gin.Context) {
+	updateWebhookEndpoint(c, true)
+}
+
+func updateWebhookEndpoint(c *gin.Context, system bool) {
+	var req webhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if !req.validate(c, system) {
+		return
+	}
+	endpoint, err := model.GetWebhookEndpoint(req.Id, c.GetInt("id"), system)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgWebhookNotFound)
+		return
+	}
+	endpoint.Name = req.Name
+	endpoint.Url = req.Url
+	// 与渠道密钥一致，未填写时保留原有的签名密钥
+	if req.Secret != "" {
+		endpoint.Secret = req.Secret
+	}
+	endpoint.Events = strings.Join(req.Events, ",")
+	endpoint.Status = req.Status
+	if err := endpoint.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, endpoint)
+}
+
+func DeleteWebhookEndpoint(c *gin.Context) {
+	deleteWebhookEndpoint(c, false)
+}
+
+func DeleteSystemWebhookEndpoint(c *gin.Context) {
+	deleteWebhookEndpoint(c, true)
+}
+
+func deleteWebhookEndpoint(c *gin.Context, system bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	endpoint, err := model.GetWebhookEndpoint(id, c.GetInt("id"), system)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgWebhookNotFound)
+		return
+	}
+	if err := endpoint.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func TestWebhookEndpoint(c *gin.Context) {
+	testWebhookEndpoint(c, false)
+}
+
+func TestSystemWebhookEndpoint(c *gin.Context) {
+	testWebhookEndpoint(c, true)
+}
+
+// testWebhookEndpoint 向端点同步投递一次 webhook.ping 事件并返回投递结果
+func testWebhookEndpoint(c *gin.Context, system bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	endpoint, err := model.GetWebhookEndpoint(id, c.GetInt("id"), system)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgWebhookNotFound)
+		return
+	}
+	delivery, err := service.SendWebhookPing(endpoint)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, delivery)
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	getWebhookDeliveries(c, false)
+}
+
+func GetSystemWebhookDeliveries(c *gin.Context) {
+	getWebhookDeliveries(c, true)
+}
+
+func getWebhookDeliveries(c *gin.Context, system bool) {
+	pageInfo := common.GetPageQuery(c)
+	endpointId, _ := strconv.Atoi(c.Query("endpoint_id"))
+	status := c.Query("status")
+	deliveries, total, err := model.GetWebhookDeliveries(c.GetInt("id"), system, endpointId, status, pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func RedeliverWebhook(c *gin.Context) {
+	redeliverWebhook(c, false)
+}
+
+func RedeliverSystemWebhook(c *gin.Context) {
+	redeliverWebhook(c, true)
+}
+
+// redeliverWebhook 手动重新投递，使用原事件 ID 与负载生成新的投递记录
+func redeliverWebhook(c *gin.Context, system bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	original, err := model.GetWebhookDelivery(id, c.GetInt("id"), system)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgWebhookNotFound)
+		return
+	}
+	delivery, err := service.RedeliverWebhook(original)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, delivery)
+}
@@ -24,22 +24,51 @@ const (
 )
 
 type ChannelOtherSettings struct {
-	AzureResponsesVersion                 string        `json:"azure_responses_version,omitempty"`
-	VertexKeyType                         VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
-	OpenRouterEnterprise                  *bool         `json:"openrouter_enterprise,omitempty"`
-	ClaudeBetaQuery                       bool          `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
-	AllowServiceTier                      bool          `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
-	AllowInferenceGeo                     bool          `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
-	AllowSafetyIdentifier                 bool          `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
-	DisableStore                          bool          `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
-	AllowIncludeObfuscation               bool          `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
-	AwsKeyType                            AwsKeyType    `json:"aws_key_type,omitempty"`
-	UpstreamModelUpdateCheckEnabled       bool          `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
-	UpstreamModelUpdateAutoSyncEnabled    bool          `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
-	UpstreamModelUpdateLastCheckTime      int64         `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
-	UpstreamModelUpdateLastDetectedModels []string      `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
-	UpstreamModelUpdateLastRemovedModels  []string      `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
-	UpstreamModelUpdateIgnoredModels      []string      `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	AzureResponsesVersion                 string             `json:"azure_responses_version,omitempty"`
+	VertexKeyType                         VertexKeyType      `json:"vertex_key_type,omitempty"` // "json" or "api_key"
+	OpenRouterEnterprise                  *bool              `json:"openrouter_enterprise,omitempty"`
+	ClaudeBetaQuery                       bool               `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
+	AllowServiceTier                      bool               `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
+	AllowInferenceGeo                     bool               `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
+	AllowSafetyIdentifier                 bool               `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
+	DisableStore                          bool               `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
+	AllowIncludeObfuscation               bool               `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
+	AwsKeyType                            AwsKeyType         `json:"aws_key_type,omitempty"`
+	UpstreamModelUpdateCheckEnabled       bool               `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
+	UpstreamModelUpdateAutoSyncEnabled    bool               `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
+	UpstreamModelUpdateLastCheckTime      int64              `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
+	UpstreamModelUpdateLastDetectedModels []string           `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
+	UpstreamModelUpdateLastRemovedModels  []string           `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
+	UpstreamModelUpdateIgnoredModels      []string           `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	CostRatio                             *float64           `json:"cost_ratio,omitempty"`                                 // 上游成本倍率，相对官方价格，如中转 0.3；未设置视为 1
+	CostModelRatio                        map[string]float64 `json:"cost_model_ratio,omitempty"`                           // 按模型配置的上游模型倍率，语义同模型倍率
+	CostModelPrice                        map[string]float64 `json:"cost_model_price,omitempty"`                           // 按模型配置的上游按次价格（美元），优先于上游模型倍率
+}
+
+// GetCostRatio 返回渠道的上游成本倍率，未设置或为负数时按官方价格计为 1
+func (s *ChannelOtherSettings) GetCostRatio() float64 {
+	if s == nil || s.CostRatio == nil || *s.CostRatio < 0 {
+		return 1
+	}
+	return *s.CostRatio
+}
+
+// GetCostModelPrice 返回模型的上游按次价格，未配置时返回 false
+func (s *ChannelOtherSettings) GetCostModelPrice(modelName string) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	price, ok := s.CostModelPrice[modelName]
+	return price, ok && price >= 0
+}
+
+// GetCostModelRatio 返回模型的上游模型倍率，未配置时返回 false
+func (s *ChannelOtherSettings) GetCostModelRatio(modelName string) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	ratio, ok := s.CostModelRatio[modelName]
+	return ratio, ok && ratio >= 0
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
@@ -64,6 +64,20 @@ type LatLng struct {
 	Longitude *float64 `json:"longitude,omitempty"`
 }
 
+// GeminiCountTokensRequest models/{model}:countTokens 的请求体，contents 与 generateContentRequest 二选一
+type GeminiCountTokensRequest struct {
+	Contents               []GeminiChatContent `json:"contents,omitempty"`
+	GenerateContentRequest *GeminiChatRequest  `json:"generateContentRequest,omitempty"`
+}
+
+// ToChatRequest 转换为 GeminiChatRequest 以复用 token 统计逻辑
+func (r *GeminiCountTokensRequest) ToChatRequest() *GeminiChatRequest {
+	if r.GenerateContentRequest != nil {
+		return r.GenerateContentRequest
+	}
+	return &GeminiChatRequest{Contents: r.Contents}
+}
+
 // createGeminiFileSource 根据数据内容创建正确类型的 FileSource
 func createGeminiFileSource(data string, mimeType string) *types.FileSource {
 	if strings.HasPrefix(data, "http://") || strings.HasPrefix(data, "https://") {
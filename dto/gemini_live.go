@@ -0,0 +1,90 @@
+package dto
+
+import "encoding/json"
+
+// Gemini Live API (BidiGenerateContent) WebSocket 消息
+// https://ai.google.dev/api/live
+
+// GeminiLiveClientMessage 客户端发往 Gemini Live 的消息，每条消息只设置其中一个字段
+type GeminiLiveClientMessage struct {
+	Setup         *GeminiLiveSetup         `json:"setup,omitempty"`
+	ClientContent *GeminiLiveClientContent `json:"clientContent,omitempty"`
+	RealtimeInput *GeminiLiveRealtimeInput `json:"realtimeInput,omitempty"`
+	ToolResponse  *GeminiLiveToolResponse  `json:"toolResponse,omitempty"`
+}
+
+// GeminiLiveSetup 会话配置，必须是连接后发送的第一条消息，之后不可修改
+type GeminiLiveSetup struct {
+	Model                    string                      `json:"model"`
+	GenerationConfig         *GeminiChatGenerationConfig `json:"generationConfig,omitempty"`
+	SystemInstruction        *GeminiChatContent          `json:"systemInstruction,omitempty"`
+	Tools                    []GeminiChatTool            `json:"tools,omitempty"`
+	InputAudioTranscription  *struct{}                   `json:"inputAudioTranscription,omitempty"`
+	OutputAudioTranscription *struct{}                   `json:"outputAudioTranscription,omitempty"`
+}
+
+type GeminiLiveClientContent struct {
+	Turns        []GeminiChatContent `json:"turns,omitempty"`
+	TurnComplete bool                `json:"turnComplete"`
+}
+
+type GeminiLiveRealtimeInput struct {
+	Audio          *GeminiInlineData `json:"audio,omitempty"`
+	Text           string            `json:"text,omitempty"`
+	AudioStreamEnd bool              `json:"audioStreamEnd,omitempty"`
+}
+
+type GeminiLiveToolResponse struct {
+	FunctionResponses []GeminiLiveFunctionResponse `json:"functionResponses"`
+}
+
+type GeminiLiveFunctionResponse struct {
+	Id       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// GeminiLiveServerMessage Gemini Live 发往客户端的消息
+type GeminiLiveServerMessage struct {
+	SetupComplete        *struct{}                       `json:"setupComplete,omitempty"`
+	ServerContent        *GeminiLiveServerContent        `json:"serverContent,omitempty"`
+	ToolCall             *GeminiLiveToolCall             `json:"toolCall,omitempty"`
+	ToolCallCancellation *GeminiLiveToolCallCancellation `json:"toolCallCancellation,omitempty"`
+	UsageMetadata        *GeminiLiveUsageMetadata        `json:"usageMetadata,omitempty"`
+	GoAway               json.RawMessage                 `json:"goAway,omitempty"`
+}
+
+type GeminiLiveServerContent struct {
+	ModelTurn           *GeminiChatContent       `json:"modelTurn,omitempty"`
+	TurnComplete        bool                     `json:"turnComplete,omitempty"`
+	Interrupted         bool                     `json:"interrupted,omitempty"`
+	GenerationComplete  bool                     `json:"generationComplete,omitempty"`
+	InputTranscription  *GeminiLiveTranscription `json:"inputTranscription,omitempty"`
+	OutputTranscription *GeminiLiveTranscription `json:"outputTranscription,omitempty"`
+}
+
+type GeminiLiveTranscription struct {
+	Text string `json:"text"`
+}
+
+type GeminiLiveToolCall struct {
+	FunctionCalls []GeminiLiveFunctionCall `json:"functionCalls"`
+}
+
+type GeminiLiveFunctionCall struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Args any    `json:"args"`
+}
+
+type GeminiLiveToolCallCancellation struct {
+	Ids []string `json:"ids"`
+}
+
+type GeminiLiveUsageMetadata struct {
+	PromptTokenCount      int                         `json:"promptTokenCount"`
+	ResponseTokenCount    int                         `json:"responseTokenCount"`
+	TotalTokenCount       int                         `json:"totalTokenCount"`
+	PromptTokensDetails   []GeminiPromptTokensDetails `json:"promptTokensDetails"`
+	ResponseTokensDetails []GeminiPromptTokensDetails `json:"responseTokensDetails"`
+}
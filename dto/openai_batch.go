@@ -0,0 +1,107 @@
+package dto
+
+import "encoding/json"
+
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+type OpenAIBatchRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// OpenAIBatch https://platform.openai.com/docs/api-reference/batch/object
+type OpenAIBatch struct {
+	ID               string                   `json:"id"`
+	Object           string                   `json:"object"`
+	Endpoint         string                   `json:"endpoint"`
+	Errors           *OpenAIBatchErrors       `json:"errors,omitempty"`
+	InputFileID      string                   `json:"input_file_id"`
+	CompletionWindow string                   `json:"completion_window"`
+	Status           string                   `json:"status"`
+	OutputFileID     string                   `json:"output_file_id,omitempty"`
+	ErrorFileID      string                   `json:"error_file_id,omitempty"`
+	CreatedAt        int64                    `json:"created_at"`
+	InProgressAt     int64                    `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64                    `json:"expires_at,omitempty"`
+	FinalizingAt     int64                    `json:"finalizing_at,omitempty"`
+	CompletedAt      int64                    `json:"completed_at,omitempty"`
+	FailedAt         int64                    `json:"failed_at,omitempty"`
+	ExpiredAt        int64                    `json:"expired_at,omitempty"`
+	CancellingAt     int64                    `json:"cancelling_at,omitempty"`
+	CancelledAt      int64                    `json:"cancelled_at,omitempty"`
+	RequestCounts    OpenAIBatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string        `json:"metadata,omitempty"`
+}
+
+type OpenAIBatchErrors struct {
+	Object string             `json:"object"`
+	Data   []OpenAIBatchError `json:"data"`
+}
+
+type OpenAIBatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+type OpenAIBatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type OpenAIBatchList struct {
+	Object  string        `json:"object"`
+	Data    []OpenAIBatch `json:"data"`
+	FirstID string        `json:"first_id,omitempty"`
+	LastID  string        `json:"last_id,omitempty"`
+	HasMore bool          `json:"has_more"`
+}
+
+// OpenAIBatchInputLine 批处理输入文件中的一行
+type OpenAIBatchInputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// OpenAIBatchOutputLine 批处理输出/错误文件中的一行，Billing 为网关扩展字段
+type OpenAIBatchOutputLine struct {
+	ID       string                   `json:"id"`
+	CustomID string                   `json:"custom_id"`
+	Response *OpenAIBatchLineResponse `json:"response"`
+	Error    *OpenAIBatchLineError    `json:"error"`
+	Billing  *OpenAIBatchLineBilling  `json:"billing,omitempty"`
+}
+
+type OpenAIBatchLineResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type OpenAIBatchLineError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type OpenAIBatchLineBilling struct {
+	Model            string  `json:"model,omitempty"`
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	BatchRatio       float64 `json:"batch_ratio"`
+	Quota            int     `json:"quota"`
+}
@@ -344,6 +344,9 @@ type ResponsesOutput struct {
 	CallId    string                   `json:"call_id,omitempty"`
 	Name      string                   `json:"name,omitempty"`
 	Arguments string                   `json:"arguments,omitempty"`
+
+	// reasoning 条目的摘要
+	Summary []ResponsesReasoningSummaryPart `json:"summary,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -384,6 +387,10 @@ type ResponsesStreamResponse struct {
 	SummaryIndex *int                           `json:"summary_index,omitempty"`
 	ItemID       string                         `json:"item_id,omitempty"`
 	Part         *ResponsesReasoningSummaryPart `json:"part,omitempty"`
+
+	// *.done 事件携带的完整文本或参数
+	Text      string `json:"text,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
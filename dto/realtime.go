@@ -8,6 +8,8 @@ const (
 	RealtimeEventTypeConversationCreate = "conversation.item.create"
 	RealtimeEventTypeResponseCreate     = "response.create"
 	RealtimeEventInputAudioBufferAppend = "input_audio_buffer.append"
+	RealtimeEventInputAudioBufferCommit = "input_audio_buffer.commit"
+	RealtimeEventTypeResponseCancel     = "response.cancel"
 )
 
 const (
@@ -19,6 +21,12 @@ const (
 	RealtimeEventResponseFunctionCallArgumentsDelta = "response.function_call_arguments.delta"
 	RealtimeEventResponseFunctionCallArgumentsDone  = "response.function_call_arguments.done"
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
+	RealtimeEventResponseCreated                    = "response.created"
+	RealtimeEventResponseOutputItemAdded            = "response.output_item.added"
+	RealtimeEventResponseOutputItemDone             = "response.output_item.done"
+	RealtimeEventResponseTextDelta                  = "response.text.delta"
+	RealtimeEventInputAudioBufferSpeechStarted      = "input_audio_buffer.speech_started"
+	RealtimeEventInputAudioTranscriptionDelta       = "conversation.item.input_audio_transcription.delta"
 )
 
 type RealtimeEvent struct {
@@ -31,10 +39,21 @@ type RealtimeEvent struct {
 	Response *RealtimeResponse  `json:"response,omitempty"`
 	Delta    string             `json:"delta,omitempty"`
 	Audio    string             `json:"audio,omitempty"`
+
+	// 服务端 response.* 事件关联的响应与条目
+	ResponseId string `json:"response_id,omitempty"`
+	ItemId     string `json:"item_id,omitempty"`
+	CallId     string `json:"call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Arguments  string `json:"arguments,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
 }
 
 type RealtimeResponse struct {
-	Usage *RealtimeUsage `json:"usage"`
+	Id     string         `json:"id,omitempty"`
+	Object string         `json:"object,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Usage  *RealtimeUsage `json:"usage"`
 }
 
 type RealtimeUsage struct {
@@ -79,6 +98,8 @@ type RealtimeItem struct {
 	Name      *string           `json:"name,omitempty"`
 	ToolCalls any               `json:"tool_calls,omitempty"`
 	CallId    string            `json:"call_id,omitempty"`
+	Arguments string            `json:"arguments,omitempty"`
+	Output    string            `json:"output,omitempty"`
 }
 type RealtimeContent struct {
 	Type       string `json:"type"`
@@ -0,0 +1,63 @@
+package dto
+
+// 事件订阅（webhook）支持的事件类型
+const (
+	WebhookEventChannelDisabled      = "channel.disabled"
+	WebhookEventChannelEnabled       = "channel.enabled"
+	WebhookEventTopUpCompleted       = "topup.completed"
+	WebhookEventSubscriptionExpired  = "subscription.expired"
+	WebhookEventTaskFinished         = "task.finished"
+	WebhookEventTokenBudgetExhausted = "token.budget_exhausted"
+	WebhookEventRedemptionUsed       = "redemption.used"
+	// WebhookEventPing 仅用于测试端点连通性，始终投递到被测试的端点
+	WebhookEventPing = "webhook.ping"
+
+	// WebhookEventAll 订阅全部事件
+	WebhookEventAll = "*"
+)
+
+// WebhookEvents 可订阅的事件及是否为系统级事件，系统级事件只投递到管理员创建的系统端点
+var WebhookEvents = []WebhookEventInfo{
+	{Event: WebhookEventChannelDisabled, System: true},
+	{Event: WebhookEventChannelEnabled, System: true},
+	{Event: WebhookEventTopUpCompleted},
+	{Event: WebhookEventSubscriptionExpired},
+	{Event: WebhookEventTaskFinished},
+	{Event: WebhookEventTokenBudgetExhausted},
+	{Event: WebhookEventRedemptionUsed},
+}
+
+type WebhookEventInfo struct {
+	Event  string `json:"event"`
+	System bool   `json:"system"`
+}
+
+func IsValidWebhookEvent(event string) bool {
+	if event == WebhookEventAll {
+		return true
+	}
+	for _, info := range WebhookEvents {
+		if info.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+func IsSystemWebhookEvent(event string) bool {
+	for _, info := range WebhookEvents {
+		if info.Event == event {
+			return info.System
+		}
+	}
+	return false
+}
+
+// WebhookEventPayload 事件投递的请求体，同一事件重试与重新投递时 Id 保持不变，可用于幂等处理
+type WebhookEventPayload struct {
+	Id        string `json:"id"`
+	Type      string `json:"type"`
+	UserId    int    `json:"user_id,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	Data      any    `json:"data"`
+}
@@ -42,6 +42,9 @@ const (
 	MsgTokenExhausted            = "token.exhausted"
 	MsgTokenStatusUnavailable    = "token.status_unavailable"
 	MsgTokenDbError              = "token.db_error"
+	MsgTokenRateLimitInvalid     = "token.rate_limit_invalid"
+	MsgTokenBudgetInvalid        = "token.budget_invalid"
+	MsgTokenOrganizationInvalid  = "token.organization_invalid"
 )
 
 // Redemption related messages
@@ -216,8 +219,11 @@ const (
 
 // Rate limit related messages
 const (
-	MsgRateLimitReached      = "rate_limit.reached"
-	MsgRateLimitTotalReached = "rate_limit.total_reached"
+	MsgRateLimitReached          = "rate_limit.reached"
+	MsgRateLimitTotalReached     = "rate_limit.total_reached"
+	MsgRateLimitTokenRPM         = "rate_limit.token_rpm"
+	MsgRateLimitTokenTPM         = "rate_limit.token_tpm"
+	MsgRateLimitTokenConcurrency = "rate_limit.token_concurrency"
 )
 
 // Setting related messages
@@ -302,6 +308,7 @@ const (
 	MsgDistributorNoAvailableChannel  = "distributor.no_available_channel"
 	MsgDistributorInvalidMidjourney   = "distributor.invalid_midjourney_request"
 	MsgDistributorInvalidParseModel   = "distributor.invalid_request_parse_model"
+	MsgDistributorFileChannelInvalid  = "distributor.file_channel_unavailable"
 )
 
 // Custom OAuth provider related messages
@@ -314,3 +321,13 @@ const (
 	MsgCustomOAuthBindingNotFound   = "custom_oauth.binding_not_found"
 	MsgCustomOAuthProviderIdInvalid = "custom_oauth.provider_id_field_invalid"
 )
+
+// Webhook related messages
+const (
+	MsgWebhookNotFound      = "webhook.not_found"
+	MsgWebhookNameInvalid   = "webhook.name_invalid"
+	MsgWebhookUrlInvalid    = "webhook.url_invalid"
+	MsgWebhookSecretTooLong = "webhook.secret_too_long"
+	MsgWebhookEventsInvalid = "webhook.events_invalid"
+	MsgWebhookEndpointLimit = "webhook.endpoint_limit"
+)
@@ -93,9 +93,15 @@ func main() {
 	// 热更新配置
 	go model.SyncOptions(common.SyncFrequency)
 
+	// 多节点缓存失效广播
+	model.StartCacheInvalidationBus()
+
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 用量预聚合
+	go model.UpdateUsageRollups()
+
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
 		if err != nil {
@@ -112,6 +118,15 @@ func main() {
 	// Subscription quota reset task (daily/weekly/monthly/custom)
 	service.StartSubscriptionQuotaResetTask()
 
+	// Webhook delivery retry task
+	service.StartWebhookDeliveryTask()
+
+	// Payload capture retention cleanup
+	service.StartPayloadCaptureCleanupTask()
+
+	// Monthly balance snapshots for statements
+	service.StartBalanceSnapshotTask()
+
 	// Wire task polling adaptor factory (breaks service -> relay import cycle)
 	service.GetTaskAdaptorFunc = func(platform constant.TaskPlatform) service.TaskPollingAdaptor {
 		a := relay.GetTaskAdaptor(platform)
@@ -121,6 +136,9 @@ func main() {
 		return a
 	}
 
+	// Wire local batch line executor (breaks service -> controller import cycle)
+	service.ExecuteBatchLineFunc = controller.ExecuteBatchLine
+
 	// Channel upstream model update check task
 	controller.StartChannelUpstreamModelUpdateTask()
 
@@ -268,6 +286,17 @@ func InitResources() error {
 		return err
 	}
 
+	// 加密存量敏感数据并迁移到当前主密钥，可在服务运行期间执行
+	if *common.EncryptSecrets {
+		result, err := model.MigrateSecretEncryption()
+		if err != nil {
+			common.FatalLog("failed to encrypt secrets: " + err.Error())
+		}
+		common.SysLog(fmt.Sprintf("secrets encrypted with key %s: channels=%d, oauth_providers=%d, webhooks=%d, options=%d",
+			common.GetSecretActiveKeyId(), result.Channels, result.OAuthProviders, result.Webhooks, result.Options))
+		os.Exit(0)
+	}
+
 	model.CheckSetup()
 
 	// Initialize options, should after model.InitDB()
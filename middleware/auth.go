@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -37,6 +38,7 @@ func authHelper(c *gin.Context, minRole int) {
 	id := session.Get("id")
 	status := session.Get("status")
 	useAccessToken := false
+	var managementKey *model.ManagementKey
 	if username == nil {
 		// Check access token
 		accessToken := c.Request.Header.Get("Authorization")
@@ -48,8 +50,19 @@ func authHelper(c *gin.Context, minRole int) {
 			c.Abort()
 			return
 		}
-		user := model.ValidateAccessToken(accessToken)
-		if user != nil && user.Username != "" {
+		if model.IsManagementKey(strings.TrimSpace(strings.TrimPrefix(accessToken, "Bearer "))) {
+			// 管理密钥：权限范围在角色校验通过后按路由声明的资源检查
+			key, user := authManagementKey(c, accessToken)
+			if key == nil {
+				return
+			}
+			managementKey = key
+			username = user.Username
+			role = user.Role
+			id = user.Id
+			status = user.Status
+			useAccessToken = true
+		} else if user := model.ValidateAccessToken(accessToken); user != nil && user.Username != "" {
 			if !validUserInfo(user.Username, user.Role) {
 				c.JSON(http.StatusOK, gin.H{
 					"success": false,
@@ -125,6 +138,13 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	if managementKey != nil {
+		if !checkManagementKeyScope(c, managementKey) {
+			return
+		}
+		c.Set("management_key_id", managementKey.Id)
+		touchManagementKey(managementKey, c.ClientIP())
+	}
 	// 防止不同newapi版本冲突，导致数据不通用
 	c.Header("Auth-Version", "864b7076dbcd0a3c01b5520316720ebf")
 	c.Set("username", username)
@@ -308,6 +328,11 @@ func TokenAuth() func(c *gin.Context) {
 				c.Set("id", token.UserId)
 			}
 		}
+		if errors.Is(err, model.ErrTokenBudgetExhausted) {
+			service.EmitTokenBudgetExhaustedWebhook(token)
+			abortWithOpenAiMessage(c, http.StatusForbidden, err.Error(), types.ErrorCodeTokenBudgetExhausted)
+			return
+		}
 		if err != nil {
 			abortWithOpenAiMessage(c, http.StatusUnauthorized, err.Error())
 			return
@@ -381,6 +406,12 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	if !token.UnlimitedQuota {
 		c.Set("token_quota", token.RemainQuota)
 	}
+	if token.IsBudgetEnabled() {
+		c.Set("token_budget_remain", token.GetBudgetRemainQuota())
+	}
+	if rateLimit := token.GetRateLimitConfig(); rateLimit != nil {
+		common.SetContextKey(c, constant.ContextKeyTokenRateLimit, rateLimit)
+	}
 	if token.ModelLimitsEnabled {
 		c.Set("token_model_limit_enabled", true)
 		c.Set("token_model_limit", token.GetModelLimitsMap())
@@ -389,6 +420,7 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenOrganizationId, token.OrganizationId)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -36,6 +36,12 @@ func Distribute() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusBadRequest, i18n.T(c, i18n.MsgDistributorInvalidRequest, map[string]any{"Error": err.Error()}))
 			return
 		}
+		// 引用了网关文件 ID 的请求需要改写为上游文件 ID，并固定到文件所在渠道
+		fileRef, err := service.ResolveGatewayFileIds(c)
+		if err != nil {
+			abortWithOpenAiMessage(c, http.StatusBadRequest, i18n.T(c, i18n.MsgDistributorInvalidRequest, map[string]any{"Error": err.Error()}))
+			return
+		}
 		if ok {
 			id, err := strconv.Atoi(channelId.(string))
 			if err != nil {
@@ -51,6 +57,10 @@ func Distribute() func(c *gin.Context) {
 				abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgDistributorChannelDisabled))
 				return
 			}
+			if fileRef != nil && fileRef.ChannelId != channel.Id {
+				abortWithOpenAiMessage(c, http.StatusBadRequest, i18n.T(c, i18n.MsgDistributorFileChannelInvalid, map[string]any{"Group": common.GetContextKeyString(c, constant.ContextKeyUsingGroup), "Model": modelRequest.Model}))
+				return
+			}
 		} else {
 			// Select a channel for the user
 			// check token model mapping
@@ -99,7 +109,15 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 
-				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
+				if fileRef != nil {
+					channel, selectGroup = getFilePinnedChannel(c, fileRef.ChannelId, modelRequest.Model, usingGroup)
+					if channel == nil {
+						abortWithOpenAiMessage(c, http.StatusServiceUnavailable, i18n.T(c, i18n.MsgDistributorFileChannelInvalid, map[string]any{"Group": usingGroup, "Model": modelRequest.Model}))
+						return
+					}
+					// 文件只存在于该渠道，禁止重试到其他渠道
+					c.Set("specific_channel_id", strconv.Itoa(channel.Id))
+				} else if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
 					preferred, err := model.CacheGetChannel(preferredChannelID)
 					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled {
 						if usingGroup == "auto" {
@@ -152,13 +170,52 @@ func Distribute() func(c *gin.Context) {
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
 		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if fileRef != nil && channel != nil {
+			pinFileChannelKey(c, channel, fileRef)
+		}
 		c.Next()
+		ReleaseChannelKey(c)
 		if channel != nil && c.Writer != nil && c.Writer.Status() < http.StatusBadRequest {
 			service.RecordChannelAffinity(c, channel.Id)
 		}
 	}
 }
 
+// getFilePinnedChannel 校验文件所在渠道对当前分组和模型可用，auto 分组下按顺序匹配可用分组
+func getFilePinnedChannel(c *gin.Context, channelId int, modelName string, usingGroup string) (*model.Channel, string) {
+	channel, err := model.CacheGetChannel(channelId)
+	if err != nil || channel == nil || channel.Status != common.ChannelStatusEnabled {
+		return nil, usingGroup
+	}
+	if usingGroup == "auto" {
+		userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+		for _, g := range service.GetUserAutoGroup(userGroup) {
+			if model.IsChannelEnabledForGroupModel(g, modelName, channel.Id) {
+				common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
+				return channel, g
+			}
+		}
+		return nil, usingGroup
+	}
+	if !model.IsChannelEnabledForGroupModel(usingGroup, modelName, channel.Id) {
+		return nil, usingGroup
+	}
+	return channel, usingGroup
+}
+
+// pinFileChannelKey 多密钥渠道中文件只对上传时使用的密钥可见，改用该密钥
+func pinFileChannelKey(c *gin.Context, channel *model.Channel, file *model.File) {
+	if !channel.ChannelInfo.IsMultiKey {
+		return
+	}
+	keys := channel.GetKeys()
+	if file.KeyIndex < 0 || file.KeyIndex >= len(keys) {
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, file.KeyIndex)
+	common.SetContextKey(c, constant.ContextKeyChannelKey, keys[file.KeyIndex])
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -337,6 +394,13 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	return &modelRequest, shouldSelectChannel, nil
 }
 
+// ReleaseChannelKey 释放当前请求占用的多 Key 渠道 key 并发计数，可重复调用
+func ReleaseChannelKey(c *gin.Context) {
+	if release, ok := common.GetContextKeyType[func()](c, constant.ContextKeyChannelKeyRelease); ok && release != nil {
+		release()
+	}
+}
+
 func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) *types.NewAPIError {
 	c.Set("original_model", modelName) // for retry
 	if channel == nil {
@@ -366,9 +430,12 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	if newAPIError != nil {
 		return newAPIError
 	}
+	// 重试切换渠道时先释放上一次选中的 key
+	ReleaseChannelKey(c)
 	if channel.ChannelInfo.IsMultiKey {
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
 		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
+		common.SetContextKey(c, constant.ContextKeyChannelKeyRelease, model.AcquireChannelKey(channel.Id, index))
 	} else {
 		// 必须设置为 false，否则在重试到单个 key 的时候会导致日志显示错误
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, false)
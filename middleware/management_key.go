@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const managementScopeContextKey = "management_scope"
+
+// ManagementScope 声明路由组对应的管理密钥资源范围，需注册在 UserAuth/AdminAuth/RootAuth 之前。
+// 使用管理密钥访问时，GET/HEAD 请求要求 "<资源>:read"，其余请求要求 "<资源>:write"；
+// 未声明资源范围的路由一律拒绝管理密钥访问。
+func ManagementScope(resource string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		c.Set(managementScopeContextKey, resource)
+		c.Next()
+	}
+}
+
+func abortManagementKey(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": message,
+	})
+	c.Abort()
+}
+
+// authManagementKey 校验管理密钥本身（状态、有效期、IP 白名单），成功时返回密钥与所属用户
+func authManagementKey(c *gin.Context, rawKey string) (*model.ManagementKey, *model.User) {
+	key, err := model.ValidateManagementKey(rawKey)
+	if err != nil {
+		switch err {
+		case model.ErrManagementKeyDisabled:
+			abortManagementKey(c, http.StatusUnauthorized, "无权进行此操作，管理密钥已被禁用")
+		case model.ErrManagementKeyExpired:
+			abortManagementKey(c, http.StatusUnauthorized, "无权进行此操作，管理密钥已过期")
+		default:
+			abortManagementKey(c, http.StatusUnauthorized, "无权进行此操作，管理密钥无效")
+		}
+		return nil, nil
+	}
+	if allowIps := key.GetAllowIps(); len(allowIps) > 0 {
+		ip := net.ParseIP(c.ClientIP())
+		if ip == nil || !common.IsIpInCIDRList(ip, allowIps) {
+			abortManagementKey(c, http.StatusForbidden, "无权进行此操作，您的 IP 不在管理密钥允许访问的列表中")
+			return nil, nil
+		}
+	}
+	user, err := model.GetUserById(key.UserId, false)
+	if err != nil {
+		abortManagementKey(c, http.StatusUnauthorized, "无权进行此操作，管理密钥无效")
+		return nil, nil
+	}
+	return key, user
+}
+
+// checkManagementKeyScope 校验管理密钥是否拥有当前路由声明的资源权限
+func checkManagementKeyScope(c *gin.Context, key *model.ManagementKey) bool {
+	resource := c.GetString(managementScopeContextKey)
+	if resource == "" {
+		abortManagementKey(c, http.StatusForbidden, "无权进行此操作，该接口不支持管理密钥访问")
+		return false
+	}
+	write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+	if !key.HasScope(resource, write) {
+		abortManagementKey(c, http.StatusForbidden, "无权进行此操作，管理密钥未授予该接口的权限范围")
+		return false
+	}
+	if resource == model.ManagementScopeChannels {
+		if tags := key.GetChannelTags(); len(tags) > 0 && !checkManagementKeyChannelTags(c, tags) {
+			abortManagementKey(c, http.StatusForbidden, "无权进行此操作，管理密钥仅能管理指定标签的渠道")
+			return false
+		}
+	}
+	return true
+}
+
+// 限定标签的密钥可访问的、路径中不带渠道 id 的接口，按确定渠道范围的请求字段分类
+const (
+	channelTagScopeQueryTag = iota + 1 // 查询参数 tag
+	channelTagScopeBodyTag             // 请求体 tag（及 new_tag）
+	channelTagScopeBodyId              // 请求体 id（及 tag）
+	channelTagScopeBodyIds             // 请求体 ids（及 tag）
+)
+
+// managementKeyChannelTagRoutes 仅列出确实按上述字段筛选渠道的接口，
+// 忽略 tag 的接口（如全量列表、搜索、全部测试）即使带上 tag 参数也不能访问
+var managementKeyChannelTagRoutes = map[string]int{
+	"GET /api/channel/tag/models":    channelTagScopeQueryTag,
+	"POST /api/channel/tag/disabled": channelTagScopeBodyTag,
+	"POST /api/channel/tag/enabled":  channelTagScopeBodyTag,
+	"PUT /api/channel/tag":           channelTagScopeBodyTag,
+	"PUT /api/channel/":              channelTagScopeBodyId,
+	"POST /api/channel/batch":        channelTagScopeBodyIds,
+	"POST /api/channel/batch/tag":    channelTagScopeBodyIds,
+}
+
+// checkManagementKeyChannelTags 限定标签的密钥只能操作可确定标签的渠道接口：
+// 路径中带渠道 id 的接口校验该渠道的标签，managementKeyChannelTagRoutes 中的接口校验请求中的 tag、id 或 ids，
+// 其余无法确定范围的接口（如全量列表、新增渠道）一律拒绝
+func checkManagementKeyChannelTags(c *gin.Context, tags []string) bool {
+	allowed := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		allowed[tag] = true
+	}
+	if idStr := c.Param("id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return false
+		}
+		return channelTagAllowed(id, allowed)
+	}
+	scope := managementKeyChannelTagRoutes[c.Request.Method+" "+c.FullPath()]
+	if scope == 0 {
+		return false
+	}
+	if scope == channelTagScopeQueryTag {
+		return allowed[c.Query("tag")]
+	}
+	var body struct {
+		Id     int     `json:"id"`
+		Ids    []int   `json:"ids"`
+		Tag    *string `json:"tag"`
+		NewTag *string `json:"new_tag"`
+	}
+	if err := common.UnmarshalBodyReusable(c, &body); err != nil {
+		return false
+	}
+	switch scope {
+	case channelTagScopeBodyTag:
+		if body.NewTag != nil && *body.NewTag != "" && !allowed[*body.NewTag] {
+			return false
+		}
+		return body.Tag != nil && allowed[*body.Tag]
+	case channelTagScopeBodyId:
+		if body.Id <= 0 || !channelTagAllowed(body.Id, allowed) {
+			return false
+		}
+	case channelTagScopeBodyIds:
+		if len(body.Ids) == 0 {
+			return false
+		}
+		for _, id := range body.Ids {
+			if !channelTagAllowed(id, allowed) {
+				return false
+			}
+		}
+	}
+	return body.Tag == nil || allowed[*body.Tag]
+}
+
+func channelTagAllowed(channelId int, allowed map[string]bool) bool {
+	channel, err := model.GetChannelById(channelId, false)
+	if err != nil || channel.Tag == nil {
+		return false
+	}
+	return allowed[*channel.Tag]
+}
+
+func touchManagementKey(key *model.ManagementKey, ip string) {
+	gopool.Go(func() {
+		if err := model.TouchManagementKey(key, ip); err != nil {
+			common.SysLog("failed to update management key last used: " + err.Error())
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckManagementKeyChannelTagsRouteAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := func(c *gin.Context) {
+		if !checkManagementKeyChannelTags(c, []string{"team-a"}) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+	channelRoute := router.Group("/api/channel")
+	channelRoute.GET("/", handler)
+	channelRoute.GET("/search", handler)
+	channelRoute.GET("/test", handler)
+	channelRoute.DELETE("/disabled", handler)
+	channelRoute.POST("/fix", handler)
+	channelRoute.GET("/tag/models", handler)
+	channelRoute.POST("/tag/disabled", handler)
+	channelRoute.PUT("/tag", handler)
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		// 忽略 tag 筛选的接口即使带上允许的 tag 也拒绝
+		{http.MethodGet, "/api/channel/?tag=team-a", "", http.StatusForbidden},
+		{http.MethodGet, "/api/channel/search?tag=team-a", "", http.StatusForbidden},
+		{http.MethodGet, "/api/channel/test?tag=team-a", "", http.StatusForbidden},
+		{http.MethodDelete, "/api/channel/disabled?tag=team-a", "", http.StatusForbidden},
+		{http.MethodPost, "/api/channel/fix?tag=team-a", `{"tag":"team-a"}`, http.StatusForbidden},
+		{http.MethodGet, "/api/channel/tag/models?tag=team-a", "", http.StatusOK},
+		{http.MethodGet, "/api/channel/tag/models?tag=team-b", "", http.StatusForbidden},
+		{http.MethodPost, "/api/channel/tag/disabled?tag=team-a", `{"tag":"team-b"}`, http.StatusForbidden},
+		{http.MethodPost, "/api/channel/tag/disabled", `{"tag":"team-a"}`, http.StatusOK},
+		{http.MethodPut, "/api/channel/tag", `{"tag":"team-a","new_tag":"team-b"}`, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(recorder, req)
+		require.Equal(t, tc.status, recorder.Code, "%s %s", tc.method, tc.target)
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth 校验 Prometheus 抓取请求：Bearer 令牌匹配或客户端 IP 位于白名单内即可通过
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if constant.MetricsToken != "" {
+			token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(constant.MetricsToken)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		if len(constant.MetricsAllowedIps) > 0 {
+			ip := net.ParseIP(c.ClientIP())
+			if ip != nil && common.IsIpInCIDRList(ip, constant.MetricsAllowedIps) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
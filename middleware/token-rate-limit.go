@@ -0,0 +1,287 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 令牌桶按秒补充令牌，每分钟限额 N 对应容量 N*60、速率 N/s、每次请求消耗 60
+const tokenRateLimitWindowSeconds = 60
+
+// 并发计数的兜底过期时间，防止进程异常退出后计数无法归还
+const tokenConcurrencyKeyExpiration = 10 * time.Minute
+
+var (
+	tokenMemoryLimiter     = limiter.NewMemoryLimiter()
+	tokenMemoryConcurrency = struct {
+		sync.Mutex
+		counts map[string]int
+	}{counts: make(map[string]int)}
+)
+
+// tokenRateLimitScope 一组独立计数的限流规则：令牌级或令牌下的单个模型
+type tokenRateLimitScope struct {
+	key   string
+	model string
+	limit model.TokenRateLimit
+}
+
+// tokenRateLimitStatus 限流检查结果，用于生成 x-ratelimit-* 响应头
+type tokenRateLimitStatus struct {
+	limit      int
+	remaining  int64
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func (s *tokenRateLimitStatus) tighterThan(other *tokenRateLimitStatus) bool {
+	return other == nil || s.remaining < other.remaining
+}
+
+func tokenBucketOptions(limitPerMinute int, requested int64, force bool) []limiter.Option {
+	opts := []limiter.Option{
+		limiter.WithCapacity(int64(limitPerMinute) * tokenRateLimitWindowSeconds),
+		limiter.WithRate(int64(limitPerMinute)),
+		limiter.WithRequested(requested * tokenRateLimitWindowSeconds),
+	}
+	if force {
+		opts = append(opts, limiter.WithForce())
+	}
+	return opts
+}
+
+func takeTokenBucket(ctx context.Context, key string, opts []limiter.Option) (limiter.Result, error) {
+	if common.RedisEnabled {
+		return limiter.New(ctx, common.RDB).Take(ctx, key, opts...)
+	}
+	return tokenMemoryLimiter.Take(key, opts...), nil
+}
+
+// newTokenBucketStatus 将桶内剩余令牌换算为每分钟限额下的剩余量与重置时间，
+// needed 为下次放行所需的桶内令牌数
+func newTokenBucketStatus(limitPerMinute int, result limiter.Result, needed int64) *tokenRateLimitStatus {
+	capacity := int64(limitPerMinute) * tokenRateLimitWindowSeconds
+	status := &tokenRateLimitStatus{
+		limit:     limitPerMinute,
+		remaining: max(result.Remaining/tokenRateLimitWindowSeconds, 0),
+		reset:     time.Duration(math.Ceil(float64(capacity-result.Remaining)/float64(limitPerMinute))) * time.Second,
+	}
+	if !result.Allowed || result.Remaining < needed {
+		wait := math.Ceil(float64(needed-result.Remaining) / float64(limitPerMinute))
+		status.retryAfter = time.Duration(max(wait, 1)) * time.Second
+	}
+	return status
+}
+
+func acquireTokenConcurrency(ctx context.Context, key string, limit int) (bool, error) {
+	if common.RedisEnabled {
+		count, err := common.RDB.Incr(ctx, key).Result()
+		if err != nil {
+			return false, err
+		}
+		common.RDB.Expire(ctx, key, tokenConcurrencyKeyExpiration)
+		if count > int64(limit) {
+			common.RDB.Decr(ctx, key)
+			return false, nil
+		}
+		return true, nil
+	}
+	tokenMemoryConcurrency.Lock()
+	defer tokenMemoryConcurrency.Unlock()
+	if tokenMemoryConcurrency.counts[key] >= limit {
+		return false, nil
+	}
+	tokenMemoryConcurrency.counts[key]++
+	return true, nil
+}
+
+func releaseTokenConcurrency(ctx context.Context, key string) {
+	if common.RedisEnabled {
+		if err := common.RDB.Decr(ctx, key).Err(); err != nil {
+			common.SysLog("failed to release token concurrency: " + err.Error())
+		}
+		return
+	}
+	tokenMemoryConcurrency.Lock()
+	defer tokenMemoryConcurrency.Unlock()
+	if tokenMemoryConcurrency.counts[key] <= 1 {
+		delete(tokenMemoryConcurrency.counts, key)
+		return
+	}
+	tokenMemoryConcurrency.counts[key]--
+}
+
+func formatRateLimitReset(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func setTokenRateLimitHeaders(c *gin.Context, requests, tokens *tokenRateLimitStatus) {
+	if requests != nil {
+		c.Header("x-ratelimit-limit-requests", strconv.Itoa(requests.limit))
+		c.Header("x-ratelimit-remaining-requests", strconv.FormatInt(requests.remaining, 10))
+		c.Header("x-ratelimit-reset-requests", formatRateLimitReset(requests.reset))
+	}
+	if tokens != nil {
+		c.Header("x-ratelimit-limit-tokens", strconv.Itoa(tokens.limit))
+		c.Header("x-ratelimit-remaining-tokens", strconv.FormatInt(tokens.remaining, 10))
+		c.Header("x-ratelimit-reset-tokens", formatRateLimitReset(tokens.reset))
+	}
+}
+
+func abortWithTokenRateLimit(c *gin.Context, retryAfter time.Duration, message string) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	abortWithOpenAiMessage(c, http.StatusTooManyRequests, message, types.ErrorCodeTokenRateLimitExceeded)
+}
+
+func tokenRateLimitScopeText(scope tokenRateLimitScope) string {
+	if scope.model == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", scope.model)
+}
+
+// getTokenRateLimitScopes 返回本次请求适用的限流规则，按模型的规则仅在令牌配置了该模型时解析请求体
+func getTokenRateLimitScopes(c *gin.Context, cfg *model.TokenRateLimitConfig) []tokenRateLimitScope {
+	tokenId := strconv.Itoa(c.GetInt("token_id"))
+	scopes := make([]tokenRateLimitScope, 0, 2)
+	if !cfg.TokenRateLimit.IsEmpty() {
+		scopes = append(scopes, tokenRateLimitScope{key: tokenId, limit: cfg.TokenRateLimit})
+	}
+	if len(cfg.Models) == 0 {
+		return scopes
+	}
+	modelRequest, _, err := getModelRequest(c)
+	if err != nil || modelRequest == nil || modelRequest.Model == "" {
+		return scopes
+	}
+	if limit, ok := cfg.Models[modelRequest.Model]; ok {
+		scopes = append(scopes, tokenRateLimitScope{
+			key:   tokenId + ":" + modelRequest.Model,
+			model: modelRequest.Model,
+			limit: limit,
+		})
+	}
+	return scopes
+}
+
+// TokenRateLimit 令牌级 RPM / TPM / 并发限流中间件。
+// RPM 在请求前扣减；TPM 在请求前检查余量，请求结束后按实际用量扣减；
+// 超限时返回 429 以及 x-ratelimit-* 与 Retry-After 响应头
+func TokenRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		value, ok := common.GetContextKey(c, constant.ContextKeyTokenRateLimit)
+		if !ok {
+			c.Next()
+			return
+		}
+		cfg, ok := value.(*model.TokenRateLimitConfig)
+		if !ok || cfg.IsEmpty() {
+			c.Next()
+			return
+		}
+		scopes := getTokenRateLimitScopes(c, cfg)
+		if len(scopes) == 0 {
+			c.Next()
+			return
+		}
+
+		ctx := context.Background()
+		var acquired []string
+		defer func() {
+			for _, key := range acquired {
+				releaseTokenConcurrency(ctx, key)
+			}
+		}()
+
+		var requests, tokens *tokenRateLimitStatus
+		for _, scope := range scopes {
+			scopeText := tokenRateLimitScopeText(scope)
+			if scope.limit.Concurrency > 0 {
+				key := "tokenRateLimit:concurrency:" + scope.key
+				allowed, err := acquireTokenConcurrency(ctx, key, scope.limit.Concurrency)
+				if err != nil {
+					common.SysLog("token concurrency limit check failed: " + err.Error())
+					abortWithOpenAiMessage(c, http.StatusInternalServerError, "rate_limit_check_failed")
+					return
+				}
+				if !allowed {
+					abortWithTokenRateLimit(c, time.Second, i18n.T(c, i18n.MsgRateLimitTokenConcurrency, map[string]any{
+						"Max": scope.limit.Concurrency, "Scope": scopeText,
+					}))
+					return
+				}
+				acquired = append(acquired, key)
+			}
+			if scope.limit.RPM > 0 {
+				result, err := takeTokenBucket(ctx, "tokenRateLimit:rpm:"+scope.key, tokenBucketOptions(scope.limit.RPM, 1, false))
+				if err != nil {
+					common.SysLog("token rpm limit check failed: " + err.Error())
+					abortWithOpenAiMessage(c, http.StatusInternalServerError, "rate_limit_check_failed")
+					return
+				}
+				status := newTokenBucketStatus(scope.limit.RPM, result, tokenRateLimitWindowSeconds)
+				if status.tighterThan(requests) {
+					requests = status
+				}
+				if !result.Allowed {
+					setTokenRateLimitHeaders(c, requests, tokens)
+					abortWithTokenRateLimit(c, status.retryAfter, i18n.T(c, i18n.MsgRateLimitTokenRPM, map[string]any{
+						"Max": scope.limit.RPM, "Scope": scopeText, "Seconds": int(status.retryAfter.Seconds()),
+					}))
+					return
+				}
+			}
+			if scope.limit.TPM > 0 {
+				// 仅查询余量，实际用量在请求结束后扣减，余量耗尽（可能因结算变为负数）时拒绝
+				result, err := takeTokenBucket(ctx, "tokenRateLimit:tpm:"+scope.key, tokenBucketOptions(scope.limit.TPM, 0, false))
+				if err != nil {
+					common.SysLog("token tpm limit check failed: " + err.Error())
+					abortWithOpenAiMessage(c, http.StatusInternalServerError, "rate_limit_check_failed")
+					return
+				}
+				status := newTokenBucketStatus(scope.limit.TPM, result, 1)
+				if status.tighterThan(tokens) {
+					tokens = status
+				}
+				if result.Remaining <= 0 {
+					setTokenRateLimitHeaders(c, requests, tokens)
+					abortWithTokenRateLimit(c, status.retryAfter, i18n.T(c, i18n.MsgRateLimitTokenTPM, map[string]any{
+						"Max": scope.limit.TPM, "Scope": scopeText, "Seconds": int(status.retryAfter.Seconds()),
+					}))
+					return
+				}
+			}
+		}
+		setTokenRateLimitHeaders(c, requests, tokens)
+
+		c.Next()
+
+		consumed := common.GetContextKeyInt(c, constant.ContextKeyConsumedTokens)
+		if consumed <= 0 {
+			return
+		}
+		for _, scope := range scopes {
+			if scope.limit.TPM <= 0 {
+				continue
+			}
+			_, err := takeTokenBucket(ctx, "tokenRateLimit:tpm:"+scope.key, tokenBucketOptions(scope.limit.TPM, int64(consumed), true))
+			if err != nil {
+				common.SysLog("token tpm limit settle failed: " + err.Error())
+			}
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/samber/lo"
 	"gorm.io/gorm"
@@ -120,7 +121,13 @@ func GetChannel(group string, model string, retry int) (*Channel, error) {
 		return nil, err
 	}
 	channel := Channel{}
-	if len(abilities) > 0 {
+	if strategy := operation_setting.GetChannelSelectStrategy(group, model); len(abilities) > 0 && strategy != operation_setting.ChannelSelectStrategyWeighted {
+		candidates := make([]channelCandidate, len(abilities))
+		for i, ability_ := range abilities {
+			candidates[i] = channelCandidate{channelId: ability_.ChannelId, weight: int(ability_.Weight)}
+		}
+		channel.Id = abilities[selectChannelByHealth(strategy, candidates)].ChannelId
+	} else if len(abilities) > 0 {
 		// Randomly choose one
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
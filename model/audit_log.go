@@ -0,0 +1,250 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditTargetChannel          = "channel"
+	AuditTargetOption           = "option"
+	AuditTargetUser             = "user"
+	AuditTargetToken            = "token"
+	AuditTargetSubscriptionPlan = "subscription_plan"
+	AuditTargetUserSubscription = "user_subscription"
+	AuditTargetRedemption       = "redemption"
+	AuditTargetOrganization     = "organization"
+
+	auditRedacted = "[REDACTED]"
+	// 并发写入时 prev_hash 唯一约束冲突的重试次数
+	auditInsertRetries = 5
+	auditVerifyBatch   = 500
+)
+
+// AuditLog 管理操作审计记录。每条记录的 Hash 由上一条记录的 Hash 与本条内容计算得出，
+// prev_hash 唯一保证链不会分叉，篡改或删除中间任意一条都会导致之后的校验失败；
+// 末尾记录被整体删除无法仅凭链本身发现，需要结合导出的备份比对
+type AuditLog struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ActorId    int    `json:"actor_id" gorm:"index"`
+	ActorName  string `json:"actor_name" gorm:"type:varchar(64);index;default:''"`
+	Ip         string `json:"ip" gorm:"type:varchar(64);default:''"`
+	Action     string `json:"action" gorm:"type:varchar(64);index"`
+	TargetType string `json:"target_type" gorm:"type:varchar(32);index"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(128);index;default:''"`
+	Diff       string `json:"diff" gorm:"type:text"`
+	PrevHash   string `json:"prev_hash" gorm:"type:varchar(64);uniqueIndex"`
+	Hash       string `json:"hash" gorm:"type:varchar(64)"`
+}
+
+type AuditDiffEntry struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// computeHash 按固定顺序拼接字段后计算 sha256，字段中的换行由 JSON 编码转义
+func (log *AuditLog) computeHash() string {
+	content, _ := common.Marshal([]any{
+		log.PrevHash, log.CreatedAt, log.ActorId, log.ActorName, log.Ip,
+		log.Action, log.TargetType, log.TargetId, log.Diff,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// isAuditSensitiveField 判断字段是否为密钥类字段，此类字段在差异中只记录是否变化。
+// 渠道的请求头覆盖常用于注入鉴权头，一并脱敏
+func isAuditSensitiveField(field string) bool {
+	name := strings.ToLower(field)
+	if name == "header_override" {
+		return true
+	}
+	for _, suffix := range []string{"key", "secret", "password", "token", "credential", "credentials"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func auditFields(value any) map[string]any {
+	fields := make(map[string]any)
+	if value == nil {
+		return fields
+	}
+	data, err := common.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	if err := common.Unmarshal(data, &fields); err != nil {
+		// 非对象类型整体作为 value 字段比较
+		var raw any
+		_ = common.Unmarshal(data, &raw)
+		fields["value"] = raw
+	}
+	return fields
+}
+
+// BuildAuditDiff 对比操作前后的对象，只保留发生变化的字段，密钥类字段替换为 [REDACTED]
+func BuildAuditDiff(before any, after any) map[string]AuditDiffEntry {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+	diff := make(map[string]AuditDiffEntry)
+	for field, afterValue := range afterFields {
+		beforeValue, ok := beforeFields[field]
+		if ok && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		diff[field] = AuditDiffEntry{Before: beforeValue, After: afterValue}
+	}
+	for field, beforeValue := range beforeFields {
+		if _, ok := afterFields[field]; !ok {
+			diff[field] = AuditDiffEntry{Before: beforeValue}
+		}
+	}
+	for field, entry := range diff {
+		if !isAuditSensitiveField(field) {
+			continue
+		}
+		if entry.Before != nil && entry.Before != "" {
+			entry.Before = auditRedacted
+		}
+		if entry.After != nil && entry.After != "" {
+			entry.After = auditRedacted
+		}
+		diff[field] = entry
+	}
+	return diff
+}
+
+func insertAuditLog(log *AuditLog) error {
+	var err error
+	for i := 0; i < auditInsertRetries; i++ {
+		var last AuditLog
+		if err = DB.Select("hash").Order("id desc").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		log.Id = 0
+		log.PrevHash = last.Hash
+		log.Hash = log.computeHash()
+		if err = DB.Create(log).Error; err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// RecordAuditLog 记录一次管理操作，before/after 为操作前后的对象，新建时 before 为 nil，删除时 after 为 nil。
+// 写入失败只记录系统日志，不影响操作本身
+func RecordAuditLog(c *gin.Context, action string, targetType string, targetId any, before any, after any) {
+	diff := ""
+	if entries := BuildAuditDiff(before, after); len(entries) > 0 {
+		diff = common.GetJsonString(entries)
+	}
+	log := &AuditLog{
+		CreatedAt:  common.GetTimestamp(),
+		ActorId:    c.GetInt("id"),
+		ActorName:  c.GetString("username"),
+		Ip:         c.ClientIP(),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   fmt.Sprintf("%v", targetId),
+		Diff:       diff,
+	}
+	if err := insertAuditLog(log); err != nil {
+		common.SysError(fmt.Sprintf("failed to record audit log: action=%s, target=%s#%s, error=%v", action, targetType, log.TargetId, err))
+	}
+}
+
+type AuditLogQuery struct {
+	Action         string
+	TargetType     string
+	TargetId       string
+	ActorName      string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func (query *AuditLogQuery) apply() *gorm.DB {
+	tx := DB.Model(&AuditLog{})
+	if query.Action != "" {
+		tx = tx.Where("action = ?", query.Action)
+	}
+	if query.TargetType != "" {
+		tx = tx.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetId != "" {
+		tx = tx.Where("target_id = ?", query.TargetId)
+	}
+	if query.ActorName != "" {
+		tx = tx.Where("actor_name = ?", query.ActorName)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	return tx
+}
+
+func GetAuditLogs(query *AuditLogQuery, pageInfo *common.PageInfo) (logs []*AuditLog, total int64, err error) {
+	tx := query.apply()
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&logs).Error
+	return logs, total, err
+}
+
+// ExportAuditLogs 按 id 升序导出，最多 limit 条
+func ExportAuditLogs(query *AuditLogQuery, limit int) (logs []*AuditLog, err error) {
+	err = query.apply().Order("id asc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+// AuditChainResult 审计链校验结果，BrokenId 为第一条校验失败的记录
+type AuditChainResult struct {
+	Checked  int    `json:"checked"`
+	Valid    bool   `json:"valid"`
+	BrokenId int    `json:"broken_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifyAuditLogChain 按 id 顺序重新计算每条记录的 Hash 并检查与上一条的链接关系
+func VerifyAuditLogChain() (*AuditChainResult, error) {
+	result := &AuditChainResult{Valid: true}
+	prevHash := ""
+	lastId := 0
+	for {
+		var logs []*AuditLog
+		if err := DB.Where("id > ?", lastId).Order("id asc").Limit(auditVerifyBatch).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			result.Checked++
+			if log.PrevHash != prevHash {
+				result.Valid, result.BrokenId, result.Reason = false, log.Id, "前一条记录缺失或被修改"
+				return result, nil
+			}
+			if log.computeHash() != log.Hash {
+				result.Valid, result.BrokenId, result.Reason = false, log.Id, "记录内容被修改"
+				return result, nil
+			}
+			prevHash = log.Hash
+			lastId = log.Id
+		}
+		if len(logs) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildAuditDiff(t *testing.T) {
+	before := map[string]any{"name": "a", "key": "sk-old", "priority": 1, "header_override": `{"Authorization":"x"}`}
+	after := map[string]any{"name": "b", "key": "sk-new", "priority": 1}
+
+	diff := BuildAuditDiff(before, after)
+	require.Len(t, diff, 3)
+	require.Equal(t, AuditDiffEntry{Before: "a", After: "b"}, diff["name"])
+	require.Equal(t, AuditDiffEntry{Before: auditRedacted, After: auditRedacted}, diff["key"])
+	require.Equal(t, AuditDiffEntry{Before: auditRedacted}, diff["header_override"])
+	require.NotContains(t, diff, "priority")
+}
+
+func TestAuditLogHashChain(t *testing.T) {
+	require.NoError(t, DB.AutoMigrate(&AuditLog{}))
+	t.Cleanup(func() { DB.Exec("DELETE FROM audit_logs") })
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("PUT", "/api/option/", nil)
+	c.Set("id", 1)
+	c.Set("username", "root")
+
+	RecordAuditLog(c, "option.update", AuditTargetOption, "ModelRatio", map[string]any{"ModelRatio.gpt-4o": 1.0}, map[string]any{"ModelRatio.gpt-4o": 2.0})
+	RecordAuditLog(c, "channel.delete", AuditTargetChannel, 3, map[string]any{"name": "c"}, nil)
+	RecordAuditLog(c, "token.delete", AuditTargetToken, 5, nil, nil)
+
+	logs, err := ExportAuditLogs(&AuditLogQuery{}, 10)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	require.Equal(t, "", logs[0].PrevHash)
+	require.Equal(t, logs[0].Hash, logs[1].PrevHash)
+	require.Equal(t, "root", logs[1].ActorName)
+
+	result, err := VerifyAuditLogChain()
+	require.NoError(t, err)
+	require.True(t, result.Valid)
+	require.Equal(t, 3, result.Checked)
+
+	// 修改中间一条记录的内容
+	require.NoError(t, DB.Model(&AuditLog{}).Where("id = ?", logs[1].Id).Update("target_id", "4").Error)
+	result, err = VerifyAuditLogChain()
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, logs[1].Id, result.BrokenId)
+
+	// 删除中间一条记录
+	require.NoError(t, DB.Delete(&AuditLog{}, logs[1].Id).Error)
+	result, err = VerifyAuditLogChain()
+	require.NoError(t, err)
+	require.False(t, result.Valid)
+	require.Equal(t, logs[2].Id, result.BrokenId)
+}
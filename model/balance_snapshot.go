@@ -0,0 +1,97 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BalanceSnapshot 每月初记录的用户余额与有限额度令牌的剩余额度，用作月度对账单的期初余额。
+// UserId 为 0 的记录标记该月快照已全部写完
+type BalanceSnapshot struct {
+	Id        int    `json:"id"`
+	Period    string `json:"period" gorm:"type:varchar(7);uniqueIndex:idx_balance_snapshot,priority:1"`
+	UserId    int    `json:"user_id" gorm:"uniqueIndex:idx_balance_snapshot,priority:2"`
+	TokenId   int    `json:"token_id" gorm:"default:0;uniqueIndex:idx_balance_snapshot,priority:3"`
+	Quota     int64  `json:"quota"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+// IsBalanceSnapshotDone 返回该月快照是否已完成
+func IsBalanceSnapshotDone(period string) (bool, error) {
+	var count int64
+	err := DB.Model(&BalanceSnapshot{}).Where("period = ? AND user_id = 0", period).Count(&count).Error
+	return count > 0, err
+}
+
+// MarkBalanceSnapshotDone 写入完成标记
+func MarkBalanceSnapshotDone(period string) error {
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&BalanceSnapshot{
+		Period:    period,
+		CreatedAt: common.GetTimestamp(),
+	}).Error
+}
+
+// SnapshotUserBalances 记录 id 大于 afterId 的一批用户余额，返回本批最后一个用户 id 与数量，已存在的快照不覆盖
+func SnapshotUserBalances(period string, afterId int, limit int) (lastId int, n int, err error) {
+	var users []struct {
+		Id    int
+		Quota int64
+	}
+	if err = DB.Model(&User{}).Select("id, quota").Where("id > ?", afterId).Order("id asc").Limit(limit).Find(&users).Error; err != nil {
+		return afterId, 0, err
+	}
+	if len(users) == 0 {
+		return afterId, 0, nil
+	}
+	now := common.GetTimestamp()
+	snapshots := make([]BalanceSnapshot, 0, len(users))
+	for _, user := range users {
+		snapshots = append(snapshots, BalanceSnapshot{Period: period, UserId: user.Id, Quota: user.Quota, CreatedAt: now})
+	}
+	if err = DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&snapshots).Error; err != nil {
+		return afterId, 0, err
+	}
+	return users[len(users)-1].Id, len(users), nil
+}
+
+// SnapshotTokenBalances 记录 id 大于 afterId 的一批有限额度令牌的剩余额度
+func SnapshotTokenBalances(period string, afterId int, limit int) (lastId int, n int, err error) {
+	var tokens []struct {
+		Id          int
+		UserId      int
+		RemainQuota int64
+	}
+	if err = DB.Model(&Token{}).Select("id, user_id, remain_quota").Where("id > ? AND unlimited_quota = ?", afterId, false).
+		Order("id asc").Limit(limit).Find(&tokens).Error; err != nil {
+		return afterId, 0, err
+	}
+	if len(tokens) == 0 {
+		return afterId, 0, nil
+	}
+	now := common.GetTimestamp()
+	snapshots := make([]BalanceSnapshot, 0, len(tokens))
+	for _, token := range tokens {
+		snapshots = append(snapshots, BalanceSnapshot{Period: period, UserId: token.UserId, TokenId: token.Id, Quota: token.RemainQuota, CreatedAt: now})
+	}
+	if err = DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&snapshots).Error; err != nil {
+		return afterId, 0, err
+	}
+	return tokens[len(tokens)-1].Id, len(tokens), nil
+}
+
+// GetBalanceSnapshot 查询用户或令牌在某月初的快照，不存在时返回 nil
+func GetBalanceSnapshot(period string, userId int, tokenId int) (*BalanceSnapshot, error) {
+	var snapshot BalanceSnapshot
+	err := DB.Where("period = ? AND user_id = ? AND token_id = ?", period, userId, tokenId).First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,204 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// 缓存失效广播：某个节点修改渠道、配置等数据后，通知其他节点立即刷新本地缓存，
+// 不必等待 SYNC_FREQUENCY 的定时同步（定时同步仍保留作为兜底）。
+// 启用 Redis 时通过 Redis pub/sub 广播；未启用 Redis 时可设置 CACHE_INVALIDATION_DB_FEED=true，
+// 通过数据库表 cache_invalidations 轮询广播。
+// 令牌与用户缓存存放在 Redis 中由所有节点共享，未启用 Redis 时直接读取数据库，因此无需广播
+const (
+	CacheInvalidationChannel         = "channel"          // 渠道配置变化，重新加载渠道缓存
+	CacheInvalidationChannelStatus   = "channel_status"   // 渠道状态变化，Id 为渠道，Status 为新状态
+	CacheInvalidationOption          = "option"           // 配置项变化，Key 为配置项
+	CacheInvalidationChannelAffinity = "channel_affinity" // 渠道亲和缓存清空，Key 为规则名称，为空表示全部
+	CacheInvalidationSubscription    = "subscription_plan"
+
+	cacheInvalidationRedisChannel = "new-api:cache_invalidation"
+	cacheInvalidationPollInterval = time.Second
+	// 合并短时间内的多次刷新，例如批量保存配置时逐项触发的更新
+	cacheReloadDebounce = 200 * time.Millisecond
+	// 数据库广播记录的保留时间
+	cacheInvalidationRetention = 10 * time.Minute
+)
+
+type CacheInvalidationMessage struct {
+	Kind   string `json:"kind"`
+	Id     int    `json:"id,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Node   string `json:"node"`
+}
+
+// CacheInvalidation 数据库广播使用的记录
+type CacheInvalidation struct {
+	Id        int    `json:"id"`
+	Node      string `json:"node" gorm:"type:varchar(64)"`
+	Payload   string `json:"payload" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+var (
+	cacheInvalidationNode     = common.GetUUID()
+	cacheInvalidationStarted  atomic.Bool
+	cacheInvalidationDBFeed   bool
+	cacheInvalidationHandlers = make(map[string]func(message CacheInvalidationMessage))
+	cacheInvalidationLock     sync.RWMutex
+)
+
+func init() {
+	RegisterCacheInvalidationHandler(CacheInvalidationChannel, func(message CacheInvalidationMessage) {
+		scheduleChannelCacheReload()
+	})
+	RegisterCacheInvalidationHandler(CacheInvalidationChannelStatus, func(message CacheInvalidationMessage) {
+		// 禁用立即从本地缓存中摘除，其余情况（启用、多 Key 状态）重新加载
+		if message.Status != common.ChannelStatusEnabled {
+			CacheUpdateChannelStatus(message.Id, message.Status)
+		}
+		scheduleChannelCacheReload()
+	})
+	RegisterCacheInvalidationHandler(CacheInvalidationOption, func(message CacheInvalidationMessage) {
+		scheduleOptionReload()
+	})
+	RegisterCacheInvalidationHandler(CacheInvalidationSubscription, func(message CacheInvalidationMessage) {
+		invalidateSubscriptionPlanCacheLocal(message.Id)
+	})
+}
+
+// RegisterCacheInvalidationHandler 注册收到其他节点广播时的处理函数
+func RegisterCacheInvalidationHandler(kind string, handler func(message CacheInvalidationMessage)) {
+	cacheInvalidationLock.Lock()
+	defer cacheInvalidationLock.Unlock()
+	cacheInvalidationHandlers[kind] = handler
+}
+
+// PublishCacheInvalidation 通知其他节点刷新缓存，广播未启动时忽略
+func PublishCacheInvalidation(message CacheInvalidationMessage) {
+	if !cacheInvalidationStarted.Load() {
+		return
+	}
+	message.Node = cacheInvalidationNode
+	payload := common.GetJsonString(message)
+	if common.RedisEnabled && common.RDB != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := common.RDB.Publish(ctx, cacheInvalidationRedisChannel, payload).Err(); err != nil {
+			common.SysError("failed to publish cache invalidation: " + err.Error())
+		}
+		return
+	}
+	if cacheInvalidationDBFeed {
+		record := &CacheInvalidation{
+			Node:      cacheInvalidationNode,
+			Payload:   payload,
+			CreatedAt: common.GetTimestamp(),
+		}
+		if err := DB.Create(record).Error; err != nil {
+			common.SysError("failed to publish cache invalidation: " + err.Error())
+		}
+	}
+}
+
+func handleCacheInvalidation(payload string) {
+	var message CacheInvalidationMessage
+	if err := common.UnmarshalJsonStr(payload, &message); err != nil {
+		common.SysError("invalid cache invalidation message: " + err.Error())
+		return
+	}
+	if message.Node == cacheInvalidationNode {
+		return
+	}
+	cacheInvalidationLock.RLock()
+	handler := cacheInvalidationHandlers[message.Kind]
+	cacheInvalidationLock.RUnlock()
+	if handler != nil {
+		handler(message)
+	}
+}
+
+// StartCacheInvalidationBus 启动缓存失效广播的订阅，需在缓存初始化之后调用
+func StartCacheInvalidationBus() {
+	if common.RedisEnabled && common.RDB != nil {
+		cacheInvalidationStarted.Store(true)
+		go subscribeCacheInvalidationRedis()
+		common.SysLog("cache invalidation bus started with redis pub/sub")
+		return
+	}
+	if common.GetEnvOrDefaultBool("CACHE_INVALIDATION_DB_FEED", false) {
+		cacheInvalidationDBFeed = true
+		cacheInvalidationStarted.Store(true)
+		go pollCacheInvalidationDB()
+		common.SysLog("cache invalidation bus started with database feed")
+	}
+}
+
+func subscribeCacheInvalidationRedis() {
+	// go-redis 会在连接断开后自动重新订阅
+	pubsub := common.RDB.Subscribe(context.Background(), cacheInvalidationRedisChannel)
+	defer pubsub.Close()
+	for msg := range pubsub.Channel() {
+		handleCacheInvalidation(msg.Payload)
+	}
+}
+
+func pollCacheInvalidationDB() {
+	var lastId int
+	DB.Model(&CacheInvalidation{}).Select("COALESCE(MAX(id), 0)").Scan(&lastId)
+	lastCleanup := time.Now()
+	ticker := time.NewTicker(cacheInvalidationPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var records []*CacheInvalidation
+		if err := DB.Where("id > ?", lastId).Order("id asc").Limit(1000).Find(&records).Error; err != nil {
+			common.SysError("failed to poll cache invalidation: " + err.Error())
+			continue
+		}
+		for _, record := range records {
+			lastId = record.Id
+			handleCacheInvalidation(record.Payload)
+		}
+		if common.IsMasterNode && time.Since(lastCleanup) > time.Minute {
+			lastCleanup = time.Now()
+			DB.Where("created_at < ?", time.Now().Add(-cacheInvalidationRetention).Unix()).Delete(&CacheInvalidation{})
+		}
+	}
+}
+
+type debouncedReload struct {
+	mu      sync.Mutex
+	pending bool
+	reload  func()
+}
+
+func (d *debouncedReload) schedule() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pending {
+		return
+	}
+	d.pending = true
+	time.AfterFunc(cacheReloadDebounce, func() {
+		d.mu.Lock()
+		d.pending = false
+		d.mu.Unlock()
+		d.reload()
+	})
+}
+
+var channelCacheReload = &debouncedReload{reload: loadChannelCache}
+var optionReload = &debouncedReload{reload: loadOptionsFromDatabase}
+
+func scheduleChannelCacheReload() {
+	channelCacheReload.schedule()
+}
+
+func scheduleOptionReload() {
+	optionReload.schedule()
+}
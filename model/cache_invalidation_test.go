@@ -0,0 +1,39 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheInvalidationDBFeed(t *testing.T) {
+	require.NoError(t, DB.AutoMigrate(&CacheInvalidation{}))
+	cacheInvalidationStarted.Store(true)
+	cacheInvalidationDBFeed = true
+	t.Cleanup(func() {
+		cacheInvalidationStarted.Store(false)
+		cacheInvalidationDBFeed = false
+		RegisterCacheInvalidationHandler("test", nil)
+		DB.Exec("DELETE FROM cache_invalidations")
+	})
+
+	var received []CacheInvalidationMessage
+	RegisterCacheInvalidationHandler("test", func(message CacheInvalidationMessage) {
+		received = append(received, message)
+	})
+
+	PublishCacheInvalidation(CacheInvalidationMessage{Kind: "test", Key: "ModelRatio"})
+	var records []*CacheInvalidation
+	require.NoError(t, DB.Find(&records).Error)
+	require.Len(t, records, 1)
+	require.Equal(t, cacheInvalidationNode, records[0].Node)
+
+	// 本节点发出的消息不重复处理
+	handleCacheInvalidation(records[0].Payload)
+	require.Empty(t, received)
+
+	handleCacheInvalidation(common.GetJsonString(CacheInvalidationMessage{Kind: "test", Key: "ModelRatio", Node: "other"}))
+	require.Len(t, received, 1)
+	require.Equal(t, "ModelRatio", received[0].Key)
+}
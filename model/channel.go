@@ -21,7 +21,7 @@ import (
 type Channel struct {
 	Id                 int     `json:"id"`
 	Type               int     `json:"type" gorm:"default:0"`
-	Key                string  `json:"key" gorm:"not null"`
+	Key                string  `json:"key" gorm:"not null;serializer:secret"`
 	OpenAIOrganization *string `json:"openai_organization"`
 	TestModel          *string `json:"test_model"`
 	Status             int     `json:"status" gorm:"default:1"`
@@ -64,11 +64,16 @@ type ChannelInfo struct {
 	MultiKeyDisabledReason map[int]string        `json:"multi_key_disabled_reason,omitempty"` // key禁用原因列表，key index -> reason
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
+	MultiKeyCooldownUntil  map[int]int64         `json:"multi_key_cooldown_until,omitempty"`  // key限流冷却截止时间（运行时状态，仅用于展示），key index -> time
+	MultiKeyCooldownReason map[int]string        `json:"multi_key_cooldown_reason,omitempty"` // key限流冷却原因（运行时状态，仅用于展示），key index -> reason
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
 }
 
 // Value implements driver.Valuer interface
 func (c ChannelInfo) Value() (driver.Value, error) {
+	// 冷却状态为运行时数据，不持久化
+	c.MultiKeyCooldownUntil = nil
+	c.MultiKeyCooldownReason = nil
 	return common.Marshal(&c)
 }
 
@@ -150,6 +155,9 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		// Randomly pick one enabled key
 		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
 		return keys[selectedIdx], selectedIdx, nil
+	case constant.MultiKeyModeLeastLoaded:
+		selectedIdx := selectLeastLoadedKey(channel.Id, enabledIdx)
+		return keys[selectedIdx], selectedIdx, nil
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
 
@@ -289,6 +297,17 @@ func GetChannelsByTag(tag string, idSort bool, selectAll bool) ([]*Channel, erro
 	return channels, err
 }
 
+// channelKeywordCondition 构造渠道搜索的关键字条件：ID、名称、完整密钥或 API 地址。
+// 启用敏感数据加密后密钥以随机密文存储，无法在数据库中按密钥精确匹配，此时不再支持按密钥搜索
+func channelKeywordCondition(keyword string, baseURLCol string) (string, []interface{}) {
+	if common.SecretEncryptionEnabled {
+		return "(id = ? OR name LIKE ? OR " + baseURLCol + " LIKE ?)",
+			[]interface{}{common.String2Int(keyword), "%" + keyword + "%", "%" + keyword + "%"}
+	}
+	return "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?)",
+		[]interface{}{common.String2Int(keyword), "%" + keyword + "%", keyword, "%" + keyword + "%"}
+}
+
 func SearchChannels(keyword string, group string, model string, idSort bool) ([]*Channel, error) {
 	var channels []*Channel
 	modelsCol := "`models`"
@@ -323,11 +342,13 @@ func SearchChannels(keyword string, group string, model string, idSort bool) ([]
 			// sqlite, PostgreSQL
 			groupCondition = `(',' || ` + commonGroupCol + ` || ',') LIKE ?`
 		}
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
+		keywordCondition, keywordArgs := channelKeywordCondition(keyword, baseURLCol)
+		whereClause = keywordCondition + " AND " + modelsCol + ` LIKE ? AND ` + groupCondition
+		args = append(keywordArgs, "%"+model+"%", "%,"+group+",%")
 	} else {
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%")
+		keywordCondition, keywordArgs := channelKeywordCondition(keyword, baseURLCol)
+		whereClause = keywordCondition + " AND " + modelsCol + " LIKE ?"
+		args = append(keywordArgs, "%"+model+"%")
 	}
 
 	// 执行查询
@@ -675,6 +696,7 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 			common.SysLog(fmt.Sprintf("failed to update channel status: channel_id=%d, status=%d, error=%v", channel.Id, status, err))
 			return false
 		}
+		PublishCacheInvalidation(CacheInvalidationMessage{Kind: CacheInvalidationChannelStatus, Id: channel.Id, Status: channel.Status})
 	}
 	return true
 }
@@ -818,11 +840,13 @@ func SearchTags(keyword string, group string, model string, idSort bool) ([]*str
 			// sqlite, PostgreSQL
 			groupCondition = `(',' || ` + commonGroupCol + ` || ',') LIKE ?`
 		}
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + ` LIKE ? AND ` + groupCondition
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%", "%,"+group+",%")
+		keywordCondition, keywordArgs := channelKeywordCondition(keyword, baseURLCol)
+		whereClause = keywordCondition + " AND " + modelsCol + ` LIKE ? AND ` + groupCondition
+		args = append(keywordArgs, "%"+model+"%", "%,"+group+",%")
 	} else {
-		whereClause = "(id = ? OR name LIKE ? OR " + commonKeyCol + " = ? OR " + baseURLCol + " LIKE ?) AND " + modelsCol + " LIKE ?"
-		args = append(args, common.String2Int(keyword), "%"+keyword+"%", keyword, "%"+keyword+"%", "%"+model+"%")
+		keywordCondition, keywordArgs := channelKeywordCondition(keyword, baseURLCol)
+		whereClause = keywordCondition + " AND " + modelsCol + " LIKE ?"
+		args = append(keywordArgs, "%"+model+"%")
 	}
 
 	subQuery := baseQuery.Where(whereClause, args...).
@@ -1006,3 +1030,10 @@ func CountChannelsGroupByType() (map[int64]int64, error) {
 	}
 	return counts, nil
 }
+
+// GetChannelStatusList returns the status fields of all channels, without keys or settings
+func GetChannelStatusList() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Select("id", "name", "type", "status", "channel_info").Find(&channels).Error
+	return channels, err
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 )
 
@@ -18,7 +19,16 @@ var group2model2channels map[string]map[string][]int // enabled channel
 var channelsIDM map[int]*Channel                     // all channels include disabled
 var channelSyncLock sync.RWMutex
 
+// InitChannelCache 重新加载本节点的渠道缓存，并通知其他节点刷新
 func InitChannelCache() {
+	if !common.MemoryCacheEnabled {
+		return
+	}
+	loadChannelCache()
+	PublishCacheInvalidation(CacheInvalidationMessage{Kind: CacheInvalidationChannel})
+}
+
+func loadChannelCache() {
 	if !common.MemoryCacheEnabled {
 		return
 	}
@@ -89,7 +99,7 @@ func SyncChannelCache(frequency int) {
 	for {
 		time.Sleep(time.Duration(frequency) * time.Second)
 		common.SysLog("syncing channels from database")
-		InitChannelCache()
+		loadChannelCache()
 	}
 }
 
@@ -159,6 +169,14 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		return nil, errors.New(fmt.Sprintf("no channel found, group: %s, model: %s, priority: %d", group, model, targetPriority))
 	}
 
+	if strategy := operation_setting.GetChannelSelectStrategy(group, model); strategy != operation_setting.ChannelSelectStrategyWeighted {
+		candidates := make([]channelCandidate, len(targetChannels))
+		for i, channel := range targetChannels {
+			candidates[i] = channelCandidate{channelId: channel.Id, weight: channel.GetWeight()}
+		}
+		return targetChannels[selectChannelByHealth(strategy, candidates)], nil
+	}
+
 	// smoothing factor and adjustment
 	smoothingFactor := 1
 	smoothingAdjustment := 0
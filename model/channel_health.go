@@ -0,0 +1,360 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 渠道健康度统计：每个节点在内存中为每个渠道维护一个分桶滑动窗口，
+// 记录成功率与延迟（流式请求使用首字时间），用于自适应渠道选择与异常摘除。
+
+const (
+	channelHealthBucketCount = 10
+	// 延迟 EWMA 的平滑系数，越大越偏向最新样本
+	channelHealthEWMAAlpha = 0.3
+	// 健康分下限，避免渠道权重被压到 0 后永远无法恢复
+	channelHealthMinScore = 0.05
+	// 半开探测请求超过该时长未回报结果时，允许再次探测
+	channelHealthProbeTimeout = time.Minute
+)
+
+const (
+	ChannelHealthStateClosed   = "closed"    // 正常
+	ChannelHealthStateEjected  = "ejected"   // 已摘除
+	ChannelHealthStateHalfOpen = "half_open" // 摘除到期，等待探测请求
+)
+
+type channelHealthBucket struct {
+	epoch    int64
+	requests int
+	failures int
+}
+
+type channelHealth struct {
+	mu                  sync.Mutex
+	buckets             [channelHealthBucketCount]channelHealthBucket
+	latencyMs           float64 // 成功请求延迟的 EWMA
+	consecutiveFailures int
+	ejections           int
+	ejectedUntil        time.Time
+	probeStartedAt      time.Time
+	lastFailureAt       time.Time
+}
+
+// ChannelHealthSnapshot 渠道健康度快照，供管理接口展示
+type ChannelHealthSnapshot struct {
+	ChannelId           int     `json:"channel_id"`
+	State               string  `json:"state"`
+	Requests            int     `json:"requests"`
+	Failures            int     `json:"failures"`
+	SuccessRate         float64 `json:"success_rate"`
+	LatencyMs           float64 `json:"latency_ms"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	Ejections           int     `json:"ejections"`
+	EjectedUntil        int64   `json:"ejected_until,omitempty"`
+	LastFailureAt       int64   `json:"last_failure_at,omitempty"`
+	// Score 为成功率得分，选择时还会乘以相对同层渠道的延迟得分
+	Score float64 `json:"score"`
+}
+
+var channelHealthMap sync.Map // channel id -> *channelHealth
+
+func getChannelHealth(channelId int) *channelHealth {
+	if value, ok := channelHealthMap.Load(channelId); ok {
+		return value.(*channelHealth)
+	}
+	value, _ := channelHealthMap.LoadOrStore(channelId, &channelHealth{})
+	return value.(*channelHealth)
+}
+
+func channelHealthBucketSeconds(setting *operation_setting.ChannelHealthSetting) int64 {
+	return max(int64(setting.WindowSeconds)/channelHealthBucketCount, 1)
+}
+
+// window 返回滑动窗口内的请求数与失败数，调用方需持有锁
+func (h *channelHealth) window(now time.Time, setting *operation_setting.ChannelHealthSetting) (requests int, failures int) {
+	epoch := now.Unix() / channelHealthBucketSeconds(setting)
+	for _, bucket := range h.buckets {
+		if epoch-bucket.epoch < channelHealthBucketCount {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+// state 返回渠道当前的摘除状态，调用方需持有锁
+func (h *channelHealth) state(now time.Time) string {
+	if h.ejectedUntil.IsZero() {
+		return ChannelHealthStateClosed
+	}
+	if now.Before(h.ejectedUntil) {
+		return ChannelHealthStateEjected
+	}
+	return ChannelHealthStateHalfOpen
+}
+
+// successScore 返回窗口内成功率得分，样本不足时视为健康，调用方需持有锁
+func (h *channelHealth) successScore(now time.Time, setting *operation_setting.ChannelHealthSetting) float64 {
+	requests, failures := h.window(now, setting)
+	if requests == 0 || requests < setting.MinRequests {
+		return 1
+	}
+	return max(float64(requests-failures)/float64(requests), channelHealthMinScore)
+}
+
+func (h *channelHealth) eject(now time.Time, setting *operation_setting.ChannelHealthSetting) {
+	h.ejections++
+	duration := time.Duration(setting.EjectionSeconds) * time.Second
+	if h.ejections > 1 {
+		duration *= time.Duration(math.Pow(2, float64(min(h.ejections-1, 16))))
+	}
+	if maxDuration := time.Duration(setting.MaxEjectionSeconds) * time.Second; maxDuration > 0 && duration > maxDuration {
+		duration = maxDuration
+	}
+	h.ejectedUntil = now.Add(duration)
+	h.probeStartedAt = time.Time{}
+}
+
+// RecordChannelHealth 记录一次渠道请求的结果，latency 为成功请求的延迟（流式请求为首字时间）
+func RecordChannelHealth(channelId int, success bool, latency time.Duration) {
+	setting := operation_setting.GetChannelHealthSetting()
+	if !setting.Enabled || channelId <= 0 {
+		return
+	}
+	now := time.Now()
+	h := getChannelHealth(channelId)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	halfOpen := h.state(now) == ChannelHealthStateHalfOpen
+	if halfOpen && success {
+		// 探测成功，恢复渠道并清空窗口，避免摘除前的失败再次触发摘除
+		h.ejections = 0
+		h.ejectedUntil = time.Time{}
+		h.probeStartedAt = time.Time{}
+		h.buckets = [channelHealthBucketCount]channelHealthBucket{}
+	}
+
+	epoch := now.Unix() / channelHealthBucketSeconds(setting)
+	bucket := &h.buckets[epoch%channelHealthBucketCount]
+	if bucket.epoch != epoch {
+		*bucket = channelHealthBucket{epoch: epoch}
+	}
+	bucket.requests++
+
+	if success {
+		sample := float64(latency.Milliseconds())
+		if h.latencyMs == 0 {
+			h.latencyMs = sample
+		} else {
+			h.latencyMs = channelHealthEWMAAlpha*sample + (1-channelHealthEWMAAlpha)*h.latencyMs
+		}
+		h.consecutiveFailures = 0
+		return
+	}
+
+	bucket.failures++
+	h.consecutiveFailures++
+	h.lastFailureAt = now
+	if halfOpen {
+		// 探测失败，延长摘除时间
+		h.eject(now, setting)
+		return
+	}
+	if h.state(now) == ChannelHealthStateEjected {
+		return
+	}
+	if setting.ConsecutiveFailures > 0 && h.consecutiveFailures >= setting.ConsecutiveFailures {
+		h.eject(now, setting)
+		return
+	}
+	requests, failures := h.window(now, setting)
+	if setting.ErrorRateThreshold > 0 && requests >= setting.MinRequests &&
+		float64(failures)/float64(requests) >= setting.ErrorRateThreshold {
+		h.eject(now, setting)
+	}
+}
+
+// ResetChannelHealth 清除渠道的健康度统计与摘除状态
+func ResetChannelHealth(channelId int) {
+	channelHealthMap.Delete(channelId)
+}
+
+// GetChannelHealthSnapshot 返回渠道的健康度快照
+func GetChannelHealthSnapshot(channelId int) ChannelHealthSnapshot {
+	setting := operation_setting.GetChannelHealthSetting()
+	now := time.Now()
+	snapshot := ChannelHealthSnapshot{
+		ChannelId:   channelId,
+		State:       ChannelHealthStateClosed,
+		SuccessRate: 1,
+		Score:       1,
+	}
+	value, ok := channelHealthMap.Load(channelId)
+	if !ok {
+		return snapshot
+	}
+	h := value.(*channelHealth)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshot.State = h.state(now)
+	snapshot.Requests, snapshot.Failures = h.window(now, setting)
+	if snapshot.Requests > 0 {
+		snapshot.SuccessRate = float64(snapshot.Requests-snapshot.Failures) / float64(snapshot.Requests)
+	}
+	snapshot.LatencyMs = math.Round(h.latencyMs)
+	snapshot.ConsecutiveFailures = h.consecutiveFailures
+	snapshot.Ejections = h.ejections
+	if !h.ejectedUntil.IsZero() {
+		snapshot.EjectedUntil = h.ejectedUntil.Unix()
+	}
+	if !h.lastFailureAt.IsZero() {
+		snapshot.LastFailureAt = h.lastFailureAt.Unix()
+	}
+	snapshot.Score = h.successScore(now, setting)
+	return snapshot
+}
+
+// channelCandidate 同一优先级下的候选渠道
+type channelCandidate struct {
+	channelId int
+	weight    int
+}
+
+type scoredChannelCandidate struct {
+	index     int
+	weight    float64
+	score     float64
+	latencyMs float64
+	probe     bool
+}
+
+// selectChannelByHealth 按健康度从候选渠道中选出一个，返回下标。
+// 已摘除的渠道不参与选择；摘除到期的渠道优先放行一个探测请求；
+// 若全部候选均被摘除，则忽略摘除状态，避免请求无渠道可用。
+func selectChannelByHealth(strategy string, candidates []channelCandidate) int {
+	setting := operation_setting.GetChannelHealthSetting()
+	now := time.Now()
+
+	// 与静态权重选择保持一致：权重全为 0 时视为等权
+	sumWeight := 0
+	for _, candidate := range candidates {
+		sumWeight += candidate.weight
+	}
+
+	available := make([]scoredChannelCandidate, 0, len(candidates))
+	all := make([]scoredChannelCandidate, 0, len(candidates))
+	for i, candidate := range candidates {
+		weight := float64(candidate.weight)
+		if sumWeight == 0 {
+			weight = 1
+		}
+		scored := scoredChannelCandidate{index: i, weight: weight, score: 1}
+		state := ChannelHealthStateClosed
+		if value, ok := channelHealthMap.Load(candidate.channelId); ok {
+			h := value.(*channelHealth)
+			h.mu.Lock()
+			state = h.state(now)
+			if state == ChannelHealthStateHalfOpen && (h.probeStartedAt.IsZero() || now.Sub(h.probeStartedAt) > channelHealthProbeTimeout) {
+				scored.probe = true
+			}
+			scored.score = h.successScore(now, setting)
+			scored.latencyMs = h.latencyMs
+			h.mu.Unlock()
+		}
+		all = append(all, scored)
+		if state == ChannelHealthStateClosed || scored.probe {
+			available = append(available, scored)
+		}
+	}
+
+	for _, scored := range available {
+		if scored.probe {
+			h := getChannelHealth(candidates[scored.index].channelId)
+			h.mu.Lock()
+			// 并发请求可能同时看到半开状态，只允许一个成为探测请求
+			claimed := h.state(now) == ChannelHealthStateHalfOpen && (h.probeStartedAt.IsZero() || now.Sub(h.probeStartedAt) > channelHealthProbeTimeout)
+			if claimed {
+				h.probeStartedAt = now
+			}
+			h.mu.Unlock()
+			if claimed {
+				return scored.index
+			}
+		}
+	}
+	available = filterClosedCandidates(available)
+	if len(available) == 0 {
+		available = all
+	}
+
+	// 延迟得分：相对同层最快渠道的比值
+	bestLatency := 0.0
+	for _, scored := range available {
+		if scored.latencyMs > 0 && (bestLatency == 0 || scored.latencyMs < bestLatency) {
+			bestLatency = scored.latencyMs
+		}
+	}
+	for i := range available {
+		if bestLatency > 0 && available[i].latencyMs > 0 {
+			available[i].score *= max(bestLatency/available[i].latencyMs, channelHealthMinScore)
+		}
+	}
+
+	if strategy == operation_setting.ChannelSelectStrategyP2C && len(available) > 1 {
+		first := pickWeightedCandidate(available, false)
+		rest := make([]scoredChannelCandidate, 0, len(available)-1)
+		for _, scored := range available {
+			if scored.index != first.index {
+				rest = append(rest, scored)
+			}
+		}
+		second := pickWeightedCandidate(rest, false)
+		if second.score > first.score {
+			return second.index
+		}
+		return first.index
+	}
+	return pickWeightedCandidate(available, true).index
+}
+
+func filterClosedCandidates(candidates []scoredChannelCandidate) []scoredChannelCandidate {
+	closed := candidates[:0]
+	for _, scored := range candidates {
+		if !scored.probe {
+			closed = append(closed, scored)
+		}
+	}
+	return closed
+}
+
+// pickWeightedCandidate 按权重随机选择，withScore 为 true 时权重乘以健康分
+func pickWeightedCandidate(candidates []scoredChannelCandidate, withScore bool) scoredChannelCandidate {
+	total := 0.0
+	for _, scored := range candidates {
+		total += candidateWeight(scored, withScore)
+	}
+	if total <= 0 {
+		return candidates[rand.Intn(len(candidates))]
+	}
+	random := rand.Float64() * total
+	for _, scored := range candidates {
+		random -= candidateWeight(scored, withScore)
+		if random < 0 {
+			return scored
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+func candidateWeight(scored scoredChannelCandidate, withScore bool) float64 {
+	if withScore {
+		return scored.weight * scored.score
+	}
+	return scored.weight
+}
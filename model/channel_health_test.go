@@ -0,0 +1,122 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enableChannelHealth(t *testing.T) *operation_setting.ChannelHealthSetting {
+	setting := operation_setting.GetChannelHealthSetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.MinRequests = 4
+	setting.ErrorRateThreshold = 0.5
+	setting.ConsecutiveFailures = 3
+	setting.EjectionSeconds = 30
+	setting.MaxEjectionSeconds = 300
+	t.Cleanup(func() {
+		*setting = saved
+		channelHealthMap.Range(func(key, _ any) bool {
+			channelHealthMap.Delete(key)
+			return true
+		})
+	})
+	return setting
+}
+
+func TestChannelHealthEjectionAndProbe(t *testing.T) {
+	enableChannelHealth(t)
+
+	RecordChannelHealth(1, true, 100*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		RecordChannelHealth(1, false, 0)
+	}
+	snapshot := GetChannelHealthSnapshot(1)
+	assert.Equal(t, ChannelHealthStateEjected, snapshot.State)
+	assert.Equal(t, 1, snapshot.Ejections)
+	assert.Equal(t, 4, snapshot.Requests)
+	assert.Equal(t, 3, snapshot.Failures)
+
+	candidates := []channelCandidate{{channelId: 1, weight: 100}, {channelId: 2, weight: 1}}
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, 1, selectChannelByHealth(operation_setting.ChannelSelectStrategyEWMA, candidates))
+	}
+
+	// 摘除到期后仅放行一个探测请求
+	h := getChannelHealth(1)
+	h.mu.Lock()
+	h.ejectedUntil = time.Now().Add(-time.Second)
+	h.mu.Unlock()
+	assert.Equal(t, ChannelHealthStateHalfOpen, GetChannelHealthSnapshot(1).State)
+	assert.Equal(t, 0, selectChannelByHealth(operation_setting.ChannelSelectStrategyEWMA, candidates))
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, 1, selectChannelByHealth(operation_setting.ChannelSelectStrategyEWMA, candidates))
+	}
+
+	// 探测失败后摘除时长翻倍
+	RecordChannelHealth(1, false, 0)
+	snapshot = GetChannelHealthSnapshot(1)
+	assert.Equal(t, ChannelHealthStateEjected, snapshot.State)
+	assert.Equal(t, 2, snapshot.Ejections)
+	assert.InDelta(t, time.Now().Add(60*time.Second).Unix(), snapshot.EjectedUntil, 1)
+
+	// 探测成功后恢复并清空窗口
+	h.mu.Lock()
+	h.ejectedUntil = time.Now().Add(-time.Second)
+	h.mu.Unlock()
+	require.Equal(t, 0, selectChannelByHealth(operation_setting.ChannelSelectStrategyP2C, candidates))
+	RecordChannelHealth(1, true, 100*time.Millisecond)
+	snapshot = GetChannelHealthSnapshot(1)
+	assert.Equal(t, ChannelHealthStateClosed, snapshot.State)
+	assert.Equal(t, 0, snapshot.Ejections)
+	assert.Equal(t, 1, snapshot.Requests)
+}
+
+func TestChannelHealthAllEjectedFallsBack(t *testing.T) {
+	enableChannelHealth(t)
+	for _, channelId := range []int{1, 2} {
+		for i := 0; i < 3; i++ {
+			RecordChannelHealth(channelId, false, 0)
+		}
+	}
+	candidates := []channelCandidate{{channelId: 1, weight: 0}, {channelId: 2, weight: 0}}
+	seen := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		seen[selectChannelByHealth(operation_setting.ChannelSelectStrategyEWMA, candidates)] = true
+	}
+	assert.True(t, seen[0])
+	assert.True(t, seen[1])
+}
+
+func TestChannelHealthScalesWeights(t *testing.T) {
+	setting := enableChannelHealth(t)
+	setting.ConsecutiveFailures = 0
+	setting.ErrorRateThreshold = 0.9
+
+	// 渠道 1 成功率 50%、延迟 1000ms；渠道 2 成功率 100%、延迟 100ms
+	for i := 0; i < 5; i++ {
+		RecordChannelHealth(1, true, time.Second)
+		RecordChannelHealth(1, false, 0)
+		RecordChannelHealth(2, true, 100*time.Millisecond)
+	}
+	assert.Equal(t, 0.5, GetChannelHealthSnapshot(1).Score)
+	assert.Equal(t, ChannelHealthStateClosed, GetChannelHealthSnapshot(1).State)
+
+	candidates := []channelCandidate{{channelId: 1, weight: 10}, {channelId: 2, weight: 10}}
+	counts := map[int]int{}
+	for i := 0; i < 2000; i++ {
+		counts[selectChannelByHealth(operation_setting.ChannelSelectStrategyEWMA, candidates)]++
+	}
+	// 渠道 1 的有效权重为 10*0.5*0.1=0.5，渠道 2 为 10
+	assert.Less(t, counts[0], 300)
+
+	counts = map[int]int{}
+	for i := 0; i < 200; i++ {
+		counts[selectChannelByHealth(operation_setting.ChannelSelectStrategyP2C, candidates)]++
+	}
+	assert.Equal(t, 200, counts[1])
+}
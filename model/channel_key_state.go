@@ -0,0 +1,295 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 多 Key 渠道的运行时状态：每个节点在内存中记录各 key 的并发请求数、
+// 上游返回的剩余限额以及因限流产生的冷却期，供 least_loaded 模式选择 key。
+
+const (
+	// 429 未携带重置时间时的首次冷却时长，连续 429 时翻倍
+	channelKeyDefaultCooldown = 5 * time.Second
+	channelKeyMaxCooldown     = 5 * time.Minute
+	// 未知剩余额度的 key 视为额度充足
+	channelKeyUnknownHeadroom = 1 << 30
+	// 上游未返回重置时间时，剩余额度信息的有效期
+	channelKeyLimitTTL = time.Minute
+)
+
+type channelKeyState struct {
+	inFlight          int
+	remainingRequests int // -1 表示未知
+	remainingTokens   int // -1 表示未知
+	resetAt           time.Time
+	observedAt        time.Time
+	cooldownUntil     time.Time
+	cooldownReason    string
+	consecutive429    int
+}
+
+type channelKeyStates struct {
+	mu   sync.Mutex
+	keys map[int]*channelKeyState
+}
+
+var channelKeyStatesMap sync.Map // channel id -> *channelKeyStates
+
+func getChannelKeyStates(channelId int) *channelKeyStates {
+	if value, ok := channelKeyStatesMap.Load(channelId); ok {
+		return value.(*channelKeyStates)
+	}
+	value, _ := channelKeyStatesMap.LoadOrStore(channelId, &channelKeyStates{keys: make(map[int]*channelKeyState)})
+	return value.(*channelKeyStates)
+}
+
+// get 返回 key 的状态，不存在时创建，调用方需持有锁
+func (s *channelKeyStates) get(index int) *channelKeyState {
+	state, ok := s.keys[index]
+	if !ok {
+		state = &channelKeyState{remainingRequests: -1, remainingTokens: -1}
+		s.keys[index] = state
+	}
+	return state
+}
+
+// headroom 返回 key 的剩余可用额度减去进行中的请求数，调用方需持有锁
+func (state *channelKeyState) headroom(now time.Time) int {
+	remaining := channelKeyUnknownHeadroom
+	// 额度重置后上游返回的剩余值已失效
+	valid := now.Before(state.resetAt) || (state.resetAt.IsZero() && now.Sub(state.observedAt) < channelKeyLimitTTL)
+	if valid {
+		if state.remainingRequests >= 0 {
+			remaining = state.remainingRequests
+		}
+		if state.remainingTokens == 0 {
+			remaining = 0
+		}
+	}
+	return remaining - state.inFlight
+}
+
+// AcquireChannelKey 记录 key 上新增一个进行中的请求，返回的函数用于释放
+func AcquireChannelKey(channelId int, index int) func() {
+	states := getChannelKeyStates(channelId)
+	states.mu.Lock()
+	states.get(index).inFlight++
+	states.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			states.mu.Lock()
+			if state := states.get(index); state.inFlight > 0 {
+				state.inFlight--
+			}
+			states.mu.Unlock()
+		})
+	}
+}
+
+// selectLeastLoadedKey 从启用的 key 中选择未冷却且剩余额度最多的一个；
+// 全部处于冷却期时选择最早结束冷却的 key，由上游决定是否仍然限流
+func selectLeastLoadedKey(channelId int, enabledIdx []int) int {
+	states := getChannelKeyStates(channelId)
+	states.mu.Lock()
+	defer states.mu.Unlock()
+	now := time.Now()
+
+	best := make([]int, 0, len(enabledIdx))
+	bestHeadroom := 0
+	soonest := -1
+	var soonestUntil time.Time
+	for _, idx := range enabledIdx {
+		state := states.get(idx)
+		if now.Before(state.cooldownUntil) {
+			if soonest < 0 || state.cooldownUntil.Before(soonestUntil) {
+				soonest = idx
+				soonestUntil = state.cooldownUntil
+			}
+			continue
+		}
+		headroom := state.headroom(now)
+		switch {
+		case len(best) == 0 || headroom > bestHeadroom:
+			best = append(best[:0], idx)
+			bestHeadroom = headroom
+		case headroom == bestHeadroom:
+			best = append(best, idx)
+		}
+	}
+	if len(best) == 0 {
+		return soonest
+	}
+	return best[rand.Intn(len(best))]
+}
+
+// RecordChannelKeyResponse 根据上游响应的状态码与限流响应头更新 key 的剩余额度与冷却状态
+func RecordChannelKeyResponse(channelId int, index int, statusCode int, header http.Header) {
+	now := time.Now()
+	limits := parseRateLimitHeaders(header, now)
+
+	states := getChannelKeyStates(channelId)
+	states.mu.Lock()
+	defer states.mu.Unlock()
+	state := states.get(index)
+
+	if limits.remainingRequests >= 0 || limits.remainingTokens >= 0 {
+		state.remainingRequests = limits.remainingRequests
+		state.remainingTokens = limits.remainingTokens
+		state.resetAt = limits.resetAt
+		state.observedAt = now
+	}
+
+	if statusCode == http.StatusTooManyRequests {
+		state.consecutive429++
+		until := limits.retryAt
+		if until.IsZero() {
+			until = limits.resetAt
+		}
+		if until.IsZero() || !until.After(now) {
+			cooldown := channelKeyDefaultCooldown << min(state.consecutive429-1, 10)
+			until = now.Add(min(cooldown, channelKeyMaxCooldown))
+		}
+		state.cooldownUntil = until
+		state.cooldownReason = fmt.Sprintf("status code %d", statusCode)
+		return
+	}
+	if statusCode >= 200 && statusCode < 300 {
+		state.consecutive429 = 0
+	}
+	// 额度已耗尽时提前冷却到重置时间，避免再收到 429
+	if (limits.remainingRequests == 0 || limits.remainingTokens == 0) && limits.resetAt.After(now) {
+		state.cooldownUntil = limits.resetAt
+		state.cooldownReason = "rate limit exhausted"
+	}
+}
+
+// FillChannelKeyCooldowns 将运行时的 key 冷却状态写入 ChannelInfo，用于管理接口展示
+func FillChannelKeyCooldowns(channel *Channel) {
+	if channel == nil || !channel.ChannelInfo.IsMultiKey {
+		return
+	}
+	value, ok := channelKeyStatesMap.Load(channel.Id)
+	if !ok {
+		return
+	}
+	states := value.(*channelKeyStates)
+	states.mu.Lock()
+	defer states.mu.Unlock()
+	now := time.Now()
+	for idx, state := range states.keys {
+		if !now.Before(state.cooldownUntil) {
+			continue
+		}
+		if channel.ChannelInfo.MultiKeyCooldownUntil == nil {
+			channel.ChannelInfo.MultiKeyCooldownUntil = make(map[int]int64)
+			channel.ChannelInfo.MultiKeyCooldownReason = make(map[int]string)
+		}
+		channel.ChannelInfo.MultiKeyCooldownUntil[idx] = state.cooldownUntil.Unix()
+		channel.ChannelInfo.MultiKeyCooldownReason[idx] = state.cooldownReason
+	}
+}
+
+// GetChannelKeyInFlight 返回各 key 当前进行中的请求数
+func GetChannelKeyInFlight(channelId int) map[int]int {
+	result := make(map[int]int)
+	value, ok := channelKeyStatesMap.Load(channelId)
+	if !ok {
+		return result
+	}
+	states := value.(*channelKeyStates)
+	states.mu.Lock()
+	defer states.mu.Unlock()
+	for idx, state := range states.keys {
+		if state.inFlight > 0 {
+			result[idx] = state.inFlight
+		}
+	}
+	return result
+}
+
+// ResetChannelKeyStates 清除渠道的 key 运行时状态（如 key 列表被修改后下标失效）
+func ResetChannelKeyStates(channelId int) {
+	channelKeyStatesMap.Delete(channelId)
+}
+
+type rateLimitHeaders struct {
+	remainingRequests int
+	remainingTokens   int
+	resetAt           time.Time
+	retryAt           time.Time
+}
+
+// parseRateLimitHeaders 解析常见的限流响应头：
+// Retry-After、OpenAI 的 x-ratelimit-*、Anthropic 的 anthropic-ratelimit-*
+func parseRateLimitHeaders(header http.Header, now time.Time) rateLimitHeaders {
+	limits := rateLimitHeaders{remainingRequests: -1, remainingTokens: -1}
+	if header == nil {
+		return limits
+	}
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		limits.retryAt = parseRateLimitReset(retryAfter, now)
+	}
+	for name, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		lowerName := strings.ToLower(name)
+		if !strings.Contains(lowerName, "ratelimit") {
+			continue
+		}
+		value := strings.TrimSpace(values[0])
+		switch {
+		case strings.Contains(lowerName, "remaining"):
+			remaining, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			if strings.Contains(lowerName, "request") {
+				limits.remainingRequests = remaining
+			} else if strings.Contains(lowerName, "token") {
+				// 输入/输出 token 限额分开返回时取较小值
+				if limits.remainingTokens < 0 || remaining < limits.remainingTokens {
+					limits.remainingTokens = remaining
+				}
+			}
+		case strings.Contains(lowerName, "reset"):
+			// 多个维度的重置时间取最晚者
+			if resetAt := parseRateLimitReset(value, now); resetAt.After(limits.resetAt) {
+				limits.resetAt = resetAt
+			}
+		}
+	}
+	return limits
+}
+
+// parseRateLimitReset 支持秒数、Go 时长（如 6m0s、20ms）、RFC3339 与 HTTP 日期格式
+func parseRateLimitReset(value string, now time.Time) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		// 部分上游返回 unix 时间戳
+		if seconds > 1e9 {
+			return time.Unix(int64(seconds), 0)
+		}
+		return now.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(duration)
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	header := http.Header{}
+	header.Set("X-Ratelimit-Remaining-Requests", "7")
+	header.Set("X-Ratelimit-Remaining-Tokens", "1200")
+	header.Set("X-Ratelimit-Reset-Requests", "6m0s")
+	header.Set("X-Ratelimit-Reset-Tokens", "20ms")
+	header.Set("Retry-After", "3")
+
+	limits := parseRateLimitHeaders(header, now)
+	assert.Equal(t, 7, limits.remainingRequests)
+	assert.Equal(t, 1200, limits.remainingTokens)
+	assert.Equal(t, now.Add(6*time.Minute), limits.resetAt)
+	assert.Equal(t, now.Add(3*time.Second), limits.retryAt)
+
+	assert.Equal(t, time.Unix(1700000100, 0), parseRateLimitReset("1700000100", now))
+	assert.Equal(t, now.Add(1500*time.Millisecond), parseRateLimitReset("1.5", now))
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), parseRateLimitReset("2024-01-02T03:04:05Z", now).UTC())
+	assert.True(t, parseRateLimitReset("soon", now).IsZero())
+
+	empty := parseRateLimitHeaders(nil, now)
+	assert.Equal(t, -1, empty.remainingRequests)
+	assert.Equal(t, -1, empty.remainingTokens)
+}
+
+func TestChannelKeyCooldownOn429(t *testing.T) {
+	const channelId = 9001
+	t.Cleanup(func() { ResetChannelKeyStates(channelId) })
+
+	RecordChannelKeyResponse(channelId, 0, http.StatusTooManyRequests, nil)
+	state := getChannelKeyStates(channelId).get(0)
+	assert.InDelta(t, time.Now().Add(channelKeyDefaultCooldown).Unix(), state.cooldownUntil.Unix(), 1)
+
+	// 连续 429 时冷却时长翻倍
+	RecordChannelKeyResponse(channelId, 0, http.StatusTooManyRequests, nil)
+	assert.InDelta(t, time.Now().Add(2*channelKeyDefaultCooldown).Unix(), state.cooldownUntil.Unix(), 1)
+
+	// Retry-After 优先
+	header := http.Header{}
+	header.Set("Retry-After", "42")
+	RecordChannelKeyResponse(channelId, 1, http.StatusTooManyRequests, header)
+	assert.InDelta(t, time.Now().Add(42*time.Second).Unix(), getChannelKeyStates(channelId).get(1).cooldownUntil.Unix(), 1)
+
+	channel := &Channel{Id: channelId, ChannelInfo: ChannelInfo{IsMultiKey: true}}
+	FillChannelKeyCooldowns(channel)
+	assert.Len(t, channel.ChannelInfo.MultiKeyCooldownUntil, 2)
+	assert.Equal(t, "status code 429", channel.ChannelInfo.MultiKeyCooldownReason[0])
+}
+
+func TestSelectLeastLoadedKey(t *testing.T) {
+	const channelId = 9002
+	t.Cleanup(func() { ResetChannelKeyStates(channelId) })
+	keys := []int{0, 1, 2}
+
+	// key 0 剩余额度较少，key 1 冷却中，选择未知额度的 key 2
+	header := http.Header{}
+	header.Set("X-Ratelimit-Remaining-Requests", "3")
+	header.Set("X-Ratelimit-Reset-Requests", "1m")
+	RecordChannelKeyResponse(channelId, 0, http.StatusOK, header)
+	RecordChannelKeyResponse(channelId, 1, http.StatusTooManyRequests, nil)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 2, selectLeastLoadedKey(channelId, keys))
+	}
+
+	// 进行中的请求占用额度
+	header.Set("X-Ratelimit-Remaining-Requests", "100")
+	RecordChannelKeyResponse(channelId, 0, http.StatusOK, header)
+	RecordChannelKeyResponse(channelId, 2, http.StatusOK, header)
+	release := AcquireChannelKey(channelId, 2)
+	assert.Equal(t, 0, selectLeastLoadedKey(channelId, keys))
+	assert.Equal(t, map[int]int{2: 1}, GetChannelKeyInFlight(channelId))
+	release()
+	release()
+	assert.Empty(t, GetChannelKeyInFlight(channelId))
+
+	// 额度耗尽的 key 冷却到重置时间；全部冷却时选择最早结束的
+	header.Set("X-Ratelimit-Remaining-Requests", "0")
+	RecordChannelKeyResponse(channelId, 0, http.StatusOK, header)
+	header.Set("X-Ratelimit-Reset-Requests", "2m")
+	RecordChannelKeyResponse(channelId, 2, http.StatusOK, header)
+	assert.Equal(t, 1, selectLeastLoadedKey(channelId, keys))
+	assert.Equal(t, 0, selectLeastLoadedKey(channelId, []int{0, 2}))
+}
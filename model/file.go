@@ -0,0 +1,101 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// File 记录通过网关上传到上游渠道的文件（OpenAI Files API）
+// FileId 为对外暴露的网关文件 ID，UpstreamFileId 为上游渠道返回的真实 ID
+type File struct {
+	Id             int            `json:"id"`
+	FileId         string         `json:"file_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId         int            `json:"user_id" gorm:"index"`
+	TokenId        int            `json:"token_id" gorm:"index"`
+	ChannelId      int            `json:"channel_id" gorm:"index"`
+	KeyIndex       int            `json:"key_index" gorm:"default:0"` // 多密钥渠道上传时使用的密钥下标
+	UpstreamFileId string         `json:"upstream_file_id" gorm:"type:varchar(191);index"`
+	LocalPath      string         `json:"-" gorm:"type:varchar(512)"` // 网关本地生成的文件（如批处理结果），非空时不对应上游文件
+	Filename       string         `json:"filename" gorm:"type:varchar(255)"`
+	Purpose        string         `json:"purpose" gorm:"type:varchar(64);index"`
+	Bytes          int64          `json:"bytes" gorm:"bigint"`
+	Status         string         `json:"status" gorm:"type:varchar(32)"`
+	CreatedAt      int64          `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt      int64          `json:"expires_at" gorm:"bigint;default:0"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// GenerateFileId 生成对外暴露的 file-xxxx 格式 ID
+func GenerateFileId() string {
+	key, _ := common.GenerateRandomCharsKey(32)
+	return "file-" + key
+}
+
+func (file *File) Insert() error {
+	if file.CreatedAt == 0 {
+		file.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
+
+// IsLocal 是否为网关本地存储的文件
+func (file *File) IsLocal() bool {
+	return file.LocalPath != ""
+}
+
+func GetUserFileByFileId(userId int, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id is empty")
+	}
+	var file File
+	err := DB.Where("user_id = ? AND file_id = ?", userId, fileId).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserFilesByFileIds 批量查询用户的文件，不存在的 ID 会被忽略
+func GetUserFilesByFileIds(userId int, fileIds []string) ([]*File, error) {
+	var files []*File
+	if len(fileIds) == 0 {
+		return files, nil
+	}
+	err := DB.Where("user_id = ? AND file_id IN ?", userId, fileIds).Find(&files).Error
+	return files, err
+}
+
+// GetUserFiles 按 OpenAI 列表语义分页查询：after 为上一页最后一个文件 ID
+func GetUserFiles(userId int, purpose string, after string, limit int, ascending bool) ([]*File, error) {
+	var files []*File
+	tx := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		tx = tx.Where("purpose = ?", purpose)
+	}
+	if after != "" {
+		cursor, err := GetUserFileByFileId(userId, after)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return files, nil
+			}
+			return nil, err
+		}
+		if ascending {
+			tx = tx.Where("id > ?", cursor.Id)
+		} else {
+			tx = tx.Where("id < ?", cursor.Id)
+		}
+	}
+	order := "id desc"
+	if ascending {
+		order = "id asc"
+	}
+	err := tx.Order(order).Limit(limit).Find(&files).Error
+	return files, err
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/types"
 
@@ -26,6 +27,7 @@ type Log struct {
 	TokenName        string `json:"token_name" gorm:"index;default:''"`
 	ModelName        string `json:"model_name" gorm:"index;index:index_username_model_name,priority:1;default:''"`
 	Quota            int    `json:"quota" gorm:"default:0"`
+	UpstreamCost     int    `json:"upstream_cost,omitempty" gorm:"default:0"`
 	PromptTokens     int    `json:"prompt_tokens" gorm:"default:0"`
 	CompletionTokens int    `json:"completion_tokens" gorm:"default:0"`
 	UseTime          int    `json:"use_time" gorm:"default:0"`
@@ -36,6 +38,7 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	RequestId        string `json:"request_id,omitempty" gorm:"type:varchar(64);index:idx_logs_request_id;default:''"`
+	OrganizationId   int    `json:"organization_id,omitempty" gorm:"index;default:0"`
 	Other            string `json:"other"`
 }
 
@@ -52,19 +55,25 @@ const (
 
 func formatUserLogs(logs []*Log, startIdx int) {
 	for i := range logs {
-		logs[i].ChannelName = ""
-		var otherMap map[string]interface{}
-		otherMap, _ = common.StrToMap(logs[i].Other)
-		if otherMap != nil {
-			// Remove admin-only debug fields.
-			delete(otherMap, "admin_info")
-			delete(otherMap, "reject_reason")
-		}
-		logs[i].Other = common.MapToJsonStr(otherMap)
+		stripAdminLogFields(logs[i])
 		logs[i].Id = startIdx + i + 1
 	}
 }
 
+// stripAdminLogFields 去除仅管理员可见的字段
+func stripAdminLogFields(log *Log) {
+	log.ChannelName = ""
+	log.UpstreamCost = 0
+	var otherMap map[string]interface{}
+	otherMap, _ = common.StrToMap(log.Other)
+	if otherMap != nil {
+		// Remove admin-only debug fields.
+		delete(otherMap, "admin_info")
+		delete(otherMap, "reject_reason")
+	}
+	log.Other = common.MapToJsonStr(otherMap)
+}
+
 func GetLogByTokenId(tokenId int) (logs []*Log, err error) {
 	err = LOG_DB.Model(&Log{}).Where("token_id = ?", tokenId).Order("id desc").Limit(common.MaxRecentItems).Find(&logs).Error
 	formatUserLogs(logs, 0)
@@ -93,6 +102,16 @@ func RecordErrorLog(c *gin.Context, userId int, channelId int, modelName string,
 	isStream bool, group string, other map[string]interface{}) {
 	logger.LogInfo(c, fmt.Sprintf("record error log: userId=%d, channelId=%d, modelName=%s, tokenName=%s, content=%s", userId, channelId, modelName, tokenName, content))
 	username := c.GetString("username")
+	recordUsageRollup(&UsageRollup{
+		BucketStart:        common.GetTimestamp(),
+		UserId:             userId,
+		Username:           username,
+		TokenId:            tokenId,
+		ModelName:          modelName,
+		ChannelId:          channelId,
+		Group:              group,
+		UsageRollupMetrics: UsageRollupMetrics{ErrorCount: 1},
+	}, -1)
 	requestId := c.GetString(common.RequestIdKey)
 	otherStr := common.MapToJsonStr(other)
 	// 判断是否需要记录 IP
@@ -149,11 +168,35 @@ type RecordConsumeLogParams struct {
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	// 令牌 TPM 限流按实际用量结算，与是否记录日志无关
+	common.SetContextKey(c, constant.ContextKeyConsumedTokens, params.PromptTokens+params.CompletionTokens)
+	username := c.GetString("username")
+	upstreamCost, costEstimated := CalcUpstreamCost(params.ChannelId, params.ModelName, params.Quota, params.Other)
+	if costEstimated {
+		params.Other = markUpstreamCostEstimated(params.Other)
+	}
+	// 预聚合与是否记录日志无关
+	recordUsageRollup(&UsageRollup{
+		BucketStart: common.GetTimestamp(),
+		UserId:      userId,
+		Username:    username,
+		TokenId:     params.TokenId,
+		ModelName:   params.ModelName,
+		ChannelId:   params.ChannelId,
+		Group:       params.Group,
+		UsageRollupMetrics: UsageRollupMetrics{
+			RequestCount:     1,
+			PromptTokens:     int64(params.PromptTokens),
+			CompletionTokens: int64(params.CompletionTokens),
+			CachedTokens:     usageRollupCachedTokens(params.Other),
+			Quota:            int64(params.Quota),
+			UpstreamCost:     int64(upstreamCost),
+		},
+	}, params.UseTimeSeconds)
 	if !common.LogConsumeEnabled {
 		return
 	}
 	logger.LogInfo(c, fmt.Sprintf("record consume log: userId=%d, params=%s", userId, common.GetJsonString(params)))
-	username := c.GetString("username")
 	requestId := c.GetString(common.RequestIdKey)
 	otherStr := common.MapToJsonStr(params.Other)
 	// 判断是否需要记录 IP
@@ -174,6 +217,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		TokenName:        params.TokenName,
 		ModelName:        params.ModelName,
 		Quota:            params.Quota,
+		UpstreamCost:     upstreamCost,
 		ChannelId:        params.ChannelId,
 		TokenId:          params.TokenId,
 		UseTime:          params.UseTimeSeconds,
@@ -185,8 +229,9 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 			}
 			return ""
 		}(),
-		RequestId: requestId,
-		Other:     otherStr,
+		RequestId:      requestId,
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
+		Other:          otherStr,
 	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
@@ -194,7 +239,7 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 	if common.DataExportEnabled {
 		gopool.Go(func() {
-			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
+			LogQuotaData(userId, username, params.ModelName, params.Quota, upstreamCost, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
 		})
 	}
 }
@@ -208,7 +253,9 @@ type RecordTaskBillingLogParams struct {
 	Quota     int
 	TokenId   int
 	Group     string
-	Other     map[string]interface{}
+	// 组织令牌提交的任务记录所属组织，用于按成员统计
+	OrganizationId int
+	Other          map[string]interface{}
 }
 
 func RecordTaskBillingLog(params RecordTaskBillingLogParams) {
@@ -222,19 +269,29 @@ func RecordTaskBillingLog(params RecordTaskBillingLogParams) {
 			tokenName = token.Name
 		}
 	}
+	upstreamCost := 0
+	if params.LogType == LogTypeConsume {
+		var costEstimated bool
+		upstreamCost, costEstimated = CalcUpstreamCost(params.ChannelId, params.ModelName, params.Quota, params.Other)
+		if costEstimated {
+			params.Other = markUpstreamCostEstimated(params.Other)
+		}
+	}
 	log := &Log{
-		UserId:    params.UserId,
-		Username:  username,
-		CreatedAt: common.GetTimestamp(),
-		Type:      params.LogType,
-		Content:   params.Content,
-		TokenName: tokenName,
-		ModelName: params.ModelName,
-		Quota:     params.Quota,
-		ChannelId: params.ChannelId,
-		TokenId:   params.TokenId,
-		Group:     params.Group,
-		Other:     common.MapToJsonStr(params.Other),
+		UserId:         params.UserId,
+		Username:       username,
+		CreatedAt:      common.GetTimestamp(),
+		Type:           params.LogType,
+		Content:        params.Content,
+		TokenName:      tokenName,
+		ModelName:      params.ModelName,
+		Quota:          params.Quota,
+		UpstreamCost:   upstreamCost,
+		ChannelId:      params.ChannelId,
+		TokenId:        params.TokenId,
+		Group:          params.Group,
+		OrganizationId: params.OrganizationId,
+		Other:          common.MapToJsonStr(params.Other),
 	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
@@ -283,6 +340,12 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 		return nil, 0, err
 	}
 
+	err = fillLogChannelNames(logs)
+	return logs, total, err
+}
+
+// fillLogChannelNames 批量填充日志的渠道名称
+func fillLogChannelNames(logs []*Log) error {
 	channelIds := types.NewSet[int]()
 	for _, log := range logs {
 		if log.ChannelId != 0 {
@@ -310,8 +373,8 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 			}
 		} else {
 			// Bulk query channels from DB
-			if err = DB.Table("channels").Select("id, name").Where("id IN ?", channelIds.Items()).Find(&channels).Error; err != nil {
-				return logs, total, err
+			if err := DB.Table("channels").Select("id, name").Where("id IN ?", channelIds.Items()).Find(&channels).Error; err != nil {
+				return err
 			}
 		}
 		channelMap := make(map[int]string, len(channels))
@@ -323,7 +386,7 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 		}
 	}
 
-	return logs, total, err
+	return nil
 }
 
 const logSearchCountLimit = 10000
@@ -280,6 +280,18 @@ func migrateDB() error {
 		&SubscriptionPreConsumeRecord{},
 		&CustomOAuthProvider{},
 		&UserOAuthBinding{},
+		&File{},
+		&WebhookEndpoint{},
+		&WebhookDelivery{},
+		&AuditLog{},
+		&CacheInvalidation{},
+		&Organization{},
+		&OrganizationMember{},
+		&OrganizationInvite{},
+		&ManagementKey{},
+		&ModerationEvent{},
+		&BalanceSnapshot{},
+		&UsageRollup{},
 	)
 	if err != nil {
 		return err
@@ -328,6 +340,18 @@ func migrateDBFast() error {
 		{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
 		{&CustomOAuthProvider{}, "CustomOAuthProvider"},
 		{&UserOAuthBinding{}, "UserOAuthBinding"},
+		{&File{}, "File"},
+		{&WebhookEndpoint{}, "WebhookEndpoint"},
+		{&WebhookDelivery{}, "WebhookDelivery"},
+		{&AuditLog{}, "AuditLog"},
+		{&CacheInvalidation{}, "CacheInvalidation"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&OrganizationInvite{}, "OrganizationInvite"},
+		{&ManagementKey{}, "ManagementKey"},
+		{&ModerationEvent{}, "ModerationEvent"},
+		{&BalanceSnapshot{}, "BalanceSnapshot"},
+		{&UsageRollup{}, "UsageRollup"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -367,7 +391,7 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &PayloadCapture{}); err != nil {
 		return err
 	}
 	return nil
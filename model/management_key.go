@@ -0,0 +1,292 @@
+package model
+
+import (
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	// ManagementKeyPrefix 管理密钥前缀，用于在 Authorization 中与用户 access token 区分
+	ManagementKeyPrefix = "mk-"
+
+	ManagementKeyStatusEnabled  = 1
+	ManagementKeyStatusDisabled = 2
+
+	// 每个用户最多可创建的管理密钥数量
+	ManagementKeyMaxPerUser = 50
+	// 最近使用时间的最小写入间隔（秒），避免每次请求都写库
+	ManagementKeyTouchInterval = 60
+)
+
+// 管理密钥可授予的资源范围，权限写作 "<资源>:read" 或 "<资源>:write"，write 隐含 read
+const (
+	ManagementScopeUsers       = "users"
+	ManagementScopeChannels    = "channels"
+	ManagementScopeTokens      = "tokens"
+	ManagementScopeRedemptions = "redemptions"
+	ManagementScopeLogs        = "logs"
+	ManagementScopeData        = "data"
+	ManagementScopeModels      = "models"
+	ManagementScopeTasks       = "tasks"
+)
+
+// managementScopeMinRole 授予各资源范围所需的最低用户角色
+var managementScopeMinRole = map[string]int{
+	ManagementScopeUsers:       common.RoleAdminUser,
+	ManagementScopeChannels:    common.RoleAdminUser,
+	ManagementScopeTokens:      common.RoleCommonUser,
+	ManagementScopeRedemptions: common.RoleAdminUser,
+	ManagementScopeLogs:        common.RoleCommonUser,
+	ManagementScopeData:        common.RoleCommonUser,
+	ManagementScopeModels:      common.RoleAdminUser,
+	ManagementScopeTasks:       common.RoleCommonUser,
+}
+
+var (
+	ErrManagementKeyNotFound     = errors.New("management key not found")
+	ErrManagementKeyInvalid      = errors.New("management key is invalid")
+	ErrManagementKeyDisabled     = errors.New("management key is disabled")
+	ErrManagementKeyExpired      = errors.New("management key has expired")
+	ErrManagementKeyLimitReached = errors.New("management key limit reached")
+)
+
+// ManagementKey 管理密钥：带权限范围、IP 白名单与有效期的管理 API 凭证，
+// 数据库中只保存密钥的 SHA-256 摘要，明文仅在创建与轮换时返回一次
+type ManagementKey struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(64)"`
+	KeyHash      string `json:"-" gorm:"type:varchar(64);uniqueIndex"`
+	KeyPrefix    string `json:"key_prefix" gorm:"type:varchar(16)"`
+	Scopes       string `json:"scopes" gorm:"type:text"`       // 逗号分隔，如 logs:read,channels:write
+	ChannelTags  string `json:"channel_tags" gorm:"type:text"` // 逗号分隔，非空时渠道权限仅限这些标签下的渠道
+	AllowIps     string `json:"allow_ips" gorm:"type:text"`    // 换行或逗号分隔，支持 CIDR
+	Status       int    `json:"status" gorm:"default:1"`
+	ExpiredTime  int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 表示永不过期
+	LastUsedTime int64  `json:"last_used_time" gorm:"bigint;default:0"`
+	LastUsedIp   string `json:"last_used_ip" gorm:"type:varchar(64);default:''"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	RotatedTime  int64  `json:"rotated_time" gorm:"bigint;default:0"`
+}
+
+// HashManagementKey 计算管理密钥明文的摘要
+func HashManagementKey(key string) string {
+	return hex.EncodeToString(common.Sha256Raw([]byte(key)))
+}
+
+// IsManagementKey 判断 Authorization 中的凭证是否为管理密钥
+func IsManagementKey(key string) bool {
+	return strings.HasPrefix(key, ManagementKeyPrefix)
+}
+
+func generateManagementKeySecret() (key string, hash string, prefix string, err error) {
+	random, err := common.GenerateRandomCharsKey(48)
+	if err != nil {
+		return "", "", "", err
+	}
+	key = ManagementKeyPrefix + random
+	return key, HashManagementKey(key), key[:len(ManagementKeyPrefix)+8], nil
+}
+
+func splitManagementKeyList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' '
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// NormalizeManagementScopes 校验并规整权限范围列表，role 为密钥所属用户的角色
+func NormalizeManagementScopes(scopes []string, role int) (string, error) {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		resource, access, ok := strings.Cut(scope, ":")
+		if !ok || (access != "read" && access != "write") {
+			return "", errors.New("invalid scope: " + scope)
+		}
+		minRole, exists := managementScopeMinRole[resource]
+		if !exists {
+			return "", errors.New("unknown scope: " + scope)
+		}
+		if role < minRole {
+			return "", errors.New("insufficient role for scope: " + scope)
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	if len(result) == 0 {
+		return "", errors.New("at least one scope is required")
+	}
+	sort.Strings(result)
+	return strings.Join(result, ","), nil
+}
+
+// GetManagementScopeResources 返回所有可授予的资源范围
+func GetManagementScopeResources() []string {
+	resources := make([]string, 0, len(managementScopeMinRole))
+	for resource := range managementScopeMinRole {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+func (k *ManagementKey) GetScopes() []string {
+	return splitManagementKeyList(k.Scopes)
+}
+
+func (k *ManagementKey) GetChannelTags() []string {
+	return splitManagementKeyList(k.ChannelTags)
+}
+
+func (k *ManagementKey) GetAllowIps() []string {
+	return splitManagementKeyList(k.AllowIps)
+}
+
+// HasScope 判断密钥是否拥有资源的读或写权限，write 权限隐含 read
+func (k *ManagementKey) HasScope(resource string, write bool) bool {
+	for _, scope := range k.GetScopes() {
+		r, access, _ := strings.Cut(scope, ":")
+		if r != resource {
+			continue
+		}
+		if access == "write" || !write {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *ManagementKey) IsExpired() bool {
+	return k.ExpiredTime != -1 && k.ExpiredTime <= common.GetTimestamp()
+}
+
+// CreateManagementKey 创建管理密钥，返回仅展示一次的明文密钥
+func CreateManagementKey(key *ManagementKey) (string, error) {
+	var count int64
+	if err := DB.Model(&ManagementKey{}).Where("user_id = ?", key.UserId).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count >= ManagementKeyMaxPerUser {
+		return "", ErrManagementKeyLimitReached
+	}
+	plain, hash, prefix, err := generateManagementKeySecret()
+	if err != nil {
+		return "", err
+	}
+	key.Id = 0
+	key.KeyHash = hash
+	key.KeyPrefix = prefix
+	key.CreatedTime = common.GetTimestamp()
+	key.LastUsedTime = 0
+	key.LastUsedIp = ""
+	if key.Status == 0 {
+		key.Status = ManagementKeyStatusEnabled
+	}
+	if err := DB.Create(key).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+func GetUserManagementKeys(userId int) ([]*ManagementKey, error) {
+	var keys []*ManagementKey
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&keys).Error
+	return keys, err
+}
+
+func GetManagementKeyById(id int, userId int) (*ManagementKey, error) {
+	key := &ManagementKey{}
+	err := DB.Where("id = ? AND user_id = ?", id, userId).First(key).Error
+	if err != nil {
+		return nil, ErrManagementKeyNotFound
+	}
+	return key, nil
+}
+
+// UpdateManagementKey 更新密钥的名称、权限、渠道标签、IP 白名单、状态与有效期，不修改密钥本身
+func UpdateManagementKey(key *ManagementKey) error {
+	return DB.Model(&ManagementKey{}).Where("id = ? AND user_id = ?", key.Id, key.UserId).
+		Select("name", "scopes", "channel_tags", "allow_ips", "status", "expired_time").
+		Updates(key).Error
+}
+
+// RotateManagementKey 轮换密钥，旧密钥立即失效，返回新的明文密钥
+func RotateManagementKey(id int, userId int) (*ManagementKey, string, error) {
+	key, err := GetManagementKeyById(id, userId)
+	if err != nil {
+		return nil, "", err
+	}
+	plain, hash, prefix, err := generateManagementKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+	now := common.GetTimestamp()
+	err = DB.Model(&ManagementKey{}).Where("id = ? AND user_id = ?", id, userId).Updates(map[string]interface{}{
+		"key_hash":     hash,
+		"key_prefix":   prefix,
+		"rotated_time": now,
+	}).Error
+	if err != nil {
+		return nil, "", err
+	}
+	key.KeyHash = hash
+	key.KeyPrefix = prefix
+	key.RotatedTime = now
+	return key, plain, nil
+}
+
+func DeleteManagementKey(id int, userId int) error {
+	result := DB.Where("id = ? AND user_id = ?", id, userId).Delete(&ManagementKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrManagementKeyNotFound
+	}
+	return nil
+}
+
+// ValidateManagementKey 校验管理密钥明文，返回启用且未过期的密钥
+func ValidateManagementKey(key string) (*ManagementKey, error) {
+	key = strings.TrimSpace(strings.TrimPrefix(key, "Bearer "))
+	if !IsManagementKey(key) {
+		return nil, ErrManagementKeyInvalid
+	}
+	managementKey := &ManagementKey{}
+	if DB.Where("key_hash = ?", HashManagementKey(key)).First(managementKey).RowsAffected != 1 {
+		return nil, ErrManagementKeyInvalid
+	}
+	if managementKey.Status != ManagementKeyStatusEnabled {
+		return nil, ErrManagementKeyDisabled
+	}
+	if managementKey.IsExpired() {
+		return nil, ErrManagementKeyExpired
+	}
+	return managementKey, nil
+}
+
+// TouchManagementKey 记录最近使用时间与来源 IP，间隔内的重复调用会被忽略
+func TouchManagementKey(key *ManagementKey, ip string) error {
+	now := common.GetTimestamp()
+	if now-key.LastUsedTime < ManagementKeyTouchInterval && key.LastUsedIp == ip {
+		return nil
+	}
+	return DB.Model(&ManagementKey{}).Where("id = ?", key.Id).Updates(map[string]interface{}{
+		"last_used_time": now,
+		"last_used_ip":   ip,
+	}).Error
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagementKeyScopesAndRotation(t *testing.T) {
+	require.NoError(t, DB.AutoMigrate(&ManagementKey{}))
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM management_keys")
+	})
+
+	// 普通用户不能授予管理员资源
+	_, err := NormalizeManagementScopes([]string{"channels:read"}, common.RoleCommonUser)
+	require.Error(t, err)
+	_, err = NormalizeManagementScopes([]string{"logs:delete"}, common.RoleAdminUser)
+	require.Error(t, err)
+	scopes, err := NormalizeManagementScopes([]string{"logs:read", " Channels:Write ", "logs:read"}, common.RoleAdminUser)
+	require.NoError(t, err)
+	require.Equal(t, "channels:write,logs:read", scopes)
+
+	key := &ManagementKey{UserId: 1, Name: "ci", Scopes: scopes, ExpiredTime: -1}
+	plain, err := CreateManagementKey(key)
+	require.NoError(t, err)
+	require.True(t, IsManagementKey(plain))
+	require.NotEqual(t, plain, key.KeyHash)
+
+	validated, err := ValidateManagementKey("Bearer " + plain)
+	require.NoError(t, err)
+	require.Equal(t, key.Id, validated.Id)
+	require.True(t, validated.HasScope(ManagementScopeLogs, false))
+	require.False(t, validated.HasScope(ManagementScopeLogs, true))
+	require.True(t, validated.HasScope(ManagementScopeChannels, true))
+	require.False(t, validated.HasScope(ManagementScopeUsers, false))
+
+	// 轮换后旧密钥立即失效
+	_, rotated, err := RotateManagementKey(key.Id, 1)
+	require.NoError(t, err)
+	_, err = ValidateManagementKey(plain)
+	require.ErrorIs(t, err, ErrManagementKeyInvalid)
+	_, err = ValidateManagementKey(rotated)
+	require.NoError(t, err)
+
+	key.ExpiredTime = common.GetTimestamp() - 1
+	require.NoError(t, UpdateManagementKey(key))
+	_, err = ValidateManagementKey(rotated)
+	require.ErrorIs(t, err, ErrManagementKeyExpired)
+
+	key.ExpiredTime = -1
+	key.Status = ManagementKeyStatusDisabled
+	require.NoError(t, UpdateManagementKey(key))
+	_, err = ValidateManagementKey(rotated)
+	require.ErrorIs(t, err, ErrManagementKeyDisabled)
+
+	require.NoError(t, DeleteManagementKey(key.Id, 1))
+	require.ErrorIs(t, DeleteManagementKey(key.Id, 1), ErrManagementKeyNotFound)
+}
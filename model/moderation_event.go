@@ -0,0 +1,93 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	ModerationStagePrompt     = "prompt"
+	ModerationStageCompletion = "completion"
+
+	ModerationEventStatusPending   = 1 // 待审核
+	ModerationEventStatusConfirmed = 2 // 确认违规
+	ModerationEventStatusDismissed = 3 // 误报
+)
+
+// ModerationEvent 内容审核命中记录，构成待人工复核的审核队列
+type ModerationEvent struct {
+	Id        int    `json:"id"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	RequestId string `json:"request_id" gorm:"type:varchar(64);index;default:''"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Username  string `json:"username" gorm:"type:varchar(64);default:''"`
+	TokenId   int    `json:"token_id" gorm:"default:0"`
+	Group     string `json:"group" gorm:"type:varchar(64);default:''"`
+	ChannelId int    `json:"channel_id" gorm:"default:0"`
+	ModelName string `json:"model_name" gorm:"type:varchar(128);default:''"`
+	// prompt / completion
+	Stage string `json:"stage" gorm:"type:varchar(16);index"`
+	// 命中的检查器：keyword / regex / model
+	Checker string `json:"checker" gorm:"type:varchar(32);index"`
+	// 命中的屏蔽词、规则或审核类别，逗号分隔
+	Labels string `json:"labels" gorm:"type:text"`
+	// 实际执行的处理方式：block / redact / flag
+	Action string `json:"action" gorm:"type:varchar(16);index"`
+	// 命中位置附近的原文片段
+	Excerpt    string `json:"excerpt" gorm:"type:text"`
+	Status     int    `json:"status" gorm:"type:int;default:1;index"`
+	ReviewerId int    `json:"reviewer_id" gorm:"default:0"`
+	ReviewedAt int64  `json:"reviewed_at" gorm:"bigint;default:0"`
+	Remark     string `json:"remark" gorm:"type:varchar(255);default:''"`
+}
+
+type ModerationEventQuery struct {
+	Status  int
+	Stage   string
+	Checker string
+	UserId  int
+	Group   string
+}
+
+func RecordModerationEvent(event *ModerationEvent) error {
+	if event.CreatedAt == 0 {
+		event.CreatedAt = common.GetTimestamp()
+	}
+	if event.Status == 0 {
+		event.Status = ModerationEventStatusPending
+	}
+	return DB.Create(event).Error
+}
+
+func GetModerationEvents(query *ModerationEventQuery, pageInfo *common.PageInfo) (events []*ModerationEvent, total int64, err error) {
+	tx := DB.Model(&ModerationEvent{})
+	if query.Status != 0 {
+		tx = tx.Where("status = ?", query.Status)
+	}
+	if query.Stage != "" {
+		tx = tx.Where("stage = ?", query.Stage)
+	}
+	if query.Checker != "" {
+		tx = tx.Where("checker = ?", query.Checker)
+	}
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.Group != "" {
+		tx = tx.Where(commonGroupCol+" = ?", query.Group)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&events).Error
+	return events, total, err
+}
+
+// ReviewModerationEvent 更新审核队列记录的复核结果
+func ReviewModerationEvent(id int, status int, reviewerId int, remark string) error {
+	return DB.Model(&ModerationEvent{}).Where("id = ?", id).Updates(map[string]any{
+		"status":      status,
+		"reviewer_id": reviewerId,
+		"reviewed_at": common.GetTimestamp(),
+		"remark":      remark,
+	}).Error
+}
@@ -23,7 +23,23 @@ func AllOption() ([]*Option, error) {
 	var options []*Option
 	var err error
 	err = DB.Find(&options).Error
-	return options, err
+	if err != nil {
+		return options, err
+	}
+	// 敏感配置项可能加密存储，读取时透明解密，无法解密的配置项会被跳过
+	decrypted := options[:0]
+	for _, option := range options {
+		if common.IsEncryptedSecret(option.Value) {
+			value, decryptErr := common.DecryptSecret(option.Value)
+			if decryptErr != nil {
+				common.SysError("failed to decrypt option " + option.Key + ": " + decryptErr.Error())
+				continue
+			}
+			option.Value = value
+		}
+		decrypted = append(decrypted, option)
+	}
+	return decrypted, nil
 }
 
 func InitOptionMap() {
@@ -116,6 +132,8 @@ func InitOptionMap() {
 	common.OptionMap["ModelPrice"] = ratio_setting.ModelPrice2JSONString()
 	common.OptionMap["CacheRatio"] = ratio_setting.CacheRatio2JSONString()
 	common.OptionMap["CreateCacheRatio"] = ratio_setting.CreateCacheRatio2JSONString()
+	common.OptionMap["BatchRatio"] = ratio_setting.BatchRatio2JSONString()
+	common.OptionMap["ModelTieredRatio"] = ratio_setting.ModelTieredRatio2JSONString()
 	common.OptionMap["GroupRatio"] = ratio_setting.GroupRatio2JSONString()
 	common.OptionMap["GroupGroupRatio"] = ratio_setting.GroupGroupRatio2JSONString()
 	common.OptionMap["UserUsableGroups"] = setting.UserUsableGroups2JSONString()
@@ -141,6 +159,7 @@ func InitOptionMap() {
 	common.OptionMap["SelfUseModeEnabled"] = strconv.FormatBool(operation_setting.SelfUseModeEnabled)
 	common.OptionMap["ModelRequestRateLimitEnabled"] = strconv.FormatBool(setting.ModelRequestRateLimitEnabled)
 	common.OptionMap["CheckSensitiveOnPromptEnabled"] = strconv.FormatBool(setting.CheckSensitiveOnPromptEnabled)
+	common.OptionMap["CheckSensitiveOnCompletionEnabled"] = strconv.FormatBool(setting.CheckSensitiveOnCompletionEnabled)
 	common.OptionMap["StopOnSensitiveEnabled"] = strconv.FormatBool(setting.StopOnSensitiveEnabled)
 	common.OptionMap["SensitiveWords"] = setting.SensitiveWordsToString()
 	common.OptionMap["StreamCacheQueueLength"] = strconv.Itoa(setting.StreamCacheQueueLength)
@@ -184,13 +203,21 @@ func UpdateOption(key string, value string) error {
 	}
 	// https://gorm.io/docs/update.html#Save-All-Fields
 	DB.FirstOrCreate(&option, Option{Key: key})
-	option.Value = value
+	storedValue, err := encryptOptionValue(key, value)
+	if err != nil {
+		return err
+	}
+	option.Value = storedValue
 	// Save is a combination function.
 	// If save value does not contain primary key, it will execute Create,
 	// otherwise it will execute Update (with all fields).
 	DB.Save(&option)
 	// Update OptionMap
-	return updateOptionMap(key, value)
+	if err := updateOptionMap(key, value); err != nil {
+		return err
+	}
+	PublishCacheInvalidation(CacheInvalidationMessage{Kind: CacheInvalidationOption, Key: key})
+	return nil
 }
 
 func updateOptionMap(key string, value string) (err error) {
@@ -286,6 +313,8 @@ func updateOptionMap(key string, value string) (err error) {
 			operation_setting.SelfUseModeEnabled = boolValue
 		case "CheckSensitiveOnPromptEnabled":
 			setting.CheckSensitiveOnPromptEnabled = boolValue
+		case "CheckSensitiveOnCompletionEnabled":
+			setting.CheckSensitiveOnCompletionEnabled = boolValue
 		case "ModelRequestRateLimitEnabled":
 			setting.ModelRequestRateLimitEnabled = boolValue
 		case "StopOnSensitiveEnabled":
@@ -430,6 +459,10 @@ func updateOptionMap(key string, value string) (err error) {
 		err = ratio_setting.UpdateCacheRatioByJSONString(value)
 	case "CreateCacheRatio":
 		err = ratio_setting.UpdateCreateCacheRatioByJSONString(value)
+	case "BatchRatio":
+		err = ratio_setting.UpdateBatchRatioByJSONString(value)
+	case "ModelTieredRatio":
+		err = ratio_setting.UpdateModelTieredRatioByJSONString(value)
 	case "ImageRatio":
 		err = ratio_setting.UpdateImageRatioByJSONString(value)
 	case "AudioRatio":
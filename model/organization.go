@@ -0,0 +1,450 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+
+	// 邀请码默认有效期（秒）
+	OrganizationInviteTTL = 7 * 24 * 3600
+)
+
+var (
+	ErrOrganizationNotFound           = errors.New("organization not found")
+	ErrOrganizationDisabled           = errors.New("organization is disabled")
+	ErrOrganizationNotMember          = errors.New("not a member of the organization")
+	ErrOrganizationQuotaInsufficient  = errors.New("organization quota insufficient")
+	ErrOrganizationMemberCapExceeded  = errors.New("organization member spending cap exceeded")
+	ErrOrganizationInviteInvalid      = errors.New("organization invite is invalid or expired")
+	ErrOrganizationAlreadyMember      = errors.New("already a member of the organization")
+	ErrOrganizationUserQuotaNotEnough = errors.New("user quota is not enough")
+	ErrOrganizationQuotaNotEmpty      = errors.New("organization wallet still has quota")
+)
+
+// Organization 组织：成员共享的额度钱包，绑定到组织的令牌从组织钱包扣费
+type Organization struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name" gorm:"type:varchar(64);not null"`
+	OwnerId   int    `json:"owner_id" gorm:"index"`
+	Quota     int    `json:"quota" gorm:"default:0"`
+	UsedQuota int    `json:"used_quota" gorm:"default:0"`
+	Status    int    `json:"status" gorm:"default:1"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+	// 以下字段仅用于返回当前用户在组织中的身份
+	Role string `json:"role,omitempty" gorm:"-"`
+}
+
+// OrganizationMember 组织成员，QuotaLimit 为成员累计可用额度上限，0 表示不限
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_member"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_organization_member;index"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"default:0"`
+	UsedQuota      int    `json:"used_quota" gorm:"default:0"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+	Username       string `json:"username" gorm:"-"`
+}
+
+// OrganizationInvite 组织邀请码，一次性使用
+type OrganizationInvite struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"index"`
+	Code           string `json:"code" gorm:"type:varchar(32);uniqueIndex"`
+	Role           string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	QuotaLimit     int    `json:"quota_limit" gorm:"default:0"`
+	InviterId      int    `json:"inviter_id"`
+	ExpiresAt      int64  `json:"expires_at" gorm:"bigint"`
+	UsedUserId     int    `json:"used_user_id" gorm:"default:0"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	}
+	return false
+}
+
+// CanManage 所有者与管理员可以管理成员、邀请和组织钱包
+func (m *OrganizationMember) CanManage() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	now := common.GetTimestamp()
+	org := &Organization{
+		Name:      strings.TrimSpace(name),
+		OwnerId:   ownerId,
+		Status:    OrganizationStatusEnabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: org.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedAt:      now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	org.Role = OrganizationRoleOwner
+	return org, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var org Organization
+	if err := DB.First(&org, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := DB.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotMember
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetUserOrganizations 返回用户加入的所有组织，Role 为用户在组织中的角色
+func GetUserOrganizations(userId int) ([]*Organization, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return []*Organization{}, nil
+	}
+	roles := make(map[int]string, len(members))
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		roles[member.OrganizationId] = member.Role
+		ids = append(ids, member.OrganizationId)
+	}
+	var orgs []*Organization
+	if err := DB.Where("id IN ?", ids).Order("id desc").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		org.Role = roles[org.Id]
+	}
+	return orgs, nil
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+func UpdateOrganization(org *Organization) error {
+	org.UpdatedAt = common.GetTimestamp()
+	return DB.Model(org).Select("name", "status", "updated_at").Updates(org).Error
+}
+
+// DeleteOrganization 删除组织，组织钱包仍有余额时拒绝删除，绑定的令牌恢复为个人计费
+func DeleteOrganization(orgId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var org Organization
+		if err := tx.First(&org, "id = ?", orgId).Error; err != nil {
+			return err
+		}
+		// 组织钱包由成员转入的额度组成，余额不能退给所有者，需用完后才能删除
+		if org.Quota > 0 {
+			return ErrOrganizationQuotaNotEmpty
+		}
+		if err := tx.Model(&Token{}).Where("organization_id = ?", orgId).Update("organization_id", 0).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", orgId).Delete(&OrganizationInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", orgId).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&org).Error
+	})
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("organization_id = ?", orgId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return members, nil
+	}
+	userIds := make([]int, 0, len(members))
+	for _, member := range members {
+		userIds = append(userIds, member.UserId)
+	}
+	var users []*User
+	if err := DB.Select("id", "username").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(users))
+	for _, user := range users {
+		names[user.Id] = user.Username
+	}
+	for _, member := range members {
+		member.Username = names[member.UserId]
+	}
+	return members, nil
+}
+
+// UpdateOrganizationMember 更新成员角色与额度上限，resetUsed 为 true 时清零成员已用额度
+func UpdateOrganizationMember(member *OrganizationMember, resetUsed bool) error {
+	updates := map[string]interface{}{
+		"role":        member.Role,
+		"quota_limit": member.QuotaLimit,
+	}
+	if resetUsed {
+		updates["used_quota"] = 0
+	}
+	return DB.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(updates).Error
+}
+
+// RemoveOrganizationMember 移除成员，并解除该成员令牌与组织的绑定
+func RemoveOrganizationMember(orgId int, userId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Token{}).Where("organization_id = ? AND user_id = ?", orgId, userId).Update("organization_id", 0).Error; err != nil {
+			return err
+		}
+		return tx.Where("organization_id = ? AND user_id = ?", orgId, userId).Delete(&OrganizationMember{}).Error
+	})
+}
+
+func CreateOrganizationInvite(invite *OrganizationInvite) error {
+	invite.Code = strings.ReplaceAll(common.GetUUID(), "-", "")
+	invite.CreatedAt = common.GetTimestamp()
+	if invite.ExpiresAt == 0 {
+		invite.ExpiresAt = invite.CreatedAt + OrganizationInviteTTL
+	}
+	return DB.Create(invite).Error
+}
+
+// GetOrganizationInvites 返回组织尚未使用的邀请码
+func GetOrganizationInvites(orgId int) ([]*OrganizationInvite, error) {
+	var invites []*OrganizationInvite
+	err := DB.Where("organization_id = ? AND used_user_id = 0", orgId).Order("id desc").Find(&invites).Error
+	return invites, err
+}
+
+func DeleteOrganizationInvite(orgId int, inviteId int) error {
+	return DB.Where("id = ? AND organization_id = ?", inviteId, orgId).Delete(&OrganizationInvite{}).Error
+}
+
+// AcceptOrganizationInvite 使用邀请码加入组织
+func AcceptOrganizationInvite(code string, userId int) (*Organization, error) {
+	var org *Organization
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var invite OrganizationInvite
+		if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+			return ErrOrganizationInviteInvalid
+		}
+		if invite.UsedUserId != 0 || invite.ExpiresAt < common.GetTimestamp() {
+			return ErrOrganizationInviteInvalid
+		}
+		var count int64
+		if err := tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", invite.OrganizationId, userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOrganizationAlreadyMember
+		}
+		// 条件更新保证邀请码只能被使用一次
+		result := tx.Model(&OrganizationInvite{}).Where("id = ? AND used_user_id = 0", invite.Id).Update("used_user_id", userId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationInviteInvalid
+		}
+		var o Organization
+		if err := tx.First(&o, "id = ?", invite.OrganizationId).Error; err != nil {
+			return ErrOrganizationNotFound
+		}
+		org = &o
+		org.Role = invite.Role
+		return tx.Create(&OrganizationMember{
+			OrganizationId: invite.OrganizationId,
+			UserId:         userId,
+			Role:           invite.Role,
+			QuotaLimit:     invite.QuotaLimit,
+			CreatedAt:      common.GetTimestamp(),
+		}).Error
+	})
+	return org, err
+}
+
+// AdjustOrganizationQuota 管理员直接调整组织钱包余额，delta 可为负数
+func AdjustOrganizationQuota(orgId int, delta int) error {
+	return DB.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota + ?", delta),
+		"updated_at": common.GetTimestamp(),
+	}).Error
+}
+
+// TransferUserQuotaToOrganization 将个人钱包额度转入组织钱包
+func TransferUserQuotaToOrganization(userId int, orgId int, amount int) error {
+	if amount <= 0 {
+		return errors.New("quota 必须大于 0")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ? AND quota >= ?", userId, amount).Update("quota", gorm.Expr("quota - ?", amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationUserQuotaNotEnough
+		}
+		return tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota + ?", amount),
+			"updated_at": common.GetTimestamp(),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if err := cacheDecrUserQuota(userId, int64(amount)); err != nil {
+		common.SysLog("failed to decrease user quota cache: " + err.Error())
+	}
+	return nil
+}
+
+// PreConsumeOrganizationQuota 校验成员身份、成员额度上限与组织余额后从组织钱包预扣，
+// amount 为 0 时只做校验
+func PreConsumeOrganizationQuota(orgId int, userId int, amount int) error {
+	if amount < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var org Organization
+		if err := tx.First(&org, "id = ?", orgId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return err
+		}
+		if org.Status != OrganizationStatusEnabled {
+			return ErrOrganizationDisabled
+		}
+		var member OrganizationMember
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotMember
+			}
+			return err
+		}
+		if org.Quota <= 0 {
+			return ErrOrganizationQuotaInsufficient
+		}
+		if member.QuotaLimit > 0 && member.UsedQuota >= member.QuotaLimit {
+			return ErrOrganizationMemberCapExceeded
+		}
+		if amount == 0 {
+			return nil
+		}
+		// 条件更新避免并发请求把余额或成员上限扣穿
+		result := tx.Model(&Organization{}).Where("id = ? AND quota >= ?", orgId, amount).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", amount),
+			"used_quota": gorm.Expr("used_quota + ?", amount),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationQuotaInsufficient
+		}
+		result = tx.Model(&OrganizationMember{}).
+			Where("id = ? AND (quota_limit = 0 OR used_quota + ? <= quota_limit)", member.Id, amount).
+			Update("used_quota", gorm.Expr("used_quota + ?", amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationMemberCapExceeded
+		}
+		return nil
+	})
+}
+
+// PostConsumeOrganizationQuota 按差额调整组织钱包与成员已用额度（正数补扣，负数退还），
+// 与钱包结算一致，补扣不再校验余额
+func PostConsumeOrganizationQuota(orgId int, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", delta),
+			"used_quota": gorm.Expr("used_quota + ?", delta),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", delta)).Error
+	})
+}
+
+// OrganizationUsageItem 组织用量按成员汇总
+type OrganizationUsageItem struct {
+	UserId           int    `json:"user_id"`
+	Username         string `json:"username"`
+	Count            int64  `json:"count"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+}
+
+// GetOrganizationUsageByMember 按成员汇总组织令牌产生的消费日志
+func GetOrganizationUsageByMember(orgId int, startTimestamp int64, endTimestamp int64) ([]*OrganizationUsageItem, error) {
+	tx := LOG_DB.Model(&Log{}).
+		Select("user_id, MAX(username) AS username, COUNT(*) AS count, COALESCE(SUM(quota), 0) AS quota, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens").
+		Where("organization_id = ? AND type = ?", orgId, LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	var items []*OrganizationUsageItem
+	err := tx.Group("user_id").Order("quota desc").Scan(&items).Error
+	return items, err
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizationWalletAndMemberCap(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.AutoMigrate(&Organization{}, &OrganizationMember{}, &OrganizationInvite{}))
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM organizations")
+		DB.Exec("DELETE FROM organization_members")
+		DB.Exec("DELETE FROM organization_invites")
+	})
+
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "owner", Quota: 1000}).Error)
+	org, err := CreateOrganization("acme", 1)
+	require.NoError(t, err)
+	require.NoError(t, TransferUserQuotaToOrganization(1, org.Id, 800))
+	require.ErrorIs(t, TransferUserQuotaToOrganization(1, org.Id, 800), ErrOrganizationUserQuotaNotEnough)
+
+	invite := &OrganizationInvite{OrganizationId: org.Id, Role: OrganizationRoleMember, QuotaLimit: 300, InviterId: 1}
+	require.NoError(t, CreateOrganizationInvite(invite))
+	_, err = AcceptOrganizationInvite(invite.Code, 2)
+	require.NoError(t, err)
+	// 邀请码只能使用一次
+	_, err = AcceptOrganizationInvite(invite.Code, 3)
+	require.ErrorIs(t, err, ErrOrganizationInviteInvalid)
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 3, 10), ErrOrganizationNotMember)
+
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 2, 200))
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 2, 200), ErrOrganizationMemberCapExceeded)
+	// 结算退还部分预扣后成员可以继续使用
+	require.NoError(t, PostConsumeOrganizationQuota(org.Id, 2, -50))
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 2, 150))
+
+	// 所有者不受成员上限限制，但受组织余额限制
+	require.ErrorIs(t, PreConsumeOrganizationQuota(org.Id, 1, 600), ErrOrganizationQuotaInsufficient)
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 1, 500))
+
+	org, err = GetOrganizationById(org.Id)
+	require.NoError(t, err)
+	require.Equal(t, 0, org.Quota)
+	require.Equal(t, 800, org.UsedQuota)
+	member, err := GetOrganizationMember(org.Id, 2)
+	require.NoError(t, err)
+	require.Equal(t, 300, member.UsedQuota)
+
+	// 组织钱包仍有成员转入的额度时不能删除，避免所有者借删除占有成员资金
+	require.NoError(t, TransferUserQuotaToOrganization(1, org.Id, 100))
+	require.ErrorIs(t, DeleteOrganization(org.Id), ErrOrganizationQuotaNotEmpty)
+	require.NoError(t, PreConsumeOrganizationQuota(org.Id, 1, 100))
+	require.NoError(t, DeleteOrganization(org.Id))
+	_, err = GetOrganizationById(org.Id)
+	require.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+)
+
+// PayloadCapture 一次请求的载荷采集记录，通过 RequestId 与消费日志关联，保存在日志库中
+type PayloadCapture struct {
+	Id           int    `json:"id"`
+	RequestId    string `json:"request_id" gorm:"type:varchar(64);index"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	TokenId      int    `json:"token_id" gorm:"default:0"`
+	ChannelId    int    `json:"channel_id" gorm:"default:0"`
+	ModelName    string `json:"model_name" gorm:"type:varchar(128);default:''"`
+	Path         string `json:"path" gorm:"type:varchar(255);default:''"`
+	StatusCode   int    `json:"status_code" gorm:"default:0"`
+	IsStream     bool   `json:"is_stream"`
+	RequestBody  string `json:"request_body" gorm:"type:text"`
+	ResponseBody string `json:"response_body" gorm:"type:text"`
+	// 流式响应拼接后的输出文本
+	ResponseText string `json:"response_text" gorm:"type:text"`
+	Truncated    bool   `json:"truncated"`
+}
+
+func RecordPayloadCapture(capture *PayloadCapture) error {
+	if capture.CreatedAt == 0 {
+		capture.CreatedAt = common.GetTimestamp()
+	}
+	return LOG_DB.Create(capture).Error
+}
+
+// GetPayloadCapturesByRequestId 按请求 ID 查询采集记录
+func GetPayloadCapturesByRequestId(requestId string) ([]*PayloadCapture, error) {
+	var captures []*PayloadCapture
+	err := LOG_DB.Where("request_id = ?", requestId).Order("id desc").Find(&captures).Error
+	return captures, err
+}
+
+// DeleteExpiredPayloadCaptures 分批删除早于 targetTimestamp 的采集记录
+func DeleteExpiredPayloadCaptures(targetTimestamp int64, limit int) (int64, error) {
+	var total int64 = 0
+	for {
+		result := LOG_DB.Where("created_at < ?", targetTimestamp).Limit(limit).Delete(&PayloadCapture{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			break
+		}
+	}
+	return total, nil
+}
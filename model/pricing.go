@@ -10,6 +10,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 )
@@ -30,11 +31,19 @@ type Pricing struct {
 	ImageRatio             *float64                `json:"image_ratio,omitempty"`
 	AudioRatio             *float64                `json:"audio_ratio,omitempty"`
 	AudioCompletionRatio   *float64                `json:"audio_completion_ratio,omitempty"`
+	TieredRatios           []types.PriceTier       `json:"tiered_ratios,omitempty"`
+	TimePriceWindows       []PricingTimeWindow     `json:"time_price_windows,omitempty"`
 	EnableGroup            []string                `json:"enable_groups"`
 	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
 	PricingVersion         string                  `json:"pricing_version,omitempty"`
 }
 
+// PricingTimeWindow 作用于模型的分时价格窗口
+type PricingTimeWindow struct {
+	operation_setting.TimePriceWindow
+	Timezone string `json:"timezone"`
+}
+
 type PricingVendor struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
@@ -301,6 +310,9 @@ func updatePricing() {
 			pricing.ModelRatio = modelRatio
 			pricing.CompletionRatio = ratio_setting.GetCompletionRatio(model)
 			pricing.QuotaType = 0
+			if tiers, ok := ratio_setting.GetModelTieredRatio(model); ok {
+				pricing.TieredRatios = tiers
+			}
 		}
 		if cacheRatio, ok := ratio_setting.GetCacheRatio(model); ok {
 			pricing.CacheRatio = &cacheRatio
@@ -319,6 +331,7 @@ func updatePricing() {
 			audioCompletionRatio := ratio_setting.GetAudioCompletionRatio(model)
 			pricing.AudioCompletionRatio = &audioCompletionRatio
 		}
+		pricing.TimePriceWindows = getPricingTimeWindows(model)
 		pricingMap = append(pricingMap, pricing)
 	}
 
@@ -344,3 +357,19 @@ func updatePricing() {
 func GetSupportedEndpointMap() map[string]common.EndpointInfo {
 	return supportedEndpointMap
 }
+
+// getPricingTimeWindows 返回作用于模型的分时价格窗口，未启用时返回空
+func getPricingTimeWindows(model string) []PricingTimeWindow {
+	setting := operation_setting.GetTimePriceSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	timezone := operation_setting.GetTimePriceLocation().String()
+	var windows []PricingTimeWindow
+	for _, window := range setting.Windows {
+		if window.MatchesModel(model) {
+			windows = append(windows, PricingTimeWindow{TimePriceWindow: window, Timezone: timezone})
+		}
+	}
+	return windows
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("secret", SecretSerializer{})
+}
+
+// SecretSerializer 字段级透明加解密：写入时按配置加密，读取时自动解密（兼容未加密的旧数据）。
+// 注意 Update("col", value) 等以 map 更新的方式不经过序列化器，需要手动调用 common.EncryptSecretIfEnabled。
+type SecretSerializer struct{}
+
+func (SecretSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+		value = ""
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("failed to scan secret field %s: unsupported type %T", field.Name, dbValue)
+	}
+	plaintext, err := common.DecryptSecret(value)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt field %s: %w", field.Name, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+func (SecretSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, _ := fieldValue.(string)
+	return common.EncryptSecretIfEnabled(value)
+}
+
+// UpdateChannelKey 单独更新渠道密钥
+func UpdateChannelKey(channelId int, key string) error {
+	encrypted, err := common.EncryptSecretIfEnabled(key)
+	if err != nil {
+		return err
+	}
+	return DB.Model(&Channel{}).Where("id = ?", channelId).Update("key", encrypted).Error
+}
+
+// sensitiveOptionKeys 需要加密存储的配置项，此外所有以 secret 结尾的配置项（如 oidc.client_secret）也会加密
+var sensitiveOptionKeys = map[string]bool{
+	"SMTPToken":          true,
+	"EpayKey":            true,
+	"CreemApiKey":        true,
+	"WorkerValidKey":     true,
+	"TelegramBotToken":   true,
+	"WeChatServerToken":  true,
+	"TurnstileSecretKey": true,
+}
+
+// IsSensitiveOption 判断配置项是否需要加密存储
+func IsSensitiveOption(key string) bool {
+	return sensitiveOptionKeys[key] || strings.HasSuffix(strings.ToLower(key), "secret")
+}
+
+func encryptOptionValue(key string, value string) (string, error) {
+	if !IsSensitiveOption(key) {
+		return value, nil
+	}
+	return common.EncryptSecretIfEnabled(value)
+}
+
+// SecretMigrationResult 各类敏感数据迁移的条数
+type SecretMigrationResult struct {
+	Channels       int `json:"channels"`
+	OAuthProviders int `json:"oauth_providers"`
+	Webhooks       int `json:"webhooks"`
+	Options        int `json:"options"`
+}
+
+func quoteSecretColumn(name string) string {
+	if common.UsingPostgreSQL {
+		return `"` + name + `"`
+	}
+	return "`" + name + "`"
+}
+
+// rewrapColumn 将表中某列逐行迁移到当前主密钥，直接读写原始列值以绕过序列化器
+func rewrapColumn(table string, idColumn string, column string, filter func(id string) bool) (int, error) {
+	var rows []map[string]interface{}
+	err := DB.Table(table).Select(quoteSecretColumn(idColumn) + " AS id, " + quoteSecretColumn(column) + " AS value").
+		Where(quoteSecretColumn(column) + " <> ''").Find(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	changedCount := 0
+	for _, row := range rows {
+		id := fmt.Sprint(row["id"])
+		if filter != nil && !filter(id) {
+			continue
+		}
+		original := ""
+		switch v := row["value"].(type) {
+		case string:
+			original = v
+		case []byte:
+			original = string(v)
+		}
+		value, changed, err := common.RewrapSecret(original)
+		if err != nil {
+			return changedCount, fmt.Errorf("%s %s: %w", table, id, err)
+		}
+		if !changed {
+			continue
+		}
+		// 条件更新，避免覆盖迁移期间被其他节点修改的值
+		result := DB.Table(table).
+			Where(quoteSecretColumn(idColumn)+" = ? AND "+quoteSecretColumn(column)+" = ?", row["id"], original).
+			Updates(map[string]interface{}{column: value})
+		if result.Error != nil {
+			return changedCount, result.Error
+		}
+		changedCount += int(result.RowsAffected)
+	}
+	return changedCount, nil
+}
+
+// MigrateSecretEncryption 加密尚未加密的渠道密钥、OAuth client secret、Webhook 签名密钥与敏感配置项，
+// 并将旧主密钥加密的数据迁移到当前主密钥。旧主密钥需保留在密钥环中直到迁移完成，
+// 迁移期间各节点仍可正常解密新旧两种数据，因此可以在线执行。
+func MigrateSecretEncryption() (*SecretMigrationResult, error) {
+	if common.GetSecretActiveKeyId() == "" {
+		return nil, common.ErrSecretKeyringEmpty
+	}
+	result := &SecretMigrationResult{}
+	var err error
+	if result.Channels, err = rewrapColumn("channels", "id", "key", nil); err != nil {
+		return result, err
+	}
+	if result.OAuthProviders, err = rewrapColumn(CustomOAuthProvider{}.TableName(), "id", "client_secret", nil); err != nil {
+		return result, err
+	}
+	if result.Webhooks, err = rewrapColumn("webhook_endpoints", "id", "secret", nil); err != nil {
+		return result, err
+	}
+	if result.Options, err = rewrapColumn("options", "key", "value", IsSensitiveOption); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelKeyEncryptionAndRotation(t *testing.T) {
+	truncateTables(t)
+	oldKey := make([]byte, 32)
+	newKey := make([]byte, 32)
+	newKey[0] = 1
+	common.SetSecretKeyring(map[string][]byte{"k1": oldKey}, "k1")
+	t.Cleanup(func() {
+		common.SecretEncryptionEnabled = false
+		common.SetSecretKeyring(map[string][]byte{}, "")
+	})
+
+	rawKey := func(id int) string {
+		var value string
+		DB.Table("channels").Select("key").Where("id = ?", id).Scan(&value)
+		return value
+	}
+
+	// 未启用加密时按明文写入，启用后旧数据仍可正常读取
+	plain := &Channel{Id: 1, Name: "plain", Key: "sk-plain"}
+	require.NoError(t, DB.Create(plain).Error)
+	require.Equal(t, "sk-plain", rawKey(1))
+
+	common.SecretEncryptionEnabled = true
+	encrypted := &Channel{Id: 2, Name: "encrypted", Key: "sk-secret"}
+	require.NoError(t, DB.Create(encrypted).Error)
+	require.Equal(t, "sk-secret", encrypted.Key)
+	require.True(t, common.IsEncryptedSecret(rawKey(2)))
+
+	loaded, err := GetChannelById(2, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-secret", loaded.Key)
+
+	require.NoError(t, UpdateChannelKey(2, "sk-updated"))
+	require.True(t, common.IsEncryptedSecret(rawKey(2)))
+	loaded, err = GetChannelById(2, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-updated", loaded.Key)
+
+	// 轮换主密钥：迁移加密明文数据并重新包装旧主密钥加密的数据
+	common.SetSecretKeyring(map[string][]byte{"k1": oldKey, "k2": newKey}, "k2")
+	count, err := rewrapColumn("channels", "id", "key", nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	require.Contains(t, rawKey(1), common.SecretEnvelopePrefix+"k2:")
+	require.Contains(t, rawKey(2), common.SecretEnvelopePrefix+"k2:")
+
+	// 移除旧主密钥后仍可解密
+	common.SetSecretKeyring(map[string][]byte{"k2": newKey}, "k2")
+	loaded, err = GetChannelById(1, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-plain", loaded.Key)
+	loaded, err = GetChannelById(2, true)
+	require.NoError(t, err)
+	require.Equal(t, "sk-updated", loaded.Key)
+}
+
+func TestWebhookSecretEncryptedAndHidden(t *testing.T) {
+	require.NoError(t, DB.AutoMigrate(&WebhookEndpoint{}))
+	common.SetSecretKeyring(map[string][]byte{"k1": make([]byte, 32)}, "k1")
+	common.SecretEncryptionEnabled = true
+	t.Cleanup(func() {
+		common.SecretEncryptionEnabled = false
+		common.SetSecretKeyring(map[string][]byte{}, "")
+		DB.Exec("DELETE FROM webhook_endpoints")
+	})
+
+	endpoint := &WebhookEndpoint{UserId: 1, Name: "hook", Url: "https://example.com/hook", Secret: "whsec-test", Events: "*"}
+	require.NoError(t, endpoint.Insert())
+	var raw string
+	DB.Table("webhook_endpoints").Select("secret").Where("id = ?", endpoint.Id).Scan(&raw)
+	require.True(t, common.IsEncryptedSecret(raw))
+
+	loaded, err := GetWebhookEndpointById(endpoint.Id)
+	require.NoError(t, err)
+	require.Equal(t, "whsec-test", loaded.Secret)
+
+	data, err := json.Marshal(loaded)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "whsec-test")
+}
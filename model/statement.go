@@ -0,0 +1,91 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/shopspring/decimal"
+)
+
+// StatementModelUsage 对账周期内按模型汇总的消费
+type StatementModelUsage struct {
+	ModelName        string `json:"model_name"`
+	Count            int64  `json:"count"`
+	Quota            int64  `json:"quota"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+}
+
+// CreditedQuota 返回充值订单到账的额度，与各支付渠道完成订单时的计算方式一致：
+// Stripe 订单按 Money 换算，Creem 订单 Amount 即为额度，其他订单按 Amount 换算
+func (topUp *TopUp) CreditedQuota() int64 {
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	switch topUp.PaymentMethod {
+	case "stripe":
+		return decimal.NewFromFloat(topUp.Money).Mul(dQuotaPerUnit).IntPart()
+	case "creem":
+		return topUp.Amount
+	}
+	return decimal.NewFromInt(topUp.Amount).Mul(dQuotaPerUnit).IntPart()
+}
+
+// GetStatementTopUps 查询时间范围内完成的额度充值订单，订阅购买产生的订单不计入
+func GetStatementTopUps(userId int, startTime int64, endTime int64) (topUps []*TopUp, err error) {
+	err = DB.Where("user_id = ? AND status = ? AND complete_time >= ? AND complete_time < ?", userId, common.TopUpStatusSuccess, startTime, endTime).
+		Where("trade_no NOT IN (?)", DB.Model(&SubscriptionOrder{}).Select("trade_no").Where("user_id = ?", userId)).
+		Order("complete_time asc").Find(&topUps).Error
+	return topUps, err
+}
+
+// GetStatementRedemptions 查询时间范围内用户使用的兑换码，包括已删除的兑换码
+func GetStatementRedemptions(userId int, startTime int64, endTime int64) (redemptions []*Redemption, err error) {
+	err = DB.Unscoped().Where("used_user_id = ? AND redeemed_time >= ? AND redeemed_time < ?", userId, startTime, endTime).
+		Order("redeemed_time asc").Find(&redemptions).Error
+	return redemptions, err
+}
+
+// GetStatementSubscriptionOrders 查询时间范围内完成的订阅购买订单
+func GetStatementSubscriptionOrders(userId int, startTime int64, endTime int64) (orders []*SubscriptionOrder, err error) {
+	err = DB.Where("user_id = ? AND status = ? AND complete_time >= ? AND complete_time < ?", userId, common.TopUpStatusSuccess, startTime, endTime).
+		Order("complete_time asc").Find(&orders).Error
+	return orders, err
+}
+
+// GetSubscriptionPlanTitles 批量查询套餐名称
+func GetSubscriptionPlanTitles(planIds []int) (map[int]string, error) {
+	titles := make(map[int]string, len(planIds))
+	if len(planIds) == 0 {
+		return titles, nil
+	}
+	var plans []SubscriptionPlan
+	if err := DB.Select("id, title").Where("id IN ?", planIds).Find(&plans).Error; err != nil {
+		return titles, err
+	}
+	for _, plan := range plans {
+		titles[plan.Id] = plan.Title
+	}
+	return titles, nil
+}
+
+// GetStatementModelUsage 按模型汇总时间范围内的消费日志，tokenId 非 0 时只统计该令牌
+func GetStatementModelUsage(userId int, tokenId int, startTime int64, endTime int64) (usage []*StatementModelUsage, err error) {
+	tx := LOG_DB.Model(&Log{}).
+		Select("model_name, count(*) as count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens").
+		Where("user_id = ? AND type = ? AND created_at >= ? AND created_at < ?", userId, LogTypeConsume, startTime, endTime)
+	if tokenId != 0 {
+		tx = tx.Where("token_id = ?", tokenId)
+	}
+	err = tx.Group("model_name").Order("model_name asc").Find(&usage).Error
+	return usage, err
+}
+
+// SumStatementLogQuota 汇总时间范围内指定类型日志的额度，用于计算消费与退款
+func SumStatementLogQuota(userId int, tokenId int, logType int, startTime int64, endTime int64) (int64, error) {
+	var quota int64
+	tx := LOG_DB.Model(&Log{}).Select("COALESCE(sum(quota), 0)").
+		Where("user_id = ? AND type = ? AND created_at >= ? AND created_at < ?", userId, logType, startTime, endTime)
+	if tokenId != 0 {
+		tx = tx.Where("token_id = ?", tokenId)
+	}
+	err := tx.Scan(&quota).Error
+	return quota, err
+}
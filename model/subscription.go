@@ -135,6 +135,14 @@ func InvalidateSubscriptionPlanCache(planId int) {
 	if planId <= 0 {
 		return
 	}
+	invalidateSubscriptionPlanCacheLocal(planId)
+	// 启用 Redis 时缓存由各节点共享，只有内存缓存需要通知其他节点
+	if !common.RedisEnabled {
+		PublishCacheInvalidation(CacheInvalidationMessage{Kind: CacheInvalidationSubscription, Id: planId})
+	}
+}
+
+func invalidateSubscriptionPlanCacheLocal(planId int) {
 	cache := getSubscriptionPlanCache()
 	_, _ = cache.DeleteMany([]string{subscriptionPlanCacheKey(planId)})
 	infoCache := getSubscriptionPlanInfoCache()
@@ -806,7 +814,8 @@ type SubscriptionPreConsumeResult struct {
 }
 
 // ExpireDueSubscriptions marks expired subscriptions and handles group downgrade.
-func ExpireDueSubscriptions(limit int) (int, error) {
+// It returns the number of expired rows and the due subscriptions that were expired.
+func ExpireDueSubscriptions(limit int) (int, []UserSubscription, error) {
 	if limit <= 0 {
 		limit = 200
 	}
@@ -816,12 +825,13 @@ func ExpireDueSubscriptions(limit int) (int, error) {
 		Order("end_time asc, id asc").
 		Limit(limit).
 		Find(&subs).Error; err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	if len(subs) == 0 {
-		return 0, nil
+		return 0, nil, nil
 	}
 	expiredCount := 0
+	expired := make([]UserSubscription, 0, len(subs))
 	userIds := make(map[int]struct{}, len(subs))
 	for _, sub := range subs {
 		if sub.UserId > 0 {
@@ -883,13 +893,19 @@ func ExpireDueSubscriptions(limit int) (int, error) {
 			return nil
 		})
 		if err != nil {
-			return expiredCount, err
+			return expiredCount, expired, err
 		}
 		if cacheGroup != "" {
 			_ = UpdateUserGroupCache(userId, cacheGroup)
 		}
+		for _, sub := range subs {
+			if sub.UserId == userId {
+				sub.Status = "expired"
+				expired = append(expired, sub)
+			}
+		}
 	}
-	return expiredCount, nil
+	return expiredCount, expired, nil
 }
 
 // SubscriptionPreConsumeRecord stores idempotent pre-consume operations per request.
@@ -39,6 +39,8 @@ const (
 	TaskStatusFailure               = "FAILURE"
 	TaskStatusSuccess               = "SUCCESS"
 	TaskStatusUnknown               = "UNKNOWN"
+	// 批处理取消中，与 IN_PROGRESS 区分，避免进度同步覆盖取消请求
+	TaskStatusCancelling = "CANCELLING"
 )
 
 type Task struct {
@@ -103,8 +105,11 @@ type TaskPrivateData struct {
 	// 计费上下文：用于异步退款/差额结算（轮询阶段读取）
 	BillingSource  string              `json:"billing_source,omitempty"`  // "wallet" 或 "subscription"
 	SubscriptionId int                 `json:"subscription_id,omitempty"` // 订阅 ID，用于订阅退款
+	OrganizationId int                 `json:"organization_id,omitempty"` // 组织 ID，用于组织钱包退款
 	TokenId        int                 `json:"token_id,omitempty"`        // 令牌 ID，用于令牌额度退款
 	BillingContext *TaskBillingContext `json:"billing_context,omitempty"` // 计费参数快照（用于轮询阶段重新计算）
+	KeyIndex       int                 `json:"key_index,omitempty"`       // 多密钥渠道提交时使用的密钥下标
+	ClientIp       string              `json:"client_ip,omitempty"`       // 提交时的客户端 IP（批处理本地执行时用于令牌 IP 限制）
 }
 
 // TaskBillingContext 记录任务提交时的计费参数，以便轮询阶段可以重新计算额度。
@@ -141,6 +146,12 @@ func GenerateTaskID() string {
 	return "task_" + key
 }
 
+// GenerateBatchID 生成对外暴露的 batch_xxxx 格式 ID
+func GenerateBatchID() string {
+	key, _ := common.GenerateRandomCharsKey(32)
+	return "batch_" + key
+}
+
 func (p *TaskPrivateData) Scan(val interface{}) error {
 	bytesValue, _ := val.([]byte)
 	if len(bytesValue) == 0 {
@@ -291,8 +302,10 @@ func TaskGetAllTasks(startIdx int, num int, queryParams SyncTaskQueryParams) []*
 
 func GetTimedOutUnfinishedTasks(cutoffUnix int64, limit int) []*Task {
 	var tasks []*Task
+	// 批处理有自己的完成窗口与过期处理，不参与通用超时清理
 	err := DB.Where("progress != ?", "100%").
 		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess}).
+		Where("platform != ?", constant.TaskPlatformBatch).
 		Where("submit_time < ?", cutoffUnix).
 		Order("submit_time").
 		Limit(limit).
@@ -343,6 +356,24 @@ func GetByTaskId(userId int, taskId string) (*Task, bool, error) {
 	return task, exist, err
 }
 
+// GetUserBatchTasks 按 OpenAI 列表语义分页查询用户的批处理：after 为上一页最后一个批处理 ID
+func GetUserBatchTasks(userId int, after string, limit int) ([]*Task, error) {
+	var tasks []*Task
+	tx := DB.Where("user_id = ? and platform = ?", userId, constant.TaskPlatformBatch)
+	if after != "" {
+		cursor, exist, err := GetByTaskId(userId, after)
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			return tasks, nil
+		}
+		tx = tx.Where("id < ?", cursor.ID)
+	}
+	err := tx.Order("id desc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
 func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 	if len(taskIds) == 0 {
 		return nil, nil
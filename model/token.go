@@ -29,6 +29,20 @@ type Token struct {
 	Group              string         `json:"group" gorm:"default:''"`
 	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
+	// 周期预算：BudgetPeriod 为 day/week/month 时每个周期最多消耗 BudgetQuota，空表示不限；
+	// BudgetUsedQuota 为 BudgetWindowStart 起始周期内的已用额度
+	BudgetPeriod      string `json:"budget_period" gorm:"type:varchar(16);default:''"`
+	BudgetQuota       int    `json:"budget_quota" gorm:"default:0"`
+	BudgetUsedQuota   int    `json:"budget_used_quota" gorm:"default:0"`
+	BudgetWindowStart int64  `json:"budget_window_start" gorm:"bigint;default:0"`
+	// 令牌限流：每分钟请求数、每分钟 token 数、最大并发请求数，0 表示不限；
+	// ModelRateLimits 为按模型单独计数的限流规则（JSON）
+	RateLimitRPM         int    `json:"rate_limit_rpm" gorm:"default:0"`
+	RateLimitTPM         int    `json:"rate_limit_tpm" gorm:"default:0"`
+	RateLimitConcurrency int    `json:"rate_limit_concurrency" gorm:"default:0"`
+	ModelRateLimits      string `json:"model_rate_limits" gorm:"type:text"`
+	// 组织令牌：不为 0 时从组织钱包扣费，令牌所有者需为组织成员
+	OrganizationId int `json:"organization_id" gorm:"index;default:0"`
 }
 
 func (token *Token) Clean() {
@@ -203,6 +217,9 @@ func ValidateUserToken(key string) (token *Token, err error) {
 			keySuffix := key[len(key)-3:]
 			return token, errors.New(fmt.Sprintf("[sk-%s***%s] 该令牌额度已用尽 !token.UnlimitedQuota && token.RemainQuota = %d", keyPrefix, keySuffix, token.RemainQuota))
 		}
+		if token.IsBudgetEnabled() && token.GetBudgetRemainQuota() <= 0 {
+			return token, token.budgetExhaustedError()
+		}
 		return token, nil
 	}
 	common.SysLog("ValidateUserToken: failed to get token: " + err.Error())
@@ -230,6 +247,9 @@ func GetTokenById(id int) (*Token, error) {
 	token := Token{Id: id}
 	var err error = nil
 	err = DB.First(&token, "id = ?", id).Error
+	if err == nil {
+		token.NormalizeBudgetWindow()
+	}
 	if shouldUpdateRedis(true, err) {
 		gopool.Go(func() {
 			if err := cacheSetToken(token); err != nil {
@@ -254,13 +274,17 @@ func GetTokenByKey(key string, fromDB bool) (token *Token, err error) {
 	if !fromDB && common.RedisEnabled {
 		// Try Redis first
 		token, err := cacheGetTokenByKey(key)
-		if err == nil {
+		// 缓存中的周期预算已跨周期时回源数据库，避免在旧周期的用量上继续累加
+		if err == nil && !token.isBudgetWindowStale() {
 			return token, nil
 		}
 		// Don't return error - fall through to DB
 	}
 	fromDB = true
 	err = DB.Where(commonKeyCol+" = ?", key).First(&token).Error
+	if err == nil {
+		token.NormalizeBudgetWindow()
+	}
 	return token, err
 }
 
@@ -283,7 +307,9 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry",
+		"budget_period", "budget_quota", "rate_limit_rpm", "rate_limit_tpm", "rate_limit_concurrency",
+		"model_rate_limits", "organization_id").Updates(token).Error
 	return err
 }
 
@@ -370,6 +396,9 @@ func IncreaseTokenQuota(tokenId int, key string, quota int) (err error) {
 			if err != nil {
 				common.SysLog("failed to increase token quota: " + err.Error())
 			}
+			if err := cacheIncrTokenBudgetUsedQuota(key, -int64(quota)); err != nil {
+				common.SysLog("failed to update token budget usage: " + err.Error())
+			}
 		})
 	}
 	if common.BatchUpdateEnabled {
@@ -380,14 +409,19 @@ func IncreaseTokenQuota(tokenId int, key string, quota int) (err error) {
 }
 
 func increaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"remain_quota":  gorm.Expr("remain_quota + ?", quota),
-			"used_quota":    gorm.Expr("used_quota - ?", quota),
-			"accessed_time": common.GetTimestamp(),
-		},
-	).Error
-	return err
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := resetTokenBudgetWindow(tx, id); err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("id = ?", id).Updates(
+			map[string]interface{}{
+				"remain_quota":      gorm.Expr("remain_quota + ?", quota),
+				"used_quota":        gorm.Expr("used_quota - ?", quota),
+				"budget_used_quota": tokenBudgetUsedQuotaExpr(-quota),
+				"accessed_time":     common.GetTimestamp(),
+			},
+		).Error
+	})
 }
 
 func DecreaseTokenQuota(id int, key string, quota int) (err error) {
@@ -400,6 +434,9 @@ func DecreaseTokenQuota(id int, key string, quota int) (err error) {
 			if err != nil {
 				common.SysLog("failed to decrease token quota: " + err.Error())
 			}
+			if err := cacheIncrTokenBudgetUsedQuota(key, int64(quota)); err != nil {
+				common.SysLog("failed to update token budget usage: " + err.Error())
+			}
 		})
 	}
 	if common.BatchUpdateEnabled {
@@ -410,14 +447,19 @@ func DecreaseTokenQuota(id int, key string, quota int) (err error) {
 }
 
 func decreaseTokenQuota(id int, quota int) (err error) {
-	err = DB.Model(&Token{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
-			"used_quota":    gorm.Expr("used_quota + ?", quota),
-			"accessed_time": common.GetTimestamp(),
-		},
-	).Error
-	return err
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := resetTokenBudgetWindow(tx, id); err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("id = ?", id).Updates(
+			map[string]interface{}{
+				"remain_quota":      gorm.Expr("remain_quota - ?", quota),
+				"used_quota":        gorm.Expr("used_quota + ?", quota),
+				"budget_used_quota": tokenBudgetUsedQuotaExpr(quota),
+				"accessed_time":     common.GetTimestamp(),
+			},
+		).Error
+	})
 }
 
 // CountUserTokens returns total number of tokens for the given user, used for pagination
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 令牌周期预算：在总额度之外按自然日/周/月限制令牌用量，周期按服务器本地时间划分，
+// 周以周一为起点。用量随令牌额度的扣减/退还一同更新，跨周期后自动从 0 开始计算。
+
+const (
+	TokenBudgetPeriodDay   = "day"
+	TokenBudgetPeriodWeek  = "week"
+	TokenBudgetPeriodMonth = "month"
+
+	tokenBudgetUsedQuotaField = "BudgetUsedQuota"
+)
+
+var ErrTokenBudgetExhausted = errors.New("token budget exhausted")
+
+func IsValidTokenBudgetPeriod(period string) bool {
+	switch period {
+	case "", TokenBudgetPeriodDay, TokenBudgetPeriodWeek, TokenBudgetPeriodMonth:
+		return true
+	default:
+		return false
+	}
+}
+
+// TokenBudgetWindow 返回 now 所在周期的起止时间
+func TokenBudgetWindow(period string, now time.Time) (start time.Time, end time.Time) {
+	year, month, day := now.Date()
+	switch period {
+	case TokenBudgetPeriodDay:
+		start = time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(0, 0, 1)
+	case TokenBudgetPeriodWeek:
+		offset := (int(now.Weekday()) + 6) % 7
+		start = time.Date(year, month, day-offset, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(0, 0, 7)
+	case TokenBudgetPeriodMonth:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		end = start.AddDate(0, 1, 0)
+	}
+	return start, end
+}
+
+func tokenBudgetWindowStart(period string, now time.Time) int64 {
+	if period == "" {
+		return 0
+	}
+	start, _ := TokenBudgetWindow(period, now)
+	return start.Unix()
+}
+
+func (token *Token) IsBudgetEnabled() bool {
+	return token.BudgetPeriod != "" && token.BudgetQuota > 0
+}
+
+func (token *Token) isBudgetWindowStale() bool {
+	return token.BudgetPeriod != "" && token.BudgetWindowStart != tokenBudgetWindowStart(token.BudgetPeriod, time.Now())
+}
+
+// NormalizeBudgetWindow 跨周期时将当前周期用量归零，仅修改内存中的值
+func (token *Token) NormalizeBudgetWindow() {
+	if !token.isBudgetWindowStale() {
+		return
+	}
+	token.BudgetUsedQuota = 0
+	token.BudgetWindowStart = tokenBudgetWindowStart(token.BudgetPeriod, time.Now())
+}
+
+// GetBudgetUsedQuota 返回当前周期已用额度
+func (token *Token) GetBudgetUsedQuota() int {
+	if token.BudgetPeriod == "" || token.isBudgetWindowStale() {
+		return 0
+	}
+	return max(token.BudgetUsedQuota, 0)
+}
+
+// GetBudgetRemainQuota 返回当前周期剩余额度，未设置预算时返回 -1
+func (token *Token) GetBudgetRemainQuota() int {
+	if !token.IsBudgetEnabled() {
+		return -1
+	}
+	return max(token.BudgetQuota-token.GetBudgetUsedQuota(), 0)
+}
+
+// GetBudgetResetTime 返回当前周期结束（预算重置）的时间戳，未设置预算时返回 0
+func (token *Token) GetBudgetResetTime() int64 {
+	if !token.IsBudgetEnabled() {
+		return 0
+	}
+	_, end := TokenBudgetWindow(token.BudgetPeriod, time.Now())
+	return end.Unix()
+}
+
+func (token *Token) budgetExhaustedError() error {
+	return fmt.Errorf("%w: %s budget %s used %s, resets at %s", ErrTokenBudgetExhausted, token.BudgetPeriod,
+		logger.FormatQuota(token.BudgetQuota), logger.FormatQuota(token.GetBudgetUsedQuota()),
+		time.Unix(token.GetBudgetResetTime(), 0).Format(time.RFC3339))
+}
+
+// CheckBudget 检查本次消耗 quota 后是否超出周期预算
+func (token *Token) CheckBudget(quota int) error {
+	if !token.IsBudgetEnabled() {
+		return nil
+	}
+	if token.GetBudgetUsedQuota()+quota > token.BudgetQuota {
+		return token.budgetExhaustedError()
+	}
+	return nil
+}
+
+// tokenBudgetWindowStartSQL 按令牌的预算周期计算当前周期起始时间。
+// 数值直接写入 SQL，避免 PostgreSQL 无法推断 CASE 中占位符的类型
+func tokenBudgetWindowStartSQL() string {
+	now := time.Now()
+	return fmt.Sprintf("(CASE budget_period WHEN '%s' THEN %d WHEN '%s' THEN %d WHEN '%s' THEN %d ELSE 0 END)",
+		TokenBudgetPeriodDay, tokenBudgetWindowStart(TokenBudgetPeriodDay, now),
+		TokenBudgetPeriodWeek, tokenBudgetWindowStart(TokenBudgetPeriodWeek, now),
+		TokenBudgetPeriodMonth, tokenBudgetWindowStart(TokenBudgetPeriodMonth, now))
+}
+
+// resetTokenBudgetWindow 跨周期时将周期用量归零并更新周期起点，未跨周期或未设置预算的令牌不受影响。
+// 两列均为不引用其他被更新列的独立表达式，结果与数据库对 SET 子句的求值顺序无关
+func resetTokenBudgetWindow(tx *gorm.DB, id int) error {
+	windowStart := tokenBudgetWindowStartSQL()
+	return tx.Model(&Token{}).
+		Where("id = ? AND budget_period <> '' AND budget_window_start <> "+windowStart, id).
+		Updates(map[string]interface{}{
+			"budget_used_quota":   0,
+			"budget_window_start": gorm.Expr(windowStart),
+		}).Error
+}
+
+// tokenBudgetUsedQuotaExpr 累加当前周期用量，结果不小于 0，需先调用 resetTokenBudgetWindow 处理跨周期
+func tokenBudgetUsedQuotaExpr(consumed int) clause.Expr {
+	return gorm.Expr(fmt.Sprintf("CASE WHEN budget_used_quota + %d > 0 THEN budget_used_quota + %d ELSE 0 END", consumed, consumed))
+}
+
+func cacheIncrTokenBudgetUsedQuota(key string, increment int64) error {
+	key = common.GenerateHMAC(key)
+	return common.RedisHIncrBy(fmt.Sprintf("token:%s", key), tokenBudgetUsedQuotaField, increment)
+}
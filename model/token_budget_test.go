@@ -0,0 +1,84 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBudgetWindow(t *testing.T) {
+	// 2026-10-15 为周四
+	now := time.Date(2026, 10, 15, 13, 30, 0, 0, time.Local)
+
+	start, end := TokenBudgetWindow(TokenBudgetPeriodDay, now)
+	assert.Equal(t, time.Date(2026, 10, 15, 0, 0, 0, 0, time.Local), start)
+	assert.Equal(t, time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local), end)
+
+	start, end = TokenBudgetWindow(TokenBudgetPeriodWeek, now)
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local), start)
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local), end)
+
+	start, _ = TokenBudgetWindow(TokenBudgetPeriodWeek, time.Date(2026, 10, 18, 23, 0, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local), start)
+
+	start, end = TokenBudgetWindow(TokenBudgetPeriodMonth, now)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), start)
+	assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local), end)
+}
+
+func TestTokenBudgetAccounting(t *testing.T) {
+	truncateTables(t)
+
+	token := &Token{Key: "budget-test-key", Name: "budget", UserId: 1, RemainQuota: 10000, BudgetPeriod: TokenBudgetPeriodDay, BudgetQuota: 500}
+	require.NoError(t, token.Insert())
+	plain := &Token{Key: "budget-plain-key", Name: "plain", UserId: 1, RemainQuota: 10000}
+	require.NoError(t, plain.Insert())
+
+	require.NoError(t, DecreaseTokenQuota(token.Id, token.Key, 300))
+	require.NoError(t, DecreaseTokenQuota(plain.Id, plain.Key, 300))
+	loaded, err := GetTokenById(token.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 300, loaded.GetBudgetUsedQuota())
+	assert.Equal(t, 200, loaded.GetBudgetRemainQuota())
+	assert.NoError(t, loaded.CheckBudget(200))
+	assert.True(t, errors.Is(loaded.CheckBudget(201), ErrTokenBudgetExhausted))
+
+	loadedPlain, err := GetTokenById(plain.Id)
+	require.NoError(t, err)
+	assert.Equal(t, -1, loadedPlain.GetBudgetRemainQuota())
+	assert.NoError(t, loadedPlain.CheckBudget(1_000_000))
+
+	// 退还额度同时退还周期用量
+	require.NoError(t, IncreaseTokenQuota(token.Id, token.Key, 100))
+	loaded, err = GetTokenById(token.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 200, loaded.GetBudgetUsedQuota())
+	assert.Equal(t, 9800, loaded.RemainQuota)
+
+	// 跨周期后用量从本次消耗重新计算
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", token.Id).Update("budget_window_start", 1).Error)
+	loaded, err = GetTokenById(token.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, loaded.GetBudgetUsedQuota())
+	require.NoError(t, DecreaseTokenQuota(token.Id, token.Key, 50))
+	var raw Token
+	require.NoError(t, DB.First(&raw, token.Id).Error)
+	assert.Equal(t, 50, raw.BudgetUsedQuota)
+	assert.Equal(t, tokenBudgetWindowStart(TokenBudgetPeriodDay, time.Now()), raw.BudgetWindowStart)
+
+	// 跨周期后的退还不会使用量小于 0
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", token.Id).Update("budget_window_start", 1).Error)
+	require.NoError(t, IncreaseTokenQuota(token.Id, token.Key, 30))
+	require.NoError(t, DB.First(&raw, token.Id).Error)
+	assert.Equal(t, 0, raw.BudgetUsedQuota)
+	assert.Equal(t, tokenBudgetWindowStart(TokenBudgetPeriodDay, time.Now()), raw.BudgetWindowStart)
+	require.NoError(t, DecreaseTokenQuota(token.Id, token.Key, 50))
+
+	// 预算用尽时令牌校验失败
+	require.NoError(t, DecreaseTokenQuota(token.Id, token.Key, 450))
+	_, err = ValidateUserToken(token.Key)
+	assert.True(t, errors.Is(err, ErrTokenBudgetExhausted))
+}
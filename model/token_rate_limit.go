@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// TokenRateLimit 令牌或令牌下单个模型的限流规则，0 表示不限
+type TokenRateLimit struct {
+	RPM         int `json:"rpm"`
+	TPM         int `json:"tpm"`
+	Concurrency int `json:"concurrency"`
+}
+
+func (l TokenRateLimit) IsEmpty() bool {
+	return l.RPM <= 0 && l.TPM <= 0 && l.Concurrency <= 0
+}
+
+func (l TokenRateLimit) validate() error {
+	if l.RPM < 0 || l.TPM < 0 || l.Concurrency < 0 {
+		return errors.New("rate limit values must not be negative")
+	}
+	return nil
+}
+
+// TokenRateLimitConfig 令牌的完整限流配置，Models 中的规则与令牌级规则分别计数、同时生效
+type TokenRateLimitConfig struct {
+	TokenRateLimit
+	Models map[string]TokenRateLimit
+}
+
+func (cfg *TokenRateLimitConfig) IsEmpty() bool {
+	return cfg == nil || (cfg.TokenRateLimit.IsEmpty() && len(cfg.Models) == 0)
+}
+
+// ParseTokenModelRateLimits 解析按模型配置的限流规则，格式为 {"model": {"rpm": 0, "tpm": 0, "concurrency": 0}}
+func ParseTokenModelRateLimits(raw string) (map[string]TokenRateLimit, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var limits map[string]TokenRateLimit
+	if err := common.UnmarshalJsonStr(raw, &limits); err != nil {
+		return nil, fmt.Errorf("invalid model rate limits: %w", err)
+	}
+	for name, limit := range limits {
+		if strings.TrimSpace(name) == "" {
+			return nil, errors.New("invalid model rate limits: model name is empty")
+		}
+		if err := limit.validate(); err != nil {
+			return nil, fmt.Errorf("invalid model rate limits for %s: %w", name, err)
+		}
+		if limit.IsEmpty() {
+			delete(limits, name)
+		}
+	}
+	return limits, nil
+}
+
+// ValidateRateLimits 校验令牌的限流配置
+func (token *Token) ValidateRateLimits() error {
+	base := TokenRateLimit{RPM: token.RateLimitRPM, TPM: token.RateLimitTPM, Concurrency: token.RateLimitConcurrency}
+	if err := base.validate(); err != nil {
+		return err
+	}
+	_, err := ParseTokenModelRateLimits(token.ModelRateLimits)
+	return err
+}
+
+// GetRateLimitConfig 返回令牌的限流配置，未配置任何限流时返回 nil
+func (token *Token) GetRateLimitConfig() *TokenRateLimitConfig {
+	cfg := &TokenRateLimitConfig{
+		TokenRateLimit: TokenRateLimit{
+			RPM:         token.RateLimitRPM,
+			TPM:         token.RateLimitTPM,
+			Concurrency: token.RateLimitConcurrency,
+		},
+	}
+	models, err := ParseTokenModelRateLimits(token.ModelRateLimits)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("token %d: %s", token.Id, err.Error()))
+	}
+	cfg.Models = models
+	if cfg.IsEmpty() {
+		return nil
+	}
+	return cfg
+}
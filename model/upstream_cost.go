@@ -0,0 +1,188 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+// CalcUpstreamCost 推算一次消费的上游成本。渠道为该模型配置了上游按次价格时直接换算为额度；
+// 配置了上游模型倍率时，先按日志中的分组倍率还原出未经分组加价的基础额度，再按上游模型倍率与
+// 计费时模型倍率之比折算。均未配置时退回按渠道成本倍率估算，此时 estimated 为 true。
+// 违规扣费与命中响应缓存的请求没有产生上游调用，成本为 0；
+// 分组倍率为 0（免费分组）时无法从计费额度还原，同样记为 0
+func CalcUpstreamCost(channelId int, modelName string, quota int, other map[string]interface{}) (cost int, estimated bool) {
+	if channelId <= 0 || quota <= 0 {
+		return 0, false
+	}
+	if other != nil {
+		if _, ok := other["violation_fee"]; ok {
+			return 0, false
+		}
+		if _, ok := other["response_cache_hit"]; ok {
+			return 0, false
+		}
+	}
+	var otherSettings dto.ChannelOtherSettings
+	if channel, err := CacheGetChannel(channelId); err == nil {
+		otherSettings = channel.GetOtherSettings()
+	}
+	if price, ok := getCostModelSetting(modelName, otherSettings.GetCostModelPrice); ok {
+		return int(math.Round(price * common.QuotaPerUnit)), false
+	}
+
+	groupRatio := 1.0
+	if other != nil {
+		if v, ok := other["group_ratio"].(float64); ok {
+			groupRatio = v
+		}
+	}
+	if groupRatio <= 0 {
+		return 0, false
+	}
+	baseQuota := float64(quota) / groupRatio
+	if costModelRatio, ok := getCostModelSetting(modelName, otherSettings.GetCostModelRatio); ok {
+		// 按次计费的请求无法从额度还原 tokens，只能按倍率计费的日志折算
+		modelRatio, _ := other["model_ratio"].(float64)
+		modelPrice, hasPrice := other["model_price"].(float64)
+		if modelRatio > 0 && (!hasPrice || modelPrice < 0) {
+			return int(math.Round(baseQuota * costModelRatio / modelRatio)), false
+		}
+	}
+	return int(math.Round(baseQuota * otherSettings.GetCostRatio())), true
+}
+
+// markUpstreamCostEstimated 在日志中标记上游成本为按渠道成本倍率估算，渠道未配置该模型的上游价格
+func markUpstreamCostEstimated(other map[string]interface{}) map[string]interface{} {
+	if other == nil {
+		other = make(map[string]interface{})
+	}
+	other["upstream_cost_estimated"] = true
+	return other
+}
+
+// getCostModelSetting 按模型名查找渠道的上游价格配置，未精确命中时按倍率配置的模型名规则匹配
+func getCostModelSetting(modelName string, get func(string) (float64, bool)) (float64, bool) {
+	if value, ok := get(modelName); ok {
+		return value, true
+	}
+	if formatted := ratio_setting.FormatMatchingModelName(modelName); formatted != modelName {
+		return get(formatted)
+	}
+	return 0, false
+}
+
+const (
+	MarginGroupByChannel = "channel"
+	MarginGroupByModel   = "model"
+	MarginGroupByGroup   = "group"
+	MarginGroupByDay     = "day"
+)
+
+// MarginReportItem 按维度汇总的收入、上游成本与毛利，单位均为额度
+type MarginReportItem struct {
+	Key         string  `json:"key"`
+	ChannelId   int     `json:"channel_id,omitempty"`
+	ChannelName string  `json:"channel_name,omitempty"`
+	Count       int64   `json:"count"`
+	Revenue     int64   `json:"revenue"`
+	Cost        int64   `json:"cost"`
+	Margin      int64   `json:"margin"`
+	MarginRate  float64 `json:"margin_rate"`
+}
+
+type marginReportRow struct {
+	ChannelId int    `gorm:"column:channel_id"`
+	ModelName string `gorm:"column:model_name"`
+	GroupName string `gorm:"column:group_name"`
+	Day       int64  `gorm:"column:day"`
+	Count     int64  `gorm:"column:count"`
+	Revenue   int64  `gorm:"column:revenue"`
+	Cost      int64  `gorm:"column:cost"`
+}
+
+// GetMarginReport 基于消费日志按渠道、模型、分组或天汇总收入与上游成本，按毛利从低到高排列
+// （按天汇总时按日期排列），便于找出亏损的渠道。tzOffset 为按天汇总时使用的时区偏移秒数
+func GetMarginReport(groupBy string, startTimestamp int64, endTimestamp int64, tzOffset int64) ([]*MarginReportItem, error) {
+	var keyColumn string
+	switch groupBy {
+	case MarginGroupByChannel:
+		keyColumn = "channel_id"
+	case MarginGroupByModel:
+		keyColumn = "model_name"
+	case MarginGroupByGroup:
+		keyColumn = logGroupCol + " as group_name"
+	case MarginGroupByDay:
+		keyColumn = "(created_at + ?) - (created_at + ?) % 86400 as day"
+	default:
+		return nil, errors.New("不支持的汇总维度")
+	}
+	groupColumn := keyColumn
+	switch groupBy {
+	case MarginGroupByGroup:
+		groupColumn = "group_name"
+	case MarginGroupByDay:
+		groupColumn = "day"
+	}
+
+	tx := LOG_DB.Table("logs")
+	selectSQL := keyColumn + ", count(*) as count, sum(quota) as revenue, sum(upstream_cost) as cost"
+	if groupBy == MarginGroupByDay {
+		tx = tx.Select(selectSQL, tzOffset, tzOffset)
+	} else {
+		tx = tx.Select(selectSQL)
+	}
+	tx = tx.Where("type = ?", LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	var rows []marginReportRow
+	if err := tx.Group(groupColumn).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]*MarginReportItem, 0, len(rows))
+	for _, row := range rows {
+		item := &MarginReportItem{
+			Count:   row.Count,
+			Revenue: row.Revenue,
+			Cost:    row.Cost,
+			Margin:  row.Revenue - row.Cost,
+		}
+		if row.Revenue > 0 {
+			item.MarginRate = float64(item.Margin) / float64(row.Revenue)
+		}
+		switch groupBy {
+		case MarginGroupByChannel:
+			item.Key = strconv.Itoa(row.ChannelId)
+			item.ChannelId = row.ChannelId
+			if channel, err := CacheGetChannel(row.ChannelId); err == nil {
+				item.ChannelName = channel.Name
+			}
+		case MarginGroupByModel:
+			item.Key = row.ModelName
+		case MarginGroupByGroup:
+			item.Key = row.GroupName
+		case MarginGroupByDay:
+			// row.Day 是按时区偏移后的当天零点，格式化时按 UTC 处理即得到当地日期
+			item.Key = time.Unix(row.Day, 0).UTC().Format("2006-01-02")
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if groupBy == MarginGroupByDay {
+			return items[i].Key < items[j].Key
+		}
+		return items[i].Margin < items[j].Margin
+	})
+	return items, nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamCostAndMarginReport(t *testing.T) {
+	truncateTables(t)
+	saved := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = false
+	t.Cleanup(func() { common.MemoryCacheEnabled = saved })
+
+	require.NoError(t, DB.Create(&Channel{Id: 1, Name: "reseller", Key: "k1", OtherSettings: `{"cost_ratio":0.3}`}).Error)
+	require.NoError(t, DB.Create(&Channel{Id: 2, Name: "official", Key: "k2"}).Error)
+	require.NoError(t, DB.Create(&Channel{Id: 3, Name: "priced", Key: "k3",
+		OtherSettings: `{"cost_ratio":0.3,"cost_model_ratio":{"gpt-4o":0.5},"cost_model_price":{"mj_imagine":0.02}}`}).Error)
+
+	// 分组倍率 2 时基础额度为 500，中转渠道成本为 150，官方渠道按 1 倍计为 500，均为估算
+	cost, estimated := CalcUpstreamCost(1, "gpt-4o", 1000, map[string]interface{}{"group_ratio": 2.0})
+	require.Equal(t, 150, cost)
+	require.True(t, estimated)
+	cost, estimated = CalcUpstreamCost(2, "gpt-4o", 1000, map[string]interface{}{"group_ratio": 2.0})
+	require.Equal(t, 500, cost)
+	require.True(t, estimated)
+	cost, _ = CalcUpstreamCost(2, "gpt-4o", 1000, map[string]interface{}{"group_ratio": 2.0, "response_cache_hit": true})
+	require.Equal(t, 0, cost)
+
+	// 配置了上游模型倍率：基础额度 500 按模型倍率 1.25 计费，上游倍率 0.5 时成本为 200
+	cost, estimated = CalcUpstreamCost(3, "gpt-4o", 1000, map[string]interface{}{"group_ratio": 2.0, "model_ratio": 1.25, "model_price": -1.0})
+	require.Equal(t, 200, cost)
+	require.False(t, estimated)
+	// 配置了上游按次价格
+	cost, estimated = CalcUpstreamCost(3, "mj_imagine", 50000, map[string]interface{}{"group_ratio": 2.0, "model_price": 0.1})
+	require.Equal(t, int(0.02*common.QuotaPerUnit), cost)
+	require.False(t, estimated)
+	// 未配置该模型的上游价格时退回渠道成本倍率
+	cost, estimated = CalcUpstreamCost(3, "claude", 1000, map[string]interface{}{"group_ratio": 2.0, "model_ratio": 1.0})
+	require.Equal(t, 150, cost)
+	require.True(t, estimated)
+
+	day := int64(1736121600) // 2025-01-06 00:00 UTC
+	logs := []*Log{
+		{Type: LogTypeConsume, CreatedAt: day + 100, ChannelId: 1, ModelName: "gpt-4o", Group: "default", Quota: 1000, UpstreamCost: 300},
+		{Type: LogTypeConsume, CreatedAt: day + 200, ChannelId: 2, ModelName: "gpt-4o", Group: "default", Quota: 400, UpstreamCost: 600},
+		{Type: LogTypeConsume, CreatedAt: day + 86400, ChannelId: 2, ModelName: "claude", Group: "vip", Quota: 500, UpstreamCost: 100},
+		{Type: LogTypeRefund, CreatedAt: day + 300, ChannelId: 2, ModelName: "gpt-4o", Group: "default", Quota: 999},
+	}
+	require.NoError(t, LOG_DB.Create(&logs).Error)
+
+	items, err := GetMarginReport(MarginGroupByChannel, 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.Equal(t, "official", items[0].ChannelName)
+	require.Equal(t, int64(900), items[0].Revenue)
+	require.Equal(t, int64(700), items[0].Cost)
+	require.Equal(t, int64(200), items[0].Margin)
+	require.Equal(t, int64(700), items[1].Margin)
+
+	items, err = GetMarginReport(MarginGroupByDay, 0, 0, 8*3600)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.Equal(t, "2025-01-06", items[0].Key)
+	require.Equal(t, int64(500), items[0].Margin)
+
+	items, err = GetMarginReport(MarginGroupByGroup, 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	_, err = GetMarginReport("token", 0, 0, 0)
+	require.Error(t, err)
+}
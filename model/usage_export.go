@@ -0,0 +1,131 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// 导出时每批读取的行数，按 id 游标分页，避免深分页与一次性加载
+const usageExportBatchSize = 1000
+
+// UsageExportQuery 消费日志与用量数据导出的筛选条件，UserId 非 0 时为用户自助导出
+type UsageExportQuery struct {
+	UserId         int
+	Username       string
+	TokenName      string
+	ModelName      string
+	Group          string
+	Channel        int
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func (query *UsageExportQuery) applyLogFilters(tx *gorm.DB) (*gorm.DB, error) {
+	tx = tx.Where("logs.type = ?", LogTypeConsume)
+	if query.UserId != 0 {
+		tx = tx.Where("logs.user_id = ?", query.UserId)
+	}
+	if query.Username != "" {
+		tx = tx.Where("logs.username = ?", query.Username)
+	}
+	if query.TokenName != "" {
+		tx = tx.Where("logs.token_name = ?", query.TokenName)
+	}
+	if query.ModelName != "" {
+		modelNamePattern, err := sanitizeLikePattern(query.ModelName)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where("logs.model_name LIKE ? ESCAPE '!'", modelNamePattern)
+	}
+	if query.Group != "" {
+		tx = tx.Where("logs."+logGroupCol+" = ?", query.Group)
+	}
+	if query.Channel != 0 {
+		tx = tx.Where("logs.channel_id = ?", query.Channel)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", query.EndTimestamp)
+	}
+	return tx, nil
+}
+
+// ExportConsumeLogs 按 id 倒序分批读取消费日志并交给 fn 处理，
+// 用户自助导出时去除仅管理员可见的字段，管理员导出时填充渠道名称
+func ExportConsumeLogs(query *UsageExportQuery, fn func(logs []*Log) error) error {
+	lastId := 0
+	for {
+		tx, err := query.applyLogFilters(LOG_DB.Model(&Log{}))
+		if err != nil {
+			return err
+		}
+		if lastId > 0 {
+			tx = tx.Where("logs.id < ?", lastId)
+		}
+		var logs []*Log
+		if err = tx.Order("logs.id desc").Limit(usageExportBatchSize).Find(&logs).Error; err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		lastId = logs[len(logs)-1].Id
+		if query.UserId != 0 {
+			for _, log := range logs {
+				stripAdminLogFields(log)
+			}
+		} else if err = fillLogChannelNames(logs); err != nil {
+			return err
+		}
+		if err = fn(logs); err != nil {
+			return err
+		}
+		if len(logs) < usageExportBatchSize {
+			return nil
+		}
+	}
+}
+
+// ExportQuotaData 按 id 顺序分批读取按小时汇总的用量数据并交给 fn 处理，用户自助导出时清空上游成本
+func ExportQuotaData(query *UsageExportQuery, fn func(data []*QuotaData) error) error {
+	lastId := 0
+	for {
+		tx := DB.Model(&QuotaData{}).Where("id > ?", lastId)
+		if query.UserId != 0 {
+			tx = tx.Where("user_id = ?", query.UserId)
+		}
+		if query.Username != "" {
+			tx = tx.Where("username = ?", query.Username)
+		}
+		if query.ModelName != "" {
+			tx = tx.Where("model_name = ?", query.ModelName)
+		}
+		if query.StartTimestamp != 0 {
+			tx = tx.Where("created_at >= ?", query.StartTimestamp)
+		}
+		if query.EndTimestamp != 0 {
+			tx = tx.Where("created_at <= ?", query.EndTimestamp)
+		}
+		var data []*QuotaData
+		if err := tx.Order("id asc").Limit(usageExportBatchSize).Find(&data).Error; err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		lastId = data[len(data)-1].Id
+		if query.UserId != 0 {
+			for _, item := range data {
+				item.UpstreamCost = 0
+			}
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		if len(data) < usageExportBatchSize {
+			return nil
+		}
+	}
+}
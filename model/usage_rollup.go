@@ -0,0 +1,489 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UsageRollupHour = "hour"
+	UsageRollupDay  = "day"
+)
+
+// 延迟分桶的上界（秒），超过最后一个上界的请求计入溢出桶
+var usageRollupLatencyBounds = [...]int{1, 3, 5, 10, 30, 60, 300}
+
+// UsageRollupMetrics 预聚合的用量指标，延迟以直方图保存，各列可直接求和后再计算分位数
+type UsageRollupMetrics struct {
+	RequestCount     int64 `json:"request_count" gorm:"default:0"`
+	ErrorCount       int64 `json:"error_count" gorm:"default:0"`
+	PromptTokens     int64 `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int64 `json:"completion_tokens" gorm:"default:0"`
+	CachedTokens     int64 `json:"cached_tokens" gorm:"default:0"`
+	Quota            int64 `json:"quota" gorm:"default:0"`
+	UpstreamCost     int64 `json:"upstream_cost,omitempty" gorm:"default:0"`
+	UseTimeSum       int64 `json:"use_time_sum" gorm:"default:0"`
+	LatencyB0        int64 `json:"-" gorm:"default:0"`
+	LatencyB1        int64 `json:"-" gorm:"default:0"`
+	LatencyB2        int64 `json:"-" gorm:"default:0"`
+	LatencyB3        int64 `json:"-" gorm:"default:0"`
+	LatencyB4        int64 `json:"-" gorm:"default:0"`
+	LatencyB5        int64 `json:"-" gorm:"default:0"`
+	LatencyB6        int64 `json:"-" gorm:"default:0"`
+	LatencyB7        int64 `json:"-" gorm:"default:0"`
+}
+
+var usageRollupMetricColumns = []string{
+	"request_count", "error_count", "prompt_tokens", "completion_tokens", "cached_tokens", "quota", "upstream_cost", "use_time_sum",
+	"latency_b0", "latency_b1", "latency_b2", "latency_b3", "latency_b4", "latency_b5", "latency_b6", "latency_b7",
+}
+
+func (m *UsageRollupMetrics) latencyBuckets() []*int64 {
+	return []*int64{&m.LatencyB0, &m.LatencyB1, &m.LatencyB2, &m.LatencyB3, &m.LatencyB4, &m.LatencyB5, &m.LatencyB6, &m.LatencyB7}
+}
+
+// values 与 usageRollupMetricColumns 顺序一致
+func (m *UsageRollupMetrics) values() []int64 {
+	values := []int64{m.RequestCount, m.ErrorCount, m.PromptTokens, m.CompletionTokens, m.CachedTokens, m.Quota, m.UpstreamCost, m.UseTimeSum}
+	for _, bucket := range m.latencyBuckets() {
+		values = append(values, *bucket)
+	}
+	return values
+}
+
+func (m *UsageRollupMetrics) observeLatency(seconds int) {
+	buckets := m.latencyBuckets()
+	for i, bound := range usageRollupLatencyBounds {
+		if seconds <= bound {
+			*buckets[i]++
+			return
+		}
+	}
+	*buckets[len(buckets)-1]++
+}
+
+func (m *UsageRollupMetrics) merge(o *UsageRollupMetrics) {
+	m.RequestCount += o.RequestCount
+	m.ErrorCount += o.ErrorCount
+	m.PromptTokens += o.PromptTokens
+	m.CompletionTokens += o.CompletionTokens
+	m.CachedTokens += o.CachedTokens
+	m.Quota += o.Quota
+	m.UpstreamCost += o.UpstreamCost
+	m.UseTimeSum += o.UseTimeSum
+	buckets, other := m.latencyBuckets(), o.latencyBuckets()
+	for i := range buckets {
+		*buckets[i] += *other[i]
+	}
+}
+
+// LatencyPercentile 由直方图估算延迟分位数（秒），返回所在桶的上界；落在溢出桶时返回最后一个上界，表示不低于该值
+func (m *UsageRollupMetrics) LatencyPercentile(p float64) int {
+	buckets := m.latencyBuckets()
+	var total int64
+	for _, bucket := range buckets {
+		total += *bucket
+	}
+	if total == 0 {
+		return 0
+	}
+	target := int64(float64(total)*p + 0.999999)
+	var cumulative int64
+	for i, bound := range usageRollupLatencyBounds {
+		cumulative += *buckets[i]
+		if cumulative >= target {
+			return bound
+		}
+	}
+	return usageRollupLatencyBounds[len(usageRollupLatencyBounds)-1]
+}
+
+// UsageRollup 按小时或天预聚合的用量，键为用户、令牌、模型、渠道与分组。
+// 小时与天的分桶均按 UTC 对齐，需要按本地日期展示时使用小时粒度
+type UsageRollup struct {
+	Id          int    `json:"id"`
+	Granularity string `json:"granularity" gorm:"type:varchar(8);uniqueIndex:idx_usage_rollup_key,priority:1;index:idx_usage_rollup_bucket,priority:1"`
+	BucketStart int64  `json:"bucket_start" gorm:"bigint;uniqueIndex:idx_usage_rollup_key,priority:2;index:idx_usage_rollup_bucket,priority:2"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:3"`
+	TokenId     int    `json:"token_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:4"`
+	ModelName   string `json:"model_name" gorm:"type:varchar(128);uniqueIndex:idx_usage_rollup_key,priority:5"`
+	ChannelId   int    `json:"channel_id" gorm:"uniqueIndex:idx_usage_rollup_key,priority:6"`
+	Group       string `json:"group" gorm:"column:group_name;type:varchar(64);uniqueIndex:idx_usage_rollup_key,priority:7"`
+	Username    string `json:"username" gorm:"type:varchar(64);default:''"`
+	UsageRollupMetrics
+}
+
+type usageRollupKey struct {
+	Granularity string
+	BucketStart int64
+	UserId      int
+	TokenId     int
+	ModelName   string
+	ChannelId   int
+	Group       string
+}
+
+func (r *UsageRollup) key() usageRollupKey {
+	return usageRollupKey{r.Granularity, r.BucketStart, r.UserId, r.TokenId, r.ModelName, r.ChannelId, r.Group}
+}
+
+var (
+	usageRollupBuffer = make(map[usageRollupKey]*UsageRollup)
+	usageRollupLock   sync.Mutex
+)
+
+// addUsageRollup 将一条小时粒度的增量合并到 buffer，天粒度在写库时由小时数据汇总
+func addUsageRollup(buffer map[usageRollupKey]*UsageRollup, entry *UsageRollup) {
+	entry.Granularity = UsageRollupHour
+	entry.BucketStart -= entry.BucketStart % 3600
+	if existing, ok := buffer[entry.key()]; ok {
+		existing.merge(&entry.UsageRollupMetrics)
+		if existing.Username == "" {
+			existing.Username = entry.Username
+		}
+		return
+	}
+	buffer[entry.key()] = entry
+}
+
+// recordUsageRollup 累计一次请求的用量，由 UpdateUsageRollups 定期批量写库；useTimeSeconds 小于 0 时不计入延迟
+func recordUsageRollup(entry *UsageRollup, useTimeSeconds int) {
+	if !operation_setting.GetUsageRollupSetting().Enabled {
+		return
+	}
+	if useTimeSeconds >= 0 {
+		entry.UseTimeSum = int64(useTimeSeconds)
+		entry.observeLatency(useTimeSeconds)
+	}
+	usageRollupLock.Lock()
+	defer usageRollupLock.Unlock()
+	addUsageRollup(usageRollupBuffer, entry)
+}
+
+// usageRollupCachedTokens 从日志的 other 字段中读取缓存命中的 token 数
+func usageRollupCachedTokens(other map[string]interface{}) int64 {
+	switch v := other["cache_tokens"].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// FlushUsageRollups 将内存中累计的增量写入数据库
+func FlushUsageRollups() {
+	usageRollupLock.Lock()
+	buffer := usageRollupBuffer
+	usageRollupBuffer = make(map[usageRollupKey]*UsageRollup)
+	usageRollupLock.Unlock()
+	if len(buffer) == 0 {
+		return
+	}
+	if err := saveUsageRollups(buffer); err != nil {
+		common.SysError("failed to save usage rollups: " + err.Error())
+	}
+}
+
+func saveUsageRollups(hourly map[usageRollupKey]*UsageRollup) error {
+	daily := make(map[usageRollupKey]*UsageRollup)
+	for _, rollup := range hourly {
+		day := *rollup
+		day.Granularity = UsageRollupDay
+		day.BucketStart = rollup.BucketStart - rollup.BucketStart%86400
+		if existing, ok := daily[day.key()]; ok {
+			existing.merge(&day.UsageRollupMetrics)
+		} else {
+			daily[day.key()] = &day
+		}
+	}
+	var errs []error
+	for _, buffer := range []map[usageRollupKey]*UsageRollup{hourly, daily} {
+		for _, rollup := range buffer {
+			if err := upsertUsageRollup(rollup); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func upsertUsageRollup(rollup *UsageRollup) error {
+	updates := make(map[string]interface{}, len(usageRollupMetricColumns))
+	for i, value := range rollup.values() {
+		column := usageRollupMetricColumns[i]
+		updates[column] = gorm.Expr(column+" + ?", value)
+	}
+	update := func() (int64, error) {
+		result := DB.Model(&UsageRollup{}).
+			Where("granularity = ? AND bucket_start = ? AND user_id = ? AND token_id = ? AND model_name = ? AND channel_id = ? AND group_name = ?",
+				rollup.Granularity, rollup.BucketStart, rollup.UserId, rollup.TokenId, rollup.ModelName, rollup.ChannelId, rollup.Group).
+			Updates(updates)
+		return result.RowsAffected, result.Error
+	}
+	affected, err := update()
+	if err != nil || affected > 0 {
+		return err
+	}
+	row := *rollup
+	row.Id = 0
+	if err = DB.Create(&row).Error; err == nil {
+		return nil
+	}
+	// 其他节点已先插入同一行，改为累加
+	_, err = update()
+	return err
+}
+
+// UpdateUsageRollups 定期写入累计的增量，主节点同时清理过期的小时数据
+func UpdateUsageRollups() {
+	var lastCleanup time.Time
+	for {
+		setting := operation_setting.GetUsageRollupSetting()
+		interval := setting.FlushIntervalSeconds
+		if interval <= 0 {
+			interval = 60
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+		FlushUsageRollups()
+		if common.IsMasterNode && setting.HourlyRetentionDays > 0 && time.Since(lastCleanup) > time.Hour {
+			cutoff := time.Now().Unix() - int64(setting.HourlyRetentionDays)*86400
+			if err := DB.Where("granularity = ? AND bucket_start < ?", UsageRollupHour, cutoff).Delete(&UsageRollup{}).Error; err != nil {
+				common.SysError("failed to clean up usage rollups: " + err.Error())
+			}
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+// UsageRollupBackfillRange 将回填范围按 UTC 天对齐并校验，今天的数据由实时汇总写入，不允许回填。
+// 未开启消费日志时历史日志不完整，回填会用残缺数据覆盖已有的预聚合数据，因此拒绝回填
+func UsageRollupBackfillRange(startTime int64, endTime int64) (int64, int64, error) {
+	if !common.LogConsumeEnabled {
+		return 0, 0, errors.New("未开启消费日志，无法由日志回填预聚合数据")
+	}
+	startTime -= startTime % 86400
+	if endTime%86400 != 0 {
+		endTime += 86400 - endTime%86400
+	}
+	today := time.Now().Unix()
+	today -= today % 86400
+	if endTime > today {
+		return 0, 0, errors.New("回填范围不能包含今天")
+	}
+	if startTime <= 0 || startTime >= endTime {
+		return 0, 0, errors.New("回填时间范围无效")
+	}
+	return startTime, endTime, nil
+}
+
+// BackfillUsageRollups 由消费与错误日志重建 [startTime, endTime) 内的预聚合数据，
+// 起止时间按 UTC 天对齐，范围内的已有数据会先删除；progress 在每批处理后回调已处理的日志数
+func BackfillUsageRollups(startTime int64, endTime int64, progress func(processed int)) error {
+	startTime, endTime, err := UsageRollupBackfillRange(startTime, endTime)
+	if err != nil {
+		return err
+	}
+	if err := DB.Where("bucket_start >= ? AND bucket_start < ?", startTime, endTime).Delete(&UsageRollup{}).Error; err != nil {
+		return err
+	}
+	lastId, processed := 0, 0
+	for {
+		var logs []*Log
+		err := LOG_DB.Where("id > ? AND type IN ? AND created_at >= ? AND created_at < ?", lastId, []int{LogTypeConsume, LogTypeError}, startTime, endTime).
+			Order("id asc").Limit(usageExportBatchSize).Find(&logs).Error
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		buffer := make(map[usageRollupKey]*UsageRollup)
+		for _, log := range logs {
+			entry := &UsageRollup{
+				BucketStart: log.CreatedAt,
+				UserId:      log.UserId,
+				Username:    log.Username,
+				TokenId:     log.TokenId,
+				ModelName:   log.ModelName,
+				ChannelId:   log.ChannelId,
+				Group:       log.Group,
+			}
+			if log.Type == LogTypeError {
+				entry.ErrorCount = 1
+			} else {
+				other, _ := common.StrToMap(log.Other)
+				entry.RequestCount = 1
+				entry.PromptTokens = int64(log.PromptTokens)
+				entry.CompletionTokens = int64(log.CompletionTokens)
+				entry.CachedTokens = usageRollupCachedTokens(other)
+				entry.Quota = int64(log.Quota)
+				entry.UpstreamCost = int64(log.UpstreamCost)
+				entry.UseTimeSum = int64(log.UseTime)
+				entry.observeLatency(log.UseTime)
+			}
+			addUsageRollup(buffer, entry)
+		}
+		if err = saveUsageRollups(buffer); err != nil {
+			return err
+		}
+		lastId = logs[len(logs)-1].Id
+		processed += len(logs)
+		if progress != nil {
+			progress(processed)
+		}
+		if len(logs) < usageExportBatchSize {
+			return nil
+		}
+	}
+}
+
+var usageRollupDimensions = map[string][]string{
+	"user":    {"user_id", "MAX(username) AS username"},
+	"token":   {"token_id"},
+	"model":   {"model_name"},
+	"channel": {"channel_id"},
+	"group":   {"group_name"},
+}
+
+// UsageRollupQuery 预聚合数据的查询条件，GroupBy 为 user、token、model、channel、group 的组合，
+// ByTime 为 true 时额外按时间分桶
+type UsageRollupQuery struct {
+	Granularity    string
+	GroupBy        []string
+	ByTime         bool
+	UserId         int
+	Username       string
+	TokenId        int
+	ModelName      string
+	ChannelId      int
+	Group          string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+// UsageRollupStat 预聚合数据的查询结果，未参与分组的维度为零值
+type UsageRollupStat struct {
+	BucketStart int64  `json:"bucket_start,omitempty"`
+	UserId      int    `json:"user_id,omitempty"`
+	Username    string `json:"username,omitempty"`
+	TokenId     int    `json:"token_id,omitempty"`
+	ModelName   string `json:"model_name,omitempty"`
+	ChannelId   int    `json:"channel_id,omitempty"`
+	Group       string `json:"group,omitempty" gorm:"column:group_name"`
+	UsageRollupMetrics
+	AvgUseTime float64 `json:"avg_use_time" gorm:"-"`
+	P50UseTime int     `json:"p50_use_time" gorm:"-"`
+	P90UseTime int     `json:"p90_use_time" gorm:"-"`
+	P99UseTime int     `json:"p99_use_time" gorm:"-"`
+}
+
+// QueryUsageRollups 按维度汇总预聚合数据并计算平均与分位延迟
+func QueryUsageRollups(query *UsageRollupQuery) ([]*UsageRollupStat, error) {
+	granularity := query.Granularity
+	if granularity != UsageRollupDay {
+		granularity = UsageRollupHour
+	}
+	var selects, groups []string
+	if query.ByTime {
+		selects = append(selects, "bucket_start")
+		groups = append(groups, "bucket_start")
+	}
+	for _, dimension := range query.GroupBy {
+		columns, ok := usageRollupDimensions[dimension]
+		if !ok {
+			return nil, fmt.Errorf("不支持的分组维度: %s", dimension)
+		}
+		selects = append(selects, columns...)
+		groups = append(groups, strings.Fields(columns[0])[0])
+	}
+	for _, column := range usageRollupMetricColumns {
+		selects = append(selects, fmt.Sprintf("COALESCE(SUM(%s), 0) AS %s", column, column))
+	}
+
+	tx := DB.Model(&UsageRollup{}).Select(strings.Join(selects, ", ")).Where("granularity = ?", granularity)
+	if query.UserId != 0 {
+		tx = tx.Where("user_id = ?", query.UserId)
+	}
+	if query.Username != "" {
+		tx = tx.Where("username = ?", query.Username)
+	}
+	if query.TokenId != 0 {
+		tx = tx.Where("token_id = ?", query.TokenId)
+	}
+	if query.ModelName != "" {
+		tx = tx.Where("model_name = ?", query.ModelName)
+	}
+	if query.ChannelId != 0 {
+		tx = tx.Where("channel_id = ?", query.ChannelId)
+	}
+	if query.Group != "" {
+		tx = tx.Where("group_name = ?", query.Group)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("bucket_start >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("bucket_start <= ?", query.EndTimestamp)
+	}
+	if len(groups) > 0 {
+		tx = tx.Group(strings.Join(groups, ", ")).Order(strings.Join(groups, ", "))
+	}
+	var stats []*UsageRollupStat
+	if err := tx.Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	for _, stat := range stats {
+		if stat.RequestCount > 0 {
+			stat.AvgUseTime = float64(stat.UseTimeSum) / float64(stat.RequestCount)
+		}
+		stat.P50UseTime = stat.LatencyPercentile(0.5)
+		stat.P90UseTime = stat.LatencyPercentile(0.9)
+		stat.P99UseTime = stat.LatencyPercentile(0.99)
+	}
+	return stats, nil
+}
+
+// GetQuotaDatesFromRollups 以 quota_data 的格式返回小时粒度的预聚合数据，供数据看板使用
+func GetQuotaDatesFromRollups(userId int, username string, startTime int64, endTime int64) ([]*QuotaData, error) {
+	query := &UsageRollupQuery{
+		Granularity:    UsageRollupHour,
+		GroupBy:        []string{"model"},
+		ByTime:         true,
+		UserId:         userId,
+		Username:       username,
+		StartTimestamp: startTime,
+		EndTimestamp:   endTime,
+	}
+	if userId != 0 || username != "" {
+		query.GroupBy = append(query.GroupBy, "user")
+	}
+	stats, err := QueryUsageRollups(query)
+	if err != nil {
+		return nil, err
+	}
+	dates := make([]*QuotaData, 0, len(stats))
+	for _, stat := range stats {
+		dates = append(dates, &QuotaData{
+			UserID:       stat.UserId,
+			Username:     stat.Username,
+			ModelName:    stat.ModelName,
+			CreatedAt:    stat.BucketStart,
+			TokenUsed:    int(stat.PromptTokens + stat.CompletionTokens),
+			Count:        int(stat.RequestCount),
+			Quota:        int(stat.Quota),
+			UpstreamCost: int(stat.UpstreamCost),
+		})
+	}
+	return dates, nil
+}
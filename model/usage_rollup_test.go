@@ -0,0 +1,106 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageRollupFlushAndQuery(t *testing.T) {
+	require.NoError(t, DB.AutoMigrate(&UsageRollup{}))
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM usage_rollups")
+	})
+
+	now := time.Now().Unix()
+	record := func(channelId int, quota int64, useTime int) {
+		recordUsageRollup(&UsageRollup{
+			BucketStart: now,
+			UserId:      1,
+			Username:    "rollup_user",
+			TokenId:     7,
+			ModelName:   "gpt-4o",
+			ChannelId:   channelId,
+			Group:       "default",
+			UsageRollupMetrics: UsageRollupMetrics{
+				RequestCount: 1,
+				PromptTokens: 10,
+				CachedTokens: usageRollupCachedTokens(map[string]interface{}{"cache_tokens": 4}),
+				Quota:        quota,
+			},
+		}, useTime)
+	}
+	record(1, 100, 1)
+	record(1, 100, 2)
+	record(2, 50, 40)
+	recordUsageRollup(&UsageRollup{BucketStart: now, UserId: 1, ModelName: "gpt-4o", ChannelId: 2, Group: "default", UsageRollupMetrics: UsageRollupMetrics{ErrorCount: 1}}, -1)
+	FlushUsageRollups()
+	// 再次写入同一分桶时累加到已有行
+	record(1, 100, 4)
+	FlushUsageRollups()
+
+	stats, err := QueryUsageRollups(&UsageRollupQuery{Granularity: UsageRollupHour, GroupBy: []string{"channel"}, UserId: 1})
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	require.Equal(t, 1, stats[0].ChannelId)
+	require.Equal(t, int64(3), stats[0].RequestCount)
+	require.Equal(t, int64(300), stats[0].Quota)
+	require.Equal(t, int64(12), stats[0].CachedTokens)
+	require.Equal(t, 3, stats[0].P50UseTime)
+	require.Equal(t, 5, stats[0].P99UseTime)
+	require.Equal(t, int64(1), stats[1].RequestCount)
+	require.Equal(t, int64(1), stats[1].ErrorCount)
+	require.Equal(t, 60, stats[1].P90UseTime)
+
+	daily, err := QueryUsageRollups(&UsageRollupQuery{Granularity: UsageRollupDay, UserId: 1})
+	require.NoError(t, err)
+	require.Len(t, daily, 1)
+	require.Equal(t, int64(4), daily[0].RequestCount)
+	require.Equal(t, int64(350), daily[0].Quota)
+
+	_, err = QueryUsageRollups(&UsageRollupQuery{GroupBy: []string{"unknown"}})
+	require.Error(t, err)
+}
+
+func TestBackfillUsageRollups(t *testing.T) {
+	require.NoError(t, DB.AutoMigrate(&UsageRollup{}))
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM usage_rollups")
+		DB.Exec("DELETE FROM logs")
+	})
+
+	today := time.Now().Unix()
+	today -= today % 86400
+	dayStart := today - 2*86400
+	require.NoError(t, DB.Create(&Log{UserId: 1, Type: LogTypeConsume, CreatedAt: dayStart + 3600, ModelName: "gpt-4o", TokenId: 7, ChannelId: 1, Group: "vip", Quota: 200, PromptTokens: 5, CompletionTokens: 6, UseTime: 2, Other: `{"cache_tokens":3}`}).Error)
+	require.NoError(t, DB.Create(&Log{UserId: 1, Type: LogTypeError, CreatedAt: dayStart + 7200, ModelName: "gpt-4o", TokenId: 7, ChannelId: 1, Group: "vip"}).Error)
+	require.NoError(t, DB.Create(&Log{UserId: 1, Type: LogTypeTopup, CreatedAt: dayStart + 7200, Quota: 1000}).Error)
+	// 回填前已有的数据会被覆盖
+	require.NoError(t, DB.Create(&UsageRollup{Granularity: UsageRollupDay, BucketStart: dayStart, UserId: 1, ModelName: "stale", UsageRollupMetrics: UsageRollupMetrics{RequestCount: 99}}).Error)
+
+	require.Error(t, BackfillUsageRollups(dayStart, today+1, nil))
+	common.LogConsumeEnabled = false
+	require.Error(t, BackfillUsageRollups(dayStart, dayStart+86400, nil))
+	common.LogConsumeEnabled = true
+	processed := 0
+	require.NoError(t, BackfillUsageRollups(dayStart, dayStart+86400, func(n int) { processed = n }))
+	require.Equal(t, 2, processed)
+
+	stats, err := QueryUsageRollups(&UsageRollupQuery{Granularity: UsageRollupDay, GroupBy: []string{"group", "token"}, StartTimestamp: dayStart})
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, "vip", stats[0].Group)
+	require.Equal(t, 7, stats[0].TokenId)
+	require.Equal(t, int64(1), stats[0].RequestCount)
+	require.Equal(t, int64(1), stats[0].ErrorCount)
+	require.Equal(t, int64(200), stats[0].Quota)
+	require.Equal(t, int64(3), stats[0].CachedTokens)
+
+	hourly, err := QueryUsageRollups(&UsageRollupQuery{Granularity: UsageRollupHour, ByTime: true, StartTimestamp: dayStart})
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+	require.Equal(t, dayStart+3600, hourly[0].BucketStart)
+}
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	WebhookEndpointStatusEnabled  = 1
+	WebhookEndpointStatusDisabled = 2
+
+	WebhookDeliveryStatusPending = "pending"
+	WebhookDeliveryStatusSuccess = "success"
+	WebhookDeliveryStatusFailed  = "failed"
+)
+
+// WebhookEndpoint 事件订阅端点。IsSystem 为管理员创建的系统端点，接收系统事件以及所有用户的事件；
+// 普通端点只接收所属用户自己的事件
+type WebhookEndpoint struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	IsSystem    bool   `json:"is_system" gorm:"index"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	Url         string `json:"url" gorm:"type:varchar(512)"`
+	Secret      string `json:"-" gorm:"type:varchar(512);serializer:secret"` // 签名密钥，不返回给前端，启用加密时加密存储
+	Events      string `json:"events" gorm:"type:text"`                      // 逗号分隔，* 表示全部事件
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// WebhookDelivery 一次事件投递及其重试状态
+type WebhookDelivery struct {
+	Id            int    `json:"id"`
+	EndpointId    int    `json:"endpoint_id" gorm:"index"`
+	UserId        int    `json:"user_id" gorm:"index"`   // 端点所属用户
+	IsSystem      bool   `json:"is_system" gorm:"index"` // 是否投递到系统端点
+	EventId       string `json:"event_id" gorm:"type:varchar(64);index"`
+	Event         string `json:"event" gorm:"type:varchar(64)"`
+	Payload       string `json:"payload" gorm:"type:text"`
+	Status        string `json:"status" gorm:"type:varchar(16);index"`
+	Attempts      int    `json:"attempts" gorm:"default:0"`
+	NextAttemptAt int64  `json:"next_attempt_at" gorm:"bigint;index"`
+	ResponseCode  int    `json:"response_code"`
+	ResponseBody  string `json:"response_body" gorm:"type:text"`
+	Error         string `json:"error" gorm:"type:text"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint;index"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+func (endpoint *WebhookEndpoint) GetEvents() []string {
+	events := make([]string, 0)
+	for _, event := range strings.Split(endpoint.Events, ",") {
+		event = strings.TrimSpace(event)
+		if event != "" {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Subscribes 端点是否订阅了该事件
+func (endpoint *WebhookEndpoint) Subscribes(event string) bool {
+	for _, e := range endpoint.GetEvents() {
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (endpoint *WebhookEndpoint) Insert() error {
+	now := common.GetTimestamp()
+	endpoint.CreatedTime = now
+	endpoint.UpdatedTime = now
+	return DB.Create(endpoint).Error
+}
+
+func (endpoint *WebhookEndpoint) Update() error {
+	endpoint.UpdatedTime = common.GetTimestamp()
+	return DB.Model(endpoint).Select("name", "url", "secret", "events", "status", "updated_time").Updates(endpoint).Error
+}
+
+func (endpoint *WebhookEndpoint) Delete() error {
+	return DB.Delete(endpoint).Error
+}
+
+// GetWebhookEndpoints 获取用户的端点，system 为 true 时获取系统端点
+func GetWebhookEndpoints(userId int, system bool) ([]*WebhookEndpoint, error) {
+	var endpoints []*WebhookEndpoint
+	query := DB.Where("is_system = ?", system)
+	if !system {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.Order("id desc").Find(&endpoints).Error
+	return endpoints, err
+}
+
+// GetWebhookEndpoint 获取用户的端点，system 为 true 时获取系统端点
+func GetWebhookEndpoint(id int, userId int, system bool) (*WebhookEndpoint, error) {
+	endpoint := &WebhookEndpoint{}
+	query := DB.Where("id = ? AND is_system = ?", id, system)
+	if !system {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.First(endpoint).Error
+	return endpoint, err
+}
+
+func GetWebhookEndpointById(id int) (*WebhookEndpoint, error) {
+	endpoint := &WebhookEndpoint{}
+	err := DB.First(endpoint, "id = ?", id).Error
+	return endpoint, err
+}
+
+// GetWebhookEndpointsForEvent 获取应接收该事件的已启用端点：系统端点，以及 userId 所属的普通端点
+func GetWebhookEndpointsForEvent(event string, userId int, systemOnly bool) ([]*WebhookEndpoint, error) {
+	var candidates []*WebhookEndpoint
+	query := DB.Where("status = ?", WebhookEndpointStatusEnabled)
+	if systemOnly || userId == 0 {
+		query = query.Where("is_system = ?", true)
+	} else {
+		query = query.Where("is_system = ? OR user_id = ?", true, userId)
+	}
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	endpoints := make([]*WebhookEndpoint, 0, len(candidates))
+	for _, endpoint := range candidates {
+		if endpoint.Subscribes(event) {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+func CreateWebhookDeliveries(deliveries []*WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return DB.Create(&deliveries).Error
+}
+
+// GetDueWebhookDeliveries 获取到期待重试的投递
+func GetDueWebhookDeliveries(now int64, limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := DB.Where("status = ? AND next_attempt_at <= ?", WebhookDeliveryStatusPending, now).
+		Order("next_attempt_at asc, id asc").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimWebhookDelivery 以 CAS 方式领取一次投递，将下次尝试时间推迟到 leaseUntil，
+// 防止多个进程同时投递；返回 false 表示已被其他进程领取
+func ClaimWebhookDelivery(delivery *WebhookDelivery, leaseUntil int64) (bool, error) {
+	result := DB.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ? AND next_attempt_at = ?",
+			delivery.Id, WebhookDeliveryStatusPending, delivery.Attempts, delivery.NextAttemptAt).
+		Updates(map[string]any{
+			"next_attempt_at": leaseUntil,
+			"updated_time":    common.GetTimestamp(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	delivery.NextAttemptAt = leaseUntil
+	return true, nil
+}
+
+// UpdateResult 保存一次投递尝试的结果
+func (delivery *WebhookDelivery) UpdateResult() error {
+	delivery.UpdatedTime = common.GetTimestamp()
+	return DB.Model(delivery).Select("status", "attempts", "next_attempt_at", "response_code",
+		"response_body", "error", "updated_time").Updates(delivery).Error
+}
+
+// GetWebhookDelivery 获取用户的投递记录，system 为 true 时获取系统端点的投递记录
+func GetWebhookDelivery(id int, userId int, system bool) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	query := DB.Where("id = ? AND is_system = ?", id, system)
+	if !system {
+		query = query.Where("user_id = ?", userId)
+	}
+	err := query.First(delivery).Error
+	return delivery, err
+}
+
+// GetWebhookDeliveries 分页获取投递记录，endpointId 为 0 时不按端点过滤
+func GetWebhookDeliveries(userId int, system bool, endpointId int, status string, pageInfo *common.PageInfo) (deliveries []*WebhookDelivery, total int64, err error) {
+	query := DB.Model(&WebhookDelivery{}).Where("is_system = ?", system)
+	if !system {
+		query = query.Where("user_id = ?", userId)
+	}
+	if endpointId > 0 {
+		query = query.Where("endpoint_id = ?", endpointId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// DeleteWebhookDeliveriesBefore 清理早于指定时间且已结束的投递记录
+func DeleteWebhookDeliveriesBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_time < ? AND status <> ?", timestamp, WebhookDeliveryStatusPending).
+		Delete(&WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
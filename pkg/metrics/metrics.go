@@ -0,0 +1,145 @@
+// Package metrics 提供 Prometheus 指标的定义与记录入口。
+// 该包不依赖业务包，relay / service / controller 均可直接引用。
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "newapi"
+
+// 计费阶段
+const (
+	BillingStagePreConsume = "pre_consume"
+	BillingStageSettle     = "settle"
+	BillingStageRefund     = "refund"
+)
+
+// latencyBuckets 覆盖从百毫秒级的短请求到数分钟的长推理请求
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Total number of relay requests.",
+	}, []string{"relay_format", "model", "group", "channel", "status_code"})
+
+	relayDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "Relay request latency in seconds, including retries.",
+		Buckets:   latencyBuckets,
+	}, []string{"relay_format", "model", "group", "channel"})
+
+	relayFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_time_to_first_token_seconds",
+		Help:      "Time from request start to the first streamed response chunk in seconds.",
+		Buckets:   latencyBuckets,
+	}, []string{"relay_format", "model", "group", "channel"})
+
+	relayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Total number of channel retries performed by relay requests.",
+	}, []string{"relay_format", "model", "group"})
+
+	upstreamResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_responses_total",
+		Help:      "Total number of upstream responses by status code; status_code is \"error\" when the request failed before a response.",
+	}, []string{"channel", "channel_type", "status_code"})
+
+	billingQuota = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "billing_quota_total",
+		Help:      "Quota processed by billing sessions, by stage (pre_consume, settle, refund) and funding source.",
+	}, []string{"stage", "source", "model"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		relayRequests,
+		relayDuration,
+		relayFirstToken,
+		relayRetries,
+		upstreamResponses,
+		billingQuota,
+	)
+}
+
+// MustRegister 注册额外的采集器，例如需要在抓取时读取数据库状态的渠道指标
+func MustRegister(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+// Handler 返回 Prometheus 文本格式的指标输出
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RelayLabels 描述一次中继请求的指标维度
+type RelayLabels struct {
+	RelayFormat string
+	Model       string
+	Group       string
+	ChannelId   int
+}
+
+func (l RelayLabels) channel() string {
+	if l.ChannelId <= 0 {
+		return ""
+	}
+	return strconv.Itoa(l.ChannelId)
+}
+
+// ObserveRelayRequest 记录一次中继请求的最终状态码与耗时
+func ObserveRelayRequest(labels RelayLabels, statusCode int, duration time.Duration) {
+	channel := labels.channel()
+	relayRequests.WithLabelValues(labels.RelayFormat, labels.Model, labels.Group, channel, strconv.Itoa(statusCode)).Inc()
+	relayDuration.WithLabelValues(labels.RelayFormat, labels.Model, labels.Group, channel).Observe(duration.Seconds())
+}
+
+// ObserveFirstToken 记录流式请求的首字时间
+func ObserveFirstToken(labels RelayLabels, duration time.Duration) {
+	if duration < 0 {
+		return
+	}
+	relayFirstToken.WithLabelValues(labels.RelayFormat, labels.Model, labels.Group, labels.channel()).Observe(duration.Seconds())
+}
+
+// AddRelayRetries 累加一次请求中发生的渠道重试次数
+func AddRelayRetries(labels RelayLabels, retries int) {
+	if retries <= 0 {
+		return
+	}
+	relayRetries.WithLabelValues(labels.RelayFormat, labels.Model, labels.Group).Add(float64(retries))
+}
+
+// IncUpstreamResponse 记录上游响应状态码，statusCode <= 0 表示请求未得到响应
+func IncUpstreamResponse(channelId int, channelType int, statusCode int) {
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	upstreamResponses.WithLabelValues(strconv.Itoa(channelId), strconv.Itoa(channelType), code).Inc()
+}
+
+// AddBillingQuota 累加计费会话在各阶段处理的额度
+func AddBillingQuota(stage string, source string, model string, quota int) {
+	if quota <= 0 {
+		return
+	}
+	billingQuota.WithLabelValues(stage, source, model).Add(float64(quota))
+}
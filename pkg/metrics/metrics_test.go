@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerExportsRecordedMetrics(t *testing.T) {
+	labels := RelayLabels{RelayFormat: "openai", Model: "gpt-4o", Group: "default", ChannelId: 3}
+	ObserveRelayRequest(labels, http.StatusOK, 2*time.Second)
+	ObserveFirstToken(labels, 300*time.Millisecond)
+	AddRelayRetries(labels, 2)
+	AddRelayRetries(labels, 0)
+	IncUpstreamResponse(3, 1, http.StatusTooManyRequests)
+	IncUpstreamResponse(3, 1, 0)
+	AddBillingQuota(BillingStagePreConsume, "wallet", "gpt-4o", 100)
+	AddBillingQuota(BillingStageRefund, "wallet", "gpt-4o", 0)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	output := string(body)
+
+	assert.Contains(t, output, `newapi_relay_requests_total{channel="3",group="default",model="gpt-4o",relay_format="openai",status_code="200"} 1`)
+	assert.Contains(t, output, `newapi_relay_request_duration_seconds_count{channel="3",group="default",model="gpt-4o",relay_format="openai"} 1`)
+	assert.Contains(t, output, `newapi_relay_time_to_first_token_seconds_bucket{channel="3",group="default",model="gpt-4o",relay_format="openai",le="0.5"} 1`)
+	assert.Contains(t, output, `newapi_relay_retries_total{group="default",model="gpt-4o",relay_format="openai"} 2`)
+	assert.Contains(t, output, `newapi_upstream_responses_total{channel="3",channel_type="1",status_code="429"} 1`)
+	assert.Contains(t, output, `newapi_upstream_responses_total{channel="3",channel_type="1",status_code="error"} 1`)
+	assert.Contains(t, output, `newapi_billing_quota_total{model="gpt-4o",source="wallet",stage="pre_consume"} 100`)
+	assert.NotContains(t, output, `stage="refund"`)
+}
@@ -0,0 +1,643 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// OpenAI Realtime 的 pcm16 为 24kHz 单声道，Gemini Live 可直接接收并在服务端重采样
+const geminiLiveInputAudioMimeType = "audio/pcm;rate=24000"
+
+// OpenAI Realtime 的预置音色在 Gemini 中不存在，使用这些音色时采用 Gemini 默认音色
+var openaiRealtimeVoices = map[string]struct{}{
+	"alloy": {}, "ash": {}, "ballad": {}, "coral": {}, "echo": {},
+	"sage": {}, "shimmer": {}, "verse": {}, "marin": {}, "cedar": {},
+}
+
+// geminiLiveSession 在 OpenAI Realtime 事件与 Gemini Live 消息之间转换的会话状态
+type geminiLiveSession struct {
+	mu sync.Mutex
+
+	model    string
+	idPrefix string
+	seq      int
+
+	setupSent bool
+	// 建立会话所用的 session.update，收到 setupComplete 后回复 session.updated
+	pendingSession *dto.RealtimeSession
+	// 已向上游回复函数调用结果，Gemini 会自动继续生成，下一次 response.create 无需再触发新轮次
+	toolResponseSent bool
+	// Gemini 的函数调用结果需要带上函数名，按 call_id 记录
+	callNames map[string]string
+	// Gemini Live 只接受 PCM 音频输入
+	inputAudioFormat string
+
+	// 当前正在输出的响应
+	responseId string
+	messageId  string
+	turnUsage  *dto.RealtimeUsage
+}
+
+func newGeminiLiveSession(model string, idPrefix string) *geminiLiveSession {
+	return &geminiLiveSession{
+		model:     model,
+		idPrefix:  idPrefix,
+		callNames: make(map[string]string),
+	}
+}
+
+func (s *geminiLiveSession) nextId(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s_%s_%d", prefix, s.idPrefix, s.seq)
+}
+
+func (s *geminiLiveSession) event(eventType string) dto.RealtimeEvent {
+	return dto.RealtimeEvent{EventId: s.nextId("event"), Type: eventType}
+}
+
+// sessionCreated 连接建立后立即发送给客户端的 session.created，实际的 Gemini 会话在收到首个客户端事件时建立
+func (s *geminiLiveSession) sessionCreated() dto.RealtimeEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event := s.event(dto.RealtimeEventTypeSessionCreated)
+	event.Session = &dto.RealtimeSession{
+		Modalities:        []string{"text", "audio"},
+		InputAudioFormat:  "pcm16",
+		OutputAudioFormat: "pcm16",
+	}
+	return event
+}
+
+// buildSetup 根据 session.update 构建 Gemini Live 的 setup 消息，session 为空时使用默认配置
+func (s *geminiLiveSession) buildSetup(session *dto.RealtimeSession) *dto.GeminiLiveSetup {
+	setup := &dto.GeminiLiveSetup{
+		Model:            "models/" + s.model,
+		GenerationConfig: &dto.GeminiChatGenerationConfig{ResponseModalities: []string{"AUDIO"}},
+	}
+	if session == nil {
+		setup.OutputAudioTranscription = &struct{}{}
+		return setup
+	}
+	if len(session.Modalities) > 0 && !common.StringsContains(session.Modalities, "audio") {
+		setup.GenerationConfig.ResponseModalities = []string{"TEXT"}
+	} else {
+		// OpenAI 会同时返回音频的文字转写
+		setup.OutputAudioTranscription = &struct{}{}
+		if _, ok := openaiRealtimeVoices[session.Voice]; session.Voice != "" && !ok {
+			setup.GenerationConfig.SpeechConfig, _ = common.Marshal(map[string]any{
+				"voiceConfig": map[string]any{
+					"prebuiltVoiceConfig": map[string]any{"voiceName": session.Voice},
+				},
+			})
+		}
+	}
+	if session.Temperature > 0 {
+		setup.GenerationConfig.Temperature = common.GetPointer(session.Temperature)
+	}
+	if strings.TrimSpace(session.Instructions) != "" {
+		setup.SystemInstruction = &dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: session.Instructions}}}
+	}
+	if session.InputAudioTranscription.Model != "" {
+		setup.InputAudioTranscription = &struct{}{}
+	}
+	functions := make([]map[string]any, 0, len(session.Tools))
+	for _, tool := range session.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		function := map[string]any{
+			"name":        tool.Name,
+			"description": tool.Description,
+		}
+		if tool.Parameters != nil {
+			function["parameters"] = cleanFunctionParameters(tool.Parameters)
+		}
+		functions = append(functions, function)
+	}
+	if len(functions) > 0 {
+		setup.Tools = []dto.GeminiChatTool{{FunctionDeclarations: functions}}
+	}
+	return setup
+}
+
+// ClientEventToGemini 将客户端的 OpenAI Realtime 事件转换为发往 Gemini 的消息，
+// replies 为需要直接回复给客户端的事件。首个事件之前会先发送 setup
+func (s *geminiLiveSession) clientEventToGemini(event *dto.RealtimeEvent) (messages []dto.GeminiLiveClientMessage, replies []dto.RealtimeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event.Type == dto.RealtimeEventTypeSessionUpdate && event.Session != nil && event.Session.InputAudioFormat != "" {
+		s.inputAudioFormat = event.Session.InputAudioFormat
+	}
+
+	if !s.setupSent {
+		s.setupSent = true
+		var session *dto.RealtimeSession
+		if event.Type == dto.RealtimeEventTypeSessionUpdate {
+			session = event.Session
+			s.pendingSession = session
+		}
+		messages = append(messages, dto.GeminiLiveClientMessage{Setup: s.buildSetup(session)})
+		if event.Type == dto.RealtimeEventTypeSessionUpdate {
+			return messages, nil
+		}
+	}
+
+	switch event.Type {
+	case dto.RealtimeEventTypeSessionUpdate:
+		// Gemini 会话建立后不能修改配置，仍回复 session.updated 以免客户端等待
+		reply := s.event(dto.RealtimeEventTypeSessionUpdated)
+		reply.Session = event.Session
+		replies = append(replies, reply)
+	case dto.RealtimeEventInputAudioBufferAppend:
+		if s.inputAudioFormat != "" && s.inputAudioFormat != "pcm16" {
+			reply := s.event(dto.RealtimeEventTypeError)
+			reply.Error = &types.OpenAIError{
+				Message: fmt.Sprintf("input_audio_format %s is not supported by this model, use pcm16", s.inputAudioFormat),
+				Type:    "invalid_request_error",
+				Code:    "unsupported_audio_format",
+			}
+			replies = append(replies, reply)
+			break
+		}
+		messages = append(messages, dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{
+			Audio: &dto.GeminiInlineData{MimeType: geminiLiveInputAudioMimeType, Data: event.Audio},
+		}})
+	case dto.RealtimeEventInputAudioBufferCommit:
+		messages = append(messages, dto.GeminiLiveClientMessage{RealtimeInput: &dto.GeminiLiveRealtimeInput{AudioStreamEnd: true}})
+	case dto.RealtimeEventTypeConversationCreate:
+		if event.Item == nil {
+			break
+		}
+		switch event.Item.Type {
+		case "message":
+			role := "user"
+			if event.Item.Role == "assistant" {
+				role = "model"
+			}
+			parts := make([]dto.GeminiPart, 0, len(event.Item.Content))
+			for _, content := range event.Item.Content {
+				switch content.Type {
+				case "input_text", "text":
+					parts = append(parts, dto.GeminiPart{Text: content.Text})
+				case "input_audio":
+					parts = append(parts, dto.GeminiPart{InlineData: &dto.GeminiInlineData{MimeType: geminiLiveInputAudioMimeType, Data: content.Audio}})
+				}
+			}
+			if len(parts) > 0 {
+				messages = append(messages, dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{
+					Turns: []dto.GeminiChatContent{{Role: role, Parts: parts}},
+				}})
+			}
+		case "function_call_output":
+			messages = append(messages, dto.GeminiLiveClientMessage{ToolResponse: &dto.GeminiLiveToolResponse{
+				FunctionResponses: []dto.GeminiLiveFunctionResponse{{
+					Id:       event.Item.CallId,
+					Name:     s.callNames[event.Item.CallId],
+					Response: map[string]any{"output": event.Item.Output},
+				}},
+			}})
+			s.toolResponseSent = true
+		}
+		reply := s.event(dto.RealtimeEventConversationItemCreated)
+		reply.Item = event.Item
+		replies = append(replies, reply)
+	case dto.RealtimeEventTypeResponseCreate:
+		if s.toolResponseSent {
+			s.toolResponseSent = false
+			break
+		}
+		messages = append(messages, dto.GeminiLiveClientMessage{ClientContent: &dto.GeminiLiveClientContent{TurnComplete: true}})
+	}
+	return messages, replies
+}
+
+// startResponse 在一轮输出开始时生成 response.created 与消息条目
+func (s *geminiLiveSession) startResponse() []dto.RealtimeEvent {
+	if s.responseId != "" {
+		return nil
+	}
+	s.responseId = s.nextId("resp")
+	created := s.event(dto.RealtimeEventResponseCreated)
+	created.Response = &dto.RealtimeResponse{Id: s.responseId, Object: "realtime.response", Status: "in_progress"}
+	return []dto.RealtimeEvent{created}
+}
+
+func (s *geminiLiveSession) startMessage() []dto.RealtimeEvent {
+	events := s.startResponse()
+	if s.messageId != "" {
+		return events
+	}
+	s.messageId = s.nextId("item")
+	added := s.event(dto.RealtimeEventResponseOutputItemAdded)
+	added.ResponseId = s.responseId
+	added.Item = &dto.RealtimeItem{Id: s.messageId, Type: "message", Status: "in_progress", Role: "assistant", Content: []dto.RealtimeContent{}}
+	return append(events, added)
+}
+
+// finishResponse 结束当前响应并返回 response.done，同时返回本轮需要结算的 usage
+func (s *geminiLiveSession) finishResponse(status string) ([]dto.RealtimeEvent, *dto.RealtimeUsage) {
+	if s.responseId == "" {
+		return nil, nil
+	}
+	var events []dto.RealtimeEvent
+	if s.messageId != "" {
+		done := s.event(dto.RealtimeEventResponseOutputItemDone)
+		done.ResponseId = s.responseId
+		done.Item = &dto.RealtimeItem{Id: s.messageId, Type: "message", Status: status, Role: "assistant", Content: []dto.RealtimeContent{}}
+		events = append(events, done)
+	}
+	usage := s.turnUsage
+	done := s.event(dto.RealtimeEventTypeResponseDone)
+	done.Response = &dto.RealtimeResponse{Id: s.responseId, Object: "realtime.response", Status: status, Usage: usage}
+	events = append(events, done)
+	s.responseId = ""
+	s.messageId = ""
+	s.turnUsage = nil
+	return events, usage
+}
+
+// serverMessageToRealtime 将 Gemini 的消息转换为 OpenAI Realtime 事件，settled 为本条消息结算的 usage
+func (s *geminiLiveSession) serverMessageToRealtime(message *dto.GeminiLiveServerMessage) (events []dto.RealtimeEvent, settled *dto.RealtimeUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// usage 可能与 turnComplete 在同一条消息中，先记录以便附加到 response.done
+	if message.UsageMetadata != nil {
+		usage := geminiLiveUsageToRealtime(message.UsageMetadata)
+		if s.responseId != "" {
+			// 同一轮中多次下发时为累计值，以最后一次为准
+			s.turnUsage = usage
+		} else {
+			settled = usage
+		}
+	}
+
+	if message.SetupComplete != nil && s.pendingSession != nil {
+		event := s.event(dto.RealtimeEventTypeSessionUpdated)
+		event.Session = s.pendingSession
+		events = append(events, event)
+		s.pendingSession = nil
+	}
+
+	if content := message.ServerContent; content != nil {
+		if content.InputTranscription != nil && content.InputTranscription.Text != "" {
+			event := s.event(dto.RealtimeEventInputAudioTranscriptionDelta)
+			event.Delta = content.InputTranscription.Text
+			events = append(events, event)
+		}
+		if content.ModelTurn != nil {
+			for _, part := range content.ModelTurn.Parts {
+				if part.Thought {
+					continue
+				}
+				if part.Text != "" {
+					events = append(events, s.startMessage()...)
+					event := s.event(dto.RealtimeEventResponseTextDelta)
+					event.ResponseId = s.responseId
+					event.ItemId = s.messageId
+					event.Delta = part.Text
+					events = append(events, event)
+				}
+				if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+					events = append(events, s.startMessage()...)
+					event := s.event(dto.RealtimeEventResponseAudioDelta)
+					event.ResponseId = s.responseId
+					event.ItemId = s.messageId
+					event.Delta = part.InlineData.Data
+					events = append(events, event)
+				}
+			}
+		}
+		if content.OutputTranscription != nil && content.OutputTranscription.Text != "" {
+			events = append(events, s.startMessage()...)
+			event := s.event(dto.RealtimeEventResponseAudioTranscriptionDelta)
+			event.ResponseId = s.responseId
+			event.ItemId = s.messageId
+			event.Delta = content.OutputTranscription.Text
+			events = append(events, event)
+		}
+		if content.Interrupted {
+			events = append(events, s.event(dto.RealtimeEventInputAudioBufferSpeechStarted))
+			doneEvents, usage := s.finishResponse("cancelled")
+			events = append(events, doneEvents...)
+			settled = mergeRealtimeUsage(settled, usage)
+		}
+		if content.TurnComplete {
+			doneEvents, usage := s.finishResponse("completed")
+			events = append(events, doneEvents...)
+			settled = mergeRealtimeUsage(settled, usage)
+		}
+	}
+
+	if message.ToolCall != nil && len(message.ToolCall.FunctionCalls) > 0 {
+		events = append(events, s.startResponse()...)
+		for _, call := range message.ToolCall.FunctionCalls {
+			arguments := "{}"
+			if call.Args != nil {
+				if args, err := common.Marshal(call.Args); err == nil {
+					arguments = string(args)
+				}
+			}
+			s.callNames[call.Id] = call.Name
+			itemId := s.nextId("item")
+			item := &dto.RealtimeItem{Id: itemId, Type: "function_call", Status: "completed", Name: common.GetPointer(call.Name), CallId: call.Id, Arguments: arguments}
+
+			added := s.event(dto.RealtimeEventResponseOutputItemAdded)
+			added.ResponseId = s.responseId
+			added.Item = item
+			delta := s.event(dto.RealtimeEventResponseFunctionCallArgumentsDelta)
+			delta.ResponseId = s.responseId
+			delta.ItemId = itemId
+			delta.CallId = call.Id
+			delta.Delta = arguments
+			argumentsDone := s.event(dto.RealtimeEventResponseFunctionCallArgumentsDone)
+			argumentsDone.ResponseId = s.responseId
+			argumentsDone.ItemId = itemId
+			argumentsDone.CallId = call.Id
+			argumentsDone.Name = call.Name
+			argumentsDone.Arguments = arguments
+			itemDone := s.event(dto.RealtimeEventResponseOutputItemDone)
+			itemDone.ResponseId = s.responseId
+			itemDone.Item = item
+			events = append(events, added, delta, argumentsDone, itemDone)
+		}
+		// 客户端需要在 response.done 后提交函数调用结果
+		doneEvents, usage := s.finishResponse("completed")
+		events = append(events, doneEvents...)
+		settled = mergeRealtimeUsage(settled, usage)
+	}
+	return events, settled
+}
+
+func geminiLiveUsageToRealtime(metadata *dto.GeminiLiveUsageMetadata) *dto.RealtimeUsage {
+	usage := &dto.RealtimeUsage{
+		InputTokens:  metadata.PromptTokenCount,
+		OutputTokens: metadata.ResponseTokenCount,
+		TotalTokens:  metadata.TotalTokenCount,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	}
+	for _, detail := range metadata.PromptTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.InputTokenDetails.AudioTokens += detail.TokenCount
+		}
+	}
+	for _, detail := range metadata.ResponseTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.OutputTokenDetails.AudioTokens += detail.TokenCount
+		}
+	}
+	// 图片、视频等其他模态按文本计费
+	usage.InputTokenDetails.TextTokens = usage.InputTokens - usage.InputTokenDetails.AudioTokens
+	usage.OutputTokenDetails.TextTokens = usage.OutputTokens - usage.OutputTokenDetails.AudioTokens
+	return usage
+}
+
+func mergeRealtimeUsage(a *dto.RealtimeUsage, b *dto.RealtimeUsage) *dto.RealtimeUsage {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	a.TotalTokens += b.TotalTokens
+	a.InputTokens += b.InputTokens
+	a.OutputTokens += b.OutputTokens
+	a.InputTokenDetails.TextTokens += b.InputTokenDetails.TextTokens
+	a.InputTokenDetails.AudioTokens += b.InputTokenDetails.AudioTokens
+	a.OutputTokenDetails.TextTokens += b.OutputTokenDetails.TextTokens
+	a.OutputTokenDetails.AudioTokens += b.OutputTokenDetails.AudioTokens
+	return a
+}
+
+// countRealtimeEvent 本地估算事件的 token，用于上游未返回 usage 时计费
+func countRealtimeEvent(c *gin.Context, info *relaycommon.RelayInfo, event dto.RealtimeEvent, usage *dto.RealtimeUsage, input bool) error {
+	textToken, audioToken, err := service.CountTokenRealtime(info, event, info.UpstreamModelName)
+	if err != nil {
+		return err
+	}
+	if textToken == 0 && audioToken == 0 {
+		return nil
+	}
+	logger.LogDebug(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", event.Type, textToken, audioToken))
+	usage.TotalTokens += textToken + audioToken
+	if input {
+		usage.InputTokens += textToken + audioToken
+		usage.InputTokenDetails.TextTokens += textToken
+		usage.InputTokenDetails.AudioTokens += audioToken
+	} else {
+		usage.OutputTokens += textToken + audioToken
+		usage.OutputTokenDetails.TextTokens += textToken
+		usage.OutputTokenDetails.AudioTokens += audioToken
+	}
+	return nil
+}
+
+// GeminiLiveRealtimeHandler 把 /v1/realtime 的 OpenAI Realtime 会话桥接到 Gemini Live（BidiGenerateContent），
+// 使用上游返回的 usage 逐轮预扣额度，上游未返回 usage 的部分按本地估算计费
+func GeminiLiveRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.RealtimeUsage) {
+	if info == nil || info.ClientWs == nil || info.TargetWs == nil {
+		return types.NewError(fmt.Errorf("invalid websocket connection"), types.ErrorCodeBadResponse), nil
+	}
+
+	info.IsStream = true
+	clientConn := info.ClientWs
+	targetConn := info.TargetWs
+	session := newGeminiLiveSession(info.UpstreamModelName, c.GetString(common.RequestIdKey))
+
+	var clientWriteMutex sync.Mutex
+	writeClient := func(events []dto.RealtimeEvent) error {
+		clientWriteMutex.Lock()
+		defer clientWriteMutex.Unlock()
+		for _, event := range events {
+			if err := helper.WssObject(c, clientConn, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	clientClosed := make(chan struct{})
+	targetClosed := make(chan struct{})
+	setupComplete := make(chan struct{})
+	errChan := make(chan error, 2)
+
+	var usageMutex sync.Mutex
+	localUsage := &dto.RealtimeUsage{}
+	sumUsage := &dto.RealtimeUsage{}
+
+	if err := writeClient([]dto.RealtimeEvent{session.sessionCreated()}); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponse), nil
+	}
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in client reader: %v", r)
+			}
+		}()
+		for {
+			select {
+			case <-c.Done():
+				return
+			default:
+			}
+			_, message, err := clientConn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					errChan <- fmt.Errorf("error reading from client: %v", err)
+				}
+				close(clientClosed)
+				return
+			}
+
+			realtimeEvent := &dto.RealtimeEvent{}
+			if err = common.Unmarshal(message, realtimeEvent); err != nil {
+				errChan <- fmt.Errorf("error unmarshalling message: %v", err)
+				return
+			}
+			if realtimeEvent.Type == dto.RealtimeEventTypeSessionUpdate && realtimeEvent.Session != nil {
+				if realtimeEvent.Session.Tools != nil {
+					info.RealtimeTools = realtimeEvent.Session.Tools
+				}
+				info.InputAudioFormat = common.GetStringIfEmpty(realtimeEvent.Session.InputAudioFormat, info.InputAudioFormat)
+				info.OutputAudioFormat = common.GetStringIfEmpty(realtimeEvent.Session.OutputAudioFormat, info.OutputAudioFormat)
+			}
+
+			usageMutex.Lock()
+			err = countRealtimeEvent(c, info, *realtimeEvent, localUsage, true)
+			usageMutex.Unlock()
+			if err != nil {
+				errChan <- fmt.Errorf("error counting token: %v", err)
+				return
+			}
+
+			messages, replies := session.clientEventToGemini(realtimeEvent)
+			for _, geminiMessage := range messages {
+				if err = helper.WssObject(c, targetConn, geminiMessage); err != nil {
+					errChan <- fmt.Errorf("error writing to target: %v", err)
+					return
+				}
+				if geminiMessage.Setup != nil {
+					// Gemini 要求收到 setupComplete 后再发送其他消息
+					select {
+					case <-setupComplete:
+					case <-targetClosed:
+						return
+					case <-c.Done():
+						return
+					}
+				}
+			}
+			usageMutex.Lock()
+			for _, reply := range replies {
+				if err = countRealtimeEvent(c, info, reply, localUsage, true); err != nil {
+					break
+				}
+			}
+			usageMutex.Unlock()
+			if err != nil {
+				errChan <- fmt.Errorf("error counting token: %v", err)
+				return
+			}
+			if err = writeClient(replies); err != nil {
+				errChan <- fmt.Errorf("error writing to client: %v", err)
+				return
+			}
+		}
+	})
+
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errChan <- fmt.Errorf("panic in target reader: %v", r)
+			}
+		}()
+		setupCompleted := false
+		for {
+			select {
+			case <-c.Done():
+				return
+			default:
+			}
+			_, message, err := targetConn.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					errChan <- fmt.Errorf("error reading from target: %v", err)
+				}
+				close(targetClosed)
+				return
+			}
+			info.SetFirstResponseTime()
+			geminiMessage := &dto.GeminiLiveServerMessage{}
+			if err = common.Unmarshal(message, geminiMessage); err != nil {
+				errChan <- fmt.Errorf("error unmarshalling message: %v", err)
+				return
+			}
+			if geminiMessage.SetupComplete != nil && !setupCompleted {
+				setupCompleted = true
+				close(setupComplete)
+			}
+			if len(geminiMessage.GoAway) > 0 {
+				logger.LogWarn(c, "gemini live session will be closed by upstream: "+string(geminiMessage.GoAway))
+			}
+
+			events, settled := session.serverMessageToRealtime(geminiMessage)
+
+			usageMutex.Lock()
+			for _, event := range events {
+				if err = countRealtimeEvent(c, info, event, localUsage, false); err != nil {
+					break
+				}
+			}
+			if err == nil && settled != nil {
+				// 上游返回的 usage 已包含本地估算的部分
+				localUsage = &dto.RealtimeUsage{}
+				err = openai.PreConsumeRealtimeUsage(c, info, settled, sumUsage)
+			}
+			usageMutex.Unlock()
+			if err != nil {
+				errChan <- fmt.Errorf("error consume usage: %v", err)
+				return
+			}
+
+			if err = writeClient(events); err != nil {
+				errChan <- fmt.Errorf("error writing to client: %v", err)
+				return
+			}
+		}
+	})
+
+	select {
+	case <-clientClosed:
+	case <-targetClosed:
+	case err := <-errChan:
+		logger.LogError(c, "realtime error: "+err.Error())
+	case <-c.Done():
+	}
+
+	usageMutex.Lock()
+	if localUsage.TotalTokens != 0 {
+		_ = openai.PreConsumeRealtimeUsage(c, info, localUsage, sumUsage)
+		localUsage = &dto.RealtimeUsage{}
+	}
+	usageMutex.Unlock()
+
+	return nil, sumUsage
+}
@@ -0,0 +1,112 @@
+package gemini
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func realtimeEventTypes(events []dto.RealtimeEvent) []string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestGeminiLiveSessionClientEvents(t *testing.T) {
+	session := newGeminiLiveSession("gemini-live-2.5-flash", "req")
+
+	messages, replies := session.clientEventToGemini(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeSessionUpdate,
+		Session: &dto.RealtimeSession{
+			Modalities:   []string{"text"},
+			Instructions: "be brief",
+			Voice:        "alloy",
+			Tools:        []dto.RealTimeTool{{Type: "function", Name: "get_weather", Parameters: map[string]any{"type": "object"}}},
+		},
+	})
+	require.Empty(t, replies)
+	require.Len(t, messages, 1)
+	setup := messages[0].Setup
+	require.NotNil(t, setup)
+	require.Equal(t, "models/gemini-live-2.5-flash", setup.Model)
+	require.Equal(t, []string{"TEXT"}, setup.GenerationConfig.ResponseModalities)
+	require.Nil(t, setup.OutputAudioTranscription)
+	require.Equal(t, "be brief", setup.SystemInstruction.Parts[0].Text)
+	require.Len(t, setup.Tools, 1)
+
+	messages, _ = session.clientEventToGemini(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferAppend, Audio: "AAAA"})
+	require.Len(t, messages, 1)
+	require.Equal(t, geminiLiveInputAudioMimeType, messages[0].RealtimeInput.Audio.MimeType)
+
+	messages, replies = session.clientEventToGemini(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeConversationCreate,
+		Item: &dto.RealtimeItem{Type: "message", Role: "user", Content: []dto.RealtimeContent{{Type: "input_text", Text: "hi"}}},
+	})
+	require.Len(t, messages, 1)
+	require.Equal(t, "user", messages[0].ClientContent.Turns[0].Role)
+	require.Equal(t, []string{dto.RealtimeEventConversationItemCreated}, realtimeEventTypes(replies))
+
+	messages, _ = session.clientEventToGemini(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseCreate})
+	require.Len(t, messages, 1)
+	require.True(t, messages[0].ClientContent.TurnComplete)
+}
+
+func TestGeminiLiveSessionServerMessages(t *testing.T) {
+	session := newGeminiLiveSession("gemini-live-2.5-flash", "req")
+	session.clientEventToGemini(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionUpdate, Session: &dto.RealtimeSession{}})
+
+	events, settled := session.serverMessageToRealtime(&dto.GeminiLiveServerMessage{SetupComplete: &struct{}{}})
+	require.Nil(t, settled)
+	require.Equal(t, []string{dto.RealtimeEventTypeSessionUpdated}, realtimeEventTypes(events))
+
+	events, _ = session.serverMessageToRealtime(&dto.GeminiLiveServerMessage{ServerContent: &dto.GeminiLiveServerContent{
+		ModelTurn: &dto.GeminiChatContent{Parts: []dto.GeminiPart{{InlineData: &dto.GeminiInlineData{MimeType: "audio/pcm;rate=24000", Data: "AAAA"}}}},
+	}})
+	require.Equal(t, []string{
+		dto.RealtimeEventResponseCreated,
+		dto.RealtimeEventResponseOutputItemAdded,
+		dto.RealtimeEventResponseAudioDelta,
+	}, realtimeEventTypes(events))
+
+	events, settled = session.serverMessageToRealtime(&dto.GeminiLiveServerMessage{
+		ServerContent: &dto.GeminiLiveServerContent{TurnComplete: true},
+		UsageMetadata: &dto.GeminiLiveUsageMetadata{
+			PromptTokenCount:      30,
+			ResponseTokenCount:    20,
+			PromptTokensDetails:   []dto.GeminiPromptTokensDetails{{Modality: "AUDIO", TokenCount: 25}, {Modality: "TEXT", TokenCount: 5}},
+			ResponseTokensDetails: []dto.GeminiPromptTokensDetails{{Modality: "AUDIO", TokenCount: 20}},
+		},
+	})
+	require.Equal(t, []string{dto.RealtimeEventResponseOutputItemDone, dto.RealtimeEventTypeResponseDone}, realtimeEventTypes(events))
+	require.NotNil(t, settled)
+	require.Equal(t, 50, settled.TotalTokens)
+	require.Equal(t, 25, settled.InputTokenDetails.AudioTokens)
+	require.Equal(t, 5, settled.InputTokenDetails.TextTokens)
+	require.Equal(t, 20, settled.OutputTokenDetails.AudioTokens)
+	require.Equal(t, settled, events[1].Response.Usage)
+
+	events, _ = session.serverMessageToRealtime(&dto.GeminiLiveServerMessage{ToolCall: &dto.GeminiLiveToolCall{
+		FunctionCalls: []dto.GeminiLiveFunctionCall{{Id: "call_1", Name: "get_weather", Args: map[string]any{"city": "a"}}},
+	}})
+	require.Equal(t, []string{
+		dto.RealtimeEventResponseCreated,
+		dto.RealtimeEventResponseOutputItemAdded,
+		dto.RealtimeEventResponseFunctionCallArgumentsDelta,
+		dto.RealtimeEventResponseFunctionCallArgumentsDone,
+		dto.RealtimeEventResponseOutputItemDone,
+		dto.RealtimeEventTypeResponseDone,
+	}, realtimeEventTypes(events))
+	require.Equal(t, `{"city":"a"}`, events[3].Arguments)
+
+	messages, _ := session.clientEventToGemini(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeConversationCreate,
+		Item: &dto.RealtimeItem{Type: "function_call_output", CallId: "call_1", Output: "sunny"},
+	})
+	require.Equal(t, "get_weather", messages[0].ToolResponse.FunctionResponses[0].Name)
+	// 提交函数结果后 Gemini 会自动继续生成
+	messages, _ = session.clientEventToGemini(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeResponseCreate})
+	require.Empty(t, messages)
+}
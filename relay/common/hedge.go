@@ -0,0 +1,145 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/QuantumNous/new-api/types"
+)
+
+var ErrHedgeLost = errors.New("hedged request lost the race")
+
+// HedgeRace 对冲请求的竞速状态：同一请求同时发往多个渠道，最先向客户端写出响应的尝试获胜，
+// 其余尝试被取消且不计费
+type HedgeRace struct {
+	mu       sync.Mutex
+	winner   *HedgeAttempt
+	attempts []*HedgeAttempt
+}
+
+// HedgeAttempt 对冲请求中的一次尝试
+type HedgeAttempt struct {
+	ChannelId int
+	// Ctx 在其他尝试获胜后被取消，用于中止上游请求
+	Ctx    context.Context
+	cancel context.CancelFunc
+	race   *HedgeRace
+}
+
+func NewHedgeRace() *HedgeRace {
+	return &HedgeRace{}
+}
+
+// NewAttempt 登记一次尝试，parent 通常为客户端请求的 context
+func (r *HedgeRace) NewAttempt(parent context.Context, channelId int) *HedgeAttempt {
+	ctx, cancel := context.WithCancel(parent)
+	attempt := &HedgeAttempt{
+		ChannelId: channelId,
+		Ctx:       ctx,
+		cancel:    cancel,
+		race:      r,
+	}
+	r.mu.Lock()
+	r.attempts = append(r.attempts, attempt)
+	r.mu.Unlock()
+	return attempt
+}
+
+// Claim 尝试成为获胜者，onWin 在获胜时于锁内执行（例如把响应头复制到真实的 Writer）。
+// 已有其他尝试获胜时返回 false
+func (r *HedgeRace) Claim(attempt *HedgeAttempt, onWin func()) bool {
+	r.mu.Lock()
+	if r.winner != nil {
+		won := r.winner == attempt
+		r.mu.Unlock()
+		return won
+	}
+	r.winner = attempt
+	if onWin != nil {
+		onWin()
+	}
+	losers := make([]*HedgeAttempt, 0, len(r.attempts))
+	for _, other := range r.attempts {
+		if other != attempt {
+			losers = append(losers, other)
+		}
+	}
+	r.mu.Unlock()
+	for _, loser := range losers {
+		loser.cancel()
+	}
+	return true
+}
+
+func (r *HedgeRace) Winner() *HedgeAttempt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner
+}
+
+// ChannelIds 返回所有尝试使用的渠道，按发起顺序排列
+func (r *HedgeRace) ChannelIds() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]int, 0, len(r.attempts))
+	for _, attempt := range r.attempts {
+		ids = append(ids, attempt.ChannelId)
+	}
+	return ids
+}
+
+func (a *HedgeAttempt) Race() *HedgeRace {
+	return a.race
+}
+
+// Lost 是否已有其他尝试获胜
+func (a *HedgeAttempt) Lost() bool {
+	winner := a.race.Winner()
+	return winner != nil && winner != a
+}
+
+// Release 释放尝试的 context，应在尝试结束后调用
+func (a *HedgeAttempt) Release() {
+	a.cancel()
+}
+
+// ClaimHedgeSettlement 结算前调用，返回本次尝试是否应计费。
+// 尚未分出胜负时（例如两个尝试均未写出响应）本次尝试直接获胜并取消其他尝试，
+// 保证同一对冲请求只结算一次；已落败的尝试返回 false，不应计费或记录消费日志
+func (info *RelayInfo) ClaimHedgeSettlement() bool {
+	if info.Hedge == nil {
+		return true
+	}
+	return info.Hedge.race.Claim(info.Hedge, nil)
+}
+
+// CloneForHedge 复制 RelayInfo 供并发的对冲尝试使用，
+// 复制在转发过程中会被修改的子结构，计费会话等仍与原 RelayInfo 共享
+func (info *RelayInfo) CloneForHedge(attempt *HedgeAttempt) *RelayInfo {
+	clone := *info
+	clone.Hedge = attempt
+	if info.ClaudeConvertInfo != nil {
+		claudeConvertInfo := *info.ClaudeConvertInfo
+		if claudeConvertInfo.Usage != nil {
+			usage := *claudeConvertInfo.Usage
+			claudeConvertInfo.Usage = &usage
+		}
+		clone.ClaudeConvertInfo = &claudeConvertInfo
+	}
+	if info.ResponsesUsageInfo != nil {
+		responsesUsageInfo := ResponsesUsageInfo{BuiltInTools: make(map[string]*BuildInToolInfo, len(info.ResponsesUsageInfo.BuiltInTools))}
+		for name, tool := range info.ResponsesUsageInfo.BuiltInTools {
+			if tool != nil {
+				toolCopy := *tool
+				tool = &toolCopy
+			}
+			responsesUsageInfo.BuiltInTools[name] = tool
+		}
+		clone.ResponsesUsageInfo = &responsesUsageInfo
+	}
+	if info.RequestConversionChain != nil {
+		clone.RequestConversionChain = append([]types.RelayFormat(nil), info.RequestConversionChain...)
+	}
+	return &clone
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgeRaceClaim(t *testing.T) {
+	race := NewHedgeRace()
+	primary := race.NewAttempt(context.Background(), 1)
+	backup := race.NewAttempt(context.Background(), 2)
+
+	won := 0
+	require.True(t, race.Claim(backup, func() { won++ }))
+	require.False(t, race.Claim(primary, func() { won++ }))
+	require.True(t, race.Claim(backup, func() { won++ }))
+	require.Equal(t, 1, won)
+
+	require.True(t, primary.Lost())
+	require.False(t, backup.Lost())
+	require.Error(t, primary.Ctx.Err(), "loser should be cancelled")
+	require.NoError(t, backup.Ctx.Err())
+	require.Equal(t, []int{1, 2}, race.ChannelIds())
+
+	info := &RelayInfo{Hedge: primary}
+	require.False(t, info.ClaimHedgeSettlement())
+	require.True(t, info.CloneForHedge(backup).ClaimHedgeSettlement())
+}
+
+func TestHedgeSettlementClaimsRace(t *testing.T) {
+	race := NewHedgeRace()
+	primary := race.NewAttempt(context.Background(), 1)
+	backup := race.NewAttempt(context.Background(), 2)
+
+	// 两个尝试均未写出响应时，先结算的尝试获胜，另一个不再计费
+	info := &RelayInfo{}
+	require.True(t, info.ClaimHedgeSettlement())
+	require.True(t, info.CloneForHedge(primary).ClaimHedgeSettlement())
+	require.False(t, info.CloneForHedge(backup).ClaimHedgeSettlement())
+	require.Equal(t, primary, race.Winner())
+	require.Error(t, backup.Ctx.Err())
+}
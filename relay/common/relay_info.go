@@ -83,9 +83,11 @@ type TokenCountMeta struct {
 }
 
 type RelayInfo struct {
-	TokenId           int
-	TokenKey          string
-	TokenGroup        string
+	TokenId    int
+	TokenKey   string
+	TokenGroup string
+	// OrganizationId 令牌绑定的组织，不为 0 时从组织钱包扣费
+	OrganizationId    int
 	UserId            int
 	UsingGroup        string // 使用的分组，当auto跨分组重试时，会变动
 	UserGroup         string // 用户所在分组
@@ -152,6 +154,9 @@ type RelayInfo struct {
 
 	PriceData types.PriceData
 
+	// Hedge 对冲请求中本次尝试的状态，未对冲时为 nil
+	Hedge *HedgeAttempt
+
 	Request dto.Request
 
 	// RequestConversionChain records request format conversions in order, e.g.
@@ -462,6 +467,7 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
 		TokenGroup:     tokenGroup,
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrganizationId),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
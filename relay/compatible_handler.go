@@ -70,6 +70,14 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 
 	info.ShouldIncludeUsage = includeUsage
 
+	responseCacheKey := service.GetResponseCacheKey(c, info, request)
+	if usage, hit := tryServeResponseCache(c, info, responseCacheKey); hit {
+		postConsumeQuota(c, info, usage)
+		return nil
+	}
+	responseCacheCapture := service.BeginResponseCacheCapture(c, info, responseCacheKey)
+	defer responseCacheCapture.Close()
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
@@ -86,6 +94,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		if newApiErr != nil {
 			return newApiErr
 		}
+		responseCacheCapture.Store(info, usage)
 
 		var containAudioTokens = usage.CompletionTokenDetails.AudioTokens > 0 || usage.PromptTokensDetails.AudioTokens > 0
 		var containsAudioRatios = ratio_setting.ContainsAudioRatio(info.OriginModelName) || ratio_setting.ContainsAudioCompletionRatio(info.OriginModelName)
@@ -209,6 +218,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 		return newApiErr
 	}
+	responseCacheCapture.Store(info, usage.(*dto.Usage))
 
 	var containAudioTokens = usage.(*dto.Usage).CompletionTokenDetails.AudioTokens > 0 || usage.(*dto.Usage).PromptTokensDetails.AudioTokens > 0
 	var containsAudioRatios = ratio_setting.ContainsAudioRatio(info.OriginModelName) || ratio_setting.ContainsAudioCompletionRatio(info.OriginModelName)
@@ -222,6 +232,11 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 }
 
 func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent ...string) {
+	if !relayInfo.ClaimHedgeSettlement() {
+		// 对冲请求只对获胜的尝试计费
+		logger.LogInfo(ctx, fmt.Sprintf("对冲请求落败（渠道 #%d），跳过计费", relayInfo.ChannelId))
+		return
+	}
 	originUsage := usage
 	if usage == nil {
 		usage = &dto.Usage{
@@ -237,6 +252,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	}
 
 	adminRejectReason := common.GetContextKeyString(ctx, constant.ContextKeyAdminRejectReason)
+	responseCacheHit := service.IsResponseCacheHit(ctx)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
@@ -247,6 +263,14 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	cachedCreationTokens := usage.PromptTokensDetails.CachedCreationTokens
 
 	modelName := relayInfo.OriginModelName
+	isClaudeUsageSemantic := relayInfo.GetFinalRequestRelayFormat() == types.RelayFormatClaude
+
+	// 按实际提示词长度重新选择分档倍率，Anthropic 语义的 input_tokens 不含缓存 tokens
+	tierPromptTokens := promptTokens
+	if isClaudeUsageSemantic {
+		tierPromptTokens += cacheTokens + cachedCreationTokens
+	}
+	relayInfo.PriceData.ApplyTier(tierPromptTokens)
 
 	tokenName := ctx.GetString("token_name")
 	completionRatio := relayInfo.PriceData.CompletionRatio
@@ -337,7 +361,6 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 
 	var audioInputQuota decimal.Decimal
 	var audioInputPrice float64
-	isClaudeUsageSemantic := relayInfo.GetFinalRequestRelayFormat() == types.RelayFormatClaude
 	if !relayInfo.PriceData.UsePrice {
 		baseTokens := dPromptTokens
 		// 减去 cached tokens
@@ -422,8 +445,18 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		if !ratio.IsZero() && quota == 0 {
 			quota = 1
 		}
+		if responseCacheHit {
+			// 命中响应缓存时按配置的命中计费方式扣费，且不计入渠道用量
+			quota = operation_setting.GetResponseCacheHitQuota(quota)
+			extraContent = append(extraContent, fmt.Sprintf("响应缓存命中，计费方式 %s", operation_setting.GetResponseCacheSetting().BillingMode))
+		}
+		if service.CheckTokenBudgetOverrun(ctx, relayInfo, quota) {
+			extraContent = append(extraContent, "超出令牌周期预算")
+		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		if !responseCacheHit {
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		}
 	}
 
 	if err := service.SettleBilling(ctx, relayInfo, quota); err != nil {
@@ -444,6 +477,9 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	if adminRejectReason != "" {
 		other["reject_reason"] = adminRejectReason
 	}
+	if responseCacheHit {
+		other["response_cache_hit"] = true
+	}
 	// For chat-based calls to the Claude model, tagging is required. Using Claude's rendering logs, the two approaches handle input rendering differently.
 	if isClaudeUsageSemantic {
 		other["claude"] = true
@@ -0,0 +1,112 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// countTokensUpstreamURL 返回渠道原生 token 统计接口的地址，渠道不支持该请求格式时返回空
+func countTokensUpstreamURL(info *relaycommon.RelayInfo) string {
+	switch {
+	case info.RelayFormat == types.RelayFormatClaude && info.ChannelType == constant.ChannelTypeAnthropic:
+		return info.ChannelBaseUrl + "/v1/messages/count_tokens"
+	case info.RelayFormat == types.RelayFormatOpenAIResponses && info.ChannelType == constant.ChannelTypeOpenAI:
+		return info.ChannelBaseUrl + "/v1/responses/input_tokens"
+	case info.RelayFormat == types.RelayFormatGemini && info.ChannelType == constant.ChannelTypeGemini:
+		version := model_setting.GetGeminiVersionSetting(info.UpstreamModelName)
+		return fmt.Sprintf("%s/%s/models/%s:countTokens", info.ChannelBaseUrl, version, info.UpstreamModelName)
+	}
+	return ""
+}
+
+// UpstreamCountTokens 调用渠道原生的 token 统计接口获取精确值，不经过计费流程。
+// 渠道不支持时 supported 返回 false，由调用方回退到本地估算
+func UpstreamCountTokens(c *gin.Context, info *relaycommon.RelayInfo) (tokens int, supported bool, err error) {
+	info.InitChannelMeta(c)
+	if err = helper.ModelMappedHelper(c, info, info.Request); err != nil {
+		return 0, false, err
+	}
+	url := countTokensUpstreamURL(info)
+	if url == "" {
+		return 0, false, nil
+	}
+
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return 0, true, err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return 0, true, err
+	}
+	switch info.RelayFormat {
+	case types.RelayFormatGemini:
+		if gjson.GetBytes(body, "generateContentRequest").Exists() {
+			body, err = sjson.SetBytes(body, "generateContentRequest.model", "models/"+info.UpstreamModelName)
+		}
+	default:
+		body, err = sjson.SetBytes(body, "model", info.UpstreamModelName)
+	}
+	if err != nil {
+		return 0, true, err
+	}
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return 0, true, fmt.Errorf("invalid api type: %d", info.ApiType)
+	}
+	adaptor.Init(info)
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, true, err
+	}
+	if err = adaptor.SetupRequestHeader(c, &req.Header, info); err != nil {
+		return 0, true, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := service.GetHttpClient()
+	if info.ChannelSetting.Proxy != "" {
+		client, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
+		if err != nil {
+			return 0, true, err
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, true, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, true, fmt.Errorf("upstream count tokens failed: status %d, body %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	field := "input_tokens"
+	if info.RelayFormat == types.RelayFormatGemini {
+		field = "totalTokens"
+	}
+	result := gjson.GetBytes(respBody, field)
+	if !result.Exists() {
+		return 0, true, fmt.Errorf("upstream count tokens response missing %s", field)
+	}
+	return int(result.Int()), true, nil
+}
@@ -34,6 +34,14 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	responseCacheKey := service.GetResponseCacheKey(c, info, request)
+	if usage, hit := tryServeResponseCache(c, info, responseCacheKey); hit {
+		postConsumeQuota(c, info, usage)
+		return nil
+	}
+	responseCacheCapture := service.BeginResponseCacheCapture(c, info, responseCacheKey)
+	defer responseCacheCapture.Close()
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
@@ -82,6 +90,7 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 		return newAPIError
 	}
+	responseCacheCapture.Store(info, usage.(*dto.Usage))
 	postConsumeQuota(c, info, usage.(*dto.Usage))
 	return nil
 }
@@ -2,8 +2,10 @@ package helper
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -61,6 +63,9 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var audioRatio float64
 	var audioCompletionRatio float64
 	var freeModel bool
+	var tiers []types.PriceTier
+	var baseTier types.PriceTier
+	var appliedTier *types.PriceTier
 	if !usePrice {
 		preConsumedTokens := common.Max(promptTokens, common.PreConsumedQuota)
 		if meta.MaxTokens != 0 {
@@ -87,6 +92,13 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		imageRatio, _ = ratio_setting.GetImageRatio(info.OriginModelName)
 		audioRatio = ratio_setting.GetAudioRatio(info.OriginModelName)
 		audioCompletionRatio = ratio_setting.GetAudioCompletionRatio(info.OriginModelName)
+		// 按估算的提示词长度选择分档倍率，结算时按实际 tokens 重新选择
+		baseTier = types.PriceTier{ModelRatio: modelRatio, CompletionRatio: completionRatio, CacheRatio: cacheRatio}
+		if tiers, _ = ratio_setting.GetModelTieredRatio(info.OriginModelName); len(tiers) > 0 {
+			tierData := types.PriceData{Tiers: tiers, BaseTier: baseTier}
+			appliedTier = tierData.ApplyTier(promptTokens)
+			modelRatio, completionRatio, cacheRatio = tierData.ModelRatio, tierData.CompletionRatio, tierData.CacheRatio
+		}
 		ratio := modelRatio * groupRatioInfo.GroupRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
@@ -115,6 +127,21 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		}
 	}
 
+	// 分时价格窗口，按当前时刻对模型倍率或价格打折
+	timeWindow, inTimeWindow := operation_setting.GetTimePriceWindow(info.OriginModelName, info.UsingGroup, time.Now())
+	if inTimeWindow {
+		preConsumedQuota = int(float64(preConsumedQuota) * timeWindow.Ratio)
+	}
+
+	// 本地批处理请求在正常计费基础上叠加批处理折扣
+	var batchRatio float64
+	if common.GetContextKeyString(c, constant.ContextKeyBatchId) != "" {
+		if ratio := ratio_setting.GetBatchRatio(info.OriginModelName); ratio > 0 {
+			batchRatio = ratio
+			preConsumedQuota = int(float64(preConsumedQuota) * batchRatio)
+		}
+	}
+
 	priceData := types.PriceData{
 		FreeModel:            freeModel,
 		ModelPrice:           modelPrice,
@@ -130,6 +157,15 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		CacheCreation5mRatio: cacheCreationRatio5m,
 		CacheCreation1hRatio: cacheCreationRatio1h,
 		QuotaToPreConsume:    preConsumedQuota,
+		Tiers:                tiers,
+		BaseTier:             baseTier,
+		AppliedTier:          appliedTier,
+	}
+	if inTimeWindow {
+		priceData.ApplyTimeRatio(timeWindow.DisplayName(), timeWindow.Ratio)
+	}
+	if batchRatio > 0 {
+		priceData.AddOtherRatio("batch", batchRatio)
 	}
 
 	if common.DebugEnabled {
@@ -166,6 +202,13 @@ func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) (types
 		}
 
 	}
+	// 分时价格窗口，按当前时刻对价格打折
+	var timeWindowName string
+	var timeRatio float64
+	if window, ok := operation_setting.GetTimePriceWindow(info.OriginModelName, info.UsingGroup, time.Now()); ok {
+		timeWindowName, timeRatio = window.DisplayName(), window.Ratio
+		modelPrice *= timeRatio
+	}
 	quota := int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 
 	// 免费模型检测（与 ModelPriceHelper 对齐）
@@ -182,6 +225,8 @@ func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) (types
 		ModelPrice:     modelPrice,
 		Quota:          quota,
 		GroupRatioInfo: groupRatioInfo,
+		TimeWindow:     timeWindowName,
+		TimeRatio:      timeRatio,
 	}
 	return priceData, nil
 }
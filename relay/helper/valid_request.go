@@ -322,6 +322,30 @@ func GetAndValidateGeminiRequest(c *gin.Context) (*dto.GeminiChatRequest, error)
 	return request, nil
 }
 
+// GetAndValidateCountTokensRequest 解析 token 统计接口的请求体，格式与对应的生成接口一致
+func GetAndValidateCountTokensRequest(c *gin.Context, format types.RelayFormat) (dto.Request, error) {
+	switch format {
+	case types.RelayFormatOpenAI:
+		return GetAndValidateTextRequest(c, relayconstant.RelayModeChatCompletions)
+	case types.RelayFormatOpenAIResponses:
+		return GetAndValidateResponsesRequest(c)
+	case types.RelayFormatClaude:
+		return GetAndValidateClaudeRequest(c)
+	case types.RelayFormatGemini:
+		countRequest := &dto.GeminiCountTokensRequest{}
+		if err := common.UnmarshalBodyReusable(c, countRequest); err != nil {
+			return nil, err
+		}
+		request := countRequest.ToChatRequest()
+		if len(request.Contents) == 0 {
+			return nil, errors.New("contents is required")
+		}
+		return request, nil
+	default:
+		return nil, fmt.Errorf("unsupported count tokens format: %s", format)
+	}
+}
+
 func GetAndValidateGeminiEmbeddingRequest(c *gin.Context) (*dto.GeminiEmbeddingRequest, error) {
 	request := &dto.GeminiEmbeddingRequest{}
 	err := common.UnmarshalBodyReusable(c, request)
@@ -172,6 +172,52 @@ func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjo
 	return
 }
 
+// preConsumeMidjourneyQuota 提交前校验额度：组织令牌从组织钱包预扣并校验成员额度上限，
+// 其余令牌只校验个人余额，成功后再由 postConsumeMidjourneyQuota 扣费
+func preConsumeMidjourneyQuota(info *relaycommon.RelayInfo, quota int) *dto.MidjourneyResponse {
+	isOrganization, err := service.PreConsumeOrganizationQuota(info, quota)
+	if isOrganization {
+		if err != nil {
+			return &dto.MidjourneyResponse{
+				Code:        4,
+				Description: err.Error(),
+			}
+		}
+		return nil
+	}
+	userQuota, err := model.GetUserQuota(info.UserId, false)
+	if err != nil {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: err.Error(),
+		}
+	}
+	if userQuota-quota < 0 {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: "quota_not_enough",
+		}
+	}
+	return nil
+}
+
+// postConsumeMidjourneyQuota 提交成功时扣费，失败时退还组织钱包的预扣额度
+func postConsumeMidjourneyQuota(info *relaycommon.RelayInfo, quota int, success bool) {
+	var err error
+	if info.BillingSource == service.BillingSourceOrganization {
+		actualQuota := 0
+		if success {
+			actualQuota = quota
+		}
+		err = service.PostConsumeOrganizationQuota(info, quota, actualQuota)
+	} else if success {
+		err = service.PostConsumeQuota(info, quota, 0, true)
+	}
+	if err != nil {
+		common.SysLog("error consuming token remain quota: " + err.Error())
+	}
+}
+
 func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyResponse {
 	var swapFaceRequest dto.SwapFaceRequest
 	err := common.UnmarshalBodyReusable(c, &swapFaceRequest)
@@ -194,33 +240,21 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		}
 	}
 
-	userQuota, err := model.GetUserQuota(info.UserId, false)
-	if err != nil {
-		return &dto.MidjourneyResponse{
-			Code:        4,
-			Description: err.Error(),
-		}
-	}
-
-	if userQuota-priceData.Quota < 0 {
-		return &dto.MidjourneyResponse{
-			Code:        4,
-			Description: "quota_not_enough",
-		}
+	if mjResp := preConsumeMidjourneyQuota(info, priceData.Quota); mjResp != nil {
+		return mjResp
 	}
 	requestURL := getMjRequestPath(c.Request.URL.String())
 	baseURL := c.GetString("base_url")
 	fullRequestURL := fmt.Sprintf("%s%s", baseURL, requestURL)
 	mjResp, _, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
+		postConsumeMidjourneyQuota(info, priceData.Quota, false)
 		return &mjResp.Response
 	}
 	defer func() {
-		if mjResp.StatusCode == 200 && mjResp.Response.Code == 1 {
-			err := service.PostConsumeQuota(info, priceData.Quota, 0, true)
-			if err != nil {
-				common.SysLog("error consuming token remain quota: " + err.Error())
-			}
+		success := mjResp.StatusCode == 200 && mjResp.Response.Code == 1
+		postConsumeMidjourneyQuota(info, priceData.Quota, success)
+		if success {
 
 			tokenName := c.GetString("token_name")
 			logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, constant.MjActionSwapFace)
@@ -258,6 +292,8 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
+		// 组织令牌的任务失败时退还到组织钱包
+		OrganizationId: info.OrganizationId,
 	}
 	err = midjourneyTask.Insert()
 	if err != nil {
@@ -501,33 +537,30 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		}
 	}
 
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
-	if err != nil {
-		return &dto.MidjourneyResponse{
-			Code:        4,
-			Description: err.Error(),
-		}
-	}
-
-	if consumeQuota && userQuota-priceData.Quota < 0 {
-		return &dto.MidjourneyResponse{
-			Code:        4,
-			Description: "quota_not_enough",
+	// 提交失败时 consumeQuota 会被置为 false，需记录是否已预扣以便退还
+	preConsumed := consumeQuota
+	if preConsumed {
+		if mjResp := preConsumeMidjourneyQuota(relayInfo, priceData.Quota); mjResp != nil {
+			return mjResp
 		}
 	}
 
 	midjResponseWithStatus, responseBody, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
+		if preConsumed {
+			postConsumeMidjourneyQuota(relayInfo, priceData.Quota, false)
+		}
 		return &midjResponseWithStatus.Response
 	}
 	midjResponse := &midjResponseWithStatus.Response
 
 	defer func() {
-		if consumeQuota && midjResponseWithStatus.StatusCode == 200 {
-			err := service.PostConsumeQuota(relayInfo, priceData.Quota, 0, true)
-			if err != nil {
-				common.SysLog("error consuming token remain quota: " + err.Error())
-			}
+		if !preConsumed {
+			return
+		}
+		success := consumeQuota && midjResponseWithStatus.StatusCode == 200
+		postConsumeMidjourneyQuota(relayInfo, priceData.Quota, success)
+		if success {
 			tokenName := c.GetString("token_name")
 			logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s，ID %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, midjRequest.Action, midjResponse.Result)
 			other := service.GenerateMjOtherInfo(relayInfo, priceData)
@@ -571,6 +604,8 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
+		// 组织令牌的任务失败时退还到组织钱包
+		OrganizationId: relayInfo.OrganizationId,
 	}
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
@@ -0,0 +1,82 @@
+package relay
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tryServeResponseCache 命中缓存时直接写出缓存响应并返回缓存时的用量
+func tryServeResponseCache(c *gin.Context, info *relaycommon.RelayInfo, key string) (*dto.Usage, bool) {
+	entry, ok := service.GetResponseCacheEntry(c, info, key)
+	if !ok {
+		return nil, false
+	}
+	switch {
+	case entry.Stream:
+		service.MarkResponseCacheHit(c)
+		helper.SetEventStreamHeaders(c)
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString(entry.Body)
+		_ = helper.FlushWriter(c)
+	case info.IsStream:
+		// 非流式缓存仅对话补全可转换为 SSE
+		if !strings.HasPrefix(entry.ContentType, "application/json") {
+			return nil, false
+		}
+		var response dto.OpenAITextResponse
+		if err := common.UnmarshalJsonStr(entry.Body, &response); err != nil || len(response.Choices) == 0 {
+			return nil, false
+		}
+		service.MarkResponseCacheHit(c)
+		replayChatCompletionAsStream(c, info, &response)
+	default:
+		service.MarkResponseCacheHit(c)
+		c.Data(http.StatusOK, entry.ContentType, []byte(entry.Body))
+	}
+	usage := entry.Usage
+	return &usage, true
+}
+
+// replayChatCompletionAsStream 将缓存的非流式对话补全响应按 SSE 格式写出
+func replayChatCompletionAsStream(c *gin.Context, info *relaycommon.RelayInfo, response *dto.OpenAITextResponse) {
+	helper.SetEventStreamHeaders(c)
+	created := common.GetTimestamp()
+	if value, ok := response.Created.(float64); ok {
+		created = int64(value)
+	}
+	for _, choice := range response.Choices {
+		chunk := helper.GenerateStartEmptyResponse(response.Id, created, response.Model, nil)
+		delta := &chunk.Choices[0].Delta
+		chunk.Choices[0].Index = choice.Index
+		delta.SetContentString(choice.Message.StringContent())
+		if choice.Message.ReasoningContent != "" {
+			delta.ReasoningContent = common.GetPointer(choice.Message.ReasoningContent)
+		}
+		if len(choice.Message.ToolCalls) > 0 {
+			var toolCalls []dto.ToolCallResponse
+			if err := common.Unmarshal(choice.Message.ToolCalls, &toolCalls); err == nil {
+				for i := range toolCalls {
+					toolCalls[i].SetIndex(i)
+				}
+				delta.ToolCalls = toolCalls
+			}
+		}
+		_ = helper.ObjectData(c, chunk)
+
+		stop := helper.GenerateStopResponse(response.Id, created, response.Model, choice.FinishReason)
+		stop.Choices[0].Index = choice.Index
+		_ = helper.ObjectData(c, stop)
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(response.Id, created, response.Model, response.Usage))
+	}
+	helper.Done(c)
+}
@@ -127,14 +127,22 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		}
 	}
 
-	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	var usageDto *dto.Usage
+	if isResponsesViaChat(info) {
+		usageDto, newAPIError = responsesViaChatDoResponse(c, info, adaptor, httpResp)
+	} else {
+		var usage any
+		usage, newAPIError = adaptor.DoResponse(c, httpResp, info)
+		if newAPIError == nil {
+			usageDto = usage.(*dto.Usage)
+		}
+	}
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 		return newAPIError
 	}
 
-	usageDto := usage.(*dto.Usage)
 	if info.RelayMode == relayconstant.RelayModeResponsesCompact {
 		originModelName := info.OriginModelName
 		originPriceData := info.PriceData
@@ -0,0 +1,259 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	appconstant "github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responsesViaChatApiTypes 不支持原生 Responses 接口的渠道类型，/v1/responses 请求由适配器转换为渠道自身格式，
+// 响应按 Chat Completions 格式输出后再转换为 Responses 格式
+var responsesViaChatApiTypes = map[int]struct{}{
+	appconstant.APITypeAnthropic: {},
+	appconstant.APITypeGemini:    {},
+	appconstant.APITypeAws:       {},
+}
+
+func isResponsesViaChat(info *relaycommon.RelayInfo) bool {
+	_, ok := responsesViaChatApiTypes[info.ApiType]
+	return ok
+}
+
+// responsesViaChatDoResponse 以 Chat Completions 格式处理上游响应，同时把写给客户端的内容转换为 Responses 格式
+func responsesViaChatDoResponse(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, httpResp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	originRelayFormat := info.RelayFormat
+	originRelayMode := info.RelayMode
+	originShouldIncludeUsage := info.ShouldIncludeUsage
+	info.RelayFormat = types.RelayFormatOpenAI
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.ShouldIncludeUsage = true
+
+	originWriter := c.Writer
+	writer := newResponsesViaChatWriter(c, info)
+	c.Writer = writer
+	defer func() {
+		c.Writer = originWriter
+		info.RelayFormat = originRelayFormat
+		info.RelayMode = originRelayMode
+		info.ShouldIncludeUsage = originShouldIncludeUsage
+	}()
+
+	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	usageDto, ok := usage.(*dto.Usage)
+	if !ok || usageDto == nil {
+		usageDto = &dto.Usage{}
+	}
+	writer.finish(usageDto)
+	return usageDto, nil
+}
+
+// responsesViaChatWriter 把渠道处理器写出的 Chat Completions 响应转换为 Responses 响应。
+// 流式响应逐个 SSE 事件转换；非流式响应缓存完整响应体，待 finish 时转换后写出。
+// 非 200 状态或无法解析的内容原样透传
+type responsesViaChatWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	stream    bool
+	converter *service.ChatToResponsesStreamConverter
+	// 流式响应中尚未形成完整事件的数据，或非流式响应的完整响应体
+	buffer bytes.Buffer
+	// 非流式响应在 finish 前暂存的响应头与状态码
+	header      http.Header
+	status      int
+	passThrough bool
+}
+
+func newResponsesViaChatWriter(c *gin.Context, info *relaycommon.RelayInfo) *responsesViaChatWriter {
+	responseID := fmt.Sprintf("resp_%s", c.GetString(common.RequestIdKey))
+	return &responsesViaChatWriter{
+		ResponseWriter: c.Writer,
+		c:              c,
+		stream:         info.IsStream,
+		converter:      service.NewChatToResponsesStreamConverter(responseID, info.UpstreamModelName, int(info.StartTime.Unix())),
+		header:         make(http.Header),
+	}
+}
+
+func (w *responsesViaChatWriter) Header() http.Header {
+	if w.stream {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *responsesViaChatWriter) WriteHeader(code int) {
+	if code > 0 && code != http.StatusOK {
+		w.passThrough = true
+	}
+	if w.stream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *responsesViaChatWriter) WriteHeaderNow() {
+	if w.stream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *responsesViaChatWriter) Write(data []byte) (int, error) {
+	if !w.stream {
+		return w.buffer.Write(data)
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer.Write(data)
+	for {
+		event, ok := w.nextEvent()
+		if !ok {
+			break
+		}
+		if err := w.convertEvent(event); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *responsesViaChatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responsesViaChatWriter) Flush() {
+	if w.stream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *responsesViaChatWriter) Status() int {
+	if w.stream {
+		return w.ResponseWriter.Status()
+	}
+	if w.status > 0 {
+		return w.status
+	}
+	return http.StatusOK
+}
+
+func (w *responsesViaChatWriter) Written() bool {
+	if w.stream {
+		return w.ResponseWriter.Written()
+	}
+	return w.buffer.Len() > 0
+}
+
+// nextEvent 取出缓冲区中下一个完整的 SSE 事件
+func (w *responsesViaChatWriter) nextEvent() (string, bool) {
+	data := w.buffer.Bytes()
+	end := bytes.Index(data, []byte("\n\n"))
+	if end < 0 {
+		return "", false
+	}
+	event := string(data[:end])
+	w.buffer.Next(end + 2)
+	return event, true
+}
+
+func (w *responsesViaChatWriter) convertEvent(event string) error {
+	payload, ok := strings.CutPrefix(strings.TrimSpace(event), "data:")
+	if !ok {
+		// Ping 等非数据事件原样透传
+		_, err := w.ResponseWriter.Write([]byte(event + "\n\n"))
+		return err
+	}
+	payload = strings.TrimSpace(payload)
+	if payload == "[DONE]" {
+		return nil
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(payload, &chunk); err != nil {
+		logger.LogError(w.c, "failed to convert chat stream chunk to responses: "+err.Error())
+		_, err = w.ResponseWriter.Write([]byte(event + "\n\n"))
+		return err
+	}
+	return w.writeEvents(w.converter.ConvertChunk(&chunk))
+}
+
+func (w *responsesViaChatWriter) writeEvents(events []dto.ResponsesStreamResponse) error {
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err = w.ResponseWriter.Write([]byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data))); err != nil {
+			return err
+		}
+	}
+	if len(events) > 0 {
+		w.ResponseWriter.Flush()
+	}
+	return nil
+}
+
+// finish 在渠道处理器写完响应后调用：流式响应补发结束事件，非流式响应转换后写出
+func (w *responsesViaChatWriter) finish(usage *dto.Usage) {
+	if w.stream {
+		if w.passThrough {
+			return
+		}
+		if rest := strings.TrimSpace(w.buffer.String()); rest != "" {
+			w.buffer.Reset()
+			_ = w.convertEvent(rest)
+		}
+		if err := w.writeEvents(w.converter.Finish(usage)); err != nil {
+			logger.LogError(w.c, "failed to write responses stream events: "+err.Error())
+		}
+		return
+	}
+
+	body := w.buffer.Bytes()
+	if len(body) == 0 && w.status == 0 {
+		return
+	}
+	status := w.Status()
+	if !w.passThrough {
+		var chatResponse dto.OpenAITextResponse
+		if err := common.Unmarshal(body, &chatResponse); err == nil && len(chatResponse.Choices) > 0 {
+			responsesResponse, err := service.ChatCompletionsResponseToResponsesResponse(&chatResponse, usage, w.converter.ResponseID)
+			if err == nil {
+				if converted, err := common.Marshal(responsesResponse); err == nil {
+					body = converted
+				}
+			}
+		}
+	}
+	dst := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		if key == "Content-Length" {
+			continue
+		}
+		dst[key] = values
+	}
+	dst.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.ResponseWriter.WriteHeader(status)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		logger.LogError(w.c, "failed to write responses response: "+err.Error())
+	}
+	w.ResponseWriter.Flush()
+}
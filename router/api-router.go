@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
 
 	// Import oauth package to register providers via init()
 	_ "github.com/QuantumNous/new-api/oauth"
@@ -83,6 +84,7 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.GET("/topup/info", controller.GetTopUpInfo)
 				selfRoute.GET("/topup/self", controller.GetUserTopUps)
+				selfRoute.GET("/self/statement", controller.GetSelfStatement)
 				selfRoute.POST("/topup", middleware.CriticalRateLimit(), controller.TopUp)
 				selfRoute.POST("/pay", middleware.CriticalRateLimit(), controller.RequestEpay)
 				selfRoute.POST("/amount", controller.RequestAmount)
@@ -106,10 +108,17 @@ func SetApiRouter(router *gin.Engine) {
 				// Custom OAuth bindings
 				selfRoute.GET("/oauth/bindings", controller.GetUserOAuthBindings)
 				selfRoute.DELETE("/oauth/bindings/:provider_id", controller.UnbindCustomOAuth)
+
+				// Scoped management keys
+				selfRoute.GET("/management_keys", controller.GetManagementKeys)
+				selfRoute.POST("/management_keys", controller.CreateManagementKey)
+				selfRoute.PUT("/management_keys/:id", controller.UpdateManagementKey)
+				selfRoute.POST("/management_keys/:id/rotate", controller.RotateManagementKey)
+				selfRoute.DELETE("/management_keys/:id", controller.DeleteManagementKey)
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
+			adminRoute.Use(middleware.ManagementScope(model.ManagementScopeUsers), middleware.AdminAuth())
 			{
 				adminRoute.GET("/", controller.GetAllUsers)
 				adminRoute.GET("/topup", controller.GetAllTopUps)
@@ -119,6 +128,7 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.DELETE("/:id/oauth/bindings/:provider_id", controller.UnbindCustomOAuthByAdmin)
 				adminRoute.DELETE("/:id/bindings/:binding_type", controller.AdminClearUserBinding)
 				adminRoute.GET("/:id", controller.GetUser)
+				adminRoute.GET("/:id/statement", controller.GetUserStatement)
 				adminRoute.POST("/", controller.CreateUser)
 				adminRoute.POST("/manage", controller.ManageUser)
 				adminRoute.PUT("/", controller.UpdateUser)
@@ -142,6 +152,32 @@ func SetApiRouter(router *gin.Engine) {
 			subscriptionRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestStripePay)
 			subscriptionRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestCreemPay)
 		}
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/self", controller.GetSelfOrganizations)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.POST("/join", middleware.CriticalRateLimit(), controller.JoinOrganization)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.PUT("/:id", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.PUT("/:id/members/:user_id", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.GET("/:id/invites", controller.GetOrganizationInvites)
+			organizationRoute.POST("/:id/invites", controller.CreateOrganizationInvite)
+			organizationRoute.DELETE("/:id/invites/:invite_id", controller.DeleteOrganizationInvite)
+			organizationRoute.POST("/:id/transfer", controller.TransferOrganizationQuota)
+			organizationRoute.GET("/:id/usage", controller.GetOrganizationUsage)
+		}
+		organizationAdminRoute := apiRouter.Group("/organization/admin")
+		organizationAdminRoute.Use(middleware.AdminAuth())
+		{
+			organizationAdminRoute.GET("/", controller.AdminListOrganizations)
+			organizationAdminRoute.POST("/:id/quota", controller.AdminAdjustOrganizationQuota)
+			organizationAdminRoute.PUT("/:id/status", controller.AdminUpdateOrganizationStatus)
+		}
+
 		subscriptionAdminRoute := apiRouter.Group("/subscription/admin")
 		subscriptionAdminRoute.Use(middleware.AdminAuth())
 		{
@@ -158,6 +194,30 @@ func SetApiRouter(router *gin.Engine) {
 			subscriptionAdminRoute.DELETE("/user_subscriptions/:id", controller.AdminDeleteUserSubscription)
 		}
 
+		webhookRoute := apiRouter.Group("/webhook")
+		webhookRoute.Use(middleware.UserAuth())
+		{
+			webhookRoute.GET("/events", controller.GetWebhookEvents)
+			webhookRoute.GET("/endpoints", controller.GetWebhookEndpoints)
+			webhookRoute.POST("/endpoints", controller.AddWebhookEndpoint)
+			webhookRoute.PUT("/endpoints", controller.UpdateWebhookEndpoint)
+			webhookRoute.DELETE("/endpoints/:id", controller.DeleteWebhookEndpoint)
+			webhookRoute.POST("/endpoints/:id/test", middleware.CriticalRateLimit(), controller.TestWebhookEndpoint)
+			webhookRoute.GET("/deliveries", controller.GetWebhookDeliveries)
+			webhookRoute.POST("/deliveries/:id/redeliver", middleware.CriticalRateLimit(), controller.RedeliverWebhook)
+		}
+		webhookSystemRoute := apiRouter.Group("/webhook/system")
+		webhookSystemRoute.Use(middleware.AdminAuth())
+		{
+			webhookSystemRoute.GET("/endpoints", controller.GetSystemWebhookEndpoints)
+			webhookSystemRoute.POST("/endpoints", controller.AddSystemWebhookEndpoint)
+			webhookSystemRoute.PUT("/endpoints", controller.UpdateSystemWebhookEndpoint)
+			webhookSystemRoute.DELETE("/endpoints/:id", controller.DeleteSystemWebhookEndpoint)
+			webhookSystemRoute.POST("/endpoints/:id/test", controller.TestSystemWebhookEndpoint)
+			webhookSystemRoute.GET("/deliveries", controller.GetSystemWebhookDeliveries)
+			webhookSystemRoute.POST("/deliveries/:id/redeliver", controller.RedeliverSystemWebhook)
+		}
+
 		// Subscription payment callbacks (no auth)
 		apiRouter.POST("/subscription/epay/notify", controller.SubscriptionEpayNotify)
 		apiRouter.GET("/subscription/epay/notify", controller.SubscriptionEpayNotify)
@@ -170,6 +230,8 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.PUT("/", controller.UpdateOption)
 			optionRoute.GET("/channel_affinity_cache", controller.GetChannelAffinityCacheStats)
 			optionRoute.DELETE("/channel_affinity_cache", controller.ClearChannelAffinityCache)
+			optionRoute.GET("/response_cache", controller.GetResponseCacheStats)
+			optionRoute.DELETE("/response_cache", controller.ClearResponseCache)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
@@ -200,13 +262,16 @@ func SetApiRouter(router *gin.Engine) {
 			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
 		}
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
+		channelRoute.Use(middleware.ManagementScope(model.ManagementScopeChannels), middleware.AdminAuth())
 		{
 			channelRoute.GET("/", controller.GetAllChannels)
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/health", controller.GetAllChannelsHealth)
 			channelRoute.GET("/:id", controller.GetChannel)
+			channelRoute.GET("/:id/health", controller.GetChannelHealth)
+			channelRoute.DELETE("/:id/health", controller.ResetChannelHealth)
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
 			channelRoute.GET("/test/:id", controller.TestChannel)
@@ -243,7 +308,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/upstream_updates/detect_all", controller.DetectAllChannelUpstreamModelUpdates)
 		}
 		tokenRoute := apiRouter.Group("/token")
-		tokenRoute.Use(middleware.UserAuth())
+		tokenRoute.Use(middleware.ManagementScope(model.ManagementScopeTokens), middleware.UserAuth())
 		{
 			tokenRoute.GET("/", controller.GetAllTokens)
 			tokenRoute.GET("/search", middleware.SearchRateLimit(), controller.SearchTokens)
@@ -265,7 +330,7 @@ func SetApiRouter(router *gin.Engine) {
 		}
 
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
+		redemptionRoute.Use(middleware.ManagementScope(model.ManagementScopeRedemptions), middleware.AdminAuth())
 		{
 			redemptionRoute.GET("/", controller.GetAllRedemptions)
 			redemptionRoute.GET("/search", controller.SearchRedemptions)
@@ -276,18 +341,45 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
+		logRoute.Use(middleware.ManagementScope(model.ManagementScopeLogs))
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
 		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/channel_affinity_usage_cache", middleware.AdminAuth(), controller.GetChannelAffinityUsageCacheStats)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/capture/:request_id", middleware.AdminAuth(), controller.GetPayloadCaptures)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
+		logRoute.GET("/export", middleware.AdminAuth(), controller.ExportAllLogs)
+		logRoute.GET("/self/export", middleware.UserAuth(), middleware.SearchRateLimit(), controller.ExportUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), middleware.SearchRateLimit(), controller.SearchUserLogs)
 
+		auditRoute := apiRouter.Group("/audit")
+		auditRoute.Use(middleware.AdminAuth())
+		{
+			auditRoute.GET("/", controller.GetAuditLogs)
+			auditRoute.GET("/export", controller.ExportAuditLogs)
+			auditRoute.GET("/verify", controller.VerifyAuditLogs)
+		}
+
+		moderationRoute := apiRouter.Group("/moderation")
+		moderationRoute.Use(middleware.AdminAuth())
+		{
+			moderationRoute.GET("/events", controller.GetModerationEvents)
+			moderationRoute.PUT("/events/:id", controller.ReviewModerationEvent)
+		}
+
 		dataRoute := apiRouter.Group("/data")
+		dataRoute.Use(middleware.ManagementScope(model.ManagementScopeData))
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
+		dataRoute.GET("/margin", middleware.AdminAuth(), controller.GetMarginReport)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
+		dataRoute.GET("/export", middleware.AdminAuth(), controller.ExportAllQuotaData)
+		dataRoute.GET("/self/export", middleware.UserAuth(), middleware.SearchRateLimit(), controller.ExportUserQuotaData)
+		dataRoute.GET("/rollup", middleware.AdminAuth(), controller.GetUsageRollups)
+		dataRoute.GET("/self/rollup", middleware.UserAuth(), controller.GetUserUsageRollups)
+		dataRoute.POST("/rollup/backfill", middleware.RootAuth(), controller.BackfillUsageRollups)
+		dataRoute.GET("/rollup/backfill", middleware.RootAuth(), controller.GetUsageRollupBackfillStatus)
 
 		logRoute.Use(middleware.CORS(), middleware.CriticalRateLimit())
 		{
@@ -309,10 +401,12 @@ func SetApiRouter(router *gin.Engine) {
 		}
 
 		mjRoute := apiRouter.Group("/mj")
+		mjRoute.Use(middleware.ManagementScope(model.ManagementScopeTasks))
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
 		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
 
 		taskRoute := apiRouter.Group("/task")
+		taskRoute.Use(middleware.ManagementScope(model.ManagementScopeTasks))
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
@@ -330,7 +424,7 @@ func SetApiRouter(router *gin.Engine) {
 		}
 
 		modelsRoute := apiRouter.Group("/models")
-		modelsRoute.Use(middleware.AdminAuth())
+		modelsRoute.Use(middleware.ManagementScope(model.ManagementScopeModels), middleware.AdminAuth())
 		{
 			modelsRoute.GET("/sync_upstream/preview", controller.SyncUpstreamPreview)
 			modelsRoute.POST("/sync_upstream", controller.SyncUpstreamModels)
@@ -0,0 +1,20 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/controller"
+	"github.com/QuantumNous/new-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetMetricsRouter(router *gin.Engine) {
+	if !constant.MetricsEnabled {
+		return
+	}
+	if constant.MetricsToken == "" && len(constant.MetricsAllowedIps) == 0 {
+		common.SysError("METRICS_ENABLED is set but neither METRICS_TOKEN nor METRICS_ALLOWED_IPS is configured, all scrape requests will be rejected")
+	}
+	router.GET("/metrics", middleware.RouteTag("metrics"), middleware.MetricsAuth(), controller.Metrics)
+}
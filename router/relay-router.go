@@ -1,6 +1,8 @@
 package router
 
 import (
+	"strings"
+
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
@@ -71,6 +73,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relayV1Router.Use(middleware.SystemPerformanceCheck())
 	relayV1Router.Use(middleware.TokenAuth())
 	relayV1Router.Use(middleware.ModelRequestRateLimit())
+	relayV1Router.Use(middleware.TokenRateLimit())
 	{
 		// WebSocket 路由（统一到 Relay）
 		wsRouter := relayV1Router.Group("")
@@ -79,6 +82,22 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// files 路由不经过 Distribute，由控制器自行选择或固定渠道
+		filesRouter := relayV1Router.Group("/files")
+		filesRouter.GET("", controller.RelayFileList)
+		filesRouter.POST("", controller.RelayFileUpload)
+		filesRouter.GET("/:id", controller.RelayFileRetrieve)
+		filesRouter.GET("/:id/content", controller.RelayFileContent)
+		filesRouter.DELETE("/:id", controller.RelayFileDelete)
+
+		// batches 基于任务系统异步执行，由轮询推进
+		batchesRouter := relayV1Router.Group("/batches")
+		batchesRouter.POST("", controller.RelayBatchCreate)
+		batchesRouter.GET("", controller.RelayBatchList)
+		batchesRouter.GET("/:id", controller.RelayBatchRetrieve)
+		batchesRouter.POST("/:id/cancel", controller.RelayBatchCancel)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -88,6 +107,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/messages", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatClaude)
 		})
+		httpRouter.POST("/messages/count_tokens", func(c *gin.Context) {
+			controller.RelayCountTokens(c, types.RelayFormatClaude)
+		})
 
 		// chat related routes
 		httpRouter.POST("/completions", func(c *gin.Context) {
@@ -96,6 +118,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/chat/completions", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAI)
 		})
+		httpRouter.POST("/chat/completions/input_tokens", func(c *gin.Context) {
+			controller.RelayCountTokens(c, types.RelayFormatOpenAI)
+		})
 
 		// response related routes
 		httpRouter.POST("/responses", func(c *gin.Context) {
@@ -104,6 +129,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/responses/compact", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIResponsesCompaction)
 		})
+		httpRouter.POST("/responses/input_tokens", func(c *gin.Context) {
+			controller.RelayCountTokens(c, types.RelayFormatOpenAIResponses)
+		})
 
 		// image related routes
 		httpRouter.POST("/edits", func(c *gin.Context) {
@@ -141,9 +169,7 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/engines/:model/embeddings", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatGemini)
 		})
-		httpRouter.POST("/models/*path", func(c *gin.Context) {
-			controller.Relay(c, types.RelayFormatGemini)
-		})
+		httpRouter.POST("/models/*path", relayGemini)
 
 		// other relay routes
 		httpRouter.POST("/moderations", func(c *gin.Context) {
@@ -152,11 +178,6 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// not implemented
 		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
@@ -179,7 +200,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relaySunoRouter := router.Group("/suno")
 	relaySunoRouter.Use(middleware.RouteTag("relay"))
 	relaySunoRouter.Use(middleware.SystemPerformanceCheck())
-	relaySunoRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relaySunoRouter.Use(middleware.TokenAuth(), middleware.TokenRateLimit(), middleware.Distribute())
 	{
 		relaySunoRouter.POST("/submit/:action", controller.RelayTask)
 		relaySunoRouter.POST("/fetch", controller.RelayTaskFetch)
@@ -191,18 +212,26 @@ func SetRelayRouter(router *gin.Engine) {
 	relayGeminiRouter.Use(middleware.SystemPerformanceCheck())
 	relayGeminiRouter.Use(middleware.TokenAuth())
 	relayGeminiRouter.Use(middleware.ModelRequestRateLimit())
+	relayGeminiRouter.Use(middleware.TokenRateLimit())
 	relayGeminiRouter.Use(middleware.Distribute())
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
-		relayGeminiRouter.POST("/models/*path", func(c *gin.Context) {
-			controller.Relay(c, types.RelayFormatGemini)
-		})
+		relayGeminiRouter.POST("/models/*path", relayGemini)
+	}
+}
+
+// relayGemini 处理 Gemini 原生接口，countTokens 在本地统计，不走生成与计费流程
+func relayGemini(c *gin.Context) {
+	if strings.HasSuffix(c.Param("path"), ":countTokens") {
+		controller.RelayCountTokens(c, types.RelayFormatGemini)
+		return
 	}
+	controller.Relay(c, types.RelayFormatGemini)
 }
 
 func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
 	relayMjRouter.GET("/image/:id", relay.RelayMidjourneyImage)
-	relayMjRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relayMjRouter.Use(middleware.TokenAuth(), middleware.TokenRateLimit(), middleware.Distribute())
 	{
 		relayMjRouter.POST("/submit/action", controller.RelayMidjourney)
 		relayMjRouter.POST("/submit/shorten", controller.RelayMidjourney)
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	balanceSnapshotTickInterval = 10 * time.Minute
+	balanceSnapshotBatchSize    = 500
+)
+
+var (
+	balanceSnapshotOnce    sync.Once
+	balanceSnapshotRunning atomic.Bool
+)
+
+// StartBalanceSnapshotTask 每月初记录用户与令牌余额，作为月度对账单的期初余额
+func StartBalanceSnapshotTask() {
+	balanceSnapshotOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("balance snapshot task started: tick=%s", balanceSnapshotTickInterval))
+			ticker := time.NewTicker(balanceSnapshotTickInterval)
+			defer ticker.Stop()
+
+			runBalanceSnapshotOnce()
+			for range ticker.C {
+				runBalanceSnapshotOnce()
+			}
+		})
+	})
+}
+
+func runBalanceSnapshotOnce() {
+	if !balanceSnapshotRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer balanceSnapshotRunning.Store(false)
+
+	ctx := context.Background()
+	period := StatementPeriod(time.Now())
+	done, err := model.IsBalanceSnapshotDone(period)
+	if err != nil || done {
+		return
+	}
+	lastId, total := 0, 0
+	for {
+		var n int
+		lastId, n, err = model.SnapshotUserBalances(period, lastId, balanceSnapshotBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("balance snapshot task failed: %v", err))
+			return
+		}
+		total += n
+		if n < balanceSnapshotBatchSize {
+			break
+		}
+	}
+	lastId = 0
+	for {
+		var n int
+		lastId, n, err = model.SnapshotTokenBalances(period, lastId, balanceSnapshotBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("balance snapshot task failed: %v", err))
+			return
+		}
+		total += n
+		if n < balanceSnapshotBatchSize {
+			break
+		}
+	}
+	if err = model.MarkBalanceSnapshotDone(period); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("balance snapshot task failed: %v", err))
+		return
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("balance snapshot for %s finished: count=%d", period, total))
+}
@@ -0,0 +1,861 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 批处理支持的请求端点，本地执行时均经过通用的 OpenAI 兼容计费链路
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+	"/v1/responses":        true,
+}
+
+// 支持上游 Batch API 的渠道类型
+var batchRelayChannelTypes = map[int]bool{
+	constant.ChannelTypeOpenAI: true,
+	constant.ChannelTypeAzure:  true,
+}
+
+const (
+	batchCompletionWindow = "24h"
+	batchPurpose          = "batch"
+	batchOutputPurpose    = "batch_output"
+
+	// 批处理任务的 Action 记录执行方式
+	BatchActionUpstream = "upstream"
+	BatchActionLocal    = "local"
+)
+
+func IsBatchRelaySupportedChannel(channelType int) bool {
+	return batchRelayChannelTypes[channelType]
+}
+
+func isBatchTerminal(status string) bool {
+	switch status {
+	case dto.BatchStatusCompleted, dto.BatchStatusFailed, dto.BatchStatusExpired, dto.BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// batchTaskStatus 将批处理状态映射为任务状态
+func batchTaskStatus(status string) model.TaskStatus {
+	switch status {
+	case dto.BatchStatusValidating:
+		return model.TaskStatusQueued
+	case dto.BatchStatusCancelling:
+		return model.TaskStatusCancelling
+	case dto.BatchStatusCompleted:
+		return model.TaskStatusSuccess
+	case dto.BatchStatusFailed, dto.BatchStatusExpired, dto.BatchStatusCancelled:
+		return model.TaskStatusFailure
+	default:
+		return model.TaskStatusInProgress
+	}
+}
+
+func batchProgress(batch *dto.OpenAIBatch) string {
+	if isBatchTerminal(batch.Status) {
+		return "100%"
+	}
+	counts := batch.RequestCounts
+	if counts.Total <= 0 {
+		return "0%"
+	}
+	// 终态之前不报告 100%，否则轮询不再处理该任务
+	progress := min((counts.Completed+counts.Failed)*100/counts.Total, 99)
+	return fmt.Sprintf("%d%%", progress)
+}
+
+// GetBatchData 读取任务中保存的批处理对象
+func GetBatchData(task *model.Task) (*dto.OpenAIBatch, error) {
+	var batch dto.OpenAIBatch
+	if err := common.Unmarshal(task.Data, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// setBatchData 写回批处理对象并同步任务状态与进度
+func setBatchData(task *model.Task, batch *dto.OpenAIBatch) {
+	task.SetData(batch)
+	task.Status = batchTaskStatus(batch.Status)
+	task.Progress = batchProgress(batch)
+	if isBatchTerminal(batch.Status) && task.FinishTime == 0 {
+		task.FinishTime = time.Now().Unix()
+	}
+	if batch.Errors != nil && len(batch.Errors.Data) > 0 {
+		task.FailReason = batch.Errors.Data[0].Message
+	}
+}
+
+func newBatchErrors(code string, message string) *dto.OpenAIBatchErrors {
+	return &dto.OpenAIBatchErrors{
+		Object: "list",
+		Data:   []dto.OpenAIBatchError{{Code: code, Message: message}},
+	}
+}
+
+func batchInputPath(batchId string) string {
+	return filepath.Join(constant.FileStoragePath, batchId+".input.jsonl")
+}
+
+// batchInputSummary 输入文件校验结果，Estimates 为按模型汇总的预估用量，用于上游批处理预扣费
+type batchInputSummary struct {
+	Lines     int
+	Models    []string
+	Estimates map[string]*batchModelUsage
+}
+
+type batchLineBody struct {
+	Model               string `json:"model"`
+	Stream              bool   `json:"stream"`
+	MaxTokens           int    `json:"max_tokens"`
+	MaxCompletionTokens int    `json:"max_completion_tokens"`
+	MaxOutputTokens     int    `json:"max_output_tokens"`
+}
+
+// maxOutputTokens 返回请求声明的补全上限，兼容 chat/completions 与 responses 的字段
+func (b *batchLineBody) maxOutputTokens() int {
+	return max(b.MaxTokens, b.MaxCompletionTokens, b.MaxOutputTokens)
+}
+
+// parseBatchInput 逐行校验批处理输入文件，每个合法行回调一次 each
+func parseBatchInput(reader io.Reader, endpoint string, maxLines int, each func(raw []byte) error) (*batchInputSummary, error) {
+	summary := &batchInputSummary{Estimates: make(map[string]*batchModelUsage)}
+	customIds := make(map[string]bool)
+	models := make(map[string]bool)
+	br := bufio.NewReader(reader)
+	lineNo := 0
+	for {
+		raw, readErr := br.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 {
+			lineNo++
+			if maxLines > 0 && lineNo > maxLines {
+				return nil, fmt.Errorf("batch input exceeds the limit of %d requests", maxLines)
+			}
+			var line dto.OpenAIBatchInputLine
+			if err := common.Unmarshal(raw, &line); err != nil {
+				return nil, fmt.Errorf("line %d: invalid json: %w", lineNo, err)
+			}
+			if line.CustomID == "" {
+				return nil, fmt.Errorf("line %d: custom_id is required", lineNo)
+			}
+			if customIds[line.CustomID] {
+				return nil, fmt.Errorf("line %d: duplicate custom_id %s", lineNo, line.CustomID)
+			}
+			customIds[line.CustomID] = true
+			if !strings.EqualFold(line.Method, http.MethodPost) {
+				return nil, fmt.Errorf("line %d: method must be POST", lineNo)
+			}
+			if line.URL != endpoint {
+				return nil, fmt.Errorf("line %d: url %s does not match batch endpoint %s", lineNo, line.URL, endpoint)
+			}
+			var body batchLineBody
+			if err := common.Unmarshal(line.Body, &body); err != nil {
+				return nil, fmt.Errorf("line %d: invalid body: %w", lineNo, err)
+			}
+			if body.Model == "" {
+				return nil, fmt.Errorf("line %d: body.model is required", lineNo)
+			}
+			if body.Stream {
+				return nil, fmt.Errorf("line %d: streaming is not supported in batch", lineNo)
+			}
+			if !models[body.Model] {
+				models[body.Model] = true
+				summary.Models = append(summary.Models, body.Model)
+				summary.Estimates[body.Model] = &batchModelUsage{}
+			}
+			estimate := summary.Estimates[body.Model]
+			estimate.Requests++
+			estimate.PromptTokens += max(EstimateTokenByModel(body.Model, string(line.Body)), common.PreConsumedQuota)
+			estimate.CompletionTokens += body.maxOutputTokens()
+			if err := each(raw); err != nil {
+				return nil, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if lineNo == 0 {
+		return nil, errors.New("batch input file is empty")
+	}
+	summary.Lines = lineNo
+	return summary, nil
+}
+
+// checkBatchTokenModelLimit 校验令牌的模型限制，本地执行时分发中间件会再次逐行校验
+func checkBatchTokenModelLimit(c *gin.Context, models []string) error {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return nil
+	}
+	limit, _ := common.GetContextKey(c, constant.ContextKeyTokenModelLimit)
+	tokenModelLimit, _ := limit.(map[string]bool)
+	for _, modelName := range models {
+		if !tokenModelLimit[ratio_setting.FormatMatchingModelName(modelName)] {
+			return fmt.Errorf("this token has no access to model %s", modelName)
+		}
+	}
+	return nil
+}
+
+// batchChannelGroup 返回可以在指定渠道上使用全部模型的分组，不存在时返回空字符串
+func batchChannelGroup(channelId int, models []string, groups []string) string {
+	for _, group := range groups {
+		satisfied := true
+		for _, modelName := range models {
+			if !model.IsChannelEnabledForGroupModel(group, modelName, channelId) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return group
+		}
+	}
+	return ""
+}
+
+// resolveBatchMode 决定批处理转发上游还是本地执行，转发上游时同时返回计费分组
+func resolveBatchMode(c *gin.Context, file *model.File, models []string) (string, string, error) {
+	usingGroup := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	mode := operation_setting.GetBatchSetting().Mode
+	if mode == operation_setting.BatchModeLocal {
+		return BatchActionLocal, usingGroup, nil
+	}
+
+	upstreamGroup := ""
+	if !file.IsLocal() {
+		channel, err := model.CacheGetChannel(file.ChannelId)
+		if err == nil && channel.Status == common.ChannelStatusEnabled && IsBatchRelaySupportedChannel(channel.Type) {
+			groups := []string{usingGroup}
+			if usingGroup == "auto" {
+				groups = GetUserAutoGroup(common.GetContextKeyString(c, constant.ContextKeyUserGroup))
+			}
+			upstreamGroup = batchChannelGroup(channel.Id, models, groups)
+		}
+	}
+	if upstreamGroup != "" {
+		return BatchActionUpstream, upstreamGroup, nil
+	}
+	if mode == operation_setting.BatchModeUpstream {
+		return "", "", errors.New("the channel of the input file does not support batch for the requested models")
+	}
+	return BatchActionLocal, usingGroup, nil
+}
+
+// CreateBatch 校验输入文件并创建批处理任务
+func CreateBatch(c *gin.Context, req *dto.OpenAIBatchRequest) (*dto.OpenAIBatch, *types.NewAPIError) {
+	if !batchEndpoints[req.Endpoint] {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("unsupported endpoint %s", req.Endpoint), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("completion_window must be %s", batchCompletionWindow), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	userId := c.GetInt("id")
+	inputFile, err := model.GetUserFileByFileId(userId, req.InputFileID)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("input file %s not found", req.InputFileID), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	if inputFile.Purpose != batchPurpose {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("input file purpose must be %s", batchPurpose), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	userQuota, err := model.GetUserQuota(userId, false)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if userQuota <= 0 {
+		return nil, types.NewErrorWithStatusCode(errors.New("user quota is not enough"), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+	}
+
+	// 读取并校验输入，规范化后的内容保存到本地，供本地执行使用
+	batchId := model.GenerateBatchID()
+	inputPath := batchInputPath(batchId)
+	summary, err := saveBatchInput(c.Request.Context(), inputFile, inputPath, req.Endpoint)
+	if err != nil {
+		_ = os.Remove(inputPath)
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	if err := checkBatchTokenModelLimit(c, summary.Models); err != nil {
+		_ = os.Remove(inputPath)
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeAccessDenied, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+	}
+	action, group, err := resolveBatchMode(c, inputFile, summary.Models)
+	if err != nil {
+		_ = os.Remove(inputPath)
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeGetChannelFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	now := time.Now().Unix()
+	batch := &dto.OpenAIBatch{
+		ID:               batchId,
+		Object:           "batch",
+		Endpoint:         req.Endpoint,
+		InputFileID:      inputFile.FileId,
+		CompletionWindow: req.CompletionWindow,
+		Status:           dto.BatchStatusValidating,
+		CreatedAt:        now,
+		ExpiresAt:        now + 24*60*60,
+		RequestCounts:    dto.OpenAIBatchRequestCounts{Total: summary.Lines},
+		Metadata:         req.Metadata,
+	}
+	task := &model.Task{
+		TaskID:     batchId,
+		Platform:   constant.TaskPlatformBatch,
+		UserId:     userId,
+		Group:      group,
+		Action:     action,
+		SubmitTime: now,
+		Properties: model.Properties{Input: inputFile.FileId},
+		PrivateData: model.TaskPrivateData{
+			TokenId:       c.GetInt("token_id"),
+			ClientIp:      c.ClientIP(),
+			BillingSource: BillingSourceWallet,
+		},
+	}
+	if len(summary.Models) == 1 {
+		task.Properties.OriginModelName = summary.Models[0]
+	}
+
+	if action == BatchActionUpstream {
+		_ = os.Remove(inputPath)
+		// 上游批处理完成后才结算，提交前按预估用量预扣，本地执行时由每行请求各自预扣
+		hold := estimateBatchHold(newBatchBilling(task), summary)
+		if newAPIError := holdBatchQuota(c.Request.Context(), task, userQuota, hold); newAPIError != nil {
+			return nil, newAPIError
+		}
+		upstreamBatch, newAPIError := submitUpstreamBatch(c.Request.Context(), inputFile, req)
+		if newAPIError != nil {
+			releaseBatchHold(c.Request.Context(), task)
+			return nil, newAPIError
+		}
+		task.ChannelId = inputFile.ChannelId
+		task.PrivateData.KeyIndex = inputFile.KeyIndex
+		task.PrivateData.UpstreamTaskID = upstreamBatch.ID
+		mergeUpstreamBatch(batch, upstreamBatch)
+	} else {
+		// 轮询会将上游 ID 为空的任务判定为失败，本地批处理使用自身 ID
+		task.PrivateData.UpstreamTaskID = batchId
+	}
+	setBatchData(task, batch)
+	if err := task.Insert(); err != nil {
+		_ = os.Remove(inputPath)
+		releaseBatchHold(c.Request.Context(), task)
+		return nil, types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+	if action == BatchActionLocal && common.IsMasterNode && constant.UpdateTask {
+		StartLocalBatch(batchId)
+	}
+	return batch, nil
+}
+
+// holdBatchQuota 校验钱包、令牌额度与令牌预算后预扣 hold，预扣额度记录在 task.Quota 中
+func holdBatchQuota(ctx context.Context, task *model.Task, userQuota int, hold int) *types.NewAPIError {
+	if hold <= 0 {
+		return nil
+	}
+	if userQuota < hold {
+		return types.NewErrorWithStatusCode(fmt.Errorf("user quota is not enough for this batch, need quota: %s", logger.FormatQuota(hold)), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+	}
+	if task.PrivateData.TokenId > 0 {
+		token, err := model.GetTokenById(task.PrivateData.TokenId)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+		}
+		if !token.UnlimitedQuota && token.RemainQuota < hold {
+			return types.NewErrorWithStatusCode(fmt.Errorf("token quota is not enough for this batch, need quota: %s", logger.FormatQuota(hold)), types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+		}
+		if err := token.CheckBudget(hold); err != nil {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+		}
+	}
+	if err := taskAdjustFunding(task, hold); err != nil {
+		return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+	taskAdjustTokenQuota(ctx, task, hold)
+	task.Quota = hold
+	return nil
+}
+
+// releaseBatchHold 提交失败时退还预扣的额度
+func releaseBatchHold(ctx context.Context, task *model.Task) {
+	if task.Quota <= 0 {
+		return
+	}
+	if err := taskAdjustFunding(task, -task.Quota); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("退还批处理预扣额度失败: %s", err.Error()))
+		return
+	}
+	taskAdjustTokenQuota(ctx, task, -task.Quota)
+	task.Quota = 0
+}
+
+func saveBatchInput(ctx context.Context, inputFile *model.File, inputPath string, endpoint string) (*batchInputSummary, error) {
+	content, err := OpenFileContent(ctx, inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	defer content.Close()
+	if err := os.MkdirAll(constant.FileStoragePath, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(f)
+	summary, err := parseBatchInput(content, endpoint, operation_setting.GetBatchSetting().MaxLines, func(raw []byte) error {
+		if _, err := writer.Write(raw); err != nil {
+			return err
+		}
+		return writer.WriteByte('\n')
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func submitUpstreamBatch(ctx context.Context, inputFile *model.File, req *dto.OpenAIBatchRequest) (*dto.OpenAIBatch, *types.NewAPIError) {
+	channel, err := model.CacheGetChannel(inputFile.ChannelId)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+	}
+	upstreamReq := *req
+	upstreamReq.InputFileID = inputFile.UpstreamFileId
+	body, err := common.Marshal(upstreamReq)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeJsonMarshalFailed, types.ErrOptionWithSkipRetry())
+	}
+	resp, err := DoFileRequest(ctx, channel, inputFile.KeyIndex, http.MethodPost, "/batches", bytes.NewReader(body), "application/json")
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, RelayErrorHandler(ctx, resp, false)
+	}
+	var upstreamBatch dto.OpenAIBatch
+	if err := common.DecodeJson(resp.Body, &upstreamBatch); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	if upstreamBatch.ID == "" {
+		return nil, types.NewError(errors.New("upstream returned empty batch id"), types.ErrorCodeBadResponseBody)
+	}
+	return &upstreamBatch, nil
+}
+
+// mergeUpstreamBatch 同步上游批处理的状态字段，文件 ID 由网关单独维护
+func mergeUpstreamBatch(batch *dto.OpenAIBatch, upstream *dto.OpenAIBatch) {
+	if upstream.Status != "" {
+		batch.Status = upstream.Status
+	}
+	batch.Errors = upstream.Errors
+	batch.RequestCounts = upstream.RequestCounts
+	batch.InProgressAt = upstream.InProgressAt
+	batch.FinalizingAt = upstream.FinalizingAt
+	batch.CompletedAt = upstream.CompletedAt
+	batch.FailedAt = upstream.FailedAt
+	batch.ExpiredAt = upstream.ExpiredAt
+	batch.CancellingAt = upstream.CancellingAt
+	batch.CancelledAt = upstream.CancelledAt
+	if upstream.ExpiresAt > 0 {
+		batch.ExpiresAt = upstream.ExpiresAt
+	}
+}
+
+func fetchUpstreamBatch(ctx context.Context, channel *model.Channel, task *model.Task, method string, path string) (*dto.OpenAIBatch, error) {
+	resp, err := DoFileRequest(ctx, channel, task.PrivateData.KeyIndex, method, path, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, RelayErrorHandler(ctx, resp, false)
+	}
+	var upstreamBatch dto.OpenAIBatch
+	if err := common.DecodeJson(resp.Body, &upstreamBatch); err != nil {
+		return nil, err
+	}
+	return &upstreamBatch, nil
+}
+
+// CancelBatch 取消批处理：上游批处理转发取消请求，本地批处理标记为 cancelling 由执行器停止
+func CancelBatch(ctx context.Context, task *model.Task) (*dto.OpenAIBatch, *types.NewAPIError) {
+	batch, err := GetBatchData(task)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody, types.ErrOptionWithSkipRetry())
+	}
+	if batch.Status == dto.BatchStatusCancelling {
+		return batch, nil
+	}
+	if isBatchTerminal(batch.Status) {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("cannot cancel a batch with status %s", batch.Status), types.ErrorCodeInvalidRequest, http.StatusConflict, types.ErrOptionWithSkipRetry())
+	}
+
+	if task.Action == BatchActionUpstream {
+		channel, err := model.CacheGetChannel(task.ChannelId)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+		}
+		upstreamBatch, err := fetchUpstreamBatch(ctx, channel, task, http.MethodPost, "/batches/"+task.GetUpstreamTaskID()+"/cancel")
+		if err != nil {
+			var newAPIError *types.NewAPIError
+			if errors.As(err, &newAPIError) {
+				return nil, newAPIError
+			}
+			return nil, types.NewError(err, types.ErrorCodeDoRequestFailed)
+		}
+		mergeUpstreamBatch(batch, upstreamBatch)
+	} else {
+		batch.Status = dto.BatchStatusCancelling
+		batch.CancellingAt = time.Now().Unix()
+	}
+
+	oldStatus := task.Status
+	setBatchData(task, batch)
+	won, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+	if !won {
+		return nil, types.NewErrorWithStatusCode(errors.New("batch status changed, please retry"), types.ErrorCodeInvalidRequest, http.StatusConflict, types.ErrOptionWithSkipRetry())
+	}
+	if task.Action == BatchActionLocal {
+		cancelLocalBatchRunner(task.TaskID)
+	}
+	return batch, nil
+}
+
+// UpdateBatchTasks 轮询推进批处理：上游批处理同步状态，本地批处理确保执行器在运行
+func UpdateBatchTasks(ctx context.Context, taskM map[string]*model.Task) {
+	for _, task := range taskM {
+		switch task.Action {
+		case BatchActionUpstream:
+			if err := updateUpstreamBatch(ctx, task); err != nil {
+				logger.LogError(ctx, fmt.Sprintf("update batch %s failed: %s", task.TaskID, err.Error()))
+			}
+		case BatchActionLocal:
+			if common.IsMasterNode {
+				StartLocalBatch(task.TaskID)
+			}
+		}
+	}
+}
+
+func updateUpstreamBatch(ctx context.Context, task *model.Task) error {
+	batch, err := GetBatchData(task)
+	if err != nil {
+		return err
+	}
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return err
+	}
+	upstreamBatch, err := fetchUpstreamBatch(ctx, channel, task, http.MethodGet, "/batches/"+task.GetUpstreamTaskID())
+	if err != nil {
+		return err
+	}
+	if isBatchTerminal(upstreamBatch.Status) {
+		return finalizeUpstreamBatch(ctx, task, channel, batch, upstreamBatch)
+	}
+	snapshot := task.Snapshot()
+	oldStatus := task.Status
+	mergeUpstreamBatch(batch, upstreamBatch)
+	setBatchData(task, batch)
+	if snapshot.Equal(task.Snapshot()) {
+		return nil
+	}
+	_, err = task.UpdateWithStatus(oldStatus)
+	return err
+}
+
+// batchModelUsage 按模型汇总的批处理用量与额度
+type batchModelUsage struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	CachedTokens     int
+	Quota            int
+}
+
+// batchBilling 上游批处理结算时的计费参数
+type batchBilling struct {
+	group      string
+	groupRatio float64
+	modelName  string // 只有一个模型时以提交时的模型名计费，避免上游返回带日期的模型名
+	usage      map[string]*batchModelUsage
+	quota      int
+}
+
+func newBatchBilling(task *model.Task) *batchBilling {
+	billing := &batchBilling{
+		group:     task.Group,
+		modelName: task.Properties.OriginModelName,
+		usage:     make(map[string]*batchModelUsage),
+	}
+	userGroup := task.Group
+	if userCache, err := model.GetUserCache(task.UserId); err == nil {
+		userGroup = userCache.Group
+	}
+	if ratio, ok := ratio_setting.GetGroupGroupRatio(userGroup, task.Group); ok {
+		billing.groupRatio = ratio
+	} else {
+		billing.groupRatio = ratio_setting.GetGroupRatio(task.Group)
+	}
+	return billing
+}
+
+type batchResponseBody struct {
+	Model string     `json:"model"`
+	Usage *dto.Usage `json:"usage"`
+}
+
+// batchUsageTokens 从响应体中解析模型名与用量，兼容 chat/completions、embeddings 与 responses 的 usage 格式
+func batchUsageTokens(body []byte) (string, int, int, int) {
+	var resp batchResponseBody
+	if err := common.Unmarshal(body, &resp); err != nil || resp.Usage == nil {
+		return resp.Model, 0, 0, 0
+	}
+	usage := resp.Usage
+	promptTokens := usage.PromptTokens
+	completionTokens := usage.CompletionTokens
+	cachedTokens := usage.PromptTokensDetails.CachedTokens
+	if promptTokens == 0 && completionTokens == 0 {
+		promptTokens = usage.InputTokens
+		completionTokens = usage.OutputTokens
+		if usage.InputTokensDetails != nil {
+			cachedTokens = usage.InputTokensDetails.CachedTokens
+		}
+	}
+	return resp.Model, promptTokens, completionTokens, cachedTokens
+}
+
+// batchQuota 计算批处理用量的额度：按量计费为 (输入 + 补全 × 补全倍率) × 模型倍率 × 分组倍率 × 批处理倍率，
+// 按次计费为 请求数 × 模型价格 × 分组倍率 × 批处理倍率；分档倍率按单个请求的平均输入长度选择
+func batchQuota(modelName string, groupRatio float64, usage *batchModelUsage) float64 {
+	batchRatio := ratio_setting.GetBatchRatio(modelName)
+	if modelPrice, usePrice := ratio_setting.GetModelPrice(modelName, false); usePrice {
+		return float64(usage.Requests) * modelPrice * common.QuotaPerUnit * groupRatio * batchRatio
+	}
+	modelRatio, _, _ := ratio_setting.GetModelRatio(modelName)
+	completionRatio := ratio_setting.GetCompletionRatio(modelName)
+	cacheRatio, _ := ratio_setting.GetCacheRatio(modelName)
+	if tiers, ok := ratio_setting.GetModelTieredRatio(modelName); ok {
+		priceData := types.PriceData{
+			Tiers:    tiers,
+			BaseTier: types.PriceTier{ModelRatio: modelRatio, CompletionRatio: completionRatio, CacheRatio: cacheRatio},
+		}
+		priceData.ApplyTier(usage.PromptTokens / max(usage.Requests, 1))
+		modelRatio, completionRatio, cacheRatio = priceData.ModelRatio, priceData.CompletionRatio, priceData.CacheRatio
+	}
+	tokens := float64(usage.PromptTokens-usage.CachedTokens) + float64(usage.CachedTokens)*cacheRatio + float64(usage.CompletionTokens)*completionRatio
+	return tokens * modelRatio * groupRatio * batchRatio
+}
+
+// estimateBatchHold 按输入文件的预估用量计算上游批处理提交时预扣的额度
+func estimateBatchHold(billing *batchBilling, summary *batchInputSummary) int {
+	var hold float64
+	for modelName, usage := range summary.Estimates {
+		hold += batchQuota(modelName, billing.groupRatio, usage)
+	}
+	return int(math.Ceil(hold))
+}
+
+// lineBilling 计算上游批处理单行的额度并累计到 billing
+func (b *batchBilling) lineBilling(body []byte) *dto.OpenAIBatchLineBilling {
+	responseModel, promptTokens, completionTokens, cachedTokens := batchUsageTokens(body)
+	modelName := b.modelName
+	if modelName == "" {
+		modelName = responseModel
+	}
+	batchRatio := ratio_setting.GetBatchRatio(modelName)
+	quota := batchQuota(modelName, b.groupRatio, &batchModelUsage{
+		Requests:         1,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		CachedTokens:     cachedTokens,
+	})
+	lineQuota := int(math.Round(quota))
+	if lineQuota == 0 && quota > 0 {
+		lineQuota = 1
+	}
+	usage, ok := b.usage[modelName]
+	if !ok {
+		usage = &batchModelUsage{}
+		b.usage[modelName] = usage
+	}
+	usage.Requests++
+	usage.PromptTokens += promptTokens
+	usage.CompletionTokens += completionTokens
+	usage.Quota += lineQuota
+	b.quota += lineQuota
+	return &dto.OpenAIBatchLineBilling{
+		Model:            modelName,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		BatchRatio:       batchRatio,
+		Quota:            lineQuota,
+	}
+}
+
+// copyUpstreamBatchResult 下载上游结果文件，为每行补充计费信息后保存为网关本地文件
+func copyUpstreamBatchResult(ctx context.Context, task *model.Task, channel *model.Channel, upstreamFileId string, filename string, billing *batchBilling) (*model.File, error) {
+	resp, err := DoFileRequest(ctx, channel, task.PrivateData.KeyIndex, http.MethodGet, "/files/"+upstreamFileId+"/content", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, RelayErrorHandler(ctx, resp, false)
+	}
+	return CreateLocalFile(task.UserId, task.PrivateData.TokenId, filename, batchOutputPurpose, func(w io.Writer) error {
+		br := bufio.NewReader(resp.Body)
+		for {
+			raw, readErr := br.ReadBytes('\n')
+			if readErr != nil && readErr != io.EOF {
+				return readErr
+			}
+			raw = bytes.TrimSpace(raw)
+			if len(raw) > 0 {
+				var line dto.OpenAIBatchOutputLine
+				if err := common.Unmarshal(raw, &line); err != nil {
+					return err
+				}
+				if line.Response != nil && line.Response.StatusCode == http.StatusOK {
+					line.Billing = billing.lineBilling(line.Response.Body)
+				}
+				if err := writeBatchOutputLine(w, &line); err != nil {
+					return err
+				}
+			}
+			if readErr == io.EOF {
+				return nil
+			}
+		}
+	})
+}
+
+func writeBatchOutputLine(w io.Writer, line *dto.OpenAIBatchOutputLine) error {
+	data, err := common.Marshal(line)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
+
+// finalizeUpstreamBatch 上游批处理到达终态后保存结果文件并结算，CAS 成功后才扣费
+func finalizeUpstreamBatch(ctx context.Context, task *model.Task, channel *model.Channel, batch *dto.OpenAIBatch, upstreamBatch *dto.OpenAIBatch) error {
+	billing := newBatchBilling(task)
+	var created []*model.File
+	cleanup := func() {
+		for _, file := range created {
+			_ = DeleteLocalFile(file)
+		}
+	}
+	if upstreamBatch.OutputFileID != "" {
+		file, err := copyUpstreamBatchResult(ctx, task, channel, upstreamBatch.OutputFileID, batch.ID+"_output.jsonl", billing)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		created = append(created, file)
+		batch.OutputFileID = file.FileId
+	}
+	if upstreamBatch.ErrorFileID != "" {
+		file, err := copyUpstreamBatchResult(ctx, task, channel, upstreamBatch.ErrorFileID, batch.ID+"_error.jsonl", billing)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		created = append(created, file)
+		batch.ErrorFileID = file.FileId
+	}
+
+	oldStatus := task.Status
+	held := task.Quota
+	mergeUpstreamBatch(batch, upstreamBatch)
+	task.Quota = billing.quota
+	setBatchData(task, batch)
+	won, err := task.UpdateWithStatus(oldStatus)
+	if err != nil || !won {
+		cleanup()
+		return err
+	}
+	chargeBatchBilling(ctx, task, billing, held)
+	return nil
+}
+
+// chargeBatchBilling 上游批处理完成后按实际用量与预扣额度差额结算，并按模型记录消费日志
+func chargeBatchBilling(ctx context.Context, task *model.Task, billing *batchBilling, held int) {
+	if delta := billing.quota - held; delta != 0 {
+		if err := taskAdjustFunding(task, delta); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("批处理 %s 结算失败: %s", task.TaskID, err.Error()))
+			return
+		}
+		taskAdjustTokenQuota(ctx, task, delta)
+	}
+	if billing.quota <= 0 {
+		return
+	}
+	model.UpdateUserUsedQuotaAndRequestCount(task.UserId, billing.quota)
+	model.UpdateChannelUsedQuota(task.ChannelId, billing.quota)
+	for modelName, usage := range billing.usage {
+		other := map[string]interface{}{
+			"batch_id":          task.TaskID,
+			"batch_ratio":       ratio_setting.GetBatchRatio(modelName),
+			"group_ratio":       billing.groupRatio,
+			"request_count":     usage.Requests,
+			"prompt_tokens":     usage.PromptTokens,
+			"completion_tokens": usage.CompletionTokens,
+		}
+		model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
+			UserId:    task.UserId,
+			LogType:   model.LogTypeConsume,
+			Content:   fmt.Sprintf("批处理 %s，共 %d 个请求", task.TaskID, usage.Requests),
+			ChannelId: task.ChannelId,
+			ModelName: modelName,
+			Quota:     usage.Quota,
+			TokenId:   task.PrivateData.TokenId,
+			Group:     billing.group,
+			Other:     other,
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+// BatchLineRequest 本地执行批处理时的单行请求
+type BatchLineRequest struct {
+	BatchId  string
+	Token    *model.Token
+	ClientIp string
+	URL      string
+	Body     []byte
+}
+
+// BatchLineResult 单行请求经过中继链路后的结果，Quota 为该请求最终结算的额度
+type BatchLineResult struct {
+	StatusCode int
+	RequestId  string
+	Body       []byte
+	Quota      int
+}
+
+// ExecuteBatchLineFunc 由 main 包注入，通过网关内部的中继链路执行一行批处理请求。
+// 打破 service -> controller 的循环依赖。
+var ExecuteBatchLineFunc func(ctx context.Context, req *BatchLineRequest) *BatchLineResult
+
+// 进度同步间隔，同时用于发现其他节点提交的取消请求
+const localBatchSyncInterval = 5 * time.Second
+
+var localBatchRunners sync.Map // batchId -> *localBatchRunner
+
+type localBatchRunner struct {
+	batchId   string
+	cancelled atomic.Bool
+
+	mu           sync.Mutex
+	outputWriter *bufio.Writer
+	errorWriter  *bufio.Writer
+	counts       dto.OpenAIBatchRequestCounts
+	quota        int
+}
+
+// StartLocalBatch 在当前进程中启动本地批处理执行器，已在运行时返回 false
+func StartLocalBatch(batchId string) bool {
+	if ExecuteBatchLineFunc == nil {
+		return false
+	}
+	runner := &localBatchRunner{batchId: batchId}
+	if _, loaded := localBatchRunners.LoadOrStore(batchId, runner); loaded {
+		return false
+	}
+	go func() {
+		defer localBatchRunners.Delete(batchId)
+		defer func() {
+			if r := recover(); r != nil {
+				common.SysError(fmt.Sprintf("local batch %s panic: %v", batchId, r))
+			}
+		}()
+		runner.run(context.Background())
+	}()
+	return true
+}
+
+func cancelLocalBatchRunner(batchId string) {
+	if value, ok := localBatchRunners.Load(batchId); ok {
+		value.(*localBatchRunner).cancelled.Store(true)
+	}
+}
+
+// batchPartialPath 执行过程中的结果文件，结束后登记为网关文件
+func batchPartialPath(batchId string, kind string) string {
+	return filepath.Join(constant.FileStoragePath, batchId+"."+kind+".jsonl")
+}
+
+func (r *localBatchRunner) run(ctx context.Context) {
+	task, exist, err := model.GetByOnlyTaskId(r.batchId)
+	if err != nil || !exist {
+		return
+	}
+	batch, err := GetBatchData(task)
+	if err != nil || isBatchTerminal(batch.Status) {
+		return
+	}
+	if batch.Status == dto.BatchStatusCancelling {
+		r.cancelled.Store(true)
+	}
+	token, err := model.GetTokenById(task.PrivateData.TokenId)
+	if err != nil {
+		r.finalize(ctx, dto.BatchStatusFailed, newBatchErrors("token_not_found", "the token that created this batch no longer exists"))
+		return
+	}
+	input, err := os.Open(batchInputPath(r.batchId))
+	if err != nil {
+		r.finalize(ctx, dto.BatchStatusFailed, newBatchErrors("input_file_missing", "batch input is no longer available"))
+		return
+	}
+	defer input.Close()
+
+	// 断点续跑：已写入结果文件的 custom_id 不再执行
+	done, err := r.loadProgress()
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("load local batch %s progress failed: %s", r.batchId, err.Error()))
+		return
+	}
+	outputFile, err := os.OpenFile(batchPartialPath(r.batchId, "output"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("open local batch %s output failed: %s", r.batchId, err.Error()))
+		return
+	}
+	defer outputFile.Close()
+	errorFile, err := os.OpenFile(batchPartialPath(r.batchId, "error"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("open local batch %s error file failed: %s", r.batchId, err.Error()))
+		return
+	}
+	defer errorFile.Close()
+	r.outputWriter = bufio.NewWriter(outputFile)
+	r.errorWriter = bufio.NewWriter(errorFile)
+
+	if batch.Status == dto.BatchStatusValidating {
+		task.StartTime = time.Now().Unix()
+		batch.Status = dto.BatchStatusInProgress
+		batch.InProgressAt = task.StartTime
+		oldStatus := task.Status
+		setBatchData(task, batch)
+		if won, err := task.UpdateWithStatus(oldStatus); err != nil || !won {
+			return
+		}
+	}
+
+	concurrency := max(operation_setting.GetBatchSetting().LocalConcurrency, 1)
+	lines := make(chan *dto.OpenAIBatchInputLine)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range lines {
+				r.execute(ctx, task, token, line)
+			}
+		}()
+	}
+
+	stopSync := make(chan struct{})
+	syncDone := make(chan struct{})
+	go func() {
+		defer close(syncDone)
+		ticker := time.NewTicker(localBatchSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopSync:
+				return
+			case <-ticker.C:
+				r.sync(ctx)
+			}
+		}
+	}()
+
+	expired := false
+	br := bufio.NewReader(input)
+	for {
+		raw, readErr := br.ReadBytes('\n')
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 {
+			var line dto.OpenAIBatchInputLine
+			if err := common.Unmarshal(raw, &line); err == nil && !done[line.CustomID] {
+				if !expired && batch.ExpiresAt > 0 && time.Now().Unix() >= batch.ExpiresAt {
+					expired = true
+				}
+				if r.cancelled.Load() {
+					break
+				}
+				if expired {
+					// 过期后未执行的请求以 batch_expired 错误写入错误文件
+					r.record(&dto.OpenAIBatchOutputLine{
+						ID:       newBatchRequestId(),
+						CustomID: line.CustomID,
+						Error:    &dto.OpenAIBatchLineError{Code: "batch_expired", Message: "This request could not be executed before the completion window expired."},
+					}, false, 0)
+				} else {
+					lines <- &line
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	close(lines)
+	wg.Wait()
+	close(stopSync)
+	<-syncDone
+
+	r.mu.Lock()
+	flushErr := r.outputWriter.Flush()
+	if err := r.errorWriter.Flush(); flushErr == nil {
+		flushErr = err
+	}
+	r.mu.Unlock()
+	if flushErr != nil {
+		logger.LogError(ctx, fmt.Sprintf("flush local batch %s results failed: %s", r.batchId, flushErr.Error()))
+		return
+	}
+
+	status := dto.BatchStatusCompleted
+	if r.cancelled.Load() {
+		status = dto.BatchStatusCancelled
+	} else if expired {
+		status = dto.BatchStatusExpired
+	}
+	r.finalize(ctx, status, nil)
+}
+
+func newBatchRequestId() string {
+	key, _ := common.GenerateRandomCharsKey(32)
+	return "batch_req_" + key
+}
+
+// loadProgress 读取已有的结果文件，恢复计数与额度并返回已完成的 custom_id
+func (r *localBatchRunner) loadProgress() (map[string]bool, error) {
+	done := make(map[string]bool)
+	for _, kind := range []string{"output", "error"} {
+		path := batchPartialPath(r.batchId, kind)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var validSize int64
+		truncated := false
+		br := bufio.NewReader(f)
+		for {
+			raw, readErr := br.ReadBytes('\n')
+			if readErr != nil {
+				// 进程中断时最后一行可能不完整，截断后重新执行
+				truncated = len(raw) > 0
+				break
+			}
+			validSize += int64(len(raw))
+			var line dto.OpenAIBatchOutputLine
+			if err := common.Unmarshal(bytes.TrimSpace(raw), &line); err == nil && line.CustomID != "" {
+				done[line.CustomID] = true
+				if kind == "output" {
+					r.counts.Completed++
+				} else {
+					r.counts.Failed++
+				}
+				if line.Billing != nil {
+					r.quota += line.Billing.Quota
+				}
+			}
+		}
+		_ = f.Close()
+		if truncated {
+			if err := os.Truncate(path, validSize); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return done, nil
+}
+
+func (r *localBatchRunner) execute(ctx context.Context, task *model.Task, token *model.Token, line *dto.OpenAIBatchInputLine) {
+	result := ExecuteBatchLineFunc(ctx, &BatchLineRequest{
+		BatchId:  r.batchId,
+		Token:    token,
+		ClientIp: task.PrivateData.ClientIp,
+		URL:      line.URL,
+		Body:     line.Body,
+	})
+	body := result.Body
+	if !json.Valid(body) {
+		body, _ = common.Marshal(string(result.Body))
+	}
+	outputLine := &dto.OpenAIBatchOutputLine{
+		ID:       newBatchRequestId(),
+		CustomID: line.CustomID,
+		Response: &dto.OpenAIBatchLineResponse{
+			StatusCode: result.StatusCode,
+			RequestID:  result.RequestId,
+			Body:       body,
+		},
+	}
+	success := result.StatusCode == http.StatusOK
+	if success || result.Quota > 0 {
+		var lineBody batchLineBody
+		_ = common.Unmarshal(line.Body, &lineBody)
+		_, promptTokens, completionTokens, _ := batchUsageTokens(result.Body)
+		outputLine.Billing = &dto.OpenAIBatchLineBilling{
+			Model:            lineBody.Model,
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			BatchRatio:       ratio_setting.GetBatchRatio(lineBody.Model),
+			Quota:            result.Quota,
+		}
+	}
+	r.record(outputLine, success, result.Quota)
+}
+
+func (r *localBatchRunner) record(line *dto.OpenAIBatchOutputLine, success bool, quota int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	writer := r.errorWriter
+	if success {
+		writer = r.outputWriter
+	}
+	if err := writeBatchOutputLine(writer, line); err != nil {
+		common.SysError(fmt.Sprintf("write local batch %s result failed: %s", r.batchId, err.Error()))
+		return
+	}
+	// 每行落盘，进程中断后可从结果文件续跑
+	_ = writer.Flush()
+	if success {
+		r.counts.Completed++
+	} else {
+		r.counts.Failed++
+	}
+	r.quota += quota
+}
+
+// sync 将进度写回任务，并发现通过其他节点提交的取消请求
+func (r *localBatchRunner) sync(ctx context.Context) {
+	task, exist, err := model.GetByOnlyTaskId(r.batchId)
+	if err != nil || !exist {
+		return
+	}
+	batch, err := GetBatchData(task)
+	if err != nil || isBatchTerminal(batch.Status) {
+		return
+	}
+	if batch.Status == dto.BatchStatusCancelling {
+		r.cancelled.Store(true)
+	}
+	r.mu.Lock()
+	batch.RequestCounts.Completed = r.counts.Completed
+	batch.RequestCounts.Failed = r.counts.Failed
+	task.Quota = r.quota
+	r.mu.Unlock()
+	oldStatus := task.Status
+	setBatchData(task, batch)
+	if _, err := task.UpdateWithStatus(oldStatus); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("sync local batch %s progress failed: %s", r.batchId, err.Error()))
+	}
+}
+
+// registerPartialFile 将非空的结果文件登记为网关文件，空文件直接删除
+func (r *localBatchRunner) registerPartialFile(task *model.Task, kind string) (string, error) {
+	path := batchPartialPath(r.batchId, kind)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Size() == 0 {
+		_ = os.Remove(path)
+		return "", nil
+	}
+	file, err := RegisterLocalFile(task.UserId, task.PrivateData.TokenId, path, r.batchId+"_"+kind+".jsonl", batchOutputPurpose)
+	if err != nil {
+		return "", err
+	}
+	return file.FileId, nil
+}
+
+func (r *localBatchRunner) finalize(ctx context.Context, status string, batchErrors *dto.OpenAIBatchErrors) {
+	task, exist, err := model.GetByOnlyTaskId(r.batchId)
+	if err != nil || !exist {
+		return
+	}
+	batch, err := GetBatchData(task)
+	if err != nil || isBatchTerminal(batch.Status) {
+		return
+	}
+	outputFileId, err := r.registerPartialFile(task, "output")
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("register local batch %s output failed: %s", r.batchId, err.Error()))
+		return
+	}
+	errorFileId, err := r.registerPartialFile(task, "error")
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("register local batch %s error file failed: %s", r.batchId, err.Error()))
+		return
+	}
+
+	now := time.Now().Unix()
+	batch.Status = status
+	batch.Errors = batchErrors
+	batch.OutputFileID = outputFileId
+	batch.ErrorFileID = errorFileId
+	r.mu.Lock()
+	if r.counts.Completed+r.counts.Failed > 0 {
+		batch.RequestCounts.Completed = r.counts.Completed
+		batch.RequestCounts.Failed = r.counts.Failed
+	}
+	task.Quota = r.quota
+	r.mu.Unlock()
+	if batch.FinalizingAt == 0 {
+		batch.FinalizingAt = now
+	}
+	switch status {
+	case dto.BatchStatusCompleted:
+		batch.CompletedAt = now
+	case dto.BatchStatusCancelled:
+		batch.CancelledAt = now
+	case dto.BatchStatusExpired:
+		batch.ExpiredAt = now
+	case dto.BatchStatusFailed:
+		batch.FailedAt = now
+	}
+	task.FinishTime = now
+	oldStatus := task.Status
+	setBatchData(task, batch)
+	if _, err := task.UpdateWithStatus(oldStatus); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("finalize local batch %s failed: %s", r.batchId, err.Error()))
+		return
+	}
+	_ = os.Remove(batchInputPath(r.batchId))
+}
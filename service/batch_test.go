@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batchInputLine(customId string, url string, body string) string {
+	return `{"custom_id":"` + customId + `","method":"POST","url":"` + url + `","body":` + body + "}\n"
+}
+
+func TestParseBatchInput(t *testing.T) {
+	input := batchInputLine("a", "/v1/chat/completions", `{"model":"gpt-4o-mini","messages":[]}`) +
+		"\n" +
+		batchInputLine("b", "/v1/chat/completions", `{"model":"gpt-4o","messages":[]}`)
+	var lines []string
+	summary, err := parseBatchInput(strings.NewReader(input), "/v1/chat/completions", 10, func(raw []byte) error {
+		lines = append(lines, string(raw))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Lines)
+	assert.Equal(t, []string{"gpt-4o-mini", "gpt-4o"}, summary.Models)
+	assert.Len(t, lines, 2)
+
+	cases := map[string]string{
+		"duplicate custom_id": batchInputLine("a", "/v1/chat/completions", `{"model":"m"}`) + batchInputLine("a", "/v1/chat/completions", `{"model":"m"}`),
+		"does not match":      batchInputLine("a", "/v1/embeddings", `{"model":"m"}`),
+		"body.model":          batchInputLine("a", "/v1/chat/completions", `{}`),
+		"streaming":           batchInputLine("a", "/v1/chat/completions", `{"model":"m","stream":true}`),
+		"exceeds the limit":   batchInputLine("a", "/v1/chat/completions", `{"model":"m"}`) + batchInputLine("b", "/v1/chat/completions", `{"model":"m"}`) + batchInputLine("c", "/v1/chat/completions", `{"model":"m"}`),
+		"empty":               "\n",
+	}
+	for want, input := range cases {
+		_, err := parseBatchInput(strings.NewReader(input), "/v1/chat/completions", 2, func([]byte) error { return nil })
+		require.Error(t, err, want)
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestBatchLineBilling(t *testing.T) {
+	require.NoError(t, ratio_setting.UpdateModelRatioByJSONString(`{"batch-test-model":2}`))
+	require.NoError(t, ratio_setting.UpdateCompletionRatioByJSONString(`{"batch-test-model":4}`))
+	require.NoError(t, ratio_setting.UpdateBatchRatioByJSONString(`{"default":0.5}`))
+	t.Cleanup(func() {
+		_ = ratio_setting.UpdateModelRatioByJSONString(`{}`)
+		_ = ratio_setting.UpdateCompletionRatioByJSONString(`{}`)
+		_ = ratio_setting.UpdateBatchRatioByJSONString(`{}`)
+	})
+
+	billing := &batchBilling{groupRatio: 1, usage: make(map[string]*batchModelUsage)}
+	// chat/completions 格式
+	line := billing.lineBilling([]byte(`{"model":"batch-test-model","usage":{"prompt_tokens":100,"completion_tokens":50}}`))
+	assert.Equal(t, 300, line.Quota) // (100 + 50*4) * 2 * 0.5
+	assert.Equal(t, 0.5, line.BatchRatio)
+	// responses 格式
+	line = billing.lineBilling([]byte(`{"model":"batch-test-model","usage":{"input_tokens":10,"output_tokens":5}}`))
+	assert.Equal(t, 30, line.Quota)
+
+	assert.Equal(t, 330, billing.quota)
+	assert.Equal(t, 2, billing.usage["batch-test-model"].Requests)
+	assert.Equal(t, 110, billing.usage["batch-test-model"].PromptTokens)
+}
+
+func TestBatchLineBillingTiered(t *testing.T) {
+	require.NoError(t, ratio_setting.UpdateModelRatioByJSONString(`{"tier-test-model":1}`))
+	require.NoError(t, ratio_setting.UpdateCompletionRatioByJSONString(`{"tier-test-model":4}`))
+	require.NoError(t, ratio_setting.UpdateBatchRatioByJSONString(`{"default":1}`))
+	require.Error(t, ratio_setting.UpdateModelTieredRatioByJSONString(`{"tier-test-model":[{"threshold":100,"model_ratio":2},{"threshold":100,"model_ratio":3}]}`))
+	require.NoError(t, ratio_setting.UpdateModelTieredRatioByJSONString(`{"tier-test-model":[{"threshold":1000,"model_ratio":3},{"threshold":100,"model_ratio":2,"completion_ratio":2}]}`))
+	t.Cleanup(func() {
+		_ = ratio_setting.UpdateModelRatioByJSONString(`{}`)
+		_ = ratio_setting.UpdateCompletionRatioByJSONString(`{}`)
+		_ = ratio_setting.UpdateBatchRatioByJSONString(`{}`)
+		_ = ratio_setting.UpdateModelTieredRatioByJSONString(`{}`)
+	})
+
+	billing := &batchBilling{groupRatio: 1, usage: make(map[string]*batchModelUsage)}
+	// 未超过最低阈值，使用原有倍率
+	line := billing.lineBilling([]byte(`{"model":"tier-test-model","usage":{"prompt_tokens":100,"completion_tokens":10}}`))
+	assert.Equal(t, 140, line.Quota)
+	// 超过 100，补全倍率使用档位配置
+	line = billing.lineBilling([]byte(`{"model":"tier-test-model","usage":{"prompt_tokens":200,"completion_tokens":10}}`))
+	assert.Equal(t, 440, line.Quota) // (200 + 10*2) * 2
+	// 超过 1000，补全倍率为 0 时沿用原有倍率
+	line = billing.lineBilling([]byte(`{"model":"tier-test-model","usage":{"prompt_tokens":2000,"completion_tokens":10}}`))
+	assert.Equal(t, 6120, line.Quota) // (2000 + 10*4) * 3
+}
+
+func TestLocalBatchRunner(t *testing.T) {
+	truncate(t)
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM files")
+	})
+	storagePath := constant.FileStoragePath
+	constant.FileStoragePath = t.TempDir()
+	t.Cleanup(func() { constant.FileStoragePath = storagePath })
+
+	seedUser(t, 1, 100000)
+	seedToken(t, 1, 1, "batchtestkey", 100000)
+
+	batchId := model.GenerateBatchID()
+	input := batchInputLine("ok", "/v1/chat/completions", `{"model":"gpt-4o-mini"}`) +
+		batchInputLine("bad", "/v1/chat/completions", `{"model":"gpt-4o-mini"}`)
+	require.NoError(t, os.WriteFile(batchInputPath(batchId), []byte(input), 0o644))
+
+	task := &model.Task{
+		TaskID:     batchId,
+		Platform:   constant.TaskPlatformBatch,
+		UserId:     1,
+		Group:      "default",
+		Action:     BatchActionLocal,
+		SubmitTime: time.Now().Unix(),
+		PrivateData: model.TaskPrivateData{
+			TokenId:        1,
+			UpstreamTaskID: batchId,
+		},
+	}
+	setBatchData(task, &dto.OpenAIBatch{
+		ID:            batchId,
+		Object:        "batch",
+		Endpoint:      "/v1/chat/completions",
+		Status:        dto.BatchStatusValidating,
+		ExpiresAt:     time.Now().Add(time.Hour).Unix(),
+		RequestCounts: dto.OpenAIBatchRequestCounts{Total: 2},
+	})
+	require.NoError(t, task.Insert())
+
+	executeFunc := ExecuteBatchLineFunc
+	t.Cleanup(func() { ExecuteBatchLineFunc = executeFunc })
+	ExecuteBatchLineFunc = func(ctx context.Context, req *BatchLineRequest) *BatchLineResult {
+		assert.Equal(t, batchId, req.BatchId)
+		assert.Equal(t, "batchtestkey", req.Token.Key)
+		assert.Equal(t, "/v1/chat/completions", req.URL)
+		return &BatchLineResult{
+			StatusCode: http.StatusOK,
+			RequestId:  "req",
+			Body:       []byte(`{"model":"gpt-4o-mini","usage":{"prompt_tokens":3,"completion_tokens":4}}`),
+			Quota:      7,
+		}
+	}
+
+	runner := &localBatchRunner{batchId: batchId}
+	// 模拟中断前已执行过一行，续跑时应跳过
+	require.NoError(t, os.WriteFile(batchPartialPath(batchId, "error"),
+		[]byte(`{"id":"x","custom_id":"bad","response":{"status_code":400,"request_id":"r","body":{}},"error":null}`+"\n"+`{"id":"y","cus`), 0o644))
+	runner.run(context.Background())
+
+	saved, exist, err := model.GetByOnlyTaskId(batchId)
+	require.NoError(t, err)
+	require.True(t, exist)
+	batch, err := GetBatchData(saved)
+	require.NoError(t, err)
+	assert.Equal(t, dto.BatchStatusCompleted, batch.Status)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusSuccess), saved.Status)
+	assert.Equal(t, "100%", saved.Progress)
+	assert.Equal(t, 1, batch.RequestCounts.Completed)
+	assert.Equal(t, 1, batch.RequestCounts.Failed)
+	assert.Equal(t, 7, saved.Quota)
+	require.NotEmpty(t, batch.OutputFileID)
+	require.NotEmpty(t, batch.ErrorFileID)
+
+	outputFile, err := model.GetUserFileByFileId(1, batch.OutputFileID)
+	require.NoError(t, err)
+	content, err := os.Open(outputFile.LocalPath)
+	require.NoError(t, err)
+	defer content.Close()
+	scanner := bufio.NewScanner(content)
+	require.True(t, scanner.Scan())
+	var line dto.OpenAIBatchOutputLine
+	require.NoError(t, common.Unmarshal(scanner.Bytes(), &line))
+	assert.Equal(t, "ok", line.CustomID)
+	require.NotNil(t, line.Billing)
+	assert.Equal(t, 7, line.Billing.Quota)
+	assert.Equal(t, 3, line.Billing.PromptTokens)
+	assert.False(t, scanner.Scan())
+
+	// 不完整的尾行被截断，错误文件只保留续跑前的一行
+	errorFile, err := model.GetUserFileByFileId(1, batch.ErrorFileID)
+	require.NoError(t, err)
+	errorContent, err := os.ReadFile(errorFile.LocalPath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(errorContent), "\n"))
+
+	_, err = os.Stat(batchInputPath(batchId))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalBatchSyncKeepsCancelling(t *testing.T) {
+	truncate(t)
+	batchId := model.GenerateBatchID()
+	task := &model.Task{
+		TaskID:      batchId,
+		Platform:    constant.TaskPlatformBatch,
+		UserId:      1,
+		Action:      BatchActionLocal,
+		PrivateData: model.TaskPrivateData{UpstreamTaskID: batchId},
+	}
+	setBatchData(task, &dto.OpenAIBatch{ID: batchId, Status: dto.BatchStatusInProgress, RequestCounts: dto.OpenAIBatchRequestCounts{Total: 2}})
+	require.NoError(t, task.Insert())
+
+	// 同步读取任务后，取消请求先一步写入
+	stale, _, err := model.GetByOnlyTaskId(batchId)
+	require.NoError(t, err)
+	_, apiErr := CancelBatch(context.Background(), task)
+	require.Nil(t, apiErr)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusCancelling), task.Status)
+
+	staleBatch, err := GetBatchData(stale)
+	require.NoError(t, err)
+	staleBatch.RequestCounts.Completed = 1
+	oldStatus := stale.Status
+	setBatchData(stale, staleBatch)
+	won, err := stale.UpdateWithStatus(oldStatus)
+	require.NoError(t, err)
+	assert.False(t, won)
+
+	saved, _, err := model.GetByOnlyTaskId(batchId)
+	require.NoError(t, err)
+	batch, err := GetBatchData(saved)
+	require.NoError(t, err)
+	assert.Equal(t, dto.BatchStatusCancelling, batch.Status)
+}
+
+func TestUpstreamBatchHold(t *testing.T) {
+	truncate(t)
+	require.NoError(t, ratio_setting.UpdateModelRatioByJSONString(`{"batch-test-model":1}`))
+	require.NoError(t, ratio_setting.UpdateCompletionRatioByJSONString(`{"batch-test-model":2}`))
+	require.NoError(t, ratio_setting.UpdateBatchRatioByJSONString(`{"default":0.5}`))
+	t.Cleanup(func() {
+		_ = ratio_setting.UpdateModelRatioByJSONString(`{}`)
+		_ = ratio_setting.UpdateCompletionRatioByJSONString(`{}`)
+		_ = ratio_setting.UpdateBatchRatioByJSONString(`{}`)
+	})
+	seedUser(t, 1, 10000)
+	seedToken(t, 1, 1, "batchholdkey", 10000)
+
+	input := batchInputLine("a", "/v1/chat/completions", `{"model":"batch-test-model","max_tokens":1000}`) +
+		batchInputLine("b", "/v1/chat/completions", `{"model":"batch-test-model","max_completion_tokens":1000}`)
+	summary, err := parseBatchInput(strings.NewReader(input), "/v1/chat/completions", 10, func([]byte) error { return nil })
+	require.NoError(t, err)
+	estimate := summary.Estimates["batch-test-model"]
+	assert.Equal(t, 2, estimate.Requests)
+	assert.Equal(t, 2000, estimate.CompletionTokens)
+
+	task := &model.Task{TaskID: "batch_hold", UserId: 1, PrivateData: model.TaskPrivateData{TokenId: 1, BillingSource: BillingSourceWallet}}
+	billing := &batchBilling{groupRatio: 1, usage: make(map[string]*batchModelUsage)}
+	hold := estimateBatchHold(billing, summary)
+	assert.Greater(t, hold, 2000) // 至少包含补全上限 2000 × 2 × 0.5
+
+	// 额度不足时拒绝提交
+	apiErr := holdBatchQuota(context.Background(), task, hold-1, hold)
+	require.NotNil(t, apiErr)
+	assert.Equal(t, 0, task.Quota)
+
+	require.Nil(t, holdBatchQuota(context.Background(), task, 10000, hold))
+	assert.Equal(t, hold, task.Quota)
+	userQuota, err := model.GetUserQuota(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, 10000-hold, userQuota)
+
+	// 结算时按实际用量退还多余的预扣额度
+	billing.lineBilling([]byte(`{"model":"batch-test-model","usage":{"prompt_tokens":100,"completion_tokens":50}}`))
+	assert.Equal(t, 100, billing.quota)
+	chargeBatchBilling(context.Background(), task, billing, hold)
+	userQuota, err = model.GetUserQuota(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, 10000-100, userQuota)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/types"
 
@@ -45,6 +47,7 @@ func (s *BillingSession) Settle(actualQuota int) error {
 	delta := actualQuota - s.preConsumedQuota
 	if delta == 0 {
 		s.settled = true
+		s.observeQuota(metrics.BillingStageSettle, actualQuota)
 		return nil
 	}
 	// 1) 调整资金来源（仅在尚未提交时执行，防止重复调用）
@@ -73,6 +76,7 @@ func (s *BillingSession) Settle(actualQuota int) error {
 		s.relayInfo.SubscriptionPostDelta += int64(delta)
 	}
 	s.settled = true
+	s.observeQuota(metrics.BillingStageSettle, actualQuota)
 	return tokenErr
 }
 
@@ -85,6 +89,7 @@ func (s *BillingSession) Refund(c *gin.Context) {
 	}
 	s.refunded = true
 	s.mu.Unlock()
+	s.observeQuota(metrics.BillingStageRefund, s.preConsumedQuota)
 
 	logger.LogInfo(c, fmt.Sprintf("用户 %d 请求失败, 返还预扣费（token_quota=%s, funding=%s）",
 		s.relayInfo.UserId,
@@ -160,6 +165,9 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	// ---- 1) 预扣令牌额度 ----
 	if effectiveQuota > 0 {
 		if err := PreConsumeTokenQuota(s.relayInfo, effectiveQuota); err != nil {
+			if errors.Is(err, model.ErrTokenBudgetExhausted) {
+				return types.NewErrorWithStatusCode(err, types.ErrorCodeTokenBudgetExhausted, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+			}
 			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
 		s.tokenConsumed = effectiveQuota
@@ -175,6 +183,12 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 			}
 			s.tokenConsumed = 0
 		}
+		if errors.Is(err, model.ErrOrganizationQuotaInsufficient) || errors.Is(err, model.ErrOrganizationMemberCapExceeded) {
+			return types.NewErrorWithStatusCode(fmt.Errorf("组织额度不足: %s", err.Error()), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
+		if errors.Is(err, model.ErrOrganizationNotMember) || errors.Is(err, model.ErrOrganizationDisabled) || errors.Is(err, model.ErrOrganizationNotFound) {
+			return types.NewErrorWithStatusCode(fmt.Errorf("组织令牌不可用: %s", err.Error()), types.ErrorCodeAccessDenied, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+		}
 		// TODO: model 层应定义哨兵错误（如 ErrNoActiveSubscription），用 errors.Is 替代字符串匹配
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "no active subscription") || strings.Contains(errMsg, "subscription quota insufficient") {
@@ -184,6 +198,7 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	}
 
 	s.preConsumedQuota = effectiveQuota
+	s.observeQuota(metrics.BillingStagePreConsume, effectiveQuota)
 
 	// ---- 同步 RelayInfo 兼容字段 ----
 	s.syncRelayInfo()
@@ -191,6 +206,11 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	return nil
 }
 
+// observeQuota 记录计费各阶段处理的额度指标
+func (s *BillingSession) observeQuota(stage string, quota int) {
+	metrics.AddBillingQuota(stage, s.funding.Source(), s.relayInfo.OriginModelName, quota)
+}
+
 // shouldTrust 统一信任额度检查，适用于钱包和订阅。
 func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 	// 异步任务（ForcePreConsume=true）必须预扣全额，不允许信任旁路
@@ -209,6 +229,10 @@ func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 		tokenQuota := c.GetInt("token_quota")
 		tokenTrusted = tokenQuota > trustQuota
 	}
+	// 设置了周期预算的令牌需在预扣时校验预算
+	if budgetRemain, ok := c.Get("token_budget_remain"); ok && budgetRemain.(int) <= trustQuota {
+		tokenTrusted = false
+	}
 	if !tokenTrusted {
 		return false
 	}
@@ -222,6 +246,9 @@ func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 		// 2. SubscriptionFunding.PreConsume 忽略参数，始终用 s.amount 预扣
 		// 3. 若信任旁路将 effectiveQuota 设为 0，会导致 preConsumedQuota 与实际订阅预扣不一致
 		return false
+	case BillingSourceOrganization:
+		// 组织钱包不启用信任旁路：成员额度上限只在预扣时校验，结算补扣不再校验
+		return false
 	default:
 		return false
 	}
@@ -311,6 +338,21 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 		return session, nil
 	}
 
+	// 组织令牌只从组织钱包扣费，不受个人计费偏好影响
+	if relayInfo.OrganizationId > 0 {
+		session := &BillingSession{
+			relayInfo: relayInfo,
+			funding: &OrganizationFunding{
+				organizationId: relayInfo.OrganizationId,
+				userId:         relayInfo.UserId,
+			},
+		}
+		if apiErr := session.preConsume(c, preConsumedQuota); apiErr != nil {
+			return nil, apiErr
+		}
+		return session, nil
+	}
+
 	switch pref {
 	case "subscription_only":
 		return trySubscription()
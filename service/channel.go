@@ -32,6 +32,11 @@ func DisableChannel(channelError types.ChannelError, reason string) {
 		subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelError.ChannelName, channelError.ChannelId)
 		content := fmt.Sprintf("通道「%s」（#%d）已被禁用，原因：%s", channelError.ChannelName, channelError.ChannelId, reason)
 		NotifyRootUser(formatNotifyType(channelError.ChannelId, common.ChannelStatusAutoDisabled), subject, content)
+		EmitWebhookEvent(dto.WebhookEventChannelDisabled, 0, map[string]any{
+			"channel_id":   channelError.ChannelId,
+			"channel_name": channelError.ChannelName,
+			"reason":       reason,
+		})
 	}
 }
 
@@ -41,6 +46,10 @@ func EnableChannel(channelId int, usingKey string, channelName string) {
 		subject := fmt.Sprintf("通道「%s」（#%d）已被启用", channelName, channelId)
 		content := fmt.Sprintf("通道「%s」（#%d）已被启用", channelName, channelId)
 		NotifyRootUser(formatNotifyType(channelId, common.ChannelStatusEnabled), subject, content)
+		EmitWebhookEvent(dto.WebhookEventChannelEnabled, 0, map[string]any{
+			"channel_id":   channelId,
+			"channel_name": channelName,
+		})
 	}
 }
 
@@ -101,6 +110,23 @@ func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	return search
 }
 
+// IsChannelHealthFailure 判断错误是否计入渠道健康度的失败次数，客户端请求错误不计入
+func IsChannelHealthFailure(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	if types.IsChannelError(err) {
+		return true
+	}
+	switch err.GetErrorCode() {
+	case types.ErrorCodeDoRequestFailed, types.ErrorCodeReadResponseBodyFailed, types.ErrorCodeBadResponse:
+		return true
+	}
+	return err.StatusCode >= http.StatusInternalServerError ||
+		err.StatusCode == http.StatusTooManyRequests ||
+		err.StatusCode == http.StatusRequestTimeout
+}
+
 func ShouldEnableChannel(newAPIError *types.NewAPIError, status int) bool {
 	if !common.AutomaticEnableChannelEnabled {
 		return false
@@ -11,6 +11,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/pkg/cachex"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -185,7 +186,33 @@ func GetChannelAffinityCacheStats() ChannelAffinityCacheStats {
 	}
 }
 
+func init() {
+	model.RegisterCacheInvalidationHandler(model.CacheInvalidationChannelAffinity, func(message model.CacheInvalidationMessage) {
+		if message.Key == "" {
+			clearChannelAffinityCacheAllLocal()
+			return
+		}
+		if _, err := getChannelAffinityCache().DeleteByPrefix(message.Key); err != nil {
+			common.SysError(fmt.Sprintf("channel affinity cache delete by prefix failed: err=%v", err))
+		}
+	})
+}
+
+// publishChannelAffinityClear 启用 Redis 时缓存由各节点共享，只有内存缓存需要通知其他节点
+func publishChannelAffinityClear(ruleName string) {
+	if common.RedisEnabled {
+		return
+	}
+	model.PublishCacheInvalidation(model.CacheInvalidationMessage{Kind: model.CacheInvalidationChannelAffinity, Key: ruleName})
+}
+
 func ClearChannelAffinityCacheAll() int {
+	deleted := clearChannelAffinityCacheAllLocal()
+	publishChannelAffinityClear("")
+	return deleted
+}
+
+func clearChannelAffinityCacheAllLocal() int {
 	cache := getChannelAffinityCache()
 	keys, err := cache.Keys()
 	if err != nil {
@@ -232,6 +259,7 @@ func ClearChannelAffinityCacheByRuleName(ruleName string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	publishChannelAffinityClear(ruleName)
 	return deleted, nil
 }
 
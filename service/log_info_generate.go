@@ -54,6 +54,15 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	if isSystemPromptOverwritten {
 		other["is_system_prompt_overwritten"] = true
 	}
+	if batchId := common.GetContextKeyString(ctx, constant.ContextKeyBatchId); batchId != "" {
+		other["batch_id"] = batchId
+	}
+	appendTimeWindowInfo(relayInfo.PriceData, other)
+	if tier := relayInfo.PriceData.AppliedTier; tier != nil {
+		// 记录命中的分档，日志中的倍率已是该档位的倍率
+		other["tier_threshold"] = tier.Threshold
+		other["base_model_ratio"] = relayInfo.PriceData.BaseTier.ModelRatio
+	}
 
 	adminInfo := make(map[string]interface{})
 	adminInfo["use_channel"] = ctx.GetStringSlice("use_channel")
@@ -69,6 +78,7 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	}
 
 	AppendChannelAffinityAdminInfo(ctx, adminInfo)
+	appendHedgeInfo(relayInfo, other, adminInfo)
 
 	other["admin_info"] = adminInfo
 	appendRequestPath(ctx, relayInfo, other)
@@ -77,6 +87,29 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	return other
 }
 
+// appendTimeWindowInfo 记录命中的分时价格窗口，日志中的模型倍率与价格已是打折后的值
+func appendTimeWindowInfo(priceData types.PriceData, other map[string]interface{}) {
+	if priceData.TimeWindow == "" {
+		return
+	}
+	other["time_window"] = priceData.TimeWindow
+	other["time_ratio"] = priceData.TimeRatio
+}
+
+// appendHedgeInfo 记录对冲请求：实际发起的渠道及获胜渠道
+func appendHedgeInfo(relayInfo *relaycommon.RelayInfo, other map[string]interface{}, adminInfo map[string]interface{}) {
+	if relayInfo == nil || relayInfo.Hedge == nil {
+		return
+	}
+	channelIds := relayInfo.Hedge.Race().ChannelIds()
+	if len(channelIds) < 2 {
+		return
+	}
+	other["hedged"] = true
+	adminInfo["hedge_channels"] = channelIds
+	adminInfo["hedge_winner_channel"] = relayInfo.Hedge.ChannelId
+}
+
 func appendBillingInfo(relayInfo *relaycommon.RelayInfo, other map[string]interface{}) {
 	if relayInfo == nil || other == nil {
 		return
@@ -211,6 +244,7 @@ func GenerateMjOtherInfo(relayInfo *relaycommon.RelayInfo, priceData types.Price
 	if priceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = priceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	appendTimeWindowInfo(priceData, other)
 	appendRequestPath(nil, relayInfo, other)
 	return other
 }
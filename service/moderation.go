@@ -0,0 +1,464 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	moderationMask = "**###**"
+	// 审核队列中命中位置前后各保留的字符数
+	moderationExcerptRunes = 100
+)
+
+// ModerationHit 单个检查器的命中结果
+type ModerationHit struct {
+	Checker string
+	// 命中的屏蔽词、规则或审核类别
+	Labels []string
+	// 命中内容的字节区间，无法定位时为空（如审核模型），此时不能脱敏
+	Spans [][2]int
+}
+
+// ModerationChecker 内容审核检查器，通过 RegisterModerationChecker 注册后加入审核流水线
+type ModerationChecker interface {
+	Name() string
+	Enabled() bool
+	// Cheap 为 true 的检查器在流式输出的每个事件上执行，否则按累计长度分段执行
+	Cheap() bool
+	// Check 未命中时返回 nil
+	Check(ctx context.Context, text string) (*ModerationHit, error)
+}
+
+// ModerationResult 审核流水线的结果与生效的处理方式
+type ModerationResult struct {
+	Action string
+	Hits   []*ModerationHit
+}
+
+func (r *ModerationResult) Flagged() bool {
+	return r != nil && len(r.Hits) > 0
+}
+
+// Labels 汇总所有命中的标签
+func (r *ModerationResult) Labels() []string {
+	labels := make([]string, 0)
+	if r == nil {
+		return labels
+	}
+	for _, hit := range r.Hits {
+		labels = append(labels, hit.Labels...)
+	}
+	return labels
+}
+
+var (
+	moderationCheckers = []ModerationChecker{
+		&keywordModerationChecker{},
+		&regexModerationChecker{},
+		&modelModerationChecker{},
+	}
+	moderationCheckersLock sync.RWMutex
+)
+
+// RegisterModerationChecker 注册自定义检查器，按注册顺序执行
+func RegisterModerationChecker(checker ModerationChecker) {
+	moderationCheckersLock.Lock()
+	defer moderationCheckersLock.Unlock()
+	moderationCheckers = append(moderationCheckers, checker)
+}
+
+// runModerationCheckers 执行满足 filter 的已启用检查器。检查器出错时记录日志并跳过，不影响请求
+func runModerationCheckers(ctx context.Context, text string, filter func(ModerationChecker) bool) []*ModerationHit {
+	if text == "" {
+		return nil
+	}
+	moderationCheckersLock.RLock()
+	checkers := append([]ModerationChecker(nil), moderationCheckers...)
+	moderationCheckersLock.RUnlock()
+
+	var hits []*ModerationHit
+	for _, checker := range checkers {
+		if !checker.Enabled() || (filter != nil && !filter(checker)) {
+			continue
+		}
+		hit, err := checker.Check(ctx, text)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("moderation checker %s failed: %s", checker.Name(), err.Error()))
+			continue
+		}
+		if hit != nil {
+			if hit.Checker == "" {
+				hit.Checker = checker.Name()
+			}
+			hits = append(hits, hit)
+		}
+	}
+	return hits
+}
+
+// resolveModerationAction 返回用户所在分组的处理方式；命中内容无法定位时 redact 降级为 block
+func resolveModerationAction(c *gin.Context, hits []*ModerationHit) string {
+	action := operation_setting.GetModerationAction(common.GetContextKeyString(c, constant.ContextKeyUsingGroup))
+	if action == operation_setting.ModerationActionRedact {
+		for _, hit := range hits {
+			if len(hit.Spans) == 0 {
+				return operation_setting.ModerationActionBlock
+			}
+		}
+	}
+	return action
+}
+
+// ModeratePrompt 对请求内容执行审核流水线，命中时写入审核队列
+func ModeratePrompt(c *gin.Context, text string) *ModerationResult {
+	hits := runModerationCheckers(c, text, nil)
+	if len(hits) == 0 {
+		return nil
+	}
+	result := &ModerationResult{Action: resolveModerationAction(c, hits), Hits: hits}
+	for _, hit := range hits {
+		recordModerationEvent(c, model.ModerationStagePrompt, hit, result.Action, moderationExcerpt(text, hit))
+	}
+	return result
+}
+
+// RedactPromptBody 将请求体 JSON 中所有字符串值的命中内容替换为掩码，调用方需重新解析请求
+func RedactPromptBody(c *gin.Context) error {
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		return errors.New("only json request body can be redacted")
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return err
+	}
+	var value any
+	if err = common.Unmarshal(body, &value); err != nil {
+		return err
+	}
+	redacted, err := common.Marshal(redactModerationValue(c, "", value))
+	if err != nil {
+		return err
+	}
+	newStorage, err := common.CreateBodyStorage(redacted)
+	if err != nil {
+		return err
+	}
+	_ = storage.Close()
+	c.Set(common.KeyBodyStorage, newStorage)
+	c.Request.Body = io.NopCloser(newStorage)
+	c.Request.ContentLength = newStorage.Size()
+	return nil
+}
+
+func redactModerationValue(ctx context.Context, key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = redactModerationValue(ctx, k, item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = redactModerationValue(ctx, key, item)
+		}
+		return v
+	case string:
+		// 模型名等标识字段不参与脱敏
+		if key == "model" {
+			return v
+		}
+		return RedactModerationText(ctx, v)
+	}
+	return value
+}
+
+// RedactModerationText 使用可定位命中内容的检查器替换文本中的命中部分
+func RedactModerationText(ctx context.Context, text string) string {
+	hits := runModerationCheckers(ctx, text, func(checker ModerationChecker) bool {
+		return checker.Cheap()
+	})
+	var spans [][2]int
+	for _, hit := range hits {
+		spans = append(spans, hit.Spans...)
+	}
+	return maskModerationSpans(text, spans)
+}
+
+// maskModerationSpans 合并重叠区间后用掩码替换
+func maskModerationSpans(text string, spans [][2]int) string {
+	if len(spans) == 0 {
+		return text
+	}
+	sorted := append([][2]int(nil), spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+	var builder strings.Builder
+	builder.Grow(len(text))
+	last := 0
+	for _, span := range sorted {
+		start, end := span[0], span[1]
+		if end <= last {
+			continue
+		}
+		if start < last {
+			start = last
+		}
+		builder.WriteString(text[last:start])
+		builder.WriteString(moderationMask)
+		last = end
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// moderationExcerpt 截取命中位置附近的原文，无法定位时截取开头
+func moderationExcerpt(text string, hit *ModerationHit) string {
+	start, end := 0, 0
+	if len(hit.Spans) > 0 {
+		start, end = hit.Spans[0][0], hit.Spans[0][1]
+	}
+	for i := 0; i < moderationExcerptRunes && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	for i := 0; i < moderationExcerptRunes && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	return text[start:end]
+}
+
+func recordModerationEvent(c *gin.Context, stage string, hit *ModerationHit, action string, excerpt string) {
+	event := &model.ModerationEvent{
+		RequestId: c.GetString(common.RequestIdKey),
+		UserId:    common.GetContextKeyInt(c, constant.ContextKeyUserId),
+		Username:  common.GetContextKeyString(c, constant.ContextKeyUserName),
+		TokenId:   common.GetContextKeyInt(c, constant.ContextKeyTokenId),
+		Group:     common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		ChannelId: common.GetContextKeyInt(c, constant.ContextKeyChannelId),
+		ModelName: common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
+		Stage:     stage,
+		Checker:   hit.Checker,
+		Labels:    strings.Join(hit.Labels, ","),
+		Action:    action,
+		Excerpt:   excerpt,
+	}
+	logger.LogWarn(c, fmt.Sprintf("moderation hit on %s: checker=%s, labels=%s, action=%s", stage, event.Checker, event.Labels, action))
+	gopool.Go(func() {
+		if err := model.RecordModerationEvent(event); err != nil {
+			common.SysError("failed to record moderation event: " + err.Error())
+		}
+	})
+}
+
+// keywordModerationChecker 屏蔽词检查，沿用 SensitiveWords 列表，不区分大小写
+type keywordModerationChecker struct {
+	mu      sync.Mutex
+	key     string
+	pattern *regexp.Regexp
+}
+
+func (k *keywordModerationChecker) Name() string { return "keyword" }
+
+func (k *keywordModerationChecker) Enabled() bool { return len(setting.SensitiveWords) > 0 }
+
+func (k *keywordModerationChecker) Cheap() bool { return true }
+
+func (k *keywordModerationChecker) getPattern() *regexp.Regexp {
+	words := setting.SensitiveWords
+	key := strings.Join(words, "\n")
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pattern != nil && k.key == key {
+		return k.pattern
+	}
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	// 长词优先，避免短词截断匹配
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	k.key = key
+	k.pattern = nil
+	if len(quoted) > 0 {
+		k.pattern = regexp.MustCompile("(?i)(?:" + strings.Join(quoted, "|") + ")")
+	}
+	return k.pattern
+}
+
+func (k *keywordModerationChecker) Check(_ context.Context, text string) (*ModerationHit, error) {
+	pattern := k.getPattern()
+	if pattern == nil {
+		return nil, nil
+	}
+	indexes := pattern.FindAllStringIndex(text, -1)
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	hit := &ModerationHit{Checker: k.Name()}
+	seen := make(map[string]bool)
+	for _, index := range indexes {
+		hit.Spans = append(hit.Spans, [2]int{index[0], index[1]})
+		word := strings.ToLower(text[index[0]:index[1]])
+		if !seen[word] {
+			seen[word] = true
+			hit.Labels = append(hit.Labels, word)
+		}
+	}
+	return hit, nil
+}
+
+// regexModerationChecker 正则规则检查
+type regexModerationChecker struct{}
+
+func (r *regexModerationChecker) Name() string { return "regex" }
+
+func (r *regexModerationChecker) Enabled() bool {
+	return len(operation_setting.GetModerationSetting().RegexRules) > 0
+}
+
+func (r *regexModerationChecker) Cheap() bool { return true }
+
+func (r *regexModerationChecker) Check(_ context.Context, text string) (*ModerationHit, error) {
+	var hit *ModerationHit
+	for _, re := range operation_setting.GetModerationRegexps() {
+		indexes := re.FindAllStringIndex(text, -1)
+		if len(indexes) == 0 {
+			continue
+		}
+		if hit == nil {
+			hit = &ModerationHit{Checker: r.Name()}
+		}
+		hit.Labels = append(hit.Labels, re.String())
+		for _, index := range indexes {
+			hit.Spans = append(hit.Spans, [2]int{index[0], index[1]})
+		}
+	}
+	return hit, nil
+}
+
+// modelModerationChecker 通过内部渠道调用 OpenAI 兼容的 moderations 接口，不计费
+type modelModerationChecker struct{}
+
+func (m *modelModerationChecker) Name() string { return "model" }
+
+func (m *modelModerationChecker) Enabled() bool {
+	s := operation_setting.GetModerationSetting()
+	return s.ModelEnabled && s.ModelName != ""
+}
+
+func (m *modelModerationChecker) Cheap() bool { return false }
+
+func (m *modelModerationChecker) Check(ctx context.Context, text string) (*ModerationHit, error) {
+	s := operation_setting.GetModerationSetting()
+	channel, err := model.GetRandomSatisfiedChannel(s.ModelGroup, s.ModelName, 0)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("no available channel for moderation model %s", s.ModelName)
+	}
+	key, _, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	upstreamModel := s.ModelName
+	if mapping := channel.GetModelMapping(); mapping != "" {
+		modelMap := make(map[string]string)
+		if err := common.UnmarshalJsonStr(mapping, &modelMap); err == nil && modelMap[upstreamModel] != "" {
+			upstreamModel = modelMap[upstreamModel]
+		}
+	}
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" && channel.Type < len(constant.ChannelBaseURLs) {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	body, err := common.Marshal(map[string]any{"model": upstreamModel, "input": text})
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(s.ModelTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/v1/moderations", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	client := GetHttpClient()
+	if proxy := channel.GetSetting().Proxy; proxy != "" {
+		if client, err = NewProxyHttpClient(proxy); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation request failed: status %d, body %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var moderationResp struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err = common.Unmarshal(respBody, &moderationResp); err != nil {
+		return nil, err
+	}
+	var hit *ModerationHit
+	for _, result := range moderationResp.Results {
+		if !result.Flagged {
+			continue
+		}
+		if hit == nil {
+			hit = &ModerationHit{Checker: m.Name()}
+		}
+		for category, flagged := range result.Categories {
+			if flagged {
+				hit.Labels = append(hit.Labels, category)
+			}
+		}
+	}
+	if hit != nil {
+		sort.Strings(hit.Labels)
+	}
+	return hit, nil
+}
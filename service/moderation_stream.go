@@ -0,0 +1,288 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// 保留上一段输出末尾的字节数，用于发现跨事件的命中
+	moderationStreamTailBytes = 64
+	moderationBlockedMessage  = "content blocked by moderation"
+)
+
+var sseEventDelimiter = []byte("\n\n")
+
+// StreamModeration 替换 c.Writer，对流式输出逐个 SSE 事件执行审核：
+// 屏蔽词与正则在每个事件上检查，命中时按分组策略中断、替换或仅记录；
+// 审核模型按累计长度分段异步检查，命中时只能中断后续输出，已输出的内容无法撤回
+type StreamModeration struct {
+	gin.ResponseWriter
+	c           *gin.Context
+	origin      gin.ResponseWriter
+	relayFormat types.RelayFormat
+
+	mu           sync.Mutex
+	wg           sync.WaitGroup
+	pending      []byte
+	text         strings.Builder
+	segmentStart int
+	blocked      bool
+	finished     bool
+}
+
+// BeginStreamModeration 为流式请求安装审核 Writer，调用方需在请求结束时调用 Finish
+func BeginStreamModeration(c *gin.Context, relayFormat types.RelayFormat) *StreamModeration {
+	s := &StreamModeration{
+		ResponseWriter: c.Writer,
+		c:              c,
+		origin:         c.Writer,
+		relayFormat:    relayFormat,
+	}
+	c.Writer = s
+	return s
+}
+
+func (s *StreamModeration) isEventStream() bool {
+	return strings.HasPrefix(s.Header().Get("Content-Type"), "text/event-stream")
+}
+
+func (s *StreamModeration) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.blocked {
+		// 中断后丢弃剩余输出，上游响应照常读完以便正常结算
+		return len(data), nil
+	}
+	if len(s.pending) == 0 && !s.isEventStream() {
+		return s.ResponseWriter.Write(data)
+	}
+	s.pending = append(s.pending, data...)
+	for !s.blocked {
+		idx := bytes.Index(s.pending, sseEventDelimiter)
+		if idx < 0 {
+			break
+		}
+		event := append([]byte(nil), s.pending[:idx+len(sseEventDelimiter)]...)
+		s.pending = s.pending[idx+len(sseEventDelimiter):]
+		if _, err := s.ResponseWriter.Write(s.processEvent(event)); err != nil {
+			return 0, err
+		}
+	}
+	if s.blocked {
+		s.pending = nil
+	}
+	return len(data), nil
+}
+
+func (s *StreamModeration) WriteString(str string) (int, error) {
+	return s.Write([]byte(str))
+}
+
+func (s *StreamModeration) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ResponseWriter.Flush()
+}
+
+// processEvent 检查事件中的输出文本，返回实际写出的事件内容
+func (s *StreamModeration) processEvent(event []byte) []byte {
+	lines := strings.Split(string(event), "\n")
+	dataIndex := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "data:") {
+			dataIndex = i
+		}
+	}
+	if dataIndex < 0 {
+		return event
+	}
+	data := strings.TrimSpace(strings.TrimPrefix(lines[dataIndex], "data:"))
+	if data == "" || !gjson.Valid(data) {
+		return event
+	}
+	paths := streamDeltaTextPaths(data)
+	if len(paths) == 0 {
+		return event
+	}
+	parts := make([]string, len(paths))
+	for i, path := range paths {
+		parts[i] = gjson.Get(data, path).String()
+	}
+	delta := strings.Join(parts, "")
+
+	full := s.text.String()
+	tail := full[moderationTailStart(full):]
+	window := tail + delta
+	hits := s.newHits(runModerationCheckers(s.c, window, func(checker ModerationChecker) bool {
+		return checker.Cheap()
+	}), len(tail))
+	if len(hits) > 0 {
+		action := resolveModerationAction(s.c, hits)
+		for _, hit := range hits {
+			recordModerationEvent(s.c, model.ModerationStageCompletion, hit, action, moderationExcerpt(window, hit))
+		}
+		switch action {
+		case operation_setting.ModerationActionBlock:
+			s.block()
+			return nil
+		case operation_setting.ModerationActionRedact:
+			parts = maskModerationParts(parts, hits, len(tail))
+			for i, path := range paths {
+				if updated, err := sjson.Set(data, path, parts[i]); err == nil {
+					data = updated
+				}
+			}
+			lines[dataIndex] = "data: " + data
+			event = []byte(strings.Join(lines, "\n"))
+			delta = strings.Join(parts, "")
+		}
+	}
+
+	s.text.WriteString(delta)
+	s.maybeCheckSegment(false)
+	return event
+}
+
+// newHits 只保留结束位置落在本次输出内的命中，之前的部分已在上一个事件中处理
+func (s *StreamModeration) newHits(hits []*ModerationHit, tailLen int) []*ModerationHit {
+	result := make([]*ModerationHit, 0, len(hits))
+	for _, hit := range hits {
+		spans := make([][2]int, 0, len(hit.Spans))
+		for _, span := range hit.Spans {
+			if span[1] > tailLen {
+				spans = append(spans, span)
+			}
+		}
+		if len(spans) > 0 {
+			result = append(result, &ModerationHit{Checker: hit.Checker, Labels: hit.Labels, Spans: spans})
+		}
+	}
+	return result
+}
+
+// maskModerationParts 将窗口坐标下的命中区间映射到各文本片段并替换，跨事件命中只替换本次输出的部分
+func maskModerationParts(parts []string, hits []*ModerationHit, tailLen int) []string {
+	offset := 0
+	result := make([]string, len(parts))
+	for i, part := range parts {
+		var spans [][2]int
+		for _, hit := range hits {
+			for _, span := range hit.Spans {
+				start, end := span[0]-tailLen-offset, span[1]-tailLen-offset
+				if end <= 0 || start >= len(part) {
+					continue
+				}
+				spans = append(spans, [2]int{max(start, 0), min(end, len(part))})
+			}
+		}
+		result[i] = maskModerationSpans(part, spans)
+		offset += len(part)
+	}
+	return result
+}
+
+func moderationTailStart(text string) int {
+	start := len(text) - moderationStreamTailBytes
+	if start <= 0 {
+		return 0
+	}
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	return start
+}
+
+// maybeCheckSegment 累计长度达到阈值或输出结束时，将未检查的部分异步送审核模型
+func (s *StreamModeration) maybeCheckSegment(final bool) {
+	if !(&modelModerationChecker{}).Enabled() {
+		return
+	}
+	full := s.text.String()
+	segment := full[s.segmentStart:]
+	threshold := operation_setting.GetModerationSetting().ModelCheckChars
+	if segment == "" || (!final && utf8.RuneCountInString(segment) < threshold) {
+		return
+	}
+	s.segmentStart = len(full)
+	s.wg.Add(1)
+	gopool.Go(func() {
+		defer s.wg.Done()
+		hits := runModerationCheckers(s.c, segment, func(checker ModerationChecker) bool {
+			return !checker.Cheap()
+		})
+		if len(hits) == 0 {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		action := resolveModerationAction(s.c, hits)
+		if s.finished || s.blocked {
+			// 输出已结束，只能记录
+			action = operation_setting.ModerationActionFlag
+		}
+		for _, hit := range hits {
+			recordModerationEvent(s.c, model.ModerationStageCompletion, hit, action, moderationExcerpt(segment, hit))
+		}
+		if action == operation_setting.ModerationActionBlock {
+			s.block()
+			s.ResponseWriter.Flush()
+		}
+	})
+}
+
+// block 写出审核拦截的错误事件并丢弃之后的输出，调用方需持有锁
+func (s *StreamModeration) block() {
+	s.blocked = true
+	s.pending = nil
+	newAPIError := types.NewErrorWithStatusCode(fmt.Errorf("%s", moderationBlockedMessage), types.ErrorCodeSensitiveWordsDetected, 400)
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), s.c.GetString(common.RequestIdKey)))
+	var payload []byte
+	switch s.relayFormat {
+	case types.RelayFormatClaude:
+		payload, _ = common.Marshal(gin.H{"type": "error", "error": newAPIError.ToClaudeError()})
+		_, _ = s.ResponseWriter.Write([]byte("event: error\ndata: " + string(payload) + "\n\n"))
+		return
+	case types.RelayFormatOpenAIResponses:
+		payload, _ = common.Marshal(gin.H{"type": "error", "code": string(types.ErrorCodeSensitiveWordsDetected), "message": newAPIError.Error()})
+		_, _ = s.ResponseWriter.Write([]byte("event: error\ndata: " + string(payload) + "\n\n"))
+		return
+	}
+	payload, _ = common.Marshal(gin.H{"error": newAPIError.ToOpenAIError()})
+	_, _ = s.ResponseWriter.Write([]byte("data: " + string(payload) + "\n\n"))
+	if s.relayFormat == types.RelayFormatOpenAI {
+		_, _ = s.ResponseWriter.Write([]byte("data: [DONE]\n\n"))
+	}
+}
+
+// Finish 写出剩余内容并等待审核模型检查完成后恢复原 Writer
+func (s *StreamModeration) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if len(s.pending) > 0 && !s.blocked {
+		_, _ = s.ResponseWriter.Write(s.processEvent(s.pending))
+	}
+	s.pending = nil
+	s.finished = true
+	s.maybeCheckSegment(true)
+	s.mu.Unlock()
+	s.wg.Wait()
+	if s.c.Writer == s {
+		s.c.Writer = s.origin
+	}
+}
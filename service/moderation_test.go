@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func setupModerationTest(t *testing.T, action string) {
+	t.Helper()
+	require.NoError(t, model.DB.AutoMigrate(&model.ModerationEvent{}))
+	words := setting.SensitiveWords
+	moderation := *operation_setting.GetModerationSetting()
+	setting.SensitiveWords = []string{"badword"}
+	operation_setting.GetModerationSetting().RegexRules = []string{`\d{3}-\d{4}`}
+	operation_setting.GetModerationSetting().ModelEnabled = false
+	operation_setting.GetModerationSetting().GroupActions = map[string]string{"vip": action}
+	t.Cleanup(func() {
+		setting.SensitiveWords = words
+		*operation_setting.GetModerationSetting() = moderation
+		model.DB.Exec("DELETE FROM moderation_events")
+	})
+}
+
+func newModerationStreamContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, "vip")
+	common.SetContextKey(c, constant.ContextKeyUserId, 7)
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	return c, recorder
+}
+
+func writeChatDelta(t *testing.T, c *gin.Context, content string) {
+	t.Helper()
+	data, err := common.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]any{"content": content}}}})
+	require.NoError(t, err)
+	_, err = c.Writer.WriteString("data: " + string(data) + "\n\n")
+	require.NoError(t, err)
+}
+
+func TestRedactModerationText(t *testing.T) {
+	setupModerationTest(t, operation_setting.ModerationActionRedact)
+	require.Equal(t, "a **###** and **###**.", RedactModerationText(context.Background(), "a BadWord and 555-1234."))
+}
+
+func TestStreamModerationRedactAcrossEvents(t *testing.T) {
+	setupModerationTest(t, operation_setting.ModerationActionRedact)
+	c, recorder := newModerationStreamContext()
+	moderation := BeginStreamModeration(c, types.RelayFormatOpenAI)
+	writeChatDelta(t, c, "hello bad")
+	writeChatDelta(t, c, "word there")
+	moderation.Finish()
+
+	body := recorder.Body.String()
+	require.Contains(t, body, "hello bad")
+	require.Contains(t, body, "**###** there")
+	require.NotContains(t, body, "word there")
+	require.Eventually(t, func() bool {
+		var count int64
+		model.DB.Model(&model.ModerationEvent{}).Where("stage = ? AND action = ?", model.ModerationStageCompletion, operation_setting.ModerationActionRedact).Count(&count)
+		return count == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStreamModerationBlock(t *testing.T) {
+	setupModerationTest(t, operation_setting.ModerationActionBlock)
+	c, recorder := newModerationStreamContext()
+	moderation := BeginStreamModeration(c, types.RelayFormatOpenAI)
+	writeChatDelta(t, c, "fine")
+	writeChatDelta(t, c, "call 555-1234")
+	writeChatDelta(t, c, "after block")
+	moderation.Finish()
+
+	body := recorder.Body.String()
+	require.Contains(t, body, "fine")
+	require.Contains(t, body, moderationBlockedMessage)
+	require.NotContains(t, body, "555-1234")
+	require.NotContains(t, body, "after block")
+	require.Same(t, moderation.origin, c.Writer)
+}
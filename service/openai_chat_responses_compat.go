@@ -16,3 +16,17 @@ func ResponsesResponseToChatCompletionsResponse(resp *dto.OpenAIResponsesRespons
 func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
 	return openaicompat.ExtractOutputTextFromResponses(resp)
 }
+
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	return openaicompat.ResponsesRequestToChatCompletionsRequest(req)
+}
+
+func ChatCompletionsResponseToResponsesResponse(resp *dto.OpenAITextResponse, usage *dto.Usage, id string) (*dto.OpenAIResponsesResponse, error) {
+	return openaicompat.ChatCompletionsResponseToResponsesResponse(resp, usage, id)
+}
+
+type ChatToResponsesStreamConverter = openaicompat.ChatToResponsesStreamConverter
+
+func NewChatToResponsesStreamConverter(responseID string, model string, createdAt int) *ChatToResponsesStreamConverter {
+	return openaicompat.NewChatToResponsesStreamConverter(responseID, model, createdAt)
+}
@@ -0,0 +1,481 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// 支持 OpenAI Files API 的渠道类型
+var fileRelayChannelTypes = map[int]bool{
+	constant.ChannelTypeOpenAI: true,
+	constant.ChannelTypeAzure:  true,
+}
+
+const defaultAzureFileApiVersion = "2024-10-21"
+
+// gatewayFileIdPattern 匹配网关生成的 file-xxxx ID（见 model.GenerateFileId）
+var gatewayFileIdPattern = regexp.MustCompile(`\bfile-[0-9A-Za-z]{32}\b`)
+
+func IsFileRelaySupportedChannel(channelType int) bool {
+	return fileRelayChannelTypes[channelType]
+}
+
+// FileUploadMeta 上传请求中网关关心的字段
+type FileUploadMeta struct {
+	Purpose  string
+	Filename string
+	Model    string
+	Bytes    int64
+}
+
+// ParseFileUploadMeta 流式扫描 multipart 请求体，读取 purpose/model 字段以及文件名和大小，
+// 文件内容只计数不驻留内存，便于配合 BodyStorage 的磁盘缓存处理大文件
+func ParseFileUploadMeta(storage common.BodyStorage, contentType string) (*FileUploadMeta, error) {
+	reader, err := newMultipartReader(storage, contentType)
+	if err != nil {
+		return nil, err
+	}
+	meta := &FileUploadMeta{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+		switch part.FormName() {
+		case "file":
+			meta.Filename = part.FileName()
+			meta.Bytes, err = io.Copy(io.Discard, part)
+		case "purpose":
+			meta.Purpose, err = readFormValue(part)
+		case "model":
+			meta.Model, err = readFormValue(part)
+		}
+		_ = part.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+	}
+	if meta.Filename == "" {
+		return nil, errors.New("file is required")
+	}
+	if meta.Purpose == "" {
+		return nil, errors.New("purpose is required")
+	}
+	return meta, nil
+}
+
+func newMultipartReader(storage common.BodyStorage, contentType string) (*multipart.Reader, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart boundary not found")
+	}
+	if _, err := storage.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return multipart.NewReader(storage, boundary), nil
+}
+
+func readFormValue(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, 1024))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// SelectFileChannel 为文件上传选择一个支持 Files API 的渠道
+// 模型名仅用于按分组能力挑选渠道，优先使用请求中的 model 字段
+func SelectFileChannel(c *gin.Context, modelName string) (*model.Channel, error) {
+	if specificChannelId := c.GetString("specific_channel_id"); specificChannelId != "" {
+		id, err := strconv.Atoi(specificChannelId)
+		if err != nil {
+			return nil, errors.New("invalid channel id")
+		}
+		channel, err := model.CacheGetChannel(id)
+		if err != nil {
+			return nil, err
+		}
+		if channel.Status != common.ChannelStatusEnabled {
+			return nil, errors.New("channel is disabled")
+		}
+		if !IsFileRelaySupportedChannel(channel.Type) {
+			return nil, errors.New("channel does not support files api")
+		}
+		return channel, nil
+	}
+
+	if modelName == "" {
+		modelName = operation_setting.GetFileSetting().DefaultModel
+	}
+	param := &RetryParam{
+		Ctx:        c,
+		TokenGroup: common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		ModelName:  modelName,
+		Retry:      common.GetPointer(0),
+	}
+	for ; param.GetRetry() <= common.RetryTimes; param.IncreaseRetry() {
+		channel, _, err := CacheGetRandomSatisfiedChannel(param)
+		if err != nil {
+			return nil, err
+		}
+		if channel == nil {
+			break
+		}
+		if IsFileRelaySupportedChannel(channel.Type) {
+			return channel, nil
+		}
+	}
+	return nil, fmt.Errorf("no channel supporting files api is available for model %s", modelName)
+}
+
+func fileRequestURL(channel *model.Channel, path string) string {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if channel.Type == constant.ChannelTypeAzure {
+		apiVersion := channel.Other
+		if apiVersion == "" {
+			apiVersion = defaultAzureFileApiVersion
+		}
+		return fmt.Sprintf("%s/openai%s?api-version=%s", baseURL, path, apiVersion)
+	}
+	return baseURL + "/v1" + path
+}
+
+func fileChannelKey(channel *model.Channel, keyIndex int) (string, error) {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key, nil
+	}
+	keys := channel.GetKeys()
+	if keyIndex < 0 || keyIndex >= len(keys) {
+		return "", errors.New("channel key for file not found")
+	}
+	return keys[keyIndex], nil
+}
+
+// DoFileRequest 以文件所在渠道（及多密钥下标）的身份向上游 Files / Batch API 发起请求
+func DoFileRequest(ctx context.Context, channel *model.Channel, keyIndex int, method string, path string, body io.Reader, contentType string) (*http.Response, error) {
+	key, err := fileChannelKey(channel, keyIndex)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fileRequestURL(channel, path), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if channel.Type == constant.ChannelTypeAzure {
+		req.Header.Set("api-key", key)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+key)
+		if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+			req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+		}
+	}
+	client, err := GetHttpClientWithProxy(channel.GetSetting().Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// rebuildUploadBody 通过管道重新组装 multipart 请求体并去掉网关私有的 model 字段，
+// 文件内容从 BodyStorage 流式拷贝到上游
+func rebuildUploadBody(storage common.BodyStorage, contentType string) (io.Reader, string, error) {
+	reader, err := newMultipartReader(storage, contentType)
+	if err != nil {
+		return nil, "", err
+	}
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		var copyErr error
+		defer func() {
+			if copyErr == nil {
+				copyErr = writer.Close()
+			}
+			_ = pw.CloseWithError(copyErr)
+		}()
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				copyErr = err
+				return
+			}
+			if part.FormName() == "model" {
+				_ = part.Close()
+				continue
+			}
+			dst, err := writer.CreatePart(part.Header)
+			if err != nil {
+				copyErr = err
+				return
+			}
+			if _, err = io.Copy(dst, part); err != nil {
+				copyErr = err
+				return
+			}
+			_ = part.Close()
+		}
+	}()
+	return pr, writer.FormDataContentType(), nil
+}
+
+// UploadFileToChannel 将上传请求转发到渠道并记录网关文件
+func UploadFileToChannel(c *gin.Context, channel *model.Channel, storage common.BodyStorage, meta *FileUploadMeta) (*model.File, *types.NewAPIError) {
+	_, keyIndex, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	body, contentType, err := rebuildUploadBody(storage, c.Request.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	resp, err := DoFileRequest(c.Request.Context(), channel, keyIndex, http.MethodPost, "/files", body, contentType)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	defer CloseResponseBodyGracefully(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, RelayErrorHandler(c.Request.Context(), resp, false)
+	}
+	var upstreamFile dto.OpenAIFile
+	if err := common.DecodeJson(resp.Body, &upstreamFile); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	if upstreamFile.ID == "" {
+		return nil, types.NewError(errors.New("upstream returned empty file id"), types.ErrorCodeBadResponseBody)
+	}
+
+	bytesSize := upstreamFile.Bytes
+	if bytesSize == 0 {
+		bytesSize = meta.Bytes
+	}
+	file := &model.File{
+		FileId:         model.GenerateFileId(),
+		UserId:         c.GetInt("id"),
+		TokenId:        c.GetInt("token_id"),
+		ChannelId:      channel.Id,
+		KeyIndex:       keyIndex,
+		UpstreamFileId: upstreamFile.ID,
+		Filename:       common.GetStringIfEmpty(upstreamFile.Filename, meta.Filename),
+		Purpose:        common.GetStringIfEmpty(upstreamFile.Purpose, meta.Purpose),
+		Bytes:          bytesSize,
+		Status:         upstreamFile.Status,
+		ExpiresAt:      upstreamFile.ExpiresAt,
+	}
+	if err := file.Insert(); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError)
+	}
+	return file, nil
+}
+
+// FileToOpenAIFile 转换为对外的 OpenAI 文件对象（隐藏上游 ID）
+func FileToOpenAIFile(file *model.File) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		ID:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		ExpiresAt: file.ExpiresAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
+
+// ResolveGatewayFileIds 将 JSON 请求体中引用的网关文件 ID 替换为上游文件 ID。
+// 被引用的文件必须位于同一渠道，返回其中一个文件用于固定渠道与密钥；未引用网关文件时返回 nil
+func ResolveGatewayFileIds(c *gin.Context) (*model.File, error) {
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		return nil, nil
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(body, []byte("file-")) {
+		return nil, nil
+	}
+	matches := gatewayFileIdPattern.FindAll(body, -1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	fileIds := make([]string, 0, len(matches))
+	seen := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		id := string(m)
+		if !seen[id] {
+			seen[id] = true
+			fileIds = append(fileIds, id)
+		}
+	}
+	files, err := model.GetUserFilesByFileIds(c.GetInt("id"), fileIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	// 网关本地文件不存在于上游，保持原样
+	files = lo.Filter(files, func(file *model.File, _ int) bool {
+		return !file.IsLocal()
+	})
+	if len(files) == 0 {
+		return nil, nil
+	}
+	pinned := files[0]
+	replacements := make(map[string]string, len(files))
+	for _, file := range files {
+		if file.ChannelId != pinned.ChannelId || file.KeyIndex != pinned.KeyIndex {
+			return nil, errors.New("referenced files belong to different upstream channels")
+		}
+		replacements[file.FileId] = file.UpstreamFileId
+	}
+	rewritten := gatewayFileIdPattern.ReplaceAllFunc(body, func(m []byte) []byte {
+		if upstreamId, ok := replacements[string(m)]; ok {
+			return []byte(upstreamId)
+		}
+		return m
+	})
+	newStorage, err := common.CreateBodyStorage(rewritten)
+	if err != nil {
+		return nil, err
+	}
+	_ = storage.Close()
+	c.Set(common.KeyBodyStorage, newStorage)
+	c.Request.Body = io.NopCloser(newStorage)
+	c.Request.ContentLength = newStorage.Size()
+	return pinned, nil
+}
+
+func localFilePath(fileId string) string {
+	return filepath.Join(constant.FileStoragePath, fileId+".jsonl")
+}
+
+// CreateLocalFile 将内容写入本地存储并登记为网关文件，用于网关自行生成的文件（如批处理结果）
+func CreateLocalFile(userId int, tokenId int, filename string, purpose string, write func(w io.Writer) error) (*model.File, error) {
+	if err := os.MkdirAll(constant.FileStoragePath, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(constant.FileStoragePath, "tmp-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+	writer := bufio.NewWriter(f)
+	err = write(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	file, err := RegisterLocalFile(userId, tokenId, path, filename, purpose)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	return file, nil
+}
+
+// RegisterLocalFile 将已写好的本地文件移动到文件存储目录并登记为网关文件
+func RegisterLocalFile(userId int, tokenId int, srcPath string, filename string, purpose string) (*model.File, error) {
+	fileId := model.GenerateFileId()
+	path := localFilePath(fileId)
+	if err := os.Rename(srcPath, path); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &model.File{
+		FileId:    fileId,
+		UserId:    userId,
+		TokenId:   tokenId,
+		LocalPath: path,
+		Filename:  filename,
+		Purpose:   purpose,
+		Bytes:     info.Size(),
+		Status:    "processed",
+	}
+	if err := file.Insert(); err != nil {
+		_ = os.Rename(path, srcPath)
+		return nil, err
+	}
+	return file, nil
+}
+
+// OpenFileContent 打开网关文件的内容，本地文件直接读取，上游文件通过所在渠道下载
+func OpenFileContent(ctx context.Context, file *model.File) (io.ReadCloser, error) {
+	if file.IsLocal() {
+		return os.Open(file.LocalPath)
+	}
+	channel, err := model.CacheGetChannel(file.ChannelId)
+	if err != nil {
+		return nil, fmt.Errorf("channel of file %s not found", file.FileId)
+	}
+	resp, err := DoFileRequest(ctx, channel, file.KeyIndex, http.MethodGet, "/files/"+file.UpstreamFileId+"/content", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		newAPIError := RelayErrorHandler(ctx, resp, false)
+		CloseResponseBodyGracefully(resp)
+		return nil, newAPIError
+	}
+	return resp.Body, nil
+}
+
+// DeleteLocalFile 删除本地文件内容及其记录
+func DeleteLocalFile(file *model.File) error {
+	if err := os.Remove(file.LocalPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return file.Delete()
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildUploadBody(t *testing.T, fields map[string]string, filename string, content []byte) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		require.NoError(t, writer.WriteField(k, v))
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes(), writer.FormDataContentType()
+}
+
+func TestParseFileUploadMeta(t *testing.T) {
+	body, contentType := buildUploadBody(t, map[string]string{"purpose": "batch", "model": "gpt-4o"}, "input.jsonl", []byte("{\"a\":1}\n"))
+	storage, err := common.CreateBodyStorage(body)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	meta, err := ParseFileUploadMeta(storage, contentType)
+	require.NoError(t, err)
+	assert.Equal(t, "batch", meta.Purpose)
+	assert.Equal(t, "gpt-4o", meta.Model)
+	assert.Equal(t, "input.jsonl", meta.Filename)
+	assert.EqualValues(t, 8, meta.Bytes)
+}
+
+func TestParseFileUploadMeta_RequiresPurpose(t *testing.T) {
+	body, contentType := buildUploadBody(t, nil, "input.jsonl", []byte("x"))
+	storage, err := common.CreateBodyStorage(body)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = ParseFileUploadMeta(storage, contentType)
+	assert.Error(t, err)
+}
+
+func TestRebuildUploadBody_DropsModelField(t *testing.T) {
+	body, contentType := buildUploadBody(t, map[string]string{"purpose": "assistants", "model": "gpt-4o"}, "doc.txt", []byte("hello"))
+	storage, err := common.CreateBodyStorage(body)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	rebuilt, newContentType, err := rebuildUploadBody(storage, contentType)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rebuilt)
+	require.NoError(t, err)
+
+	rebuiltStorage, err := common.CreateBodyStorage(data)
+	require.NoError(t, err)
+	defer rebuiltStorage.Close()
+	meta, err := ParseFileUploadMeta(rebuiltStorage, newContentType)
+	require.NoError(t, err)
+	assert.Equal(t, "assistants", meta.Purpose)
+	assert.Equal(t, "", meta.Model)
+	assert.Equal(t, "doc.txt", meta.Filename)
+	assert.EqualValues(t, 5, meta.Bytes)
+}
+
+func newJSONContext(t *testing.T, userId int, body string) *gin.Context {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("id", userId)
+	t.Cleanup(func() { common.CleanupBodyStorage(c) })
+	return c
+}
+
+func TestResolveGatewayFileIds(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM files") })
+	file := &model.File{
+		FileId:         model.GenerateFileId(),
+		UserId:         7,
+		ChannelId:      3,
+		UpstreamFileId: "file-upstream123",
+		Purpose:        "user_data",
+	}
+	require.NoError(t, file.Insert())
+
+	c := newJSONContext(t, 7, `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"file","file":{"file_id":"`+file.FileId+`"}}]}]}`)
+	pinned, err := ResolveGatewayFileIds(c)
+	require.NoError(t, err)
+	require.NotNil(t, pinned)
+	assert.Equal(t, 3, pinned.ChannelId)
+
+	storage, err := common.GetBodyStorage(c)
+	require.NoError(t, err)
+	body, err := storage.Bytes()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "file-upstream123")
+	assert.NotContains(t, string(body), file.FileId)
+}
+
+func TestResolveGatewayFileIds_IgnoresOtherUsersFiles(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM files") })
+	file := &model.File{
+		FileId:         model.GenerateFileId(),
+		UserId:         7,
+		ChannelId:      3,
+		UpstreamFileId: "file-upstream123",
+	}
+	require.NoError(t, file.Insert())
+
+	c := newJSONContext(t, 8, `{"input_file_id":"`+file.FileId+`"}`)
+	pinned, err := ResolveGatewayFileIds(c)
+	require.NoError(t, err)
+	assert.Nil(t, pinned)
+}
+
+func TestResolveGatewayFileIds_RejectsMixedChannels(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM files") })
+	first := &model.File{FileId: model.GenerateFileId(), UserId: 7, ChannelId: 3, UpstreamFileId: "file-a"}
+	second := &model.File{FileId: model.GenerateFileId(), UserId: 7, ChannelId: 4, UpstreamFileId: "file-b"}
+	require.NoError(t, first.Insert())
+	require.NoError(t, second.Insert())
+
+	c := newJSONContext(t, 7, `{"ids":["`+first.FileId+`","`+second.FileId+`"]}`)
+	_, err := ResolveGatewayFileIds(c)
+	assert.Error(t, err)
+}
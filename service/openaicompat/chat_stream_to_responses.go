@@ -0,0 +1,315 @@
+package openaicompat
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ChatToResponsesStreamConverter 将 Chat Completions 流式分片转换为 Responses 流式事件，
+// 依次输出 reasoning、message（output_text）与 function_call 条目。非并发安全，每个请求使用一个实例
+type ChatToResponsesStreamConverter struct {
+	ResponseID string
+	Model      string
+	CreatedAt  int
+
+	started      bool
+	finishReason string
+	usage        *dto.Usage
+	output       []dto.ResponsesOutput
+
+	// 当前正在输出的 reasoning 与 message 条目在 output 中的位置，-1 表示没有
+	reasoningIndex int
+	messageIndex   int
+	reasoningText  strings.Builder
+	messageText    strings.Builder
+	// 按 Chat 分片中 tool_calls 的 index 记录对应的 function_call 条目
+	toolCalls map[int]*chatToResponsesToolCall
+}
+
+type chatToResponsesToolCall struct {
+	outputIndex int
+	arguments   strings.Builder
+}
+
+func NewChatToResponsesStreamConverter(responseID string, model string, createdAt int) *ChatToResponsesStreamConverter {
+	return &ChatToResponsesStreamConverter{
+		ResponseID:     responseID,
+		Model:          model,
+		CreatedAt:      createdAt,
+		reasoningIndex: -1,
+		messageIndex:   -1,
+		toolCalls:      make(map[int]*chatToResponsesToolCall),
+	}
+}
+
+// Start 返回 response.created 与 response.in_progress 事件，只在首次调用时返回
+func (s *ChatToResponsesStreamConverter) Start() []dto.ResponsesStreamResponse {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []dto.ResponsesStreamResponse{
+		{Type: "response.created", Response: s.response("in_progress", nil)},
+		{Type: "response.in_progress", Response: s.response("in_progress", nil)},
+	}
+}
+
+// ConvertChunk 转换一个 Chat Completions 流式分片，返回需要发送的 Responses 事件
+func (s *ChatToResponsesStreamConverter) ConvertChunk(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	events := s.Start()
+	if chunk == nil {
+		return events
+	}
+	if chunk.Model != "" && s.Model == "" {
+		s.Model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		delta := choice.Delta
+		if reasoning := delta.GetReasoningContent(); reasoning != "" {
+			events = append(events, s.appendReasoning(reasoning)...)
+		}
+		if content := delta.GetContentString(); content != "" {
+			events = append(events, s.appendText(content)...)
+		}
+		for _, toolCall := range delta.ToolCalls {
+			events = append(events, s.appendToolCall(toolCall)...)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Finish 结束所有未完成的条目并返回 response.completed（或 response.incomplete）事件，
+// usage 为空时使用流中最后一次出现的 usage
+func (s *ChatToResponsesStreamConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	events := s.Start()
+	events = append(events, s.closeReasoning()...)
+	events = append(events, s.closeText()...)
+	events = append(events, s.closeToolCalls()...)
+	if usage == nil {
+		usage = s.usage
+	}
+	if s.finishReason == "length" {
+		return append(events, dto.ResponsesStreamResponse{Type: "response.incomplete", Response: s.response("incomplete", usage)})
+	}
+	return append(events, dto.ResponsesStreamResponse{Type: "response.completed", Response: s.response("completed", usage)})
+}
+
+func (s *ChatToResponsesStreamConverter) response(status string, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	output := make([]dto.ResponsesOutput, len(s.output))
+	copy(output, s.output)
+	return newResponsesResponse(s.ResponseID, s.Model, s.CreatedAt, status, output, ChatUsageToResponsesUsage(usage))
+}
+
+func (s *ChatToResponsesStreamConverter) addItem(item dto.ResponsesOutput) (int, dto.ResponsesStreamResponse) {
+	index := len(s.output)
+	s.output = append(s.output, item)
+	return index, dto.ResponsesStreamResponse{
+		Type:        dto.ResponsesOutputTypeItemAdded,
+		OutputIndex: common.GetPointer(index),
+		Item:        &item,
+	}
+}
+
+func (s *ChatToResponsesStreamConverter) itemDone(index int) dto.ResponsesStreamResponse {
+	s.output[index].Status = "completed"
+	item := s.output[index]
+	return dto.ResponsesStreamResponse{
+		Type:        dto.ResponsesOutputTypeItemDone,
+		OutputIndex: common.GetPointer(index),
+		Item:        &item,
+	}
+}
+
+func (s *ChatToResponsesStreamConverter) appendReasoning(delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.reasoningIndex < 0 {
+		events = append(events, s.closeText()...)
+		index, added := s.addItem(dto.ResponsesOutput{
+			Type:    "reasoning",
+			ID:      responsesItemID("rs", s.ResponseID, len(s.output)),
+			Status:  "in_progress",
+			Summary: []dto.ResponsesReasoningSummaryPart{},
+		})
+		s.reasoningIndex = index
+		s.reasoningText.Reset()
+		events = append(events, added, dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_part.added",
+			ItemID:       s.output[index].ID,
+			OutputIndex:  common.GetPointer(index),
+			SummaryIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "summary_text"},
+		})
+	}
+	s.reasoningText.WriteString(delta)
+	return append(events, dto.ResponsesStreamResponse{
+		Type:         "response.reasoning_summary_text.delta",
+		ItemID:       s.output[s.reasoningIndex].ID,
+		OutputIndex:  common.GetPointer(s.reasoningIndex),
+		SummaryIndex: common.GetPointer(0),
+		Delta:        delta,
+	})
+}
+
+func (s *ChatToResponsesStreamConverter) closeReasoning() []dto.ResponsesStreamResponse {
+	if s.reasoningIndex < 0 {
+		return nil
+	}
+	index := s.reasoningIndex
+	s.reasoningIndex = -1
+	text := s.reasoningText.String()
+	part := dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: text}
+	s.output[index].Summary = []dto.ResponsesReasoningSummaryPart{part}
+	itemID := s.output[index].ID
+	return []dto.ResponsesStreamResponse{
+		{
+			Type:         "response.reasoning_summary_text.done",
+			ItemID:       itemID,
+			OutputIndex:  common.GetPointer(index),
+			SummaryIndex: common.GetPointer(0),
+			Text:         text,
+		},
+		{
+			Type:         "response.reasoning_summary_part.done",
+			ItemID:       itemID,
+			OutputIndex:  common.GetPointer(index),
+			SummaryIndex: common.GetPointer(0),
+			Part:         &part,
+		},
+		s.itemDone(index),
+	}
+}
+
+func (s *ChatToResponsesStreamConverter) appendText(delta string) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	if s.messageIndex < 0 {
+		events = append(events, s.closeReasoning()...)
+		item := newResponsesMessageItem(responsesItemID("msg", s.ResponseID, len(s.output)), "", "in_progress")
+		item.Content = []dto.ResponsesOutputContent{}
+		index, added := s.addItem(item)
+		s.messageIndex = index
+		s.messageText.Reset()
+		events = append(events, added, dto.ResponsesStreamResponse{
+			Type:         "response.content_part.added",
+			ItemID:       item.ID,
+			OutputIndex:  common.GetPointer(index),
+			ContentIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "output_text"},
+		})
+	}
+	s.messageText.WriteString(delta)
+	return append(events, dto.ResponsesStreamResponse{
+		Type:         "response.output_text.delta",
+		ItemID:       s.output[s.messageIndex].ID,
+		OutputIndex:  common.GetPointer(s.messageIndex),
+		ContentIndex: common.GetPointer(0),
+		Delta:        delta,
+	})
+}
+
+func (s *ChatToResponsesStreamConverter) closeText() []dto.ResponsesStreamResponse {
+	if s.messageIndex < 0 {
+		return nil
+	}
+	index := s.messageIndex
+	s.messageIndex = -1
+	text := s.messageText.String()
+	s.output[index].Content = []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}}
+	itemID := s.output[index].ID
+	return []dto.ResponsesStreamResponse{
+		{
+			Type:         "response.output_text.done",
+			ItemID:       itemID,
+			OutputIndex:  common.GetPointer(index),
+			ContentIndex: common.GetPointer(0),
+			Text:         text,
+		},
+		{
+			Type:         "response.content_part.done",
+			ItemID:       itemID,
+			OutputIndex:  common.GetPointer(index),
+			ContentIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "output_text", Text: text},
+		},
+		s.itemDone(index),
+	}
+}
+
+func (s *ChatToResponsesStreamConverter) appendToolCall(toolCall dto.ToolCallResponse) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	callIndex := 0
+	if toolCall.Index != nil {
+		callIndex = *toolCall.Index
+	}
+	call, ok := s.toolCalls[callIndex]
+	if !ok {
+		events = append(events, s.closeReasoning()...)
+		events = append(events, s.closeText()...)
+		index, added := s.addItem(dto.ResponsesOutput{
+			Type:   "function_call",
+			ID:     responsesItemID("fc", s.ResponseID, len(s.output)),
+			Status: "in_progress",
+			CallId: toolCall.ID,
+			Name:   toolCall.Function.Name,
+		})
+		call = &chatToResponsesToolCall{outputIndex: index}
+		s.toolCalls[callIndex] = call
+		events = append(events, added)
+	} else {
+		// 部分上游会在后续分片中补充 id 与函数名
+		if s.output[call.outputIndex].CallId == "" {
+			s.output[call.outputIndex].CallId = toolCall.ID
+		}
+		if s.output[call.outputIndex].Name == "" {
+			s.output[call.outputIndex].Name = toolCall.Function.Name
+		}
+	}
+	if toolCall.Function.Arguments == "" {
+		return events
+	}
+	call.arguments.WriteString(toolCall.Function.Arguments)
+	return append(events, dto.ResponsesStreamResponse{
+		Type:        "response.function_call_arguments.delta",
+		ItemID:      s.output[call.outputIndex].ID,
+		OutputIndex: common.GetPointer(call.outputIndex),
+		Delta:       toolCall.Function.Arguments,
+	})
+}
+
+func (s *ChatToResponsesStreamConverter) closeToolCalls() []dto.ResponsesStreamResponse {
+	if len(s.toolCalls) == 0 {
+		return nil
+	}
+	calls := make([]*chatToResponsesToolCall, 0, len(s.toolCalls))
+	for _, call := range s.toolCalls {
+		calls = append(calls, call)
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].outputIndex < calls[j].outputIndex
+	})
+	s.toolCalls = make(map[int]*chatToResponsesToolCall)
+
+	events := make([]dto.ResponsesStreamResponse, 0, len(calls)*2)
+	for _, call := range calls {
+		arguments := call.arguments.String()
+		s.output[call.outputIndex].Arguments = arguments
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:        "response.function_call_arguments.done",
+			ItemID:      s.output[call.outputIndex].ID,
+			OutputIndex: common.GetPointer(call.outputIndex),
+			Arguments:   arguments,
+		}, s.itemDone(call.outputIndex))
+	}
+	return events
+}
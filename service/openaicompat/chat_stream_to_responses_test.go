@@ -0,0 +1,81 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func eventTypes(events []dto.ResponsesStreamResponse) []string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestChatToResponsesStreamConverter(t *testing.T) {
+	converter := NewChatToResponsesStreamConverter("resp_1", "claude-sonnet-4", 1)
+
+	events := converter.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningContent: common.GetPointer("thinking")}}},
+	})
+	require.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.reasoning_summary_part.added",
+		"response.reasoning_summary_text.delta",
+	}, eventTypes(events))
+
+	events = converter.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{Content: common.GetPointer("Hello")}}},
+	})
+	require.Equal(t, []string{
+		"response.reasoning_summary_text.done",
+		"response.reasoning_summary_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+	}, eventTypes(events))
+
+	events = converter.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{
+			{Index: common.GetPointer(0), ID: "call_1", Function: dto.FunctionResponse{Name: "get_weather", Arguments: `{"city":`}},
+		}}}},
+	})
+	require.Equal(t, []string{
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+	}, eventTypes(events))
+
+	converter.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{
+			Delta:        dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{Index: common.GetPointer(0), Function: dto.FunctionResponse{Arguments: `"a"}`}}}},
+			FinishReason: common.GetPointer("tool_calls"),
+		}},
+	})
+
+	events = converter.Finish(&dto.Usage{PromptTokens: 10, CompletionTokens: 5})
+	require.Equal(t, []string{
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.completed",
+	}, eventTypes(events))
+	require.Equal(t, `{"city":"a"}`, events[0].Arguments)
+
+	completed := events[2].Response
+	require.Len(t, completed.Output, 3)
+	require.Equal(t, "thinking", completed.Output[0].Summary[0].Text)
+	require.Equal(t, "Hello", completed.Output[1].Content[0].Text)
+	require.Equal(t, "call_1", completed.Output[2].CallId)
+	require.Equal(t, 10, completed.Usage.InputTokens)
+	require.Equal(t, 5, completed.Usage.OutputTokens)
+	require.Equal(t, 15, completed.Usage.TotalTokens)
+}
@@ -0,0 +1,134 @@
+package openaicompat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ChatUsageToResponsesUsage 将 Chat Completions 的 usage 转换为 Responses 的 usage 格式
+func ChatUsageToResponsesUsage(usage *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	out := *usage
+	out.InputTokens = usage.PromptTokens
+	out.OutputTokens = usage.CompletionTokens
+	if out.TotalTokens == 0 {
+		out.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	inputTokensDetails := usage.PromptTokensDetails
+	out.InputTokensDetails = &inputTokensDetails
+	return &out
+}
+
+// ChatCompletionsResponseToResponsesResponse 将 Chat Completions 非流式响应转换为 Responses 响应，
+// usage 为空时使用响应自带的 usage
+func ChatCompletionsResponseToResponsesResponse(resp *dto.OpenAITextResponse, usage *dto.Usage, id string) (*dto.OpenAIResponsesResponse, error) {
+	if resp == nil {
+		return nil, errors.New("response is nil")
+	}
+	if usage == nil {
+		usage = &resp.Usage
+	}
+
+	status := "completed"
+	output := make([]dto.ResponsesOutput, 0, 2)
+	if len(resp.Choices) > 0 {
+		choice := resp.Choices[0]
+		if choice.FinishReason == "length" {
+			status = "incomplete"
+		}
+		msg := choice.Message
+
+		reasoning := msg.ReasoningContent
+		if reasoning == "" {
+			reasoning = msg.Reasoning
+		}
+		if reasoning != "" {
+			output = append(output, newResponsesReasoningItem(responsesItemID("rs", id, len(output)), reasoning))
+		}
+
+		if text := msg.StringContent(); text != "" {
+			output = append(output, newResponsesMessageItem(responsesItemID("msg", id, len(output)), text, "completed"))
+		}
+
+		for _, toolCall := range msg.ParseToolCalls() {
+			if toolCall.Function.Name == "" {
+				continue
+			}
+			output = append(output, dto.ResponsesOutput{
+				Type:      "function_call",
+				ID:        responsesItemID("fc", id, len(output)),
+				Status:    "completed",
+				CallId:    toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+	}
+
+	created := common.GetTimestamp()
+	switch v := resp.Created.(type) {
+	case float64:
+		created = int64(v)
+	case int64:
+		created = v
+	case int:
+		created = int64(v)
+	}
+
+	return newResponsesResponse(id, resp.Model, int(created), status, output, ChatUsageToResponsesUsage(usage)), nil
+}
+
+// responsesItemID 根据响应 ID 与输出序号生成输出条目 ID，例如 msg_xxx_1
+func responsesItemID(prefix string, responseID string, index int) string {
+	return fmt.Sprintf("%s_%s_%d", prefix, strings.TrimPrefix(responseID, "resp_"), index)
+}
+
+func newResponsesResponse(id string, model string, createdAt int, status string, output []dto.ResponsesOutput, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	statusRaw, _ := common.Marshal(status)
+	if output == nil {
+		output = []dto.ResponsesOutput{}
+	}
+	return &dto.OpenAIResponsesResponse{
+		ID:        id,
+		Object:    "response",
+		CreatedAt: createdAt,
+		Status:    statusRaw,
+		Model:     model,
+		Output:    output,
+		Usage:     usage,
+	}
+}
+
+func newResponsesMessageItem(id string, text string, status string) dto.ResponsesOutput {
+	return dto.ResponsesOutput{
+		Type:   "message",
+		ID:     id,
+		Status: status,
+		Role:   "assistant",
+		Content: []dto.ResponsesOutputContent{
+			{
+				Type:        "output_text",
+				Text:        text,
+				Annotations: []interface{}{},
+			},
+		},
+	}
+}
+
+func newResponsesReasoningItem(id string, summary string) dto.ResponsesOutput {
+	item := dto.ResponsesOutput{
+		Type:   "reasoning",
+		ID:     id,
+		Status: "completed",
+	}
+	if strings.TrimSpace(summary) != "" {
+		item.Summary = []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: summary}}
+	}
+	return item
+}
@@ -0,0 +1,316 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/samber/lo"
+)
+
+// ResponsesRequestToChatCompletionsRequest 将 Responses 请求转换为 Chat Completions 请求，
+// 供不支持原生 Responses 接口的渠道（Claude、Gemini、Bedrock 等）使用。
+// 转换是无状态的，因此不支持 previous_response_id；内置工具（web_search 等）会被忽略
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("request is nil")
+	}
+	if req.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if req.PreviousResponseID != "" {
+		return nil, errors.New("previous_response_id is not supported in responses compatibility mode")
+	}
+
+	messages := make([]dto.Message, 0)
+
+	if len(req.Instructions) > 0 && common.GetJsonType(req.Instructions) == "string" {
+		var instructions string
+		if err := common.Unmarshal(req.Instructions, &instructions); err == nil && strings.TrimSpace(instructions) != "" {
+			messages = append(messages, dto.Message{Role: "system", Content: instructions})
+		}
+	}
+
+	inputMessages, err := responsesInputToChatMessages(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, inputMessages...)
+	if len(messages) == 0 {
+		return nil, errors.New("input is required")
+	}
+
+	out := &dto.GeneralOpenAIRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Stream:      req.Stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxOutputTokens,
+		User:        req.User,
+	}
+	if lo.FromPtrOr(req.Stream, false) {
+		out.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if req.Reasoning != nil && req.Reasoning.Effort != "" {
+		out.ReasoningEffort = req.Reasoning.Effort
+	}
+	if len(req.ParallelToolCalls) > 0 {
+		var parallelToolCalls bool
+		if err := common.Unmarshal(req.ParallelToolCalls, &parallelToolCalls); err == nil {
+			out.ParallelTooCalls = &parallelToolCalls
+		}
+	}
+
+	out.Tools = convertResponsesToolsToChat(req.GetToolsMap())
+	if len(out.Tools) > 0 {
+		out.ToolChoice = convertResponsesToolChoiceToChat(req.ToolChoice)
+	}
+	out.ResponseFormat = convertResponsesTextToChatResponseFormat(req.Text)
+
+	return out, nil
+}
+
+func responsesInputToChatMessages(input json.RawMessage) ([]dto.Message, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+	switch common.GetJsonType(input) {
+	case "string":
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, err
+		}
+		return []dto.Message{{Role: "user", Content: text}}, nil
+	case "array":
+	default:
+		return nil, errors.New("input must be a string or an array")
+	}
+
+	var items []map[string]any
+	if err := common.Unmarshal(input, &items); err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	messages := make([]dto.Message, 0, len(items))
+	for _, item := range items {
+		itemType := common.Interface2String(item["type"])
+		switch itemType {
+		case "", "message":
+			role := strings.TrimSpace(common.Interface2String(item["role"]))
+			if role == "" {
+				continue
+			}
+			if role == "developer" {
+				role = "system"
+			}
+			messages = append(messages, convertResponsesMessageToChat(role, item["content"]))
+		case "function_call":
+			callId := common.Interface2String(item["call_id"])
+			if callId == "" {
+				callId = common.Interface2String(item["id"])
+			}
+			toolCall := dto.ToolCallRequest{
+				ID:   callId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      common.Interface2String(item["name"]),
+					Arguments: common.Interface2String(item["arguments"]),
+				},
+			}
+			// 连续的函数调用合并到同一条 assistant 消息中
+			if n := len(messages); n > 0 && messages[n-1].Role == "assistant" {
+				toolCalls := append(messages[n-1].ParseToolCalls(), toolCall)
+				messages[n-1].SetToolCalls(toolCalls)
+				continue
+			}
+			msg := dto.Message{Role: "assistant", Content: ""}
+			msg.SetToolCalls([]dto.ToolCallRequest{toolCall})
+			messages = append(messages, msg)
+		case "function_call_output":
+			messages = append(messages, dto.Message{
+				Role:       "tool",
+				ToolCallId: common.Interface2String(item["call_id"]),
+				Content:    convertResponsesToolOutputToChat(item["output"]),
+			})
+		default:
+			// reasoning、内置工具调用等条目无法在其他渠道复现，直接忽略
+		}
+	}
+	return messages, nil
+}
+
+// convertResponsesMessageToChat 将 Responses 消息条目转换为 Chat 消息，内容可能是字符串或内容数组
+func convertResponsesMessageToChat(role string, content any) dto.Message {
+	msg := dto.Message{Role: role}
+	parts, ok := content.([]any)
+	if !ok {
+		msg.Content = common.Interface2String(content)
+		return msg
+	}
+	mediaContents := make([]dto.MediaContent, 0, len(parts))
+	for _, partAny := range parts {
+		part, ok := partAny.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch common.Interface2String(part["type"]) {
+		case "input_text", "output_text":
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type: dto.ContentTypeText,
+				Text: common.Interface2String(part["text"]),
+			})
+		case "refusal":
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type: dto.ContentTypeText,
+				Text: common.Interface2String(part["refusal"]),
+			})
+		case "input_image":
+			imageUrl := part["image_url"]
+			if url, ok := imageUrl.(map[string]any); ok {
+				imageUrl = url["url"]
+			}
+			url := common.Interface2String(imageUrl)
+			if url == "" {
+				continue
+			}
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type: dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{
+					Url:    url,
+					Detail: lo.CoalesceOrEmpty(common.Interface2String(part["detail"]), "auto"),
+				},
+			})
+		case "input_audio":
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type:       dto.ContentTypeInputAudio,
+				InputAudio: part["input_audio"],
+			})
+		case "input_file":
+			fileData := common.Interface2String(part["file_data"])
+			if fileData == "" {
+				fileData = common.Interface2String(part["file_url"])
+			}
+			mediaContents = append(mediaContents, dto.MediaContent{
+				Type: dto.ContentTypeFile,
+				File: &dto.MessageFile{
+					FileName: common.Interface2String(part["filename"]),
+					FileData: fileData,
+					FileId:   common.Interface2String(part["file_id"]),
+				},
+			})
+		}
+	}
+	msg.SetMediaContent(mediaContents)
+	return msg
+}
+
+// convertResponsesToolOutputToChat 函数调用结果可能是字符串或内容数组，统一转换为文本
+func convertResponsesToolOutputToChat(output any) string {
+	switch v := output.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		var sb strings.Builder
+		for _, partAny := range v {
+			if part, ok := partAny.(map[string]any); ok {
+				sb.WriteString(common.Interface2String(part["text"]))
+			}
+		}
+		return sb.String()
+	default:
+		b, err := common.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+}
+
+func convertResponsesToolsToChat(tools []map[string]any) []dto.ToolCallRequest {
+	if len(tools) == 0 {
+		return nil
+	}
+	chatTools := make([]dto.ToolCallRequest, 0, len(tools))
+	for _, tool := range tools {
+		if common.Interface2String(tool["type"]) != "function" {
+			continue
+		}
+		name := common.Interface2String(tool["name"])
+		if name == "" {
+			continue
+		}
+		chatTools = append(chatTools, dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        name,
+				Description: common.Interface2String(tool["description"]),
+				Parameters:  tool["parameters"],
+			},
+		})
+	}
+	return chatTools
+}
+
+func convertResponsesToolChoiceToChat(toolChoice json.RawMessage) any {
+	if len(toolChoice) == 0 {
+		return nil
+	}
+	if common.GetJsonType(toolChoice) == "string" {
+		var choice string
+		_ = common.Unmarshal(toolChoice, &choice)
+		return choice
+	}
+	var choice map[string]any
+	if err := common.Unmarshal(toolChoice, &choice); err != nil {
+		return nil
+	}
+	// Responses: {"type":"function","name":"..."}
+	// Chat: {"type":"function","function":{"name":"..."}}
+	if common.Interface2String(choice["type"]) == "function" {
+		if name := common.Interface2String(choice["name"]); name != "" {
+			return map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": name},
+			}
+		}
+	}
+	return nil
+}
+
+func convertResponsesTextToChatResponseFormat(text json.RawMessage) *dto.ResponseFormat {
+	if len(text) == 0 {
+		return nil
+	}
+	var textConfig struct {
+		Format map[string]any `json:"format"`
+	}
+	if err := common.Unmarshal(text, &textConfig); err != nil || textConfig.Format == nil {
+		return nil
+	}
+	formatType := common.Interface2String(textConfig.Format["type"])
+	switch formatType {
+	case "json_object":
+		return &dto.ResponseFormat{Type: formatType}
+	case "json_schema":
+		schema := make(map[string]any, len(textConfig.Format))
+		for key, value := range textConfig.Format {
+			if key == "type" {
+				continue
+			}
+			schema[key] = value
+		}
+		schemaRaw, err := common.Marshal(schema)
+		if err != nil {
+			return nil
+		}
+		return &dto.ResponseFormat{Type: formatType, JsonSchema: schemaRaw}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,311 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesRequestToChatCompletionsRequest(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:        "claude-sonnet-4",
+		Instructions: []byte(`"be brief"`),
+		Input: []byte(`[
+			{"role":"user","content":[{"type":"input_text","text":"weather?"}]},
+			{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"a\"}"},
+			{"type":"function_call","call_id":"call_2","name":"get_weather","arguments":"{\"city\":\"b\"}"},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"},
+			{"type":"reasoning","summary":[]}
+		]`),
+		Tools:      []byte(`[{"type":"function","name":"get_weather","parameters":{"type":"object"}},{"type":"web_search_preview"}]`),
+		ToolChoice: []byte(`{"type":"function","name":"get_weather"}`),
+		Reasoning:  &dto.Reasoning{Effort: "high"},
+		Stream:     common.GetPointer(true),
+	}
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 4)
+	require.Equal(t, "system", chatReq.Messages[0].Role)
+	require.Equal(t, "weather?", chatReq.Messages[1].ParseContent()[0].Text)
+	require.Equal(t, "assistant", chatReq.Messages[2].Role)
+	require.Len(t, chatReq.Messages[2].ParseToolCalls(), 2)
+	require.Equal(t, "tool", chatReq.Messages[3].Role)
+	require.Equal(t, "call_1", chatReq.Messages[3].ToolCallId)
+	require.Len(t, chatReq.Tools, 1)
+	require.Equal(t, "high", chatReq.ReasoningEffort)
+	require.True(t, chatReq.StreamOptions.IncludeUsage)
+}
+
+func TestResponsesRequestToChatCompletionsRequestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *dto.OpenAIResponsesRequest
+	}{
+		{name: "nil request", req: nil},
+		{name: "missing model", req: &dto.OpenAIResponsesRequest{Input: []byte(`"hi"`)}},
+		{name: "previous response id", req: &dto.OpenAIResponsesRequest{Model: "m", Input: []byte(`"hi"`), PreviousResponseID: "resp_1"}},
+		{name: "empty input", req: &dto.OpenAIResponsesRequest{Model: "m"}},
+		{name: "input object", req: &dto.OpenAIResponsesRequest{Model: "m", Input: []byte(`{"role":"user"}`)}},
+		{name: "only ignored items", req: &dto.OpenAIResponsesRequest{Model: "m", Input: []byte(`[{"type":"reasoning","summary":[]}]`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ResponsesRequestToChatCompletionsRequest(tt.req)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestResponsesInputToChatMessages(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		check func(t *testing.T, messages []dto.Message)
+	}{
+		{
+			name:  "string input",
+			input: `"hello"`,
+			check: func(t *testing.T, messages []dto.Message) {
+				require.Len(t, messages, 1)
+				require.Equal(t, "user", messages[0].Role)
+				require.Equal(t, "hello", messages[0].StringContent())
+			},
+		},
+		{
+			name:  "developer role and string content",
+			input: `[{"role":"developer","content":"rules"},{"type":"message","role":"assistant","content":"ok"},{"content":"no role"}]`,
+			check: func(t *testing.T, messages []dto.Message) {
+				require.Len(t, messages, 2)
+				require.Equal(t, "system", messages[0].Role)
+				require.Equal(t, "rules", messages[0].StringContent())
+				require.Equal(t, "assistant", messages[1].Role)
+			},
+		},
+		{
+			name: "content parts",
+			input: `[{"role":"user","content":[
+				{"type":"input_text","text":"look"},
+				{"type":"input_image","image_url":"https://example.com/a.png"},
+				{"type":"input_image","image_url":{"url":"https://example.com/b.png"},"detail":"high"},
+				{"type":"input_image"},
+				{"type":"input_file","filename":"a.pdf","file_data":"data:application/pdf;base64,AA=="},
+				{"type":"input_audio","input_audio":{"data":"AA==","format":"wav"}}
+			]},{"role":"assistant","content":[{"type":"output_text","text":"fine"},{"type":"refusal","refusal":"no"}]}]`,
+			check: func(t *testing.T, messages []dto.Message) {
+				require.Len(t, messages, 2)
+				parts := messages[0].ParseContent()
+				require.Len(t, parts, 5)
+				require.Equal(t, "look", parts[0].Text)
+				require.Equal(t, dto.ContentTypeImageURL, parts[1].Type)
+				image := parts[1].GetImageMedia()
+				require.Equal(t, "https://example.com/a.png", image.Url)
+				require.Equal(t, "auto", image.Detail)
+				require.Equal(t, "https://example.com/b.png", parts[2].GetImageMedia().Url)
+				require.Equal(t, "high", parts[2].GetImageMedia().Detail)
+				require.Equal(t, dto.ContentTypeFile, parts[3].Type)
+				require.Equal(t, "a.pdf", parts[3].GetFile().FileName)
+				require.Equal(t, dto.ContentTypeInputAudio, parts[4].Type)
+
+				reply := messages[1].ParseContent()
+				require.Len(t, reply, 2)
+				require.Equal(t, "fine", reply[0].Text)
+				require.Equal(t, "no", reply[1].Text)
+			},
+		},
+		{
+			name: "function calls merge into preceding assistant message",
+			input: `[
+				{"role":"assistant","content":"calling"},
+				{"type":"function_call","id":"fc_1","name":"lookup","arguments":"{}"},
+				{"type":"function_call_output","call_id":"fc_1","output":[{"type":"input_text","text":"a"},{"type":"input_text","text":"b"}]},
+				{"type":"function_call_output","call_id":"fc_2","output":{"ok":true}}
+			]`,
+			check: func(t *testing.T, messages []dto.Message) {
+				require.Len(t, messages, 3)
+				toolCalls := messages[0].ParseToolCalls()
+				require.Len(t, toolCalls, 1)
+				require.Equal(t, "fc_1", toolCalls[0].ID)
+				require.Equal(t, "lookup", toolCalls[0].Function.Name)
+				require.Equal(t, "ab", messages[1].StringContent())
+				require.JSONEq(t, `{"ok":true}`, messages[2].StringContent())
+			},
+		},
+		{
+			name:  "built-in tool items are ignored",
+			input: `[{"type":"web_search_call","id":"ws_1"},{"type":"reasoning","summary":[]},{"role":"user","content":"hi"}]`,
+			check: func(t *testing.T, messages []dto.Message) {
+				require.Len(t, messages, 1)
+				require.Equal(t, "user", messages[0].Role)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := responsesInputToChatMessages([]byte(tt.input))
+			require.NoError(t, err)
+			tt.check(t, messages)
+		})
+	}
+}
+
+func TestResponsesRequestToChatCompletionsRequestOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   dto.OpenAIResponsesRequest
+		check func(t *testing.T, chatReq *dto.GeneralOpenAIRequest)
+	}{
+		{
+			name: "instructions become a leading system message",
+			req:  dto.OpenAIResponsesRequest{Instructions: []byte(`"be brief"`), Input: []byte(`"hi"`)},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Len(t, chatReq.Messages, 2)
+				require.Equal(t, "system", chatReq.Messages[0].Role)
+				require.Equal(t, "be brief", chatReq.Messages[0].StringContent())
+			},
+		},
+		{
+			name: "blank or non-string instructions are dropped",
+			req:  dto.OpenAIResponsesRequest{Instructions: []byte(`"  "`), Input: []byte(`"hi"`)},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Len(t, chatReq.Messages, 1)
+				require.Equal(t, "user", chatReq.Messages[0].Role)
+			},
+		},
+		{
+			name: "instructions without input",
+			req:  dto.OpenAIResponsesRequest{Instructions: []byte(`"say hi"`)},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Len(t, chatReq.Messages, 1)
+				require.Equal(t, "system", chatReq.Messages[0].Role)
+			},
+		},
+		{
+			name: "sampling and output limits",
+			req: dto.OpenAIResponsesRequest{
+				Input:             []byte(`"hi"`),
+				Temperature:       common.GetPointer(0.5),
+				TopP:              common.GetPointer(0.9),
+				MaxOutputTokens:   common.GetPointer(uint(128)),
+				User:              []byte(`"u1"`),
+				ParallelToolCalls: []byte(`false`),
+			},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Equal(t, 0.5, *chatReq.Temperature)
+				require.Equal(t, 0.9, *chatReq.TopP)
+				require.Equal(t, uint(128), *chatReq.MaxTokens)
+				require.JSONEq(t, `"u1"`, string(chatReq.User))
+				require.NotNil(t, chatReq.ParallelTooCalls)
+				require.False(t, *chatReq.ParallelTooCalls)
+			},
+		},
+		{
+			name: "stream includes usage",
+			req:  dto.OpenAIResponsesRequest{Input: []byte(`"hi"`), Stream: common.GetPointer(true)},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.True(t, *chatReq.Stream)
+				require.NotNil(t, chatReq.StreamOptions)
+				require.True(t, chatReq.StreamOptions.IncludeUsage)
+			},
+		},
+		{
+			name: "non-stream has no stream options",
+			req:  dto.OpenAIResponsesRequest{Input: []byte(`"hi"`), Stream: common.GetPointer(false)},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Nil(t, chatReq.StreamOptions)
+			},
+		},
+		{
+			name: "reasoning effort",
+			req:  dto.OpenAIResponsesRequest{Input: []byte(`"hi"`), Reasoning: &dto.Reasoning{Effort: "low"}},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Equal(t, "low", chatReq.ReasoningEffort)
+			},
+		},
+		{
+			name: "reasoning without effort",
+			req:  dto.OpenAIResponsesRequest{Input: []byte(`"hi"`), Reasoning: &dto.Reasoning{}},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Empty(t, chatReq.ReasoningEffort)
+			},
+		},
+		{
+			name: "function tools with string tool choice",
+			req: dto.OpenAIResponsesRequest{
+				Input:      []byte(`"hi"`),
+				Tools:      []byte(`[{"type":"function","name":"lookup","description":"find","parameters":{"type":"object"}},{"type":"function"},{"type":"file_search"}]`),
+				ToolChoice: []byte(`"required"`),
+			},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Len(t, chatReq.Tools, 1)
+				require.Equal(t, "function", chatReq.Tools[0].Type)
+				require.Equal(t, "lookup", chatReq.Tools[0].Function.Name)
+				require.Equal(t, "find", chatReq.Tools[0].Function.Description)
+				require.Equal(t, "required", chatReq.ToolChoice)
+			},
+		},
+		{
+			name: "named tool choice",
+			req: dto.OpenAIResponsesRequest{
+				Input:      []byte(`"hi"`),
+				Tools:      []byte(`[{"type":"function","name":"lookup"}]`),
+				ToolChoice: []byte(`{"type":"function","name":"lookup"}`),
+			},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "lookup"}}, chatReq.ToolChoice)
+			},
+		},
+		{
+			name: "tool choice dropped without function tools",
+			req: dto.OpenAIResponsesRequest{
+				Input:      []byte(`"hi"`),
+				Tools:      []byte(`[{"type":"web_search_preview"}]`),
+				ToolChoice: []byte(`"auto"`),
+			},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Empty(t, chatReq.Tools)
+				require.Nil(t, chatReq.ToolChoice)
+			},
+		},
+		{
+			name: "json schema text format",
+			req: dto.OpenAIResponsesRequest{
+				Input: []byte(`"hi"`),
+				Text:  []byte(`{"format":{"type":"json_schema","name":"out","schema":{"type":"object"},"strict":true}}`),
+			},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.NotNil(t, chatReq.ResponseFormat)
+				require.Equal(t, "json_schema", chatReq.ResponseFormat.Type)
+				var schema map[string]any
+				require.NoError(t, json.Unmarshal(chatReq.ResponseFormat.JsonSchema, &schema))
+				require.Equal(t, "out", schema["name"])
+				require.Equal(t, true, schema["strict"])
+				require.NotContains(t, schema, "type")
+			},
+		},
+		{
+			name: "json object and plain text formats",
+			req:  dto.OpenAIResponsesRequest{Input: []byte(`"hi"`), Text: []byte(`{"format":{"type":"json_object"}}`)},
+			check: func(t *testing.T, chatReq *dto.GeneralOpenAIRequest) {
+				require.Equal(t, &dto.ResponseFormat{Type: "json_object"}, chatReq.ResponseFormat)
+
+				plain, err := ResponsesRequestToChatCompletionsRequest(&dto.OpenAIResponsesRequest{Model: "m", Input: []byte(`"hi"`), Text: []byte(`{"format":{"type":"text"}}`)})
+				require.NoError(t, err)
+				require.Nil(t, plain.ResponseFormat)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Model = "claude-sonnet-4"
+			chatReq, err := ResponsesRequestToChatCompletionsRequest(&req)
+			require.NoError(t, err)
+			require.Equal(t, "claude-sonnet-4", chatReq.Model)
+			tt.check(t, chatReq)
+		})
+	}
+}
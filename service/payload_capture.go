@@ -0,0 +1,294 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	payloadCaptureRedacted = "[REDACTED]"
+	// 原始响应按上限的倍数采集，便于流式响应拼接出完整文本后再截断
+	payloadCaptureRawFactor = 4
+
+	payloadCaptureCleanupInterval = time.Hour
+	payloadCaptureCleanupBatch    = 1000
+)
+
+var payloadCaptureCleanupOnce sync.Once
+
+// PayloadCaptureSession 记录一次请求发往上游的请求体与返回给客户端的响应
+type PayloadCaptureSession struct {
+	mu          sync.Mutex
+	c           *gin.Context
+	origin      gin.ResponseWriter
+	writer      *responseCaptureWriter
+	requestBody []byte
+	requestSeen bool
+	truncated   bool
+}
+
+// BeginPayloadCapture 为可能需要采集的请求创建采集会话，不需要采集时返回 nil。
+// 命中用户或令牌时立即替换 c.Writer；只配置了渠道列表时，在选定渠道后由 ActivateForChannel 决定是否采集
+func BeginPayloadCapture(c *gin.Context) *PayloadCaptureSession {
+	userId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+	tokenId := common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+	if !operation_setting.MayCapturePayload(userId, tokenId) {
+		return nil
+	}
+	session := &PayloadCaptureSession{c: c, origin: c.Writer}
+	common.SetContextKey(c, constant.ContextKeyPayloadCapture, session)
+	if operation_setting.ShouldCapturePayload(userId, tokenId, 0) {
+		session.activate()
+	}
+	return session
+}
+
+// ActivateForChannel 在选定渠道、发起转发前调用，渠道在采集列表中时开始采集
+func (s *PayloadCaptureSession) ActivateForChannel(channelId int) {
+	if s == nil || !operation_setting.ShouldCapturePayload(0, 0, channelId) {
+		return
+	}
+	s.activate()
+}
+
+// ActivateForHedge 对冲的备用渠道在主尝试发出后才选定，配置了渠道列表时在对冲开始前即开始采集
+func (s *PayloadCaptureSession) ActivateForHedge() {
+	if s == nil || len(operation_setting.GetPayloadCaptureSetting().ChannelIds) == 0 {
+		return
+	}
+	s.activate()
+}
+
+// activate 安装响应采集 Writer。流式审核已包装原 Writer 时插入到其下层，记录客户端实际收到的内容
+func (s *PayloadCaptureSession) activate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer != nil {
+		return
+	}
+	writer := &responseCaptureWriter{
+		ResponseWriter: s.origin,
+		limit:          operation_setting.GetPayloadCaptureMaxBodyBytes() * payloadCaptureRawFactor,
+		truncate:       true,
+	}
+	if moderation, ok := s.c.Writer.(*StreamModeration); ok && moderation.ResponseWriter == s.origin {
+		moderation.ResponseWriter = writer
+	} else {
+		writer.ResponseWriter = s.c.Writer
+		s.c.Writer = writer
+	}
+	s.writer = writer
+}
+
+// active 返回是否已开始采集
+func (s *PayloadCaptureSession) active() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer != nil
+}
+
+// CapturePayloadRequestBody 记录发往上游的请求体（参数覆盖之后），重试时以最后一次为准。
+// 未开启采集时原样返回 requestBody
+func CapturePayloadRequestBody(c *gin.Context, requestBody io.Reader) io.Reader {
+	value, ok := common.GetContextKey(c, constant.ContextKeyPayloadCapture)
+	if !ok || requestBody == nil {
+		return requestBody
+	}
+	session, ok := value.(*PayloadCaptureSession)
+	if !ok || session == nil || !session.active() {
+		return requestBody
+	}
+	data, err := io.ReadAll(requestBody)
+	if err != nil {
+		return io.MultiReader(bytes.NewReader(data), requestBody)
+	}
+	session.mu.Lock()
+	session.requestBody = data
+	session.requestSeen = true
+	session.mu.Unlock()
+	return bytes.NewReader(data)
+}
+
+// Finish 恢复原 Writer，并在命中采集条件时异步保存采集记录
+func (s *PayloadCaptureSession) Finish(modelName string) {
+	if s == nil || !s.active() {
+		return
+	}
+	c := s.c
+	if c.Writer == s.writer {
+		c.Writer = s.origin
+	}
+	userId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+	tokenId := common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+	channelId := common.GetContextKeyInt(c, constant.ContextKeyChannelId)
+	if !operation_setting.ShouldCapturePayload(userId, tokenId, channelId) {
+		return
+	}
+
+	s.mu.Lock()
+	requestBody := s.requestBody
+	requestSeen := s.requestSeen
+	s.mu.Unlock()
+	if !requestSeen {
+		// 未发往上游（如校验失败）时记录客户端原始请求
+		if storage, err := common.GetBodyStorage(c); err == nil {
+			requestBody, _ = storage.Bytes()
+		}
+	}
+
+	contentType := s.writer.Header().Get("Content-Type")
+	stream := strings.HasPrefix(contentType, "text/event-stream")
+	responseBody := s.writer.buf.Bytes()
+	responseText := ""
+	if stream {
+		responseText = assembleStreamText(responseBody)
+	}
+
+	capture := &model.PayloadCapture{
+		RequestId:  c.GetString(common.RequestIdKey),
+		UserId:     userId,
+		TokenId:    tokenId,
+		ChannelId:  channelId,
+		ModelName:  modelName,
+		Path:       c.Request.URL.Path,
+		StatusCode: s.writer.Status(),
+		IsStream:   stream,
+		Truncated:  s.writer.overflow,
+	}
+	capture.RequestBody = s.prepareField(string(requestBody))
+	capture.ResponseBody = s.prepareField(string(responseBody))
+	capture.ResponseText = s.prepareField(responseText)
+	capture.Truncated = capture.Truncated || s.truncated
+
+	gopool.Go(func() {
+		if err := model.RecordPayloadCapture(capture); err != nil {
+			common.SysError("failed to record payload capture: " + err.Error())
+		}
+	})
+}
+
+// prepareField 脱敏并截断到单体上限
+func (s *PayloadCaptureSession) prepareField(value string) string {
+	value = RedactPayload(value)
+	limit := operation_setting.GetPayloadCaptureMaxBodyBytes()
+	if len(value) <= limit {
+		return value
+	}
+	s.truncated = true
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
+
+// RedactPayload 按配置的正则替换敏感内容
+func RedactPayload(value string) string {
+	if value == "" {
+		return value
+	}
+	for _, re := range operation_setting.GetPayloadCaptureRedactRegexps() {
+		value = re.ReplaceAllString(value, payloadCaptureRedacted)
+	}
+	return value
+}
+
+// assembleStreamText 从 SSE 响应中拼接输出文本，兼容 OpenAI Chat/Completions/Responses、Claude 与 Gemini 格式
+func assembleStreamText(body []byte) string {
+	var builder strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" || data == "[DONE]" || !gjson.Valid(data) {
+			continue
+		}
+		builder.WriteString(extractStreamDeltaText(data))
+	}
+	return builder.String()
+}
+
+func extractStreamDeltaText(data string) string {
+	var builder strings.Builder
+	for _, path := range streamDeltaTextPaths(data) {
+		builder.WriteString(gjson.Get(data, path).String())
+	}
+	return builder.String()
+}
+
+// streamDeltaTextPaths 返回流式事件中承载输出文本的 JSON 路径，按文本顺序排列
+func streamDeltaTextPaths(data string) []string {
+	if content := gjson.Get(data, "choices.0.delta.content"); content.Type == gjson.String {
+		return []string{"choices.0.delta.content"}
+	}
+	if text := gjson.Get(data, "choices.0.text"); text.Type == gjson.String {
+		return []string{"choices.0.text"}
+	}
+	if gjson.Get(data, "type").String() == "response.output_text.delta" {
+		return []string{"delta"}
+	}
+	if text := gjson.Get(data, "delta.text"); text.Type == gjson.String {
+		return []string{"delta.text"}
+	}
+	var paths []string
+	for i, part := range gjson.Get(data, "candidates.0.content.parts").Array() {
+		if part.Get("text").Type == gjson.String {
+			paths = append(paths, fmt.Sprintf("candidates.0.content.parts.%d.text", i))
+		}
+	}
+	return paths
+}
+
+// StartPayloadCaptureCleanupTask 按保留天数定期清理采集记录，与消费日志的清理相互独立
+func StartPayloadCaptureCleanupTask() {
+	payloadCaptureCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(payloadCaptureCleanupInterval)
+			defer ticker.Stop()
+			for {
+				cleanupExpiredPayloadCaptures()
+				<-ticker.C
+			}
+		})
+	})
+}
+
+func cleanupExpiredPayloadCaptures() {
+	days := operation_setting.GetPayloadCaptureSetting().RetentionDays
+	if days <= 0 {
+		return
+	}
+	target := time.Now().AddDate(0, 0, -days).Unix()
+	count, err := model.DeleteExpiredPayloadCaptures(target, payloadCaptureCleanupBatch)
+	if err != nil {
+		logger.LogError(context.Background(), "failed to clean up payload captures: "+err.Error())
+		return
+	}
+	if count > 0 {
+		logger.LogInfo(context.Background(), fmt.Sprintf("cleaned up %d expired payload captures", count))
+	}
+}
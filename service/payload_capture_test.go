@@ -0,0 +1,77 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssembleStreamText(t *testing.T) {
+	openai := "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+	require.Equal(t, "Hello", assembleStreamText([]byte(openai)))
+
+	claude := "event: content_block_delta\n" +
+		"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	require.Equal(t, "Hi", assembleStreamText([]byte(claude)))
+
+	responses := "data: {\"type\":\"response.output_text.delta\",\"delta\":\"ok\"}\n\n"
+	require.Equal(t, "ok", assembleStreamText([]byte(responses)))
+
+	gemini := "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"a\"},{\"text\":\"b\"}]}}]}\n\n"
+	require.Equal(t, "ab", assembleStreamText([]byte(gemini)))
+}
+
+func TestRedactPayload(t *testing.T) {
+	redacted := RedactPayload(`{"key":"sk-abcdefghijklmnopqrstuvwxyz","email":"alice@example.com"}`)
+	require.NotContains(t, redacted, "sk-abcdefghijklmnop")
+	require.NotContains(t, redacted, "alice@example.com")
+	require.Contains(t, redacted, payloadCaptureRedacted)
+}
+
+func TestPayloadCaptureActivatesForSelectedChannel(t *testing.T) {
+	setting := operation_setting.GetPayloadCaptureSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.Enabled = true
+	setting.UserIds = []int{}
+	setting.TokenIds = []int{}
+	setting.ChannelIds = []int{5}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyUserId, 7)
+	origin := c.Writer
+
+	session := BeginPayloadCapture(c)
+	require.NotNil(t, session)
+	require.Equal(t, origin, c.Writer, "channel-only capture must not wrap before a channel is selected")
+	moderation := BeginStreamModeration(c, types.RelayFormatOpenAI)
+
+	body := CapturePayloadRequestBody(c, strings.NewReader("ignored"))
+	_, isReader := body.(*strings.Reader)
+	require.True(t, isReader, "request body must not be buffered for unmatched channels")
+
+	session.ActivateForChannel(3)
+	require.False(t, session.active())
+
+	session.ActivateForChannel(5)
+	require.True(t, session.active())
+	require.Equal(t, moderation, c.Writer)
+	require.Equal(t, session.writer, moderation.ResponseWriter, "capture writer sits below stream moderation")
+
+	_, err := c.Writer.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", session.writer.buf.String())
+}
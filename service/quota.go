@@ -37,6 +37,8 @@ type QuotaInfo struct {
 	ModelPrice    float64
 	ModelRatio    float64
 	GroupRatio    float64
+	// CompletionRatio 命中分档倍率时的补全倍率，为 0 时使用模型配置的补全倍率
+	CompletionRatio float64
 }
 
 func hasCustomModelRatio(modelName string, currentRatio float64) bool {
@@ -58,6 +60,9 @@ func calculateAudioQuota(info QuotaInfo) int {
 	}
 
 	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName))
+	if info.CompletionRatio > 0 {
+		completionRatio = decimal.NewFromFloat(info.CompletionRatio)
+	}
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(info.ModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(info.ModelName))
 
@@ -90,11 +95,6 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
-	if err != nil {
-		return err
-	}
-
 	token, err := model.GetTokenByKey(strings.TrimPrefix(relayInfo.TokenKey, "sk-"), false)
 	if err != nil {
 		return err
@@ -138,18 +138,34 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 
 	quota := calculateAudioQuota(quotaInfo)
 
-	if userQuota < quota {
-		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", logger.FormatQuota(userQuota), logger.FormatQuota(quota))
-	}
-
 	if !token.UnlimitedQuota && token.RemainQuota < quota {
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
+	if err := token.CheckBudget(quota); err != nil {
+		return err
+	}
 
-	err = PostConsumeQuota(relayInfo, quota, 0, false)
+	// 组织令牌从组织钱包扣费，校验成员额度上限与组织余额而不是个人余额
+	isOrganization, err := PreConsumeOrganizationQuota(relayInfo, quota)
 	if err != nil {
 		return err
 	}
+	if isOrganization {
+		if err := PostConsumeOrganizationQuota(relayInfo, quota, quota); err != nil {
+			return err
+		}
+	} else {
+		userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+		if err != nil {
+			return err
+		}
+		if userQuota < quota {
+			return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", logger.FormatQuota(userQuota), logger.FormatQuota(quota))
+		}
+		if err := PostConsumeQuota(relayInfo, quota, 0, false); err != nil {
+			return err
+		}
+	}
 	logger.LogInfo(ctx, "realtime streaming consume quota success, quota: "+fmt.Sprintf("%d", quota))
 	return nil
 }
@@ -236,6 +252,10 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 }
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	if !relayInfo.ClaimHedgeSettlement() {
+		logger.LogInfo(ctx, fmt.Sprintf("对冲请求落败（渠道 #%d），跳过计费", relayInfo.ChannelId))
+		return
+	}
 	if usage != nil {
 		ObserveChannelAffinityUsageCacheByRelayFormat(ctx, usage, relayInfo.GetFinalRequestRelayFormat())
 	}
@@ -245,6 +265,13 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	completionTokens := usage.CompletionTokens
 	modelName := relayInfo.OriginModelName
 
+	// 按实际提示词长度重新选择分档倍率，OpenRouter 的 prompt_tokens 已包含缓存 tokens
+	tierPromptTokens := usage.PromptTokens
+	if relayInfo.ChannelType != constant.ChannelTypeOpenRouter {
+		tierPromptTokens += usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	}
+	relayInfo.PriceData.ApplyTier(tierPromptTokens)
+
 	tokenName := ctx.GetString("token_name")
 	completionRatio := relayInfo.PriceData.CompletionRatio
 	modelRatio := relayInfo.PriceData.ModelRatio
@@ -306,6 +333,9 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		logger.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, relayInfo.FinalPreConsumedQuota))
 	} else {
+		if CheckTokenBudgetOverrun(ctx, relayInfo, quota) {
+			logContent += "（超出令牌周期预算）"
+		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
@@ -359,6 +389,10 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	if !relayInfo.ClaimHedgeSettlement() {
+		logger.LogInfo(ctx, fmt.Sprintf("对冲请求落败（渠道 #%d），跳过计费", relayInfo.ChannelId))
+		return
+	}
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -367,8 +401,14 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	audioInputTokens := usage.PromptTokensDetails.AudioTokens
 	audioOutTokens := usage.CompletionTokenDetails.AudioTokens
 
+	// 按实际提示词长度重新选择分档倍率，prompt_tokens 已包含缓存与音频 tokens
+	relayInfo.PriceData.ApplyTier(usage.PromptTokens)
+
 	tokenName := ctx.GetString("token_name")
 	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(relayInfo.OriginModelName))
+	if relayInfo.PriceData.AppliedTier != nil {
+		completionRatio = decimal.NewFromFloat(relayInfo.PriceData.CompletionRatio)
+	}
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(relayInfo.OriginModelName))
 
@@ -386,10 +426,11 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  relayInfo.OriginModelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		ModelName:       relayInfo.OriginModelName,
+		UsePrice:        usePrice,
+		ModelRatio:      modelRatio,
+		GroupRatio:      groupRatio,
+		CompletionRatio: completionRatio.InexactFloat64(),
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -412,6 +453,9 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		logger.LogError(ctx, fmt.Sprintf("total tokens is 0, cannot consume quota, userId %d, channelId %d, "+
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, relayInfo.OriginModelName, relayInfo.FinalPreConsumedQuota))
 	} else {
+		if CheckTokenBudgetOverrun(ctx, relayInfo, quota) {
+			logContent += "（超出令牌周期预算）"
+		}
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
@@ -459,6 +503,10 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if !relayInfo.TokenUnlimited && token.RemainQuota < quota {
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
+	// 周期预算独立于无限额度设置
+	if err := token.CheckBudget(quota); err != nil {
+		return err
+	}
 	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
 	if err != nil {
 		return err
@@ -466,6 +514,37 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	return nil
 }
 
+// CheckTokenBudgetOverrun 结算后检查令牌周期预算：上游已返回的用量照常全额结算，
+// 本次超出预扣额度的部分超过剩余预算时记录并投递预算耗尽事件，之后的请求在鉴权与预扣费时被拒绝。
+// 返回本次结算是否超出预算
+func CheckTokenBudgetOverrun(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, quota int) bool {
+	// 鉴权时未设置预算的令牌无需查询
+	if _, ok := ctx.Get("token_budget_remain"); !ok || relayInfo.IsPlayground {
+		return false
+	}
+	preConsumed := relayInfo.FinalPreConsumedQuota
+	if relayInfo.Billing != nil {
+		preConsumed = relayInfo.Billing.GetPreConsumedQuota()
+	}
+	delta := quota - preConsumed
+	if delta <= 0 {
+		return false
+	}
+	// 周期用量以数据库为准，缓存中的用量为异步累加
+	token, err := model.GetTokenById(relayInfo.TokenId)
+	if err != nil || !token.IsBudgetEnabled() {
+		return false
+	}
+	remain := token.GetBudgetRemainQuota()
+	if delta <= remain {
+		return false
+	}
+	logger.LogWarn(ctx, fmt.Sprintf("令牌 %d 周期预算不足，本次结算 %s 超出剩余预算 %s", relayInfo.TokenId,
+		logger.FormatQuota(quota), logger.FormatQuota(preConsumed+remain)))
+	EmitTokenBudgetExhaustedWebhook(token)
+	return true
+}
+
 func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int, sendEmail bool) (err error) {
 
 	// 1) Consume from wallet quota OR subscription item
@@ -480,6 +559,10 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 			}
 			relayInfo.SubscriptionPostDelta += delta
 		}
+	} else if relayInfo != nil && relayInfo.BillingSource == BillingSourceOrganization {
+		if err := model.PostConsumeOrganizationQuota(relayInfo.OrganizationId, relayInfo.UserId, quota); err != nil {
+			return err
+		}
 	} else {
 		// Wallet
 		if quota > 0 {
@@ -492,15 +575,8 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
-	if !relayInfo.IsPlayground {
-		if quota > 0 {
-			err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
-		} else {
-			err = model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, -quota)
-		}
-		if err != nil {
-			return err
-		}
+	if err = postConsumeTokenQuota(relayInfo, quota); err != nil {
+		return err
 	}
 
 	if sendEmail {
@@ -512,6 +588,40 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 	return nil
 }
 
+// postConsumeTokenQuota 按 quota 扣减（负数为退还）令牌额度，练习场请求不扣令牌额度
+func postConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
+	if relayInfo.IsPlayground || quota == 0 {
+		return nil
+	}
+	if quota > 0 {
+		return model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
+	}
+	return model.IncreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, -quota)
+}
+
+// PreConsumeOrganizationQuota 供未创建 BillingSession 的路径（实时音频、Midjourney）使用：
+// 组织令牌与文本中继一致，校验成员身份、成员额度上限与组织余额后从组织钱包预扣 quota，
+// 并将计费来源标记为组织。返回 false 表示不是组织令牌，调用方按个人钱包处理
+func PreConsumeOrganizationQuota(relayInfo *relaycommon.RelayInfo, quota int) (bool, error) {
+	if relayInfo.OrganizationId <= 0 {
+		return false, nil
+	}
+	if err := model.PreConsumeOrganizationQuota(relayInfo.OrganizationId, relayInfo.UserId, quota); err != nil {
+		return true, err
+	}
+	relayInfo.BillingSource = BillingSourceOrganization
+	return true, nil
+}
+
+// PostConsumeOrganizationQuota 结算 PreConsumeOrganizationQuota 预扣的额度：按实际额度与预扣额度的差额
+// 调整组织钱包，并扣减令牌额度。请求失败时 actualQuota 传 0 即全额退还
+func PostConsumeOrganizationQuota(relayInfo *relaycommon.RelayInfo, preConsumedQuota int, actualQuota int) error {
+	if err := model.PostConsumeOrganizationQuota(relayInfo.OrganizationId, relayInfo.UserId, actualQuota-preConsumedQuota); err != nil {
+		return err
+	}
+	return postConsumeTokenQuota(relayInfo, actualQuota)
+}
+
 func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQuota int) {
 	gopool.Go(func() {
 		userSetting := relayInfo.UserSetting
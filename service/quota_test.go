@@ -0,0 +1,107 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTokenBudgetOverrun(t *testing.T) {
+	truncate(t)
+	seedToken(t, 1, 1, "budgetcapkey", 100000)
+	windowStart, _ := model.TokenBudgetWindow(model.TokenBudgetPeriodDay, time.Now())
+	require.NoError(t, model.DB.Model(&model.Token{}).Where("id = ?", 1).Updates(map[string]interface{}{
+		"budget_period":       model.TokenBudgetPeriodDay,
+		"budget_quota":        1000,
+		"budget_used_quota":   700,
+		"budget_window_start": windowStart.Unix(),
+	}).Error)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Set("token_budget_remain", 300)
+	info := &relaycommon.RelayInfo{TokenId: 1, TokenKey: "budgetcapkey", FinalPreConsumedQuota: 200}
+	// 预扣 200 已计入周期用量，剩余预算 300
+	assert.False(t, CheckTokenBudgetOverrun(ctx, info, 500))
+	assert.True(t, CheckTokenBudgetOverrun(ctx, info, 900))
+	// 退还不会超出预算
+	assert.False(t, CheckTokenBudgetOverrun(ctx, info, 100))
+
+	// 超出预算的用量照常结算，之后的请求按预算拒绝
+	require.NoError(t, model.DecreaseTokenQuota(1, "budgetcapkey", 700))
+	token, err := model.GetTokenById(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1400, token.GetBudgetUsedQuota())
+	assert.ErrorIs(t, token.CheckBudget(1), model.ErrTokenBudgetExhausted)
+
+	info.IsPlayground = true
+	assert.False(t, CheckTokenBudgetOverrun(ctx, info, 900))
+}
+
+func TestCalculateAudioQuotaUsesTierCompletionRatio(t *testing.T) {
+	info := QuotaInfo{
+		InputDetails:  TokenDetails{TextTokens: 100},
+		OutputDetails: TokenDetails{TextTokens: 10},
+		ModelName:     "audio-tier-test-model",
+		ModelRatio:    2,
+		GroupRatio:    1,
+	}
+	base := calculateAudioQuota(info)
+
+	// 命中分档后按档位的补全倍率计算
+	info.CompletionRatio = 10
+	assert.Equal(t, 2*(100+10*10), calculateAudioQuota(info))
+	assert.NotEqual(t, base, calculateAudioQuota(info))
+}
+
+func TestOrganizationQuotaWithoutBillingSession(t *testing.T) {
+	truncate(t)
+	require.NoError(t, model.DB.AutoMigrate(&model.Organization{}, &model.OrganizationMember{}))
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM organizations")
+		model.DB.Exec("DELETE FROM organization_members")
+	})
+	seedUser(t, 1, 0)
+	seedToken(t, 1, 1, "orgquotakey", 10000)
+	require.NoError(t, model.DB.Create(&model.Organization{Id: 1, Name: "acme", OwnerId: 2, Quota: 1000, Status: model.OrganizationStatusEnabled}).Error)
+	require.NoError(t, model.DB.Create(&model.OrganizationMember{OrganizationId: 1, UserId: 1, Role: model.OrganizationRoleMember, QuotaLimit: 300}).Error)
+
+	// 非组织令牌交由调用方按个人钱包处理
+	isOrganization, err := PreConsumeOrganizationQuota(&relaycommon.RelayInfo{UserId: 1}, 100)
+	require.NoError(t, err)
+	assert.False(t, isOrganization)
+
+	info := &relaycommon.RelayInfo{UserId: 1, TokenId: 1, TokenKey: "orgquotakey", OrganizationId: 1}
+	// 个人余额为 0 也可以使用组织钱包，但受成员额度上限限制
+	_, err = PreConsumeOrganizationQuota(info, 400)
+	require.ErrorIs(t, err, model.ErrOrganizationMemberCapExceeded)
+
+	isOrganization, err = PreConsumeOrganizationQuota(info, 200)
+	require.NoError(t, err)
+	assert.True(t, isOrganization)
+	assert.Equal(t, BillingSourceOrganization, info.BillingSource)
+	require.NoError(t, PostConsumeOrganizationQuota(info, 200, 200))
+
+	// 请求失败时全额退还组织钱包
+	_, err = PreConsumeOrganizationQuota(info, 100)
+	require.NoError(t, err)
+	require.NoError(t, PostConsumeOrganizationQuota(info, 100, 0))
+
+	org, err := model.GetOrganizationById(1)
+	require.NoError(t, err)
+	assert.Equal(t, 800, org.Quota)
+	member, err := model.GetOrganizationMember(1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 200, member.UsedQuota)
+	token, err := model.GetTokenById(1)
+	require.NoError(t, err)
+	assert.Equal(t, 9800, token.RemainQuota)
+	user, err := model.GetUserById(1, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, user.Quota)
+}
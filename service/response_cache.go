@@ -0,0 +1,311 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+)
+
+const (
+	responseCacheNamespace = "new-api:response_cache:v1"
+
+	// ResponseCacheStatusHeader 响应头，标记本次请求是否命中缓存（HIT/MISS）
+	ResponseCacheStatusHeader = "X-New-Api-Cache"
+	// ResponseCacheBypassHeader 客户端携带该请求头（true/1）时跳过缓存读取
+	ResponseCacheBypassHeader = "X-New-Api-Cache-Bypass"
+)
+
+var (
+	responseCacheLock     sync.RWMutex
+	responseCache         *cachex.HybridCache[ResponseCacheEntry]
+	responseCacheMemory   *hot.HotCache[string, ResponseCacheEntry]
+	responseCacheCapacity int
+)
+
+// ResponseCacheEntry 缓存的上游响应，Body 为已转换为客户端格式的完整响应
+type ResponseCacheEntry struct {
+	Body        string    `json:"body"`
+	ContentType string    `json:"content_type"`
+	Stream      bool      `json:"stream"`
+	Usage       dto.Usage `json:"usage"`
+	CreatedAt   int64     `json:"created_at"`
+}
+
+// getResponseCache 返回响应缓存，容量配置变更后重建内存缓存（Redis 中的条目不受影响）
+func getResponseCache() *cachex.HybridCache[ResponseCacheEntry] {
+	capacity := operation_setting.GetResponseCacheSetting().MaxEntries
+	if capacity <= 0 {
+		capacity = 10_000
+	}
+	responseCacheLock.RLock()
+	cache, current := responseCache, responseCacheCapacity
+	responseCacheLock.RUnlock()
+	if cache != nil && current == capacity {
+		return cache
+	}
+
+	responseCacheLock.Lock()
+	defer responseCacheLock.Unlock()
+	if responseCache != nil && responseCacheCapacity == capacity {
+		return responseCache
+	}
+	if responseCacheMemory != nil {
+		responseCacheMemory.StopJanitor()
+	}
+	// 写入时均按模型规则指定 TTL，此处默认值仅用于启用过期清理
+	memory := hot.NewHotCache[string, ResponseCacheEntry](hot.LRU, capacity).
+		WithTTL(time.Hour).
+		WithJanitor().
+		Build()
+	responseCache = cachex.NewHybridCache[ResponseCacheEntry](cachex.HybridCacheConfig[ResponseCacheEntry]{
+		Namespace: cachex.Namespace(responseCacheNamespace),
+		Redis:     common.RDB,
+		RedisEnabled: func() bool {
+			return common.RedisEnabled && common.RDB != nil
+		},
+		RedisCodec: cachex.JSONCodec[ResponseCacheEntry]{},
+		Memory: func() *hot.HotCache[string, ResponseCacheEntry] {
+			return memory
+		},
+	})
+	responseCacheMemory = memory
+	responseCacheCapacity = capacity
+	return responseCache
+}
+
+// shouldBypassResponseCache 客户端通过请求头要求跳过缓存
+func shouldBypassResponseCache(c *gin.Context) bool {
+	switch strings.ToLower(strings.TrimSpace(c.GetHeader(ResponseCacheBypassHeader))) {
+	case "true", "1":
+		return true
+	}
+	cacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+	return strings.Contains(cacheControl, "no-cache") || strings.Contains(cacheControl, "no-store")
+}
+
+// normalizeResponseCacheRequest 返回去除流式参数后的请求体，非确定性请求返回 false
+func normalizeResponseCacheRequest(request any) ([]byte, bool) {
+	switch req := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if req.Temperature == nil || *req.Temperature != 0 {
+			return nil, false
+		}
+		if req.N != nil && *req.N > 1 {
+			return nil, false
+		}
+		normalized := *req
+		normalized.Stream = nil
+		normalized.StreamOptions = nil
+		data, err := common.Marshal(&normalized)
+		return data, err == nil
+	case *dto.EmbeddingRequest:
+		data, err := common.Marshal(req)
+		return data, err == nil
+	default:
+		return nil, false
+	}
+}
+
+// GetResponseCacheKey 计算请求的缓存键：分组 + 中继模式 + 模型映射后的规范化请求体。
+// 未启用缓存、模型未配置规则或请求不可缓存时返回空字符串
+func GetResponseCacheKey(c *gin.Context, info *relaycommon.RelayInfo, request any) string {
+	if _, ok := operation_setting.GetResponseCacheModelRule(info.OriginModelName); !ok {
+		return ""
+	}
+	body, ok := normalizeResponseCacheRequest(request)
+	if !ok {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d|", info.UsingGroup, info.RelayMode)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func responseCacheStreamKey(key string, includeUsage bool) string {
+	return fmt.Sprintf("%s:sse:%t", key, includeUsage)
+}
+
+// GetResponseCacheEntry 查找缓存响应。流式请求优先返回原样缓存的 SSE 响应，
+// 其次返回非流式响应（由调用方转换为 SSE）
+func GetResponseCacheEntry(c *gin.Context, info *relaycommon.RelayInfo, key string) (*ResponseCacheEntry, bool) {
+	if key == "" || shouldBypassResponseCache(c) {
+		return nil, false
+	}
+	cache := getResponseCache()
+	if info.IsStream {
+		entry, found, err := cache.Get(responseCacheStreamKey(key, info.ShouldIncludeUsage))
+		if err != nil {
+			logger.LogWarn(c, "get response cache failed: "+err.Error())
+		}
+		if found {
+			return &entry, true
+		}
+	}
+	entry, found, err := cache.Get(key)
+	if err != nil {
+		logger.LogWarn(c, "get response cache failed: "+err.Error())
+	}
+	if !found {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// MarkResponseCacheHit 标记请求由缓存响应，供计费与日志使用
+func MarkResponseCacheHit(c *gin.Context) {
+	common.SetContextKey(c, constant.ContextKeyResponseCacheHit, true)
+	c.Header(ResponseCacheStatusHeader, "HIT")
+}
+
+// IsResponseCacheHit 请求是否由缓存响应
+func IsResponseCacheHit(c *gin.Context) bool {
+	return common.GetContextKeyBool(c, constant.ContextKeyResponseCacheHit)
+}
+
+// responseCaptureWriter 在写出响应的同时复制一份，超过上限后放弃复制；
+// truncate 为 true 时保留上限以内的部分
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	limit    int
+	truncate bool
+	overflow bool
+}
+
+func (w *responseCaptureWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.buf.Len()+len(data) > w.limit {
+		w.overflow = true
+		if w.truncate {
+			w.buf.Write(data[:w.limit-w.buf.Len()])
+		} else {
+			w.buf = bytes.Buffer{}
+		}
+		return
+	}
+	w.buf.Write(data)
+}
+
+func (w *responseCaptureWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.capture(data[:n])
+	return n, err
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.capture([]byte(s[:n]))
+	return n, err
+}
+
+// ResponseCacheCapture 记录一次未命中请求的响应，请求成功后写入缓存
+type ResponseCacheCapture struct {
+	c      *gin.Context
+	key    string
+	ttl    time.Duration
+	origin gin.ResponseWriter
+	writer *responseCaptureWriter
+}
+
+// BeginResponseCacheCapture 替换 c.Writer 以复制响应，key 为空时返回 nil。
+// 调用方需在请求结束时调用 Close 恢复原 Writer
+func BeginResponseCacheCapture(c *gin.Context, info *relaycommon.RelayInfo, key string) *ResponseCacheCapture {
+	if key == "" {
+		return nil
+	}
+	rule, ok := operation_setting.GetResponseCacheModelRule(info.OriginModelName)
+	if !ok || rule.MaxBodyBytes <= 0 {
+		return nil
+	}
+	c.Header(ResponseCacheStatusHeader, "MISS")
+	writer := &responseCaptureWriter{ResponseWriter: c.Writer, limit: rule.MaxBodyBytes}
+	capture := &ResponseCacheCapture{
+		c:      c,
+		key:    key,
+		ttl:    time.Duration(rule.TTLSeconds) * time.Second,
+		origin: c.Writer,
+		writer: writer,
+	}
+	c.Writer = writer
+	return capture
+}
+
+// Close 恢复原 Writer
+func (rc *ResponseCacheCapture) Close() {
+	if rc == nil {
+		return
+	}
+	if rc.c.Writer == rc.writer {
+		rc.c.Writer = rc.origin
+	}
+}
+
+// Store 将成功的响应写入缓存，响应超过大小上限或状态码非 200 时跳过
+func (rc *ResponseCacheCapture) Store(info *relaycommon.RelayInfo, usage *dto.Usage) {
+	if rc == nil || usage == nil || rc.writer.overflow || rc.writer.buf.Len() == 0 {
+		return
+	}
+	if rc.writer.Status() != http.StatusOK {
+		return
+	}
+	contentType := rc.writer.Header().Get("Content-Type")
+	stream := strings.HasPrefix(contentType, "text/event-stream")
+	key := rc.key
+	if stream {
+		key = responseCacheStreamKey(rc.key, info.ShouldIncludeUsage)
+	}
+	entry := ResponseCacheEntry{
+		Body:        rc.writer.buf.String(),
+		ContentType: contentType,
+		Stream:      stream,
+		Usage:       *usage,
+		CreatedAt:   time.Now().Unix(),
+	}
+	if err := getResponseCache().SetWithTTL(key, entry, rc.ttl); err != nil {
+		logger.LogWarn(rc.c, "set response cache failed: "+err.Error())
+	}
+}
+
+type ResponseCacheStats struct {
+	Enabled       bool   `json:"enabled"`
+	Total         int    `json:"total"`
+	CacheCapacity int    `json:"cache_capacity"`
+	CacheAlgo     string `json:"cache_algo"`
+}
+
+func GetResponseCacheStats() (ResponseCacheStats, error) {
+	cache := getResponseCache()
+	mainCap, _ := cache.Capacity()
+	mainAlgo, _ := cache.Algorithm()
+	keys, err := cache.Keys()
+	return ResponseCacheStats{
+		Enabled:       operation_setting.GetResponseCacheSetting().Enabled,
+		Total:         len(keys),
+		CacheCapacity: mainCap,
+		CacheAlgo:     mainAlgo,
+	}, err
+}
+
+// ClearResponseCache 清空响应缓存
+func ClearResponseCache() error {
+	return getResponseCache().Purge()
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enableResponseCache(t *testing.T) *operation_setting.ResponseCacheSetting {
+	setting := operation_setting.GetResponseCacheSetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.ModelRules = map[string]operation_setting.ResponseCacheModelRule{
+		"*":            {TTLSeconds: 60, MaxBodyBytes: 1024},
+		"gpt-uncached": {TTLSeconds: 0},
+	}
+	t.Cleanup(func() {
+		*setting = saved
+		_ = ClearResponseCache()
+	})
+	return setting
+}
+
+func TestGetResponseCacheKey(t *testing.T) {
+	enableResponseCache(t)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	info := &relaycommon.RelayInfo{OriginModelName: "gpt-4o", UsingGroup: "default"}
+
+	chat := func(stream bool, temperature *float64) *dto.GeneralOpenAIRequest {
+		return &dto.GeneralOpenAIRequest{
+			Model:       "gpt-4o-mapped",
+			Messages:    []dto.Message{{Role: "user", Content: "hi"}},
+			Stream:      common.GetPointer(stream),
+			Temperature: temperature,
+		}
+	}
+
+	key := GetResponseCacheKey(ctx, info, chat(false, common.GetPointer(0.0)))
+	require.NotEmpty(t, key)
+	// 流式参数不参与缓存键
+	assert.Equal(t, key, GetResponseCacheKey(ctx, info, chat(true, common.GetPointer(0.0))))
+	// 非确定性请求不缓存
+	assert.Empty(t, GetResponseCacheKey(ctx, info, chat(false, nil)))
+	assert.Empty(t, GetResponseCacheKey(ctx, info, chat(false, common.GetPointer(0.7))))
+
+	otherGroup := &relaycommon.RelayInfo{OriginModelName: "gpt-4o", UsingGroup: "vip"}
+	assert.NotEqual(t, key, GetResponseCacheKey(ctx, otherGroup, chat(false, common.GetPointer(0.0))))
+
+	uncached := &relaycommon.RelayInfo{OriginModelName: "gpt-uncached", UsingGroup: "default"}
+	assert.Empty(t, GetResponseCacheKey(ctx, uncached, chat(false, common.GetPointer(0.0))))
+
+	embedding := &dto.EmbeddingRequest{Model: "text-embedding-3-small", Input: "hello"}
+	assert.NotEmpty(t, GetResponseCacheKey(ctx, info, embedding))
+}
+
+func TestResponseCacheCaptureAndLookup(t *testing.T) {
+	enableResponseCache(t)
+	info := &relaycommon.RelayInfo{OriginModelName: "text-embedding-3-small", UsingGroup: "default"}
+	request := &dto.EmbeddingRequest{Model: "text-embedding-3-small", Input: "hello"}
+	usage := &dto.Usage{PromptTokens: 2, TotalTokens: 2}
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+	key := GetResponseCacheKey(ctx, info, request)
+	_, found := GetResponseCacheEntry(ctx, info, key)
+	require.False(t, found)
+
+	capture := BeginResponseCacheCapture(ctx, info, key)
+	require.NotNil(t, capture)
+	ctx.Data(http.StatusOK, "application/json", []byte(`{"data":[]}`))
+	capture.Store(info, usage)
+	capture.Close()
+	assert.Equal(t, `{"data":[]}`, rec.Body.String())
+	assert.Equal(t, "MISS", rec.Header().Get(ResponseCacheStatusHeader))
+
+	ctx, _ = gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+	entry, found := GetResponseCacheEntry(ctx, info, key)
+	require.True(t, found)
+	assert.Equal(t, `{"data":[]}`, entry.Body)
+	assert.False(t, entry.Stream)
+	assert.Equal(t, 2, entry.Usage.PromptTokens)
+
+	// 客户端要求跳过缓存
+	ctx.Request.Header.Set(ResponseCacheBypassHeader, "true")
+	_, found = GetResponseCacheEntry(ctx, info, key)
+	assert.False(t, found)
+
+	// 超过大小上限的响应不缓存
+	_ = ClearResponseCache()
+	ctx, _ = gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/embeddings", nil)
+	capture = BeginResponseCacheCapture(ctx, info, key)
+	ctx.Data(http.StatusOK, "application/json", make([]byte, 2048))
+	capture.Store(info, usage)
+	capture.Close()
+	_, found = GetResponseCacheEntry(ctx, info, key)
+	assert.False(t, found)
+}
+
+func TestGetResponseCacheHitQuota(t *testing.T) {
+	setting := enableResponseCache(t)
+	setting.BillingMode = operation_setting.ResponseCacheBillingFull
+	assert.Equal(t, 1000, operation_setting.GetResponseCacheHitQuota(1000))
+	setting.BillingMode = operation_setting.ResponseCacheBillingRatio
+	setting.BillingRatio = 0.25
+	assert.Equal(t, 250, operation_setting.GetResponseCacheHitQuota(1000))
+	setting.BillingMode = operation_setting.ResponseCacheBillingFree
+	assert.Equal(t, 0, operation_setting.GetResponseCacheHitQuota(1000))
+}
+
+func TestResponseCacheCapacityFollowsSetting(t *testing.T) {
+	setting := enableResponseCache(t)
+	setting.MaxEntries = 100
+	capacity, _ := getResponseCache().Capacity()
+	assert.Equal(t, 100, capacity)
+
+	setting.MaxEntries = 200
+	capacity, _ = getResponseCache().Capacity()
+	assert.Equal(t, 200, capacity)
+}
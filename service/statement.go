@@ -0,0 +1,375 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+)
+
+const statementPeriodLayout = "2006-01"
+
+// StatementPeriod 返回时间所在的对账月份，按服务器时区划分
+func StatementPeriod(t time.Time) string {
+	return t.In(time.Local).Format(statementPeriodLayout)
+}
+
+type StatementTopUp struct {
+	Time          int64   `json:"time"`
+	TradeNo       string  `json:"trade_no"`
+	PaymentMethod string  `json:"payment_method"`
+	Money         float64 `json:"money"`
+	Quota         int64   `json:"quota"`
+}
+
+type StatementRedemption struct {
+	Time  int64  `json:"time"`
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Quota int64  `json:"quota"`
+}
+
+type StatementSubscription struct {
+	Time          int64   `json:"time"`
+	TradeNo       string  `json:"trade_no"`
+	PlanTitle     string  `json:"plan_title"`
+	PaymentMethod string  `json:"payment_method"`
+	Money         float64 `json:"money"`
+}
+
+// Statement 用户或令牌的月度对账单。
+// 期初余额取自月初快照，期末余额取自次月快照或当前余额；缺少快照时由当前余额与之后的收支倒推，
+// 此时 BalanceEstimated 为 true。Adjustment 为余额变化中无法由充值、兑换与消费解释的部分，如管理员调整额度
+type Statement struct {
+	Period           string                       `json:"period"`
+	StartTime        int64                        `json:"start_time"`
+	EndTime          int64                        `json:"end_time"`
+	GeneratedAt      int64                        `json:"generated_at"`
+	UserId           int                          `json:"user_id"`
+	Username         string                       `json:"username"`
+	TokenId          int                          `json:"token_id,omitempty"`
+	TokenName        string                       `json:"token_name,omitempty"`
+	Unlimited        bool                         `json:"unlimited,omitempty"`
+	OpeningBalance   int64                        `json:"opening_balance"`
+	ClosingBalance   int64                        `json:"closing_balance"`
+	BalanceEstimated bool                         `json:"balance_estimated"`
+	TopUpQuota       int64                        `json:"top_up_quota"`
+	RedemptionQuota  int64                        `json:"redemption_quota"`
+	ConsumedQuota    int64                        `json:"consumed_quota"`
+	RefundedQuota    int64                        `json:"refunded_quota"`
+	AdjustmentQuota  int64                        `json:"adjustment_quota"`
+	PaidMoney        float64                      `json:"paid_money"`
+	TopUps           []StatementTopUp             `json:"top_ups"`
+	Redemptions      []StatementRedemption        `json:"redemptions"`
+	Subscriptions    []StatementSubscription      `json:"subscriptions"`
+	Usage            []*model.StatementModelUsage `json:"usage"`
+}
+
+// BuildStatement 生成指定月份的对账单，tokenId 非 0 时只统计该令牌的消费与剩余额度
+func BuildStatement(userId int, tokenId int, period string) (*Statement, error) {
+	start, err := time.ParseInLocation(statementPeriodLayout, period, time.Local)
+	if err != nil {
+		return nil, errors.New("对账月份格式错误，应为 YYYY-MM")
+	}
+	now := time.Now()
+	if start.After(now) {
+		return nil, errors.New("对账月份不能晚于当前月份")
+	}
+	end := start.AddDate(0, 1, 0)
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	statement := &Statement{
+		Period:      period,
+		StartTime:   start.Unix(),
+		EndTime:     end.Unix(),
+		GeneratedAt: now.Unix(),
+		UserId:      user.Id,
+		Username:    user.Username,
+	}
+	currentBalance := int64(user.Quota)
+	if tokenId != 0 {
+		token, err := model.GetTokenByIds(tokenId, userId)
+		if err != nil {
+			return nil, errors.New("令牌不存在")
+		}
+		statement.TokenId = token.Id
+		statement.TokenName = token.Name
+		statement.Unlimited = token.UnlimitedQuota
+		currentBalance = int64(token.RemainQuota)
+	}
+
+	if err = statement.loadItems(); err != nil {
+		return nil, err
+	}
+	if statement.Unlimited {
+		return statement, nil
+	}
+	if err = statement.loadBalances(currentBalance, now); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+func (s *Statement) loadItems() error {
+	var err error
+	if s.TokenId == 0 {
+		topUps, err := model.GetStatementTopUps(s.UserId, s.StartTime, s.EndTime)
+		if err != nil {
+			return err
+		}
+		for _, topUp := range topUps {
+			quota := topUp.CreditedQuota()
+			s.TopUps = append(s.TopUps, StatementTopUp{
+				Time:          topUp.CompleteTime,
+				TradeNo:       topUp.TradeNo,
+				PaymentMethod: topUp.PaymentMethod,
+				Money:         topUp.Money,
+				Quota:         quota,
+			})
+			s.TopUpQuota += quota
+			s.PaidMoney += topUp.Money
+		}
+		redemptions, err := model.GetStatementRedemptions(s.UserId, s.StartTime, s.EndTime)
+		if err != nil {
+			return err
+		}
+		for _, redemption := range redemptions {
+			s.Redemptions = append(s.Redemptions, StatementRedemption{
+				Time:  redemption.RedeemedTime,
+				Id:    redemption.Id,
+				Name:  redemption.Name,
+				Quota: int64(redemption.Quota),
+			})
+			s.RedemptionQuota += int64(redemption.Quota)
+		}
+		orders, err := model.GetStatementSubscriptionOrders(s.UserId, s.StartTime, s.EndTime)
+		if err != nil {
+			return err
+		}
+		planIds := make([]int, 0, len(orders))
+		for _, order := range orders {
+			planIds = append(planIds, order.PlanId)
+		}
+		titles, err := model.GetSubscriptionPlanTitles(planIds)
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			s.Subscriptions = append(s.Subscriptions, StatementSubscription{
+				Time:          order.CompleteTime,
+				TradeNo:       order.TradeNo,
+				PlanTitle:     titles[order.PlanId],
+				PaymentMethod: order.PaymentMethod,
+				Money:         order.Money,
+			})
+			s.PaidMoney += order.Money
+		}
+	}
+	if s.Usage, err = model.GetStatementModelUsage(s.UserId, s.TokenId, s.StartTime, s.EndTime); err != nil {
+		return err
+	}
+	if s.ConsumedQuota, err = model.SumStatementLogQuota(s.UserId, s.TokenId, model.LogTypeConsume, s.StartTime, s.EndTime); err != nil {
+		return err
+	}
+	s.RefundedQuota, err = model.SumStatementLogQuota(s.UserId, s.TokenId, model.LogTypeRefund, s.StartTime, s.EndTime)
+	return err
+}
+
+// netChange 计算时间范围内可由记录解释的余额变化
+func (s *Statement) netChange(startTime int64, endTime int64) (int64, error) {
+	if endTime <= startTime {
+		return 0, nil
+	}
+	var change int64
+	if s.TokenId == 0 {
+		topUps, err := model.GetStatementTopUps(s.UserId, startTime, endTime)
+		if err != nil {
+			return 0, err
+		}
+		for _, topUp := range topUps {
+			change += topUp.CreditedQuota()
+		}
+		redemptions, err := model.GetStatementRedemptions(s.UserId, startTime, endTime)
+		if err != nil {
+			return 0, err
+		}
+		for _, redemption := range redemptions {
+			change += int64(redemption.Quota)
+		}
+	}
+	consumed, err := model.SumStatementLogQuota(s.UserId, s.TokenId, model.LogTypeConsume, startTime, endTime)
+	if err != nil {
+		return 0, err
+	}
+	refunded, err := model.SumStatementLogQuota(s.UserId, s.TokenId, model.LogTypeRefund, startTime, endTime)
+	if err != nil {
+		return 0, err
+	}
+	return change - consumed + refunded, nil
+}
+
+// balanceAt 根据月初快照得到 at 时刻的余额，快照在月初之后写入时扣除期间的收支
+func (s *Statement) balanceAt(period string, at int64) (balance int64, ok bool, err error) {
+	snapshot, err := model.GetBalanceSnapshot(period, s.UserId, s.TokenId)
+	if err != nil || snapshot == nil {
+		return 0, false, err
+	}
+	change, err := s.netChange(at, snapshot.CreatedAt)
+	if err != nil {
+		return 0, false, err
+	}
+	return snapshot.Quota - change, true, nil
+}
+
+func (s *Statement) loadBalances(currentBalance int64, now time.Time) error {
+	net := s.TopUpQuota + s.RedemptionQuota - s.ConsumedQuota + s.RefundedQuota
+
+	closing, closingOk, err := s.balanceAt(StatementPeriod(time.Unix(s.EndTime, 0)), s.EndTime)
+	if err != nil {
+		return err
+	}
+	if !closingOk {
+		change, err := s.netChange(s.EndTime, now.Unix())
+		if err != nil {
+			return err
+		}
+		closing = currentBalance - change
+		// 当月对账单的期末余额即当前余额
+		s.BalanceEstimated = s.EndTime <= now.Unix()
+	}
+	opening, openingOk, err := s.balanceAt(s.Period, s.StartTime)
+	if err != nil {
+		return err
+	}
+	if !openingOk {
+		opening = closing - net
+		s.BalanceEstimated = true
+	}
+	s.OpeningBalance = opening
+	s.ClosingBalance = closing
+	s.AdjustmentQuota = closing - opening - net
+	return nil
+}
+
+// StatementLineColumns 对账单明细导出的列
+var StatementLineColumns = []string{"section", "time", "description", "reference", "quota", "amount", "money"}
+
+// StatementLines 将对账单展开为明细行，额度增加为正、减少为负
+func (s *Statement) StatementLines() [][]any {
+	line := func(section string, timestamp int64, description string, reference string, quota int64, money float64) []any {
+		return []any{section, time.Unix(timestamp, 0).Format(time.RFC3339), description, reference, quota, float64(quota) / common.QuotaPerUnit, money}
+	}
+	var lines [][]any
+	if !s.Unlimited {
+		lines = append(lines, line("opening_balance", s.StartTime, "", "", s.OpeningBalance, 0))
+	}
+	for _, topUp := range s.TopUps {
+		lines = append(lines, line("top_up", topUp.Time, topUp.PaymentMethod, topUp.TradeNo, topUp.Quota, topUp.Money))
+	}
+	for _, redemption := range s.Redemptions {
+		lines = append(lines, line("redemption", redemption.Time, redemption.Name, fmt.Sprintf("%d", redemption.Id), redemption.Quota, 0))
+	}
+	for _, subscription := range s.Subscriptions {
+		lines = append(lines, line("subscription", subscription.Time, subscription.PlanTitle, subscription.TradeNo, 0, subscription.Money))
+	}
+	for _, usage := range s.Usage {
+		description := fmt.Sprintf("%d requests, %d prompt tokens, %d completion tokens", usage.Count, usage.PromptTokens, usage.CompletionTokens)
+		lines = append(lines, line("usage", s.EndTime, usage.ModelName+": "+description, "", -usage.Quota, 0))
+	}
+	if s.RefundedQuota != 0 {
+		lines = append(lines, line("refund", s.EndTime, "", "", s.RefundedQuota, 0))
+	}
+	if !s.Unlimited {
+		if s.AdjustmentQuota != 0 {
+			lines = append(lines, line("adjustment", s.EndTime, "", "", s.AdjustmentQuota, 0))
+		}
+		lines = append(lines, line("closing_balance", s.EndTime, "", "", s.ClosingBalance, 0))
+	}
+	return lines
+}
+
+var statementTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"quota": func(quota int64) string { return logger.FormatQuota(int(quota)) },
+	"date": func(timestamp int64) string {
+		return time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
+	},
+	"money": func(money float64) string { return fmt.Sprintf("%.2f", money) },
+	"neg":   func(quota int64) int64 { return -quota },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.SystemName}} Statement {{.S.Period}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; max-width: 880px; margin: 32px auto; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+h2 { font-size: 16px; margin-top: 28px; border-bottom: 1px solid #ddd; padding-bottom: 4px; }
+table { width: 100%; border-collapse: collapse; font-size: 13px; }
+th, td { text-align: left; padding: 6px 8px; border-bottom: 1px solid #eee; }
+td.num, th.num { text-align: right; }
+.meta { color: #666; font-size: 13px; }
+.note { color: #a60; font-size: 12px; margin-top: 8px; }
+</style>
+</head>
+<body>
+<h1>{{.SystemName}} · Statement {{.S.Period}}</h1>
+<div class="meta">
+User: {{.S.Username}} (#{{.S.UserId}}){{if .S.TokenId}} · Token: {{.S.TokenName}} (#{{.S.TokenId}}){{end}}<br>
+Period: {{date .S.StartTime}} – {{date .S.EndTime}} · Generated: {{date .S.GeneratedAt}}
+</div>
+
+<h2>Summary</h2>
+<table>
+{{if not .S.Unlimited}}<tr><td>Opening balance</td><td class="num">{{quota .S.OpeningBalance}}</td></tr>{{end}}
+{{if not .S.TokenId}}<tr><td>Top-ups</td><td class="num">{{quota .S.TopUpQuota}}</td></tr>
+<tr><td>Redemptions</td><td class="num">{{quota .S.RedemptionQuota}}</td></tr>{{end}}
+<tr><td>Consumption</td><td class="num">{{quota (neg .S.ConsumedQuota)}}</td></tr>
+<tr><td>Refunds</td><td class="num">{{quota .S.RefundedQuota}}</td></tr>
+{{if not .S.Unlimited}}<tr><td>Other adjustments</td><td class="num">{{quota .S.AdjustmentQuota}}</td></tr>
+<tr><th>Closing balance</th><th class="num">{{quota .S.ClosingBalance}}</th></tr>{{end}}
+{{if not .S.TokenId}}<tr><td>Total paid</td><td class="num">{{money .S.PaidMoney}}</td></tr>{{end}}
+</table>
+{{if .S.BalanceEstimated}}<div class="note">Balances are estimated from current balance and recorded transactions because no monthly snapshot exists for this period.</div>{{end}}
+
+{{if .S.TopUps}}<h2>Top-ups</h2>
+<table>
+<tr><th>Time</th><th>Order</th><th>Method</th><th class="num">Paid</th><th class="num">Credited</th></tr>
+{{range .S.TopUps}}<tr><td>{{date .Time}}</td><td>{{.TradeNo}}</td><td>{{.PaymentMethod}}</td><td class="num">{{money .Money}}</td><td class="num">{{quota .Quota}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .S.Redemptions}}<h2>Redemptions</h2>
+<table>
+<tr><th>Time</th><th>Code</th><th class="num">Credited</th></tr>
+{{range .S.Redemptions}}<tr><td>{{date .Time}}</td><td>{{.Name}} (#{{.Id}})</td><td class="num">{{quota .Quota}}</td></tr>
+{{end}}</table>{{end}}
+
+{{if .S.Subscriptions}}<h2>Subscriptions</h2>
+<table>
+<tr><th>Time</th><th>Order</th><th>Plan</th><th>Method</th><th class="num">Paid</th></tr>
+{{range .S.Subscriptions}}<tr><td>{{date .Time}}</td><td>{{.TradeNo}}</td><td>{{.PlanTitle}}</td><td>{{.PaymentMethod}}</td><td class="num">{{money .Money}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Usage</h2>
+<table>
+<tr><th>Model</th><th class="num">Requests</th><th class="num">Prompt tokens</th><th class="num">Completion tokens</th><th class="num">Cost</th></tr>
+{{range .S.Usage}}<tr><td>{{.ModelName}}</td><td class="num">{{.Count}}</td><td class="num">{{.PromptTokens}}</td><td class="num">{{.CompletionTokens}}</td><td class="num">{{quota .Quota}}</td></tr>
+{{else}}<tr><td colspan="5">No usage</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// RenderStatementHTML 输出可打印的 HTML 对账单
+func RenderStatementHTML(w io.Writer, s *Statement) error {
+	return statementTemplate.Execute(w, map[string]any{
+		"SystemName": common.SystemName,
+		"S":          s,
+	})
+}
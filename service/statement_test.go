@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildStatement(t *testing.T) {
+	require.NoError(t, model.DB.AutoMigrate(&model.TopUp{}, &model.Redemption{}, &model.SubscriptionOrder{}, &model.SubscriptionPlan{}, &model.BalanceSnapshot{}))
+	t.Cleanup(func() {
+		for _, table := range []string{"users", "logs", "top_ups", "redemptions", "subscription_orders", "subscription_plans", "balance_snapshots"} {
+			model.DB.Exec("DELETE FROM " + table)
+		}
+	})
+
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local)
+	mid := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local).Unix()
+	credited := int64(common.QuotaPerUnit)
+
+	user := &model.User{Id: 1, Username: "statement_user", Password: "password", Quota: int(100000 + credited + 20000 - 30000 + 5000)}
+	require.NoError(t, model.DB.Create(user).Error)
+	require.NoError(t, model.DB.Create(&model.BalanceSnapshot{Period: "2025-03", UserId: 1, Quota: 100000, CreatedAt: start.Unix()}).Error)
+	require.NoError(t, model.DB.Create(&model.TopUp{UserId: 1, Amount: 1, Money: 7.3, TradeNo: "topup-1", PaymentMethod: "alipay", Status: common.TopUpStatusSuccess, CompleteTime: mid}).Error)
+	require.NoError(t, model.DB.Create(&model.SubscriptionPlan{Id: 1, Title: "Pro"}).Error)
+	require.NoError(t, model.DB.Create(&model.SubscriptionOrder{UserId: 1, PlanId: 1, Money: 9.9, TradeNo: "sub-1", PaymentMethod: "stripe", Status: common.TopUpStatusSuccess, CompleteTime: mid}).Error)
+	require.NoError(t, model.DB.Create(&model.TopUp{UserId: 1, Money: 9.9, TradeNo: "sub-1", PaymentMethod: "stripe", Status: common.TopUpStatusSuccess, CompleteTime: mid}).Error)
+	require.NoError(t, model.DB.Create(&model.Redemption{Key: "statement-redemption", Name: "gift", Quota: 20000, UsedUserId: 1, RedeemedTime: mid}).Error)
+	require.NoError(t, model.DB.Create(&model.Log{UserId: 1, Type: model.LogTypeConsume, ModelName: "gpt-4o", Quota: 30000, PromptTokens: 100, CompletionTokens: 50, CreatedAt: mid}).Error)
+	require.NoError(t, model.DB.Create(&model.Log{UserId: 1, Type: model.LogTypeRefund, ModelName: "gpt-4o", Quota: 5000, CreatedAt: mid}).Error)
+
+	statement, err := BuildStatement(1, 0, "2025-03")
+	require.NoError(t, err)
+	require.Len(t, statement.TopUps, 1)
+	require.Equal(t, credited, statement.TopUpQuota)
+	require.Len(t, statement.Subscriptions, 1)
+	require.Equal(t, "Pro", statement.Subscriptions[0].PlanTitle)
+	require.InDelta(t, 17.2, statement.PaidMoney, 1e-9)
+	require.Equal(t, int64(20000), statement.RedemptionQuota)
+	require.Equal(t, int64(30000), statement.ConsumedQuota)
+	require.Equal(t, int64(5000), statement.RefundedQuota)
+	require.Equal(t, int64(100000), statement.OpeningBalance)
+	require.Equal(t, int64(user.Quota), statement.ClosingBalance)
+	require.Zero(t, statement.AdjustmentQuota)
+	// 次月没有快照时期末余额由当前余额倒推
+	require.True(t, statement.BalanceEstimated)
+
+	// 次月快照与记录不一致的部分计为调整
+	require.NoError(t, model.DB.Create(&model.BalanceSnapshot{Period: "2025-04", UserId: 1, Quota: int64(user.Quota) + 1000, CreatedAt: start.AddDate(0, 1, 0).Unix()}).Error)
+	statement, err = BuildStatement(1, 0, "2025-03")
+	require.NoError(t, err)
+	require.False(t, statement.BalanceEstimated)
+	require.Equal(t, int64(user.Quota)+1000, statement.ClosingBalance)
+	require.Equal(t, int64(1000), statement.AdjustmentQuota)
+
+	_, err = BuildStatement(1, 0, "2025-13")
+	require.Error(t, err)
+}
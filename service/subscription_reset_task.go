@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 
@@ -54,7 +55,15 @@ func runSubscriptionQuotaResetOnce() {
 	totalReset := 0
 	totalExpired := 0
 	for {
-		n, err := model.ExpireDueSubscriptions(subscriptionResetBatchSize)
+		n, expired, err := model.ExpireDueSubscriptions(subscriptionResetBatchSize)
+		for _, sub := range expired {
+			EmitWebhookEvent(dto.WebhookEventSubscriptionExpired, sub.UserId, map[string]any{
+				"subscription_id": sub.Id,
+				"plan_id":         sub.PlanId,
+				"start_time":      sub.StartTime,
+				"end_time":        sub.EndTime,
+			})
+		}
 		if err != nil {
 			logger.LogWarn(ctx, fmt.Sprintf("subscription expire task failed: %v", err))
 			return
@@ -42,6 +42,7 @@ func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) {
 	if info.PriceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = info.PriceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	appendTimeWindowInfo(info.PriceData, other)
 	if info.IsModelMapped {
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = info.UpstreamModelName
@@ -80,11 +81,14 @@ func taskIsSubscription(task *model.Task) bool {
 	return task.PrivateData.BillingSource == BillingSourceSubscription && task.PrivateData.SubscriptionId > 0
 }
 
-// taskAdjustFunding 调整任务的资金来源（钱包或订阅），delta > 0 表示扣费，delta < 0 表示退还。
+// taskAdjustFunding 调整任务的资金来源（钱包、订阅或组织），delta > 0 表示扣费，delta < 0 表示退还。
 func taskAdjustFunding(task *model.Task, delta int) error {
 	if taskIsSubscription(task) {
 		return model.PostConsumeUserSubscriptionDelta(task.PrivateData.SubscriptionId, int64(delta))
 	}
+	if task.PrivateData.BillingSource == BillingSourceOrganization && task.PrivateData.OrganizationId > 0 {
+		return model.PostConsumeOrganizationQuota(task.PrivateData.OrganizationId, task.UserId, delta)
+	}
 	if delta > 0 {
 		return model.DecreaseUserQuota(task.UserId, delta)
 	}
@@ -170,7 +174,9 @@ func RefundTaskQuota(ctx context.Context, task *model.Task, reason string) {
 		Quota:     quota,
 		TokenId:   task.PrivateData.TokenId,
 		Group:     task.Group,
-		Other:     other,
+		// 组织任务的日志计入组织用量
+		OrganizationId: task.PrivateData.OrganizationId,
+		Other:          other,
 	})
 }
 
@@ -234,7 +240,9 @@ func RecalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int
 		Quota:     logQuota,
 		TokenId:   task.PrivateData.TokenId,
 		Group:     task.Group,
-		Other:     other,
+		// 组织任务的日志计入组织用量
+		OrganizationId: task.PrivateData.OrganizationId,
+		Other:          other,
 	})
 }
 
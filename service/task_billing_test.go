@@ -43,6 +43,7 @@ func TestMain(m *testing.M) {
 		&model.Log{},
 		&model.Channel{},
 		&model.UserSubscription{},
+		&model.File{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
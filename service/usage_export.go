@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatXLSX  = "xlsx"
+	ExportFormatJSONL = "jsonl"
+)
+
+// TabularWriter 按行流式写出导出文件，写完后需调用 Close
+type TabularWriter interface {
+	WriteRow(values []any) error
+	Flush() error
+	Close() error
+}
+
+// ExportContentType 返回导出格式对应的 Content-Type，格式不支持时返回空
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case ExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ExportFormatJSONL:
+		return "application/x-ndjson; charset=utf-8"
+	}
+	return ""
+}
+
+// NewTabularWriter 创建指定格式的 Writer，csv 与 xlsx 会先写出表头，jsonl 以列名作为字段名
+func NewTabularWriter(w io.Writer, format string, sheetName string, columns []string) (TabularWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		writer := &csvTabularWriter{w: csv.NewWriter(w)}
+		if err := writer.w.Write(columns); err != nil {
+			return nil, err
+		}
+		return writer, nil
+	case ExportFormatXLSX:
+		xw, err := common.NewXLSXWriter(w, sheetName)
+		if err != nil {
+			return nil, err
+		}
+		header := make([]any, len(columns))
+		for i, column := range columns {
+			header[i] = column
+		}
+		if err = xw.WriteRow(header); err != nil {
+			return nil, err
+		}
+		return xw, nil
+	case ExportFormatJSONL:
+		return &jsonlTabularWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format: %s", format)
+}
+
+type csvTabularWriter struct {
+	w *csv.Writer
+}
+
+func (t *csvTabularWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			record[i] = v
+		case float64:
+			record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTabularWriter) Flush() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+func (t *csvTabularWriter) Close() error {
+	return t.Flush()
+}
+
+type jsonlTabularWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+// WriteRow 按列顺序拼接 JSON 对象，保证每行字段顺序与表头一致
+func (t *jsonlTabularWriter) WriteRow(values []any) error {
+	if len(values) != len(t.columns) {
+		return errors.New("column count mismatch")
+	}
+	t.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			t.w.WriteByte(',')
+		}
+		key, _ := common.Marshal(t.columns[i])
+		data, err := common.Marshal(value)
+		if err != nil {
+			return err
+		}
+		t.w.Write(key)
+		t.w.WriteByte(':')
+		t.w.Write(data)
+	}
+	_, err := t.w.WriteString("}\n")
+	return err
+}
+
+func (t *jsonlTabularWriter) Flush() error {
+	return t.w.Flush()
+}
+
+func (t *jsonlTabularWriter) Close() error {
+	return t.Flush()
+}
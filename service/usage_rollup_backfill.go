@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// UsageRollupBackfillStatus 最近一次预聚合回填任务的状态
+type UsageRollupBackfillStatus struct {
+	Running        bool   `json:"running"`
+	StartTimestamp int64  `json:"start_timestamp"`
+	EndTimestamp   int64  `json:"end_timestamp"`
+	Processed      int    `json:"processed"`
+	StartedAt      int64  `json:"started_at"`
+	FinishedAt     int64  `json:"finished_at"`
+	Error          string `json:"error"`
+}
+
+var (
+	usageRollupBackfillLock   sync.Mutex
+	usageRollupBackfillStatus UsageRollupBackfillStatus
+)
+
+// StartUsageRollupBackfill 在后台由日志重建预聚合数据，同一时间只允许一个回填任务
+func StartUsageRollupBackfill(startTimestamp int64, endTimestamp int64) error {
+	usageRollupBackfillLock.Lock()
+	defer usageRollupBackfillLock.Unlock()
+	if usageRollupBackfillStatus.Running {
+		return errors.New("已有回填任务正在运行")
+	}
+	if _, _, err := model.UsageRollupBackfillRange(startTimestamp, endTimestamp); err != nil {
+		return err
+	}
+	usageRollupBackfillStatus = UsageRollupBackfillStatus{
+		Running:        true,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		StartedAt:      common.GetTimestamp(),
+	}
+	gopool.Go(func() {
+		err := model.BackfillUsageRollups(startTimestamp, endTimestamp, func(processed int) {
+			usageRollupBackfillLock.Lock()
+			usageRollupBackfillStatus.Processed = processed
+			usageRollupBackfillLock.Unlock()
+		})
+		usageRollupBackfillLock.Lock()
+		defer usageRollupBackfillLock.Unlock()
+		usageRollupBackfillStatus.Running = false
+		usageRollupBackfillStatus.FinishedAt = common.GetTimestamp()
+		if err != nil {
+			usageRollupBackfillStatus.Error = err.Error()
+			logger.LogWarn(context.Background(), fmt.Sprintf("usage rollup backfill failed: %v", err))
+			return
+		}
+		logger.LogInfo(context.Background(), fmt.Sprintf("usage rollup backfill finished: processed=%d", usageRollupBackfillStatus.Processed))
+	})
+	return nil
+}
+
+func GetUsageRollupBackfillStatus() UsageRollupBackfillStatus {
+	usageRollupBackfillLock.Lock()
+	defer usageRollupBackfillLock.Unlock()
+	return usageRollupBackfillStatus
+}
@@ -54,10 +54,23 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 		return fmt.Errorf("failed to marshal webhook payload: %v", err)
 	}
 
-	// 创建 HTTP 请求
-	var req *http.Request
-	var resp *http.Response
+	resp, err := doWebhookRequest(webhookURL, secret, payloadBytes, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 检查响应状态
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
 
+// doWebhookRequest 发送已序列化的 webhook 负载，secret 非空时附带 HMAC-SHA256 签名；
+// headers 为额外请求头。调用方负责关闭响应体
+func doWebhookRequest(webhookURL string, secret string, payloadBytes []byte, headers map[string]string) (*http.Response, error) {
 	if system_setting.EnableWorker() {
 		// 构建worker请求数据
 		workerReq := &WorkerRequest{
@@ -69,6 +82,9 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 			},
 			Body: payloadBytes,
 		}
+		for k, v := range headers {
+			workerReq.Headers[k] = v
+		}
 
 		// 如果有secret，添加签名到headers
 		if secret != "" {
@@ -77,50 +93,41 @@ func SendWebhookNotify(webhookURL string, secret string, data dto.Notify) error
 			workerReq.Headers["Authorization"] = "Bearer " + secret
 		}
 
-		resp, err = DoWorkerRequest(workerReq)
-		if err != nil {
-			return fmt.Errorf("failed to send webhook request through worker: %v", err)
-		}
-		defer resp.Body.Close()
-
-		// 检查响应状态
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
-		}
-	} else {
-		// SSRF防护：验证Webhook URL（非Worker模式）
-		fetchSetting := system_setting.GetFetchSetting()
-		if err := common.ValidateURLWithFetchSetting(webhookURL, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain); err != nil {
-			return fmt.Errorf("request reject: %v", err)
-		}
-
-		req, err = http.NewRequest(http.MethodPost, webhookURL, bytes.NewBuffer(payloadBytes))
+		resp, err := DoWorkerRequest(workerReq)
 		if err != nil {
-			return fmt.Errorf("failed to create webhook request: %v", err)
+			return nil, fmt.Errorf("failed to send webhook request through worker: %v", err)
 		}
+		return resp, nil
+	}
 
-		// 设置请求头
-		req.Header.Set("Content-Type", "application/json")
+	// SSRF防护：验证Webhook URL（非Worker模式）
+	fetchSetting := system_setting.GetFetchSetting()
+	if err := common.ValidateURLWithFetchSetting(webhookURL, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain); err != nil {
+		return nil, fmt.Errorf("request reject: %v", err)
+	}
 
-		// 如果有 secret，生成签名
-		if secret != "" {
-			signature := generateSignature(secret, payloadBytes)
-			req.Header.Set("X-Webhook-Signature", signature)
-		}
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook request: %v", err)
+	}
 
-		// 发送请求
-		client := GetHttpClient()
-		resp, err = client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to send webhook request: %v", err)
-		}
-		defer resp.Body.Close()
+	// 设置请求头
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 
-		// 检查响应状态
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
-		}
+	// 如果有 secret，生成签名
+	if secret != "" {
+		signature := generateSignature(secret, payloadBytes)
+		req.Header.Set("X-Webhook-Signature", signature)
 	}
 
-	return nil
+	// 发送请求
+	client := GetHttpClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send webhook request: %v", err)
+	}
+	return resp, nil
 }
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	// 单次投递最多尝试次数，失败后按 webhookRetryBaseDelay * 2^(n-1) 退避重试
+	webhookMaxAttempts     = 8
+	webhookRetryBaseDelay  = time.Minute
+	webhookRetryMaxDelay   = 2 * time.Hour
+	webhookDeliveryLease   = 2 * time.Minute
+	webhookResponseLimit   = 2048
+	webhookRetryInterval   = 30 * time.Second
+	webhookRetryBatchSize  = 100
+	webhookRetryWorkers    = 8
+	webhookCleanupInterval = 24 * time.Hour
+	webhookRetentionDays   = 30
+)
+
+var (
+	webhookRetryOnce    sync.Once
+	webhookRetryRunning atomic.Bool
+	webhookCleanupLast  atomic.Int64
+
+	// 未启用 Redis 时事件去重的内存存储，值为过期时间
+	webhookOnceStore sync.Map
+)
+
+// webhookRetryDelay 返回第 attempts 次失败后的重试间隔
+func webhookRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMaxDelay {
+			return webhookRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+// EmitWebhookEvent 异步投递事件到订阅的端点。userId 为事件所属用户，系统事件传 0
+func EmitWebhookEvent(event string, userId int, data any) {
+	gopool.Go(func() {
+		if err := emitWebhookEvent(event, userId, data); err != nil {
+			common.SysLog(fmt.Sprintf("failed to emit webhook event %s: %s", event, err.Error()))
+		}
+	})
+}
+
+// EmitWebhookEventOnce 在 ttl 内相同 dedupeKey 的事件只投递一次
+func EmitWebhookEventOnce(dedupeKey string, ttl time.Duration, event string, userId int, data any) {
+	if !acquireWebhookOnce(event+":"+dedupeKey, ttl) {
+		return
+	}
+	EmitWebhookEvent(event, userId, data)
+}
+
+func acquireWebhookOnce(key string, ttl time.Duration) bool {
+	if common.RedisEnabled {
+		ok, err := common.RDB.SetNX(context.Background(), "webhook_once:"+key, 1, ttl).Result()
+		if err != nil {
+			common.SysLog("failed to dedupe webhook event: " + err.Error())
+			return true
+		}
+		return ok
+	}
+	now := time.Now()
+	expireAt := now.Add(ttl)
+	if value, loaded := webhookOnceStore.LoadOrStore(key, expireAt); loaded {
+		if now.Before(value.(time.Time)) {
+			return false
+		}
+		webhookOnceStore.Store(key, expireAt)
+	}
+	return true
+}
+
+func newWebhookEventPayload(event string, userId int, data any) (*dto.WebhookEventPayload, string, error) {
+	payload := &dto.WebhookEventPayload{
+		Id:        "evt_" + common.GetUUID(),
+		Type:      event,
+		UserId:    userId,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	}
+	payloadBytes, err := common.Marshal(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	return payload, string(payloadBytes), nil
+}
+
+func newWebhookDelivery(endpoint *model.WebhookEndpoint, payload *dto.WebhookEventPayload, body string) *model.WebhookDelivery {
+	now := common.GetTimestamp()
+	return &model.WebhookDelivery{
+		EndpointId: endpoint.Id,
+		UserId:     endpoint.UserId,
+		IsSystem:   endpoint.IsSystem,
+		EventId:    payload.Id,
+		Event:      payload.Type,
+		Payload:    body,
+		Status:     model.WebhookDeliveryStatusPending,
+		// 由创建者立即投递，租约到期前不会被重试任务领取
+		NextAttemptAt: now + int64(webhookDeliveryLease.Seconds()),
+		CreatedTime:   now,
+		UpdatedTime:   now,
+	}
+}
+
+func emitWebhookEvent(event string, userId int, data any) error {
+	endpoints, err := model.GetWebhookEndpointsForEvent(event, userId, dto.IsSystemWebhookEvent(event))
+	if err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+	payload, body, err := newWebhookEventPayload(event, userId, data)
+	if err != nil {
+		return err
+	}
+	deliveries := make([]*model.WebhookDelivery, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		deliveries = append(deliveries, newWebhookDelivery(endpoint, payload, body))
+	}
+	if err := model.CreateWebhookDeliveries(deliveries); err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		attemptWebhookDelivery(delivery)
+	}
+	return nil
+}
+
+// SendWebhookPing 向端点投递一次测试事件并返回投递结果
+func SendWebhookPing(endpoint *model.WebhookEndpoint) (*model.WebhookDelivery, error) {
+	payload, body, err := newWebhookEventPayload(dto.WebhookEventPing, endpoint.UserId, map[string]any{
+		"endpoint_id": endpoint.Id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery := newWebhookDelivery(endpoint, payload, body)
+	if err := model.CreateWebhookDeliveries([]*model.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+	attemptWebhookDelivery(delivery)
+	// 测试事件不重试
+	if delivery.Status == model.WebhookDeliveryStatusPending {
+		delivery.Status = model.WebhookDeliveryStatusFailed
+		saveWebhookDelivery(delivery)
+	}
+	return delivery, nil
+}
+
+// RedeliverWebhook 以相同的事件 ID 与负载重新投递，生成一条新的投递记录并立即尝试
+func RedeliverWebhook(original *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	endpoint, err := model.GetWebhookEndpointById(original.EndpointId)
+	if err != nil {
+		return nil, fmt.Errorf("webhook endpoint not found: %w", err)
+	}
+	payload := &dto.WebhookEventPayload{Id: original.EventId, Type: original.Event}
+	delivery := newWebhookDelivery(endpoint, payload, original.Payload)
+	if err := model.CreateWebhookDeliveries([]*model.WebhookDelivery{delivery}); err != nil {
+		return nil, err
+	}
+	attemptWebhookDelivery(delivery)
+	return delivery, nil
+}
+
+// attemptWebhookDelivery 执行一次投递并保存结果，失败时安排下次重试
+func attemptWebhookDelivery(delivery *model.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.ResponseCode = 0
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	endpoint, err := model.GetWebhookEndpointById(delivery.EndpointId)
+	if err != nil || endpoint.Status != model.WebhookEndpointStatusEnabled {
+		delivery.Status = model.WebhookDeliveryStatusFailed
+		delivery.Error = "webhook endpoint not found or disabled"
+		saveWebhookDelivery(delivery)
+		return
+	}
+
+	err = sendWebhookDelivery(endpoint, delivery)
+	if err == nil {
+		delivery.Status = model.WebhookDeliveryStatusSuccess
+	} else {
+		delivery.Error = err.Error()
+		if delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = model.WebhookDeliveryStatusFailed
+		} else {
+			delivery.NextAttemptAt = time.Now().Add(webhookRetryDelay(delivery.Attempts)).Unix()
+		}
+	}
+	saveWebhookDelivery(delivery)
+}
+
+func sendWebhookDelivery(endpoint *model.WebhookEndpoint, delivery *model.WebhookDelivery) error {
+	resp, err := doWebhookRequest(endpoint.Url, endpoint.Secret, []byte(delivery.Payload), map[string]string{
+		"X-Webhook-Event": delivery.Event,
+		"X-Webhook-Id":    delivery.EventId,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	delivery.ResponseCode = resp.StatusCode
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	delivery.ResponseBody = string(body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func saveWebhookDelivery(delivery *model.WebhookDelivery) {
+	if err := delivery.UpdateResult(); err != nil {
+		common.SysLog(fmt.Sprintf("failed to save webhook delivery %d: %s", delivery.Id, err.Error()))
+	}
+}
+
+// EmitTopUpCompletedWebhook 充值订单完成后投递 topup.completed 事件
+func EmitTopUpCompletedWebhook(tradeNo string) {
+	topUp := model.GetTopUpByTradeNo(tradeNo)
+	if topUp == nil {
+		return
+	}
+	EmitWebhookEvent(dto.WebhookEventTopUpCompleted, topUp.UserId, map[string]any{
+		"trade_no":       topUp.TradeNo,
+		"amount":         topUp.Amount,
+		"money":          topUp.Money,
+		"payment_method": topUp.PaymentMethod,
+		"complete_time":  topUp.CompleteTime,
+	})
+}
+
+// EmitTokenBudgetExhaustedWebhook 令牌周期预算耗尽时投递事件，每个预算周期只投递一次
+func EmitTokenBudgetExhaustedWebhook(token *model.Token) {
+	if token == nil || !token.IsBudgetEnabled() {
+		return
+	}
+	resetAt := token.GetBudgetResetTime()
+	ttl := time.Until(time.Unix(resetAt, 0))
+	if ttl <= 0 {
+		return
+	}
+	EmitWebhookEventOnce(fmt.Sprintf("%d:%d", token.Id, resetAt), ttl, dto.WebhookEventTokenBudgetExhausted, token.UserId, map[string]any{
+		"token_id":          token.Id,
+		"token_name":        token.Name,
+		"budget_period":     token.BudgetPeriod,
+		"budget_quota":      token.BudgetQuota,
+		"budget_used_quota": token.GetBudgetUsedQuota(),
+		"reset_at":          resetAt,
+	})
+}
+
+// EmitRedemptionUsedWebhook 兑换码使用成功后投递 redemption.used 事件，兑换码仅保留首尾字符
+func EmitRedemptionUsedWebhook(userId int, key string, quota int) {
+	masked := "***"
+	if len(key) > 8 {
+		masked = key[:4] + "***" + key[len(key)-4:]
+	}
+	EmitWebhookEvent(dto.WebhookEventRedemptionUsed, userId, map[string]any{
+		"redemption": masked,
+		"quota":      quota,
+	})
+}
+
+// EmitTaskFinishedWebhook 异步任务进入终态后投递 task.finished 事件
+func EmitTaskFinishedWebhook(task *model.Task) {
+	EmitWebhookEvent(dto.WebhookEventTaskFinished, task.UserId, map[string]any{
+		"task_id":     task.TaskID,
+		"platform":    task.Platform,
+		"action":      task.Action,
+		"status":      task.Status,
+		"fail_reason": task.FailReason,
+		"quota":       task.Quota,
+		"finish_time": task.FinishTime,
+	})
+}
+
+// StartWebhookDeliveryTask 启动失败投递的重试任务，仅在主节点运行
+func StartWebhookDeliveryTask() {
+	webhookRetryOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("webhook delivery task started: tick=%s", webhookRetryInterval))
+			ticker := time.NewTicker(webhookRetryInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				runWebhookDeliveryOnce()
+			}
+		})
+	})
+}
+
+func runWebhookDeliveryOnce() {
+	if !webhookRetryRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer webhookRetryRunning.Store(false)
+
+	ctx := context.Background()
+	now := time.Now()
+	deliveries, err := model.GetDueWebhookDeliveries(now.Unix(), webhookRetryBatchSize)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("webhook delivery task failed: %v", err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, webhookRetryWorkers)
+	leaseUntil := now.Add(webhookDeliveryLease).Unix()
+	for _, delivery := range deliveries {
+		claimed, err := model.ClaimWebhookDelivery(delivery, leaseUntil)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("claim webhook delivery %d failed: %v", delivery.Id, err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		gopool.Go(func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			attemptWebhookDelivery(delivery)
+		})
+	}
+	wg.Wait()
+
+	last := time.Unix(webhookCleanupLast.Load(), 0)
+	if now.Sub(last) >= webhookCleanupInterval {
+		webhookCleanupLast.Store(now.Unix())
+		cutoff := now.AddDate(0, 0, -webhookRetentionDays).Unix()
+		if n, err := model.DeleteWebhookDeliveriesBefore(cutoff); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("webhook delivery cleanup failed: %v", err))
+		} else if n > 0 {
+			logger.LogInfo(ctx, fmt.Sprintf("webhook delivery cleanup: %d deleted", n))
+		}
+		webhookOnceStore.Range(func(key, value any) bool {
+			if now.After(value.(time.Time)) {
+				webhookOnceStore.Delete(key)
+			}
+			return true
+		})
+	}
+}
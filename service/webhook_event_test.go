@@ -0,0 +1,168 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, webhookRetryDelay(0))
+	assert.Equal(t, time.Minute, webhookRetryDelay(1))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(3))
+	assert.Equal(t, webhookRetryMaxDelay, webhookRetryDelay(20))
+}
+
+func TestWebhookEndpointSubscribes(t *testing.T) {
+	endpoint := &model.WebhookEndpoint{Events: "topup.completed, task.finished"}
+	assert.True(t, endpoint.Subscribes("task.finished"))
+	assert.False(t, endpoint.Subscribes("channel.disabled"))
+
+	endpoint.Events = "*"
+	assert.True(t, endpoint.Subscribes("channel.disabled"))
+}
+
+type webhookTestRequest struct {
+	path    string
+	headers http.Header
+	body    string
+}
+
+// newWebhookTestServer 启动记录请求的测试端点，status 为返回的状态码
+func newWebhookTestServer(t *testing.T, status int) (*httptest.Server, func() []webhookTestRequest) {
+	t.Helper()
+	require.NoError(t, model.DB.AutoMigrate(&model.WebhookEndpoint{}, &model.WebhookDelivery{}))
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM webhook_endpoints")
+		model.DB.Exec("DELETE FROM webhook_deliveries")
+	})
+	if GetHttpClient() == nil {
+		InitHttpClient()
+	}
+	fetchSetting := system_setting.GetFetchSetting()
+	ssrfProtection := fetchSetting.EnableSSRFProtection
+	fetchSetting.EnableSSRFProtection = false
+	t.Cleanup(func() { fetchSetting.EnableSSRFProtection = ssrfProtection })
+
+	var mu sync.Mutex
+	var requests []webhookTestRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, webhookTestRequest{path: r.URL.Path, headers: r.Header.Clone(), body: string(body)})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []webhookTestRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookTestRequest(nil), requests...)
+	}
+}
+
+func createTestWebhookEndpoint(t *testing.T, endpoint *model.WebhookEndpoint) *model.WebhookEndpoint {
+	t.Helper()
+	if endpoint.Status == 0 {
+		endpoint.Status = model.WebhookEndpointStatusEnabled
+	}
+	require.NoError(t, endpoint.Insert())
+	return endpoint
+}
+
+func TestEmitWebhookEventFanOut(t *testing.T) {
+	server, requests := newWebhookTestServer(t, http.StatusOK)
+	own := createTestWebhookEndpoint(t, &model.WebhookEndpoint{UserId: 1, Name: "own", Url: server.URL + "/own", Secret: "own-secret", Events: dto.WebhookEventTopUpCompleted})
+	createTestWebhookEndpoint(t, &model.WebhookEndpoint{UserId: 1, IsSystem: true, Name: "system", Url: server.URL + "/system", Events: dto.WebhookEventAll})
+	createTestWebhookEndpoint(t, &model.WebhookEndpoint{UserId: 2, Name: "other user", Url: server.URL + "/other", Events: dto.WebhookEventTopUpCompleted})
+	createTestWebhookEndpoint(t, &model.WebhookEndpoint{UserId: 1, Name: "other event", Url: server.URL + "/event", Events: dto.WebhookEventTaskFinished})
+	createTestWebhookEndpoint(t, &model.WebhookEndpoint{UserId: 1, Name: "disabled", Url: server.URL + "/disabled", Events: dto.WebhookEventAll, Status: model.WebhookEndpointStatusDisabled})
+
+	require.NoError(t, emitWebhookEvent(dto.WebhookEventTopUpCompleted, 1, map[string]any{"trade_no": "T1"}))
+
+	// 只投递到用户自己订阅了该事件的端点与系统端点
+	received := requests()
+	require.Len(t, received, 2)
+	byPath := map[string]webhookTestRequest{}
+	for _, req := range received {
+		byPath[req.path] = req
+	}
+	require.Contains(t, byPath, "/own")
+	require.Contains(t, byPath, "/system")
+
+	ownReq := byPath["/own"]
+	assert.Equal(t, dto.WebhookEventTopUpCompleted, ownReq.headers.Get("X-Webhook-Event"))
+	assert.NotEmpty(t, ownReq.headers.Get("X-Webhook-Id"))
+	assert.Equal(t, ownReq.headers.Get("X-Webhook-Id"), byPath["/system"].headers.Get("X-Webhook-Id"))
+	assert.Equal(t, generateSignature(own.Secret, []byte(ownReq.body)), ownReq.headers.Get("X-Webhook-Signature"))
+	assert.Empty(t, byPath["/system"].headers.Get("X-Webhook-Signature"))
+
+	var deliveries []*model.WebhookDelivery
+	require.NoError(t, model.DB.Find(&deliveries).Error)
+	require.Len(t, deliveries, 2)
+	for _, delivery := range deliveries {
+		assert.Equal(t, model.WebhookDeliveryStatusSuccess, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusOK, delivery.ResponseCode)
+	}
+
+	// 系统事件只投递到系统端点
+	require.NoError(t, emitWebhookEvent(dto.WebhookEventChannelDisabled, 1, map[string]any{"channel_id": 1}))
+	received = requests()
+	require.Len(t, received, 3)
+	assert.Equal(t, "/system", received[2].path)
+}
+
+func TestWebhookDeliveryRetryAndDisable(t *testing.T) {
+	server, requests := newWebhookTestServer(t, http.StatusInternalServerError)
+	endpoint := createTestWebhookEndpoint(t, &model.WebhookEndpoint{UserId: 1, Name: "failing", Url: server.URL, Events: dto.WebhookEventAll})
+
+	require.NoError(t, emitWebhookEvent(dto.WebhookEventTaskFinished, 1, map[string]any{"task_id": "t"}))
+	var delivery model.WebhookDelivery
+	require.NoError(t, model.DB.First(&delivery).Error)
+	// 失败后保持待投递并按退避安排下次重试
+	assert.Equal(t, model.WebhookDeliveryStatusPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseCode)
+	assert.InDelta(t, time.Now().Add(webhookRetryDelay(1)).Unix(), delivery.NextAttemptAt, 2)
+
+	// 同一次投递只能被领取一次
+	claimed, err := model.ClaimWebhookDelivery(&delivery, time.Now().Add(webhookDeliveryLease).Unix())
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	stale := delivery
+	stale.NextAttemptAt = 0
+	claimed, err = model.ClaimWebhookDelivery(&stale, time.Now().Unix())
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// 达到最大尝试次数后标记为失败
+	delivery.Attempts = webhookMaxAttempts - 1
+	attemptWebhookDelivery(&delivery)
+	assert.Equal(t, model.WebhookDeliveryStatusFailed, delivery.Status)
+	assert.Equal(t, webhookMaxAttempts, delivery.Attempts)
+	assert.Len(t, requests(), 2)
+
+	// 端点停用后待重试的投递直接失败，不再发送请求
+	endpoint.Status = model.WebhookEndpointStatusDisabled
+	require.NoError(t, endpoint.Update())
+	retry := &model.WebhookDelivery{EndpointId: endpoint.Id, Status: model.WebhookDeliveryStatusPending, Payload: "{}"}
+	require.NoError(t, model.CreateWebhookDeliveries([]*model.WebhookDelivery{retry}))
+	attemptWebhookDelivery(retry)
+	assert.Equal(t, model.WebhookDeliveryStatusFailed, retry.Status)
+	assert.NotEmpty(t, retry.Error)
+	assert.Len(t, requests(), 2)
+
+	var saved model.WebhookDelivery
+	require.NoError(t, model.DB.First(&saved, retry.Id).Error)
+	assert.Equal(t, model.WebhookDeliveryStatusFailed, saved.Status)
+}
@@ -0,0 +1,33 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	BatchModeAuto     = "auto"     // 输入文件所在渠道支持全部模型时转发上游，否则本地执行
+	BatchModeUpstream = "upstream" // 始终转发到上游批处理接口
+	BatchModeLocal    = "local"    // 始终在网关本地逐行执行
+)
+
+// BatchSetting Batch API 相关配置
+type BatchSetting struct {
+	Mode             string `json:"mode"`
+	LocalConcurrency int    `json:"local_concurrency"` // 本地执行时单个批处理的并发请求数
+	MaxLines         int    `json:"max_lines"`         // 单个批处理允许的最大请求行数
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	Mode:             BatchModeAuto,
+	LocalConcurrency: 4,
+	MaxLines:         50000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+// GetBatchSetting 获取 Batch API 配置
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
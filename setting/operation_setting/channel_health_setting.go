@@ -0,0 +1,77 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	ChannelSelectStrategyWeighted = "weighted" // 按静态权重随机（默认）
+	ChannelSelectStrategyEWMA     = "ewma"     // 按健康度缩放后的权重随机
+	ChannelSelectStrategyP2C      = "p2c"      // 按静态权重随机选出两个渠道，取健康度较高者
+)
+
+// ChannelHealthSetting 自适应渠道选择配置
+type ChannelHealthSetting struct {
+	Enabled bool `json:"enabled"`
+	// 未命中分组/模型配置时使用的策略
+	DefaultStrategy string `json:"default_strategy"`
+	// 按分组、模型单独指定策略，模型配置优先
+	GroupStrategies map[string]string `json:"group_strategies"`
+	ModelStrategies map[string]string `json:"model_strategies"`
+	// 滑动窗口长度（秒）
+	WindowSeconds int `json:"window_seconds"`
+	// 窗口内请求数达到该值后才计算成功率，避免少量样本造成误判
+	MinRequests int `json:"min_requests"`
+	// 窗口内失败率达到该值时摘除渠道
+	ErrorRateThreshold float64 `json:"error_rate_threshold"`
+	// 连续失败达到该次数时摘除渠道，0 表示不按连续失败摘除
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// 首次摘除时长（秒），之后每次连续摘除翻倍，最长 MaxEjectionSeconds
+	EjectionSeconds    int `json:"ejection_seconds"`
+	MaxEjectionSeconds int `json:"max_ejection_seconds"`
+}
+
+// 默认配置
+var channelHealthSetting = ChannelHealthSetting{
+	Enabled:             false,
+	DefaultStrategy:     ChannelSelectStrategyWeighted,
+	GroupStrategies:     map[string]string{},
+	ModelStrategies:     map[string]string{},
+	WindowSeconds:       60,
+	MinRequests:         10,
+	ErrorRateThreshold:  0.5,
+	ConsecutiveFailures: 5,
+	EjectionSeconds:     30,
+	MaxEjectionSeconds:  300,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_health_setting", &channelHealthSetting)
+}
+
+// GetChannelHealthSetting 获取自适应渠道选择配置
+func GetChannelHealthSetting() *ChannelHealthSetting {
+	return &channelHealthSetting
+}
+
+// GetChannelSelectStrategy 返回指定分组、模型使用的渠道选择策略
+func GetChannelSelectStrategy(group string, model string) string {
+	if !channelHealthSetting.Enabled {
+		return ChannelSelectStrategyWeighted
+	}
+	if strategy, ok := channelHealthSetting.ModelStrategies[model]; ok {
+		return normalizeChannelSelectStrategy(strategy)
+	}
+	if strategy, ok := channelHealthSetting.GroupStrategies[group]; ok {
+		return normalizeChannelSelectStrategy(strategy)
+	}
+	return normalizeChannelSelectStrategy(channelHealthSetting.DefaultStrategy)
+}
+
+func normalizeChannelSelectStrategy(strategy string) string {
+	switch strategy {
+	case ChannelSelectStrategyEWMA, ChannelSelectStrategyP2C:
+		return strategy
+	default:
+		return ChannelSelectStrategyWeighted
+	}
+}
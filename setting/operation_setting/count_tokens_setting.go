@@ -0,0 +1,23 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// CountTokensSetting token 统计接口配置，默认在本地估算，不产生任何费用
+type CountTokensSetting struct {
+	// 渠道支持原生统计接口时转发到上游获取精确值，上游失败时回退到本地估算
+	UpstreamEnabled bool `json:"upstream_enabled"`
+}
+
+// 默认配置
+var countTokensSetting = CountTokensSetting{
+	UpstreamEnabled: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("count_tokens_setting", &countTokensSetting)
+}
+
+func GetCountTokensSetting() *CountTokensSetting {
+	return &countTokensSetting
+}
@@ -0,0 +1,23 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// FileSetting Files API 相关配置
+type FileSetting struct {
+	DefaultModel string `json:"default_model"` // 上传请求未携带 model 时用于挑选渠道的模型
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	DefaultModel: "gpt-4o-mini",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+// GetFileSetting 获取 Files API 配置
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
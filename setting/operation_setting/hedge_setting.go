@@ -0,0 +1,58 @@
+package operation_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// HedgeRule 对冲规则，DelayMs 为首个渠道未返回响应头或首个流式分片时，
+// 向备用渠道发起同一请求前的等待时间，小于等于 0 表示不对冲
+type HedgeRule struct {
+	DelayMs int `json:"delay_ms"`
+}
+
+// HedgeSetting 对冲请求配置，仅对对话类请求生效
+type HedgeSetting struct {
+	Enabled bool `json:"enabled"`
+	// 按请求模型配置，优先级最高；"*" 匹配其余模型，优先级低于分组规则
+	ModelRules map[string]HedgeRule `json:"model_rules"`
+	// 按使用分组配置
+	GroupRules map[string]HedgeRule `json:"group_rules"`
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled:    false,
+	ModelRules: map[string]HedgeRule{},
+	GroupRules: map[string]HedgeRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+// GetHedgeSetting 获取对冲请求配置
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// GetHedgeDelay 返回模型在分组下的对冲等待时间，未启用或未配置时返回 false。
+// 匹配顺序：模型规则 > 分组规则 > 模型 "*" 规则
+func GetHedgeDelay(model string, group string) (time.Duration, bool) {
+	if !hedgeSetting.Enabled {
+		return 0, false
+	}
+	rule, ok := hedgeSetting.ModelRules[model]
+	if !ok {
+		rule, ok = hedgeSetting.GroupRules[group]
+	}
+	if !ok {
+		rule, ok = hedgeSetting.ModelRules["*"]
+	}
+	if !ok || rule.DelayMs <= 0 {
+		return 0, false
+	}
+	return time.Duration(rule.DelayMs) * time.Millisecond, true
+}
@@ -0,0 +1,44 @@
+package operation_setting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHedgeDelay(t *testing.T) {
+	saved := hedgeSetting
+	t.Cleanup(func() { hedgeSetting = saved })
+
+	hedgeSetting = HedgeSetting{
+		Enabled: true,
+		ModelRules: map[string]HedgeRule{
+			"gpt-4o":     {DelayMs: 800},
+			"gpt-4o-off": {DelayMs: 0},
+			"*":          {DelayMs: 3000},
+		},
+		GroupRules: map[string]HedgeRule{
+			"vip": {DelayMs: 500},
+		},
+	}
+
+	delay, ok := GetHedgeDelay("gpt-4o", "vip")
+	require.True(t, ok)
+	require.Equal(t, 800*time.Millisecond, delay)
+
+	delay, ok = GetHedgeDelay("claude", "vip")
+	require.True(t, ok)
+	require.Equal(t, 500*time.Millisecond, delay)
+
+	delay, ok = GetHedgeDelay("claude", "default")
+	require.True(t, ok)
+	require.Equal(t, 3*time.Second, delay)
+
+	_, ok = GetHedgeDelay("gpt-4o-off", "vip")
+	require.False(t, ok)
+
+	hedgeSetting.Enabled = false
+	_, ok = GetHedgeDelay("gpt-4o", "vip")
+	require.False(t, ok)
+}
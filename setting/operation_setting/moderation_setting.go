@@ -0,0 +1,102 @@
+package operation_setting
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 命中审核后的处理方式
+const (
+	ModerationActionBlock  = "block"  // 拒绝请求或中断输出
+	ModerationActionRedact = "redact" // 替换命中内容后继续
+	ModerationActionFlag   = "flag"   // 仅记录到审核队列
+)
+
+// ModerationSetting 内容审核流水线配置，屏蔽词列表沿用 SensitiveWords，总开关沿用 CheckSensitiveEnabled
+type ModerationSetting struct {
+	// 正则规则，每条一个表达式
+	RegexRules []string `json:"regex_rules"`
+	// 是否调用审核模型（OpenAI moderations 接口）
+	ModelEnabled bool   `json:"model_enabled"`
+	ModelName    string `json:"model_name"`
+	// 选择审核渠道时使用的分组
+	ModelGroup          string `json:"model_group"`
+	ModelTimeoutSeconds int    `json:"model_timeout_seconds"`
+	// 流式输出每累计多少字符调用一次审核模型，结束时对剩余内容再检查一次
+	ModelCheckChars int `json:"model_check_chars"`
+	// 默认处理方式，可按分组覆盖
+	DefaultAction string            `json:"default_action"`
+	GroupActions  map[string]string `json:"group_actions"`
+}
+
+// 默认配置
+var moderationSetting = ModerationSetting{
+	RegexRules:          []string{},
+	ModelEnabled:        false,
+	ModelName:           "omni-moderation-latest",
+	ModelGroup:          "default",
+	ModelTimeoutSeconds: 10,
+	ModelCheckChars:     500,
+	DefaultAction:       ModerationActionBlock,
+	GroupActions:        map[string]string{},
+}
+
+var (
+	moderationRegexCache = map[string]*regexp.Regexp{}
+	moderationRegexLock  sync.Mutex
+)
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+func isValidModerationAction(action string) bool {
+	switch action {
+	case ModerationActionBlock, ModerationActionRedact, ModerationActionFlag:
+		return true
+	}
+	return false
+}
+
+// GetModerationAction 返回分组生效的处理方式，未配置或配置无效时为 block
+func GetModerationAction(group string) string {
+	if action, ok := moderationSetting.GroupActions[group]; ok && isValidModerationAction(action) {
+		return action
+	}
+	if isValidModerationAction(moderationSetting.DefaultAction) {
+		return moderationSetting.DefaultAction
+	}
+	return ModerationActionBlock
+}
+
+// GetModerationRegexps 返回编译后的正则规则，无效的正则会被忽略
+func GetModerationRegexps() []*regexp.Regexp {
+	moderationRegexLock.Lock()
+	defer moderationRegexLock.Unlock()
+	result := make([]*regexp.Regexp, 0, len(moderationSetting.RegexRules))
+	for _, rule := range moderationSetting.RegexRules {
+		if rule == "" {
+			continue
+		}
+		re, ok := moderationRegexCache[rule]
+		if !ok {
+			compiled, err := regexp.Compile(rule)
+			if err != nil {
+				compiled = nil
+			}
+			moderationRegexCache[rule] = compiled
+			re = compiled
+		}
+		if re != nil {
+			result = append(result, re)
+		}
+	}
+	return result
+}
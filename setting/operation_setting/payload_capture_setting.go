@@ -0,0 +1,119 @@
+package operation_setting
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// PayloadCaptureMaxBodyLimit 单个请求/响应体保存的字节数上限，保证能写入 MySQL TEXT 列
+const PayloadCaptureMaxBodyLimit = 60000
+
+// PayloadCaptureSetting 请求/响应载荷采集配置，仅对列表中的用户、令牌或渠道生效
+type PayloadCaptureSetting struct {
+	Enabled    bool  `json:"enabled"`
+	UserIds    []int `json:"user_ids"`
+	TokenIds   []int `json:"token_ids"`
+	ChannelIds []int `json:"channel_ids"`
+	// 保存前对请求与响应做脱敏的正则，匹配内容替换为 [REDACTED]
+	RedactPatterns []string `json:"redact_patterns"`
+	// 单个请求/响应体保存的最大字节数，超出部分截断
+	MaxBodyBytes int `json:"max_body_bytes"`
+	// 采集记录的保留天数，与消费日志分开清理
+	RetentionDays int `json:"retention_days"`
+}
+
+// 默认配置
+var payloadCaptureSetting = PayloadCaptureSetting{
+	Enabled:    false,
+	UserIds:    []int{},
+	TokenIds:   []int{},
+	ChannelIds: []int{},
+	RedactPatterns: []string{
+		`sk-[A-Za-z0-9_\-]{16,}`,
+		`(?i)bearer\s+[A-Za-z0-9._\-]{16,}`,
+		`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	},
+	MaxBodyBytes:  32 * 1024,
+	RetentionDays: 7,
+}
+
+var (
+	payloadCaptureRegexCache = map[string]*regexp.Regexp{}
+	payloadCaptureRegexLock  sync.Mutex
+)
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("payload_capture_setting", &payloadCaptureSetting)
+}
+
+// GetPayloadCaptureSetting 获取载荷采集配置
+func GetPayloadCaptureSetting() *PayloadCaptureSetting {
+	return &payloadCaptureSetting
+}
+
+func containsId(ids []int, id int) bool {
+	if id <= 0 {
+		return false
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldCapturePayload 判断请求是否需要采集载荷，任意一项命中即采集
+func ShouldCapturePayload(userId int, tokenId int, channelId int) bool {
+	if !payloadCaptureSetting.Enabled {
+		return false
+	}
+	return containsId(payloadCaptureSetting.UserIds, userId) ||
+		containsId(payloadCaptureSetting.TokenIds, tokenId) ||
+		containsId(payloadCaptureSetting.ChannelIds, channelId)
+}
+
+// MayCapturePayload 判断请求在选定渠道前是否可能需要采集（配置了渠道列表时需在选定渠道后再确认）
+func MayCapturePayload(userId int, tokenId int) bool {
+	if !payloadCaptureSetting.Enabled {
+		return false
+	}
+	return len(payloadCaptureSetting.ChannelIds) > 0 || ShouldCapturePayload(userId, tokenId, 0)
+}
+
+// GetPayloadCaptureMaxBodyBytes 返回生效的单体字节上限
+func GetPayloadCaptureMaxBodyBytes() int {
+	limit := payloadCaptureSetting.MaxBodyBytes
+	if limit <= 0 || limit > PayloadCaptureMaxBodyLimit {
+		return PayloadCaptureMaxBodyLimit
+	}
+	return limit
+}
+
+// GetPayloadCaptureRedactRegexps 返回编译后的脱敏正则，无效的正则会被忽略
+func GetPayloadCaptureRedactRegexps() []*regexp.Regexp {
+	payloadCaptureRegexLock.Lock()
+	defer payloadCaptureRegexLock.Unlock()
+	result := make([]*regexp.Regexp, 0, len(payloadCaptureSetting.RedactPatterns))
+	for _, pattern := range payloadCaptureSetting.RedactPatterns {
+		if pattern == "" {
+			continue
+		}
+		re, ok := payloadCaptureRegexCache[pattern]
+		if !ok {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				compiled = nil
+			}
+			payloadCaptureRegexCache[pattern] = compiled
+			re = compiled
+		}
+		if re != nil {
+			result = append(result, re)
+		}
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	ResponseCacheBillingFree  = "free"  // 命中不计费
+	ResponseCacheBillingRatio = "ratio" // 命中按 BillingRatio 折扣计费
+	ResponseCacheBillingFull  = "full"  // 命中按原价计费
+)
+
+// ResponseCacheModelRule 单个模型的缓存规则
+type ResponseCacheModelRule struct {
+	TTLSeconds int `json:"ttl_seconds"`
+	// 单条缓存响应的最大字节数，超过则不缓存
+	MaxBodyBytes int `json:"max_body_bytes"`
+}
+
+// ResponseCacheSetting 确定性请求（embedding、temperature 为 0 的对话）响应缓存配置
+type ResponseCacheSetting struct {
+	Enabled bool `json:"enabled"`
+	// 内存缓存最大条目数，启用 Redis 时不生效
+	MaxEntries int `json:"max_entries"`
+	// 按请求模型配置缓存规则，"*" 匹配其余模型；未命中任何规则的模型不缓存
+	ModelRules map[string]ResponseCacheModelRule `json:"model_rules"`
+	// 命中时的计费方式：free、ratio、full
+	BillingMode  string  `json:"billing_mode"`
+	BillingRatio float64 `json:"billing_ratio"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:    false,
+	MaxEntries: 10_000,
+	ModelRules: map[string]ResponseCacheModelRule{
+		"*": {TTLSeconds: 3600, MaxBodyBytes: 1 << 20},
+	},
+	BillingMode:  ResponseCacheBillingFull,
+	BillingRatio: 1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+// GetResponseCacheSetting 获取响应缓存配置
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// GetResponseCacheModelRule 返回模型的缓存规则，未启用或未配置时返回 false
+func GetResponseCacheModelRule(model string) (ResponseCacheModelRule, bool) {
+	if !responseCacheSetting.Enabled {
+		return ResponseCacheModelRule{}, false
+	}
+	rule, ok := responseCacheSetting.ModelRules[model]
+	if !ok {
+		rule, ok = responseCacheSetting.ModelRules["*"]
+	}
+	if !ok || rule.TTLSeconds <= 0 {
+		return ResponseCacheModelRule{}, false
+	}
+	return rule, true
+}
+
+// GetResponseCacheHitQuota 按命中计费方式换算命中请求的额度
+func GetResponseCacheHitQuota(quota int) int {
+	switch responseCacheSetting.BillingMode {
+	case ResponseCacheBillingFree:
+		return 0
+	case ResponseCacheBillingRatio:
+		ratio := responseCacheSetting.BillingRatio
+		if ratio < 0 {
+			ratio = 0
+		}
+		return int(float64(quota) * ratio)
+	default:
+		return quota
+	}
+}
@@ -0,0 +1,193 @@
+package operation_setting
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// TimePriceWindow 分时价格窗口，在 Start 到 End 之间对匹配的模型与分组的模型倍率或按次价格乘以 Ratio
+type TimePriceWindow struct {
+	Name string `json:"name"`
+	// 为空匹配全部模型，支持以 * 结尾的前缀匹配，如 deepseek-*
+	Models []string `json:"models"`
+	// 为空匹配全部分组
+	Groups []string `json:"groups"`
+	// 生效的星期，0 为周日；为空每天生效。跨越午夜的窗口以当前时刻所在的星期判断
+	Weekdays []int `json:"weekdays"`
+	// HH:MM 格式，End 早于 Start 时表示跨越午夜，如 16:30 至 00:30
+	Start string  `json:"start"`
+	End   string  `json:"end"`
+	Ratio float64 `json:"ratio"`
+}
+
+type TimePriceSetting struct {
+	Enabled bool `json:"enabled"`
+	// IANA 时区名称，如 Asia/Shanghai，为空使用服务器本地时区
+	Timezone string            `json:"timezone"`
+	Windows  []TimePriceWindow `json:"windows"`
+}
+
+// 默认配置
+var timePriceSetting = TimePriceSetting{
+	Enabled:  false,
+	Timezone: "",
+	Windows:  []TimePriceWindow{},
+}
+
+var timePriceLocations sync.Map
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("time_price_setting", &timePriceSetting)
+}
+
+func GetTimePriceSetting() *TimePriceSetting {
+	return &timePriceSetting
+}
+
+// GetTimePriceLocation 返回分时价格使用的时区，时区无效时使用服务器本地时区
+func GetTimePriceLocation() *time.Location {
+	name := timePriceSetting.Timezone
+	if name == "" {
+		return time.Local
+	}
+	if loc, ok := timePriceLocations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	timePriceLocations.Store(name, loc)
+	return loc
+}
+
+// MatchesModel 判断窗口是否作用于指定模型
+func (w *TimePriceWindow) MatchesModel(model string) bool {
+	if len(w.Models) == 0 {
+		return true
+	}
+	for _, pattern := range w.Models {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+		} else if pattern == model {
+			return true
+		}
+	}
+	return false
+}
+
+// DisplayName 返回记录到日志中的窗口名称，未命名时使用时间范围
+func (w *TimePriceWindow) DisplayName() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return w.Start + "-" + w.End
+}
+
+func (w *TimePriceWindow) matchesGroup(group string) bool {
+	if len(w.Groups) == 0 {
+		return true
+	}
+	for _, g := range w.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("时间 %s 格式错误，应为 HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains 判断时刻 now（已转换到配置的时区）是否落在窗口内
+func (w *TimePriceWindow) Contains(now time.Time) bool {
+	if len(w.Weekdays) > 0 {
+		matched := false
+		for _, weekday := range w.Weekdays {
+			if time.Weekday(weekday) == now.Weekday() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// ValidateTimePriceWindows 校验窗口配置的 JSON 文本
+func ValidateTimePriceWindows(jsonStr string) error {
+	var windows []TimePriceWindow
+	if err := common.UnmarshalJsonStr(jsonStr, &windows); err != nil {
+		return fmt.Errorf("分时价格窗口不是合法的 JSON: %v", err)
+	}
+	for i, window := range windows {
+		if _, err := parseClock(window.Start); err != nil {
+			return fmt.Errorf("窗口 %d: %v", i+1, err)
+		}
+		if _, err := parseClock(window.End); err != nil {
+			return fmt.Errorf("窗口 %d: %v", i+1, err)
+		}
+		// 未填写倍率时为 0，会使窗口内的请求免费，必须显式配置正数
+		if window.Ratio <= 0 {
+			return fmt.Errorf("窗口 %d: 倍率必须大于 0", i+1)
+		}
+		for _, weekday := range window.Weekdays {
+			if weekday < 0 || weekday > 6 {
+				return fmt.Errorf("窗口 %d: 星期应在 0-6 之间", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+func ValidateTimePriceTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("无效的时区 %s", name)
+	}
+	return nil
+}
+
+// GetTimePriceWindow 返回当前时刻对模型与分组生效的分时价格窗口，按配置顺序取第一个命中的窗口
+func GetTimePriceWindow(model string, group string, now time.Time) (*TimePriceWindow, bool) {
+	if !timePriceSetting.Enabled || len(timePriceSetting.Windows) == 0 {
+		return nil, false
+	}
+	now = now.In(GetTimePriceLocation())
+	for i := range timePriceSetting.Windows {
+		window := &timePriceSetting.Windows[i]
+		if window.MatchesModel(model) && window.matchesGroup(group) && window.Contains(now) {
+			return window, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,52 @@
+package operation_setting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTimePriceWindow(t *testing.T) {
+	saved := timePriceSetting
+	t.Cleanup(func() { timePriceSetting = saved })
+
+	timePriceSetting = TimePriceSetting{
+		Enabled:  true,
+		Timezone: "UTC",
+		Windows: []TimePriceWindow{
+			{Name: "deepseek-off-peak", Models: []string{"deepseek-*"}, Start: "16:30", End: "00:30", Ratio: 0.5},
+			{Name: "weekend", Groups: []string{"batch"}, Weekdays: []int{0, 6}, Start: "00:00", End: "23:59", Ratio: 0.8},
+		},
+	}
+
+	// 2025-01-06 为周一
+	window, ok := GetTimePriceWindow("deepseek-chat", "default", time.Date(2025, 1, 6, 23, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, "deepseek-off-peak", window.DisplayName())
+
+	// 跨越午夜
+	_, ok = GetTimePriceWindow("deepseek-chat", "default", time.Date(2025, 1, 7, 0, 15, 0, 0, time.UTC))
+	require.True(t, ok)
+	_, ok = GetTimePriceWindow("deepseek-chat", "default", time.Date(2025, 1, 7, 0, 30, 0, 0, time.UTC))
+	require.False(t, ok)
+
+	// 按配置的时区判断：UTC+8 的 08:00 即 UTC 00:00
+	_, ok = GetTimePriceWindow("deepseek-chat", "default", time.Date(2025, 1, 7, 8, 0, 0, 0, time.FixedZone("UTC+8", 8*3600)))
+	require.True(t, ok)
+
+	_, ok = GetTimePriceWindow("gpt-4o", "batch", time.Date(2025, 1, 6, 12, 0, 0, 0, time.UTC))
+	require.False(t, ok)
+	window, ok = GetTimePriceWindow("gpt-4o", "batch", time.Date(2025, 1, 5, 12, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	require.Equal(t, 0.8, window.Ratio)
+	_, ok = GetTimePriceWindow("gpt-4o", "default", time.Date(2025, 1, 5, 12, 0, 0, 0, time.UTC))
+	require.False(t, ok)
+
+	require.Error(t, ValidateTimePriceWindows(`[{"start":"25:00","end":"01:00","ratio":1}]`))
+	require.Error(t, ValidateTimePriceWindows(`[{"start":"01:00","end":"02:00","ratio":1,"weekdays":[7]}]`))
+	require.Error(t, ValidateTimePriceWindows(`[{"start":"01:00","end":"02:00","ratio":0}]`))
+	require.Error(t, ValidateTimePriceWindows(`[{"start":"01:00","end":"02:00"}]`))
+	require.Error(t, ValidateTimePriceWindows(`[{"start":"01:00","end":"02:00","ratio":-1}]`))
+	require.NoError(t, ValidateTimePriceWindows(`[{"start":"16:30","end":"00:30","ratio":0.5}]`))
+}
@@ -0,0 +1,31 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// UsageRollupSetting 用量预聚合配置，按小时与天汇总用户、令牌、模型、渠道与分组维度的用量
+type UsageRollupSetting struct {
+	Enabled bool `json:"enabled"`
+	// 内存中累计的增量写入数据库的间隔
+	FlushIntervalSeconds int `json:"flush_interval_seconds"`
+	// 小时粒度数据保留天数，0 表示不清理；天粒度数据不清理
+	HourlyRetentionDays int `json:"hourly_retention_days"`
+	// 数据看板接口改为读取预聚合数据，而不是 quota_data 表
+	ServeDashboard bool `json:"serve_dashboard"`
+}
+
+// 默认配置
+var usageRollupSetting = UsageRollupSetting{
+	Enabled:              true,
+	FlushIntervalSeconds: 60,
+	HourlyRetentionDays:  90,
+	ServeDashboard:       false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("usage_rollup_setting", &usageRollupSetting)
+}
+
+func GetUsageRollupSetting() *UsageRollupSetting {
+	return &usageRollupSetting
+}
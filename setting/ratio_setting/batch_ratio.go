@@ -0,0 +1,43 @@
+package ratio_setting
+
+import (
+	"github.com/QuantumNous/new-api/types"
+)
+
+// BatchRatioDefaultKey 未单独配置的模型使用该键对应的批处理倍率
+const BatchRatioDefaultKey = "default"
+
+// 批处理（/v1/batches）折扣倍率，在正常计费基础上额外相乘
+var defaultBatchRatio = map[string]float64{
+	BatchRatioDefaultKey: 0.5,
+}
+
+var batchRatioMap = types.NewRWMap[string, float64]()
+
+// BatchRatio2JSONString converts the batch ratio map to a JSON string
+func BatchRatio2JSONString() string {
+	return batchRatioMap.MarshalJSONString()
+}
+
+// UpdateBatchRatioByJSONString updates the batch ratio map from a JSON string
+func UpdateBatchRatioByJSONString(jsonStr string) error {
+	return types.LoadFromJsonString(batchRatioMap, jsonStr)
+}
+
+// GetBatchRatio returns the batch discount ratio for a model, falling back to the default entry
+func GetBatchRatio(name string) float64 {
+	if ratio, ok := batchRatioMap.Get(name); ok {
+		return ratio
+	}
+	if ratio, ok := batchRatioMap.Get(FormatMatchingModelName(name)); ok {
+		return ratio
+	}
+	if ratio, ok := batchRatioMap.Get(BatchRatioDefaultKey); ok {
+		return ratio
+	}
+	return 1
+}
+
+func GetBatchRatioCopy() map[string]float64 {
+	return batchRatioMap.ReadAll()
+}
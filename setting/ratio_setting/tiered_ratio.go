@@ -0,0 +1,63 @@
+package ratio_setting
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// 按提示词长度分档计费，键为模型名称，值为档位列表。
+// 例如 {"gemini-2.5-pro":[{"threshold":200000,"model_ratio":1.25,"completion_ratio":6}]}
+// 表示提示词超过 200k tokens 时使用新的模型倍率与补全倍率
+var modelTieredRatioMap = types.NewRWMap[string, []types.PriceTier]()
+
+func ModelTieredRatio2JSONString() string {
+	return modelTieredRatioMap.MarshalJSONString()
+}
+
+// CheckModelTieredRatio 校验分档倍率配置：阈值与倍率不能为负，同一模型的阈值不能重复
+func CheckModelTieredRatio(jsonStr string) error {
+	tieredRatios := make(map[string][]types.PriceTier)
+	if err := common.Unmarshal([]byte(jsonStr), &tieredRatios); err != nil {
+		return err
+	}
+	for model, tiers := range tieredRatios {
+		thresholds := make(map[int]struct{}, len(tiers))
+		for _, tier := range tiers {
+			if tier.Threshold < 0 || tier.ModelRatio < 0 || tier.CompletionRatio < 0 || tier.CacheRatio < 0 {
+				return fmt.Errorf("模型 %s 的分档阈值与倍率不能为负数", model)
+			}
+			if _, ok := thresholds[tier.Threshold]; ok {
+				return fmt.Errorf("模型 %s 存在重复的分档阈值 %d", model, tier.Threshold)
+			}
+			thresholds[tier.Threshold] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func UpdateModelTieredRatioByJSONString(jsonStr string) error {
+	if err := CheckModelTieredRatio(jsonStr); err != nil {
+		return err
+	}
+	return types.LoadFromJsonString(modelTieredRatioMap, jsonStr)
+}
+
+// GetModelTieredRatio 返回模型配置的分档倍率，按阈值从小到大排列
+func GetModelTieredRatio(name string) ([]types.PriceTier, bool) {
+	tiers, ok := modelTieredRatioMap.Get(name)
+	if !ok {
+		tiers, ok = modelTieredRatioMap.Get(FormatMatchingModelName(name))
+	}
+	if !ok || len(tiers) == 0 {
+		return nil, false
+	}
+	sorted := make([]types.PriceTier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Threshold < sorted[j].Threshold
+	})
+	return sorted, true
+}
@@ -85,6 +85,8 @@ const (
 	// quota error
 	ErrorCodeInsufficientUserQuota      ErrorCode = "insufficient_user_quota"
 	ErrorCodePreConsumeTokenQuotaFailed ErrorCode = "pre_consume_token_quota_failed"
+	ErrorCodeTokenBudgetExhausted       ErrorCode = "token_budget_exhausted"
+	ErrorCodeTokenRateLimitExceeded     ErrorCode = "token_rate_limit_exceeded"
 )
 
 type NewAPIError struct {
@@ -8,6 +8,15 @@ type GroupRatioInfo struct {
 	HasSpecialRatio   bool
 }
 
+// PriceTier 按提示词长度分档的倍率，提示词 tokens 超过 Threshold 时生效；
+// CompletionRatio、CacheRatio 为 0 时沿用模型原有倍率
+type PriceTier struct {
+	Threshold       int     `json:"threshold"`
+	ModelRatio      float64 `json:"model_ratio"`
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+	CacheRatio      float64 `json:"cache_ratio,omitempty"`
+}
+
 type PriceData struct {
 	FreeModel            bool
 	ModelPrice           float64
@@ -25,6 +34,56 @@ type PriceData struct {
 	Quota                int // 按次计费的最终额度（MJ / Task）
 	QuotaToPreConsume    int // 按量计费的预消耗额度
 	GroupRatioInfo       GroupRatioInfo
+
+	// 模型配置的分档倍率，BaseTier 为未命中任何档位时的倍率，AppliedTier 为当前命中的档位
+	Tiers       []PriceTier
+	BaseTier    PriceTier
+	AppliedTier *PriceTier
+
+	// 命中的分时价格窗口，ModelRatio（含分档倍率）或 ModelPrice 已乘以 TimeRatio
+	TimeWindow string
+	TimeRatio  float64
+}
+
+// ApplyTimeRatio 将分时价格窗口的倍率乘到模型倍率、分档倍率与按次价格上
+func (p *PriceData) ApplyTimeRatio(window string, ratio float64) {
+	p.TimeWindow = window
+	p.TimeRatio = ratio
+	p.ModelPrice *= ratio
+	p.ModelRatio *= ratio
+	p.BaseTier.ModelRatio *= ratio
+	for i := range p.Tiers {
+		p.Tiers[i].ModelRatio *= ratio
+	}
+}
+
+// ApplyTier 按提示词 tokens 选择命中的档位并替换模型、补全与缓存倍率，未命中时恢复原有倍率。
+// 预扣费时按估算的 tokens 调用，结算时按实际 tokens 再次调用
+func (p *PriceData) ApplyTier(promptTokens int) *PriceTier {
+	if len(p.Tiers) == 0 || p.UsePrice {
+		return nil
+	}
+	var applied *PriceTier
+	for i := range p.Tiers {
+		if promptTokens > p.Tiers[i].Threshold && (applied == nil || p.Tiers[i].Threshold > applied.Threshold) {
+			applied = &p.Tiers[i]
+		}
+	}
+	p.AppliedTier = applied
+	p.ModelRatio = p.BaseTier.ModelRatio
+	p.CompletionRatio = p.BaseTier.CompletionRatio
+	p.CacheRatio = p.BaseTier.CacheRatio
+	if applied == nil {
+		return nil
+	}
+	p.ModelRatio = applied.ModelRatio
+	if applied.CompletionRatio > 0 {
+		p.CompletionRatio = applied.CompletionRatio
+	}
+	if applied.CacheRatio > 0 {
+		p.CacheRatio = applied.CacheRatio
+	}
+	return applied
 }
 
 func (p *PriceData) AddOtherRatio(key string, ratio float64) {
@@ -45,6 +45,7 @@ import ModelPage from './pages/Model';
 import ModelDeploymentPage from './pages/ModelDeployment';
 import Playground from './pages/Playground';
 import Subscription from './pages/Subscription';
+import Organization from './pages/Organization';
 import OAuth2Callback from './components/auth/OAuth2Callback';
 import PersonalSetting from './components/settings/PersonalSetting';
 import Setup from './pages/Setup';
@@ -147,6 +148,14 @@ function App() {
             </PrivateRoute>
           }
         />
+        <Route
+          path='/console/organization'
+          element={
+            <PrivateRoute>
+              <Organization />
+            </PrivateRoute>
+          }
+        />
         <Route
           path='/console/playground'
           element={
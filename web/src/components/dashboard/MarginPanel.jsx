@@ -0,0 +1,132 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useCallback, useEffect, useState } from 'react';
+import { Card, Button, Table, Tabs, TabPane, Tag } from '@douyinfe/semi-ui';
+import { Scale, RefreshCw } from 'lucide-react';
+import { API, showError, renderQuota } from '../../helpers';
+
+const MARGIN_GROUP_BY = [
+  { key: 'channel', label: '按渠道' },
+  { key: 'model', label: '按模型' },
+  { key: 'group', label: '按分组' },
+  { key: 'day', label: '按天' },
+];
+
+const MarginPanel = ({ inputs, CARD_PROPS, t }) => {
+  const [groupBy, setGroupBy] = useState('channel');
+  const [items, setItems] = useState([]);
+  const [loading, setLoading] = useState(false);
+
+  const loadMarginData = useCallback(async () => {
+    setLoading(true);
+    try {
+      const startTimestamp = Date.parse(inputs.start_timestamp) / 1000;
+      const endTimestamp = Date.parse(inputs.end_timestamp) / 1000;
+      // 按天汇总时使用浏览器所在时区划分日期
+      const tzOffset = -new Date().getTimezoneOffset() * 60;
+      const res = await API.get(
+        `/api/data/margin?group_by=${groupBy}&start_timestamp=${startTimestamp}&end_timestamp=${endTimestamp}&tz_offset=${tzOffset}`,
+      );
+      const { success, message, data } = res.data;
+      if (success) {
+        setItems(data || []);
+      } else {
+        showError(message);
+      }
+    } finally {
+      setLoading(false);
+    }
+  }, [groupBy, inputs.start_timestamp, inputs.end_timestamp]);
+
+  useEffect(() => {
+    loadMarginData();
+  }, [loadMarginData]);
+
+  const columns = [
+    {
+      title: t(MARGIN_GROUP_BY.find((item) => item.key === groupBy).label),
+      dataIndex: 'key',
+      render: (text, record) =>
+        groupBy === 'channel' && record.channel_name
+          ? `${record.channel_name} (#${record.channel_id})`
+          : text || '-',
+    },
+    { title: t('请求次数'), dataIndex: 'count' },
+    {
+      title: t('收入'),
+      dataIndex: 'revenue',
+      render: (value) => renderQuota(value),
+    },
+    {
+      title: t('上游成本'),
+      dataIndex: 'cost',
+      render: (value) => renderQuota(value),
+    },
+    {
+      title: t('毛利'),
+      dataIndex: 'margin',
+      render: (value, record) => (
+        <Tag color={value < 0 ? 'red' : 'green'} shape='circle'>
+          {renderQuota(value)} ({(record.margin_rate * 100).toFixed(1)}%)
+        </Tag>
+      ),
+    },
+  ];
+
+  return (
+    <Card
+      {...CARD_PROPS}
+      className='shadow-sm !rounded-2xl'
+      title={
+        <div className='flex items-center justify-between w-full gap-2'>
+          <div className='flex items-center gap-2'>
+            <Scale size={16} />
+            {t('收入与成本')}
+          </div>
+          <Button
+            icon={<RefreshCw size={14} />}
+            onClick={loadMarginData}
+            loading={loading}
+            size='small'
+            theme='borderless'
+            type='tertiary'
+            className='text-gray-500 hover:text-blue-500 hover:bg-blue-50 !rounded-full'
+          />
+        </div>
+      }
+    >
+      <Tabs type='button' activeKey={groupBy} onChange={setGroupBy}>
+        {MARGIN_GROUP_BY.map((item) => (
+          <TabPane tab={t(item.label)} itemKey={item.key} key={item.key} />
+        ))}
+      </Tabs>
+      <Table
+        columns={columns}
+        dataSource={items}
+        rowKey='key'
+        loading={loading}
+        size='small'
+        pagination={{ pageSize: 10 }}
+      />
+    </Card>
+  );
+};
+
+export default MarginPanel;
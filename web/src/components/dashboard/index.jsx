@@ -29,6 +29,7 @@ import ApiInfoPanel from './ApiInfoPanel';
 import AnnouncementsPanel from './AnnouncementsPanel';
 import FaqPanel from './FaqPanel';
 import UptimePanel from './UptimePanel';
+import MarginPanel from './MarginPanel';
 import SearchModal from './modals/SearchModal';
 
 import { useDashboardData } from '../../hooks/dashboard/useDashboardData';
@@ -203,6 +204,17 @@ const Dashboard = () => {
         </div>
       </div>
 
+      {/* 收入、上游成本与毛利，仅管理员可见 */}
+      {dashboardData.isAdminUser && (
+        <div className='mb-4'>
+          <MarginPanel
+            inputs={dashboardData.inputs}
+            CARD_PROPS={CARD_PROPS}
+            t={dashboardData.t}
+          />
+        </div>
+      )}
+
       {/* 系统公告和常见问答卡片 */}
       {dashboardData.hasInfoPanels && (
         <div className='mb-4'>
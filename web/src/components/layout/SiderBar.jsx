@@ -38,6 +38,7 @@ const routerMap = {
   topup: '/console/topup',
   user: '/console/user',
   subscription: '/console/subscription',
+  organization: '/console/organization',
   log: '/console/log',
   midjourney: '/console/midjourney',
   setting: '/console/setting',
@@ -129,6 +130,11 @@ const SiderBar = ({ onNavigate = () => {} }) => {
         itemKey: 'topup',
         to: '/topup',
       },
+      {
+        text: t('组织管理'),
+        itemKey: 'organization',
+        to: '/organization',
+      },
       {
         text: t('个人设置'),
         itemKey: 'personal',
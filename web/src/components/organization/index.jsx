@@ -0,0 +1,624 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useCallback, useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Button,
+  Card,
+  Checkbox,
+  Empty,
+  Input,
+  InputNumber,
+  Modal,
+  Popconfirm,
+  Select,
+  Table,
+  TabPane,
+  Tabs,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { Building2, Plus, UserPlus } from 'lucide-react';
+import {
+  API,
+  copy,
+  renderQuota,
+  renderQuotaWithPrompt,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../helpers';
+
+const { Text, Title } = Typography;
+
+const ROLE_COLORS = {
+  owner: 'orange',
+  admin: 'blue',
+  member: 'grey',
+};
+
+const ROLE_LABELS = {
+  owner: '所有者',
+  admin: '管理员',
+  member: '成员',
+};
+
+const USAGE_DAYS = 30;
+
+const OrganizationDetail = ({ orgId, onChanged }) => {
+  const { t } = useTranslation();
+  const [org, setOrg] = useState(null);
+  const [self, setSelf] = useState(null);
+  const [members, setMembers] = useState([]);
+  const [invites, setInvites] = useState([]);
+  const [usage, setUsage] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [transferVisible, setTransferVisible] = useState(false);
+  const [transferQuota, setTransferQuota] = useState(0);
+  const [editingMember, setEditingMember] = useState(null);
+  const [inviteVisible, setInviteVisible] = useState(false);
+  const [inviteForm, setInviteForm] = useState({
+    role: 'member',
+    quota_limit: 0,
+    expires_days: 7,
+  });
+
+  const canManage = self?.role === 'owner' || self?.role === 'admin';
+
+  const loadDetail = useCallback(async () => {
+    setLoading(true);
+    try {
+      const res = await API.get(`/api/organization/${orgId}`);
+      const { success, message, data } = res.data;
+      if (!success) {
+        showError(message);
+        return;
+      }
+      setOrg(data.organization);
+      setSelf(data.member);
+      const memberRes = await API.get(`/api/organization/${orgId}/members`);
+      if (memberRes.data.success) {
+        setMembers(memberRes.data.data || []);
+      }
+      if (data.member.role === 'owner' || data.member.role === 'admin') {
+        const inviteRes = await API.get(`/api/organization/${orgId}/invites`);
+        if (inviteRes.data.success) {
+          setInvites(inviteRes.data.data || []);
+        }
+        const start = Math.floor(Date.now() / 1000) - USAGE_DAYS * 86400;
+        const usageRes = await API.get(
+          `/api/organization/${orgId}/usage?start_timestamp=${start}`,
+        );
+        if (usageRes.data.success) {
+          setUsage(usageRes.data.data || []);
+        }
+      }
+    } finally {
+      setLoading(false);
+    }
+  }, [orgId]);
+
+  useEffect(() => {
+    loadDetail();
+  }, [loadDetail]);
+
+  const handleResult = (res, successMessage) => {
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(successMessage);
+      loadDetail();
+      onChanged();
+      return true;
+    }
+    showError(message);
+    return false;
+  };
+
+  const submitTransfer = async () => {
+    const res = await API.post(`/api/organization/${orgId}/transfer`, {
+      quota: parseInt(transferQuota) || 0,
+    });
+    if (handleResult(res, t('转入成功'))) {
+      setTransferVisible(false);
+      setTransferQuota(0);
+    }
+  };
+
+  const submitMember = async () => {
+    const res = await API.put(
+      `/api/organization/${orgId}/members/${editingMember.user_id}`,
+      {
+        role: editingMember.role,
+        quota_limit: parseInt(editingMember.quota_limit) || 0,
+        reset_used: editingMember.reset_used || false,
+      },
+    );
+    if (handleResult(res, t('更新成功'))) {
+      setEditingMember(null);
+    }
+  };
+
+  // 删除组织或退出组织后当前组织不再可见，只刷新组织列表
+  const leaveResult = (res, successMessage) => {
+    const { success, message } = res.data;
+    if (success) {
+      showSuccess(successMessage);
+      onChanged();
+    } else {
+      showError(message);
+    }
+  };
+
+  const removeMember = async (userId) => {
+    const res = await API.delete(
+      `/api/organization/${orgId}/members/${userId}`,
+    );
+    if (userId === self?.user_id) {
+      leaveResult(res, t('已退出组织'));
+      return;
+    }
+    handleResult(res, t('操作成功'));
+  };
+
+  const submitInvite = async () => {
+    const res = await API.post(`/api/organization/${orgId}/invites`, {
+      role: inviteForm.role,
+      quota_limit: parseInt(inviteForm.quota_limit) || 0,
+      expires_in: (parseInt(inviteForm.expires_days) || 0) * 86400,
+    });
+    if (handleResult(res, t('邀请码已创建'))) {
+      setInviteVisible(false);
+    }
+  };
+
+  const deleteInvite = async (inviteId) => {
+    const res = await API.delete(
+      `/api/organization/${orgId}/invites/${inviteId}`,
+    );
+    handleResult(res, t('删除成功'));
+  };
+
+  const deleteOrganization = async () => {
+    const res = await API.delete(`/api/organization/${orgId}`);
+    leaveResult(res, t('删除成功'));
+  };
+
+  const renderRole = (role) => (
+    <Tag color={ROLE_COLORS[role]} shape='circle'>
+      {t(ROLE_LABELS[role] || role)}
+    </Tag>
+  );
+
+  const renderLimit = (limit) => (limit > 0 ? renderQuota(limit) : t('不限'));
+
+  const memberColumns = [
+    { title: t('用户名'), dataIndex: 'username' },
+    { title: t('角色'), dataIndex: 'role', render: renderRole },
+    { title: t('额度上限'), dataIndex: 'quota_limit', render: renderLimit },
+    {
+      title: t('已用额度'),
+      dataIndex: 'used_quota',
+      render: (value) => renderQuota(value),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => {
+        const isSelf = record.user_id === self?.user_id;
+        if (record.role === 'owner') {
+          return null;
+        }
+        return (
+          <div className='flex gap-1'>
+            {canManage && (
+              <Button
+                size='small'
+                type='tertiary'
+                onClick={() => setEditingMember({ ...record })}
+              >
+                {t('编辑')}
+              </Button>
+            )}
+            {(canManage || isSelf) && (
+              <Popconfirm
+                title={isSelf ? t('确定退出该组织？') : t('确定移除该成员？')}
+                onConfirm={() => removeMember(record.user_id)}
+              >
+                <Button size='small' type='danger'>
+                  {isSelf ? t('退出') : t('移除')}
+                </Button>
+              </Popconfirm>
+            )}
+          </div>
+        );
+      },
+    },
+  ];
+
+  const inviteColumns = [
+    {
+      title: t('邀请码'),
+      dataIndex: 'code',
+      render: (code) => (
+        <Text
+          copyable={{ content: code, onCopy: () => copy(code) }}
+          ellipsis={{ showTooltip: true }}
+          style={{ maxWidth: 200 }}
+        >
+          {code}
+        </Text>
+      ),
+    },
+    { title: t('角色'), dataIndex: 'role', render: renderRole },
+    { title: t('额度上限'), dataIndex: 'quota_limit', render: renderLimit },
+    {
+      title: t('过期时间'),
+      dataIndex: 'expires_at',
+      render: (value) => timestamp2string(value),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (text, record) => (
+        <Popconfirm
+          title={t('确定删除该邀请码？')}
+          onConfirm={() => deleteInvite(record.id)}
+        >
+          <Button size='small' type='danger'>
+            {t('删除')}
+          </Button>
+        </Popconfirm>
+      ),
+    },
+  ];
+
+  const usageColumns = [
+    { title: t('用户名'), dataIndex: 'username' },
+    { title: t('请求次数'), dataIndex: 'count' },
+    {
+      title: t('消耗额度'),
+      dataIndex: 'quota',
+      render: (value) => renderQuota(value),
+    },
+    { title: t('输入 Tokens'), dataIndex: 'prompt_tokens' },
+    { title: t('输出 Tokens'), dataIndex: 'completion_tokens' },
+  ];
+
+  if (!org) {
+    return <Card loading={loading} className='!rounded-2xl' />;
+  }
+
+  return (
+    <Card className='!rounded-2xl' loading={loading && !org}>
+      <div className='flex flex-wrap items-start justify-between gap-2 mb-4'>
+        <div>
+          <Title heading={5}>
+            {org.name} {renderRole(self?.role)}
+          </Title>
+          <Text type='secondary'>
+            {t('组织余额')}：{renderQuota(org.quota)}　{t('已用额度')}：
+            {renderQuota(org.used_quota)}　{t('我的额度上限')}：
+            {renderLimit(self?.quota_limit)}
+          </Text>
+          {org.status !== 1 && (
+            <Tag color='red' className='ml-2'>
+              {t('已禁用')}
+            </Tag>
+          )}
+        </div>
+        <div className='flex gap-2'>
+          <Button onClick={() => setTransferVisible(true)}>
+            {t('转入额度')}
+          </Button>
+          {self?.role === 'owner' && (
+            <Popconfirm
+              title={t('确定删除该组织？')}
+              content={t('组织钱包有余额时无法删除，绑定的令牌将改为个人钱包扣费')}
+              onConfirm={deleteOrganization}
+            >
+              <Button type='danger'>{t('删除组织')}</Button>
+            </Popconfirm>
+          )}
+        </div>
+      </div>
+      <Tabs type='line'>
+        <TabPane tab={t('成员')} itemKey='members'>
+          <Table
+            columns={memberColumns}
+            dataSource={members}
+            rowKey='id'
+            size='small'
+            pagination={false}
+          />
+        </TabPane>
+        {canManage && (
+          <TabPane tab={t('邀请')} itemKey='invites'>
+            <Button
+              icon={<UserPlus size={14} />}
+              className='mb-2'
+              onClick={() => setInviteVisible(true)}
+            >
+              {t('创建邀请码')}
+            </Button>
+            <Table
+              columns={inviteColumns}
+              dataSource={invites}
+              rowKey='id'
+              size='small'
+              pagination={false}
+            />
+          </TabPane>
+        )}
+        {canManage && (
+          <TabPane
+            tab={t('近 {{days}} 天用量', { days: USAGE_DAYS })}
+            itemKey='usage'
+          >
+            <Table
+              columns={usageColumns}
+              dataSource={usage}
+              rowKey='user_id'
+              size='small'
+              pagination={false}
+            />
+          </TabPane>
+        )}
+      </Tabs>
+
+      <Modal
+        title={t('从个人钱包转入额度')}
+        visible={transferVisible}
+        onOk={submitTransfer}
+        onCancel={() => setTransferVisible(false)}
+      >
+        <InputNumber
+          value={transferQuota}
+          min={0}
+          onChange={setTransferQuota}
+          style={{ width: '100%' }}
+        />
+        <Text type='secondary'>{renderQuotaWithPrompt(transferQuota)}</Text>
+      </Modal>
+
+      <Modal
+        title={t('编辑成员')}
+        visible={!!editingMember}
+        onOk={submitMember}
+        onCancel={() => setEditingMember(null)}
+      >
+        {editingMember && (
+          <div className='flex flex-col gap-3'>
+            <Select
+              value={editingMember.role}
+              onChange={(role) => setEditingMember({ ...editingMember, role })}
+              optionList={[
+                { label: t('管理员'), value: 'admin' },
+                { label: t('成员'), value: 'member' },
+              ]}
+              disabled={self?.role !== 'owner'}
+            />
+            <InputNumber
+              value={editingMember.quota_limit}
+              min={0}
+              prefix={t('额度上限')}
+              onChange={(value) =>
+                setEditingMember({ ...editingMember, quota_limit: value })
+              }
+            />
+            <Text type='secondary'>
+              {t('0 表示不限')}，
+              {renderQuotaWithPrompt(editingMember.quota_limit || 0)}
+            </Text>
+            <Checkbox
+              checked={editingMember.reset_used}
+              onChange={(e) =>
+                setEditingMember({
+                  ...editingMember,
+                  reset_used: e.target.checked,
+                })
+              }
+            >
+              {t('清零已用额度')}
+            </Checkbox>
+          </div>
+        )}
+      </Modal>
+
+      <Modal
+        title={t('创建邀请码')}
+        visible={inviteVisible}
+        onOk={submitInvite}
+        onCancel={() => setInviteVisible(false)}
+      >
+        <div className='flex flex-col gap-3'>
+          <Select
+            value={inviteForm.role}
+            onChange={(role) => setInviteForm({ ...inviteForm, role })}
+            optionList={[
+              { label: t('成员'), value: 'member' },
+              ...(self?.role === 'owner'
+                ? [{ label: t('管理员'), value: 'admin' }]
+                : []),
+            ]}
+          />
+          <InputNumber
+            value={inviteForm.quota_limit}
+            min={0}
+            prefix={t('额度上限')}
+            onChange={(value) =>
+              setInviteForm({ ...inviteForm, quota_limit: value })
+            }
+          />
+          <InputNumber
+            value={inviteForm.expires_days}
+            min={1}
+            prefix={t('有效天数')}
+            onChange={(value) =>
+              setInviteForm({ ...inviteForm, expires_days: value })
+            }
+          />
+        </div>
+      </Modal>
+    </Card>
+  );
+};
+
+const Organization = () => {
+  const { t } = useTranslation();
+  const [orgs, setOrgs] = useState([]);
+  const [selectedId, setSelectedId] = useState(null);
+  const [createVisible, setCreateVisible] = useState(false);
+  const [joinVisible, setJoinVisible] = useState(false);
+  const [name, setName] = useState('');
+  const [code, setCode] = useState('');
+
+  const loadOrganizations = useCallback(async () => {
+    const res = await API.get('/api/organization/self');
+    const { success, message, data } = res.data;
+    if (!success) {
+      showError(message);
+      return;
+    }
+    const list = data || [];
+    setOrgs(list);
+    setSelectedId((current) =>
+      list.some((org) => org.id === current) ? current : list[0]?.id || null,
+    );
+  }, []);
+
+  useEffect(() => {
+    loadOrganizations();
+  }, [loadOrganizations]);
+
+  const submitCreate = async () => {
+    const res = await API.post('/api/organization/', { name });
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(t('创建成功'));
+      setCreateVisible(false);
+      setName('');
+      setSelectedId(data.id);
+      loadOrganizations();
+    } else {
+      showError(message);
+    }
+  };
+
+  const submitJoin = async () => {
+    const res = await API.post('/api/organization/join', { code });
+    const { success, message, data } = res.data;
+    if (success) {
+      showSuccess(t('已加入组织'));
+      setJoinVisible(false);
+      setCode('');
+      setSelectedId(data.id);
+      loadOrganizations();
+    } else {
+      showError(message);
+    }
+  };
+
+  return (
+    <div className='mt-[60px] px-2'>
+      <div className='flex flex-col lg:flex-row gap-4'>
+        <Card
+          className='!rounded-2xl lg:w-72 shrink-0'
+          title={
+            <div className='flex items-center gap-2'>
+              <Building2 size={16} />
+              {t('我的组织')}
+            </div>
+          }
+          headerExtraContent={
+            <div className='flex gap-1'>
+              <Button
+                size='small'
+                icon={<Plus size={14} />}
+                onClick={() => setCreateVisible(true)}
+              >
+                {t('创建')}
+              </Button>
+              <Button size='small' onClick={() => setJoinVisible(true)}>
+                {t('加入')}
+              </Button>
+            </div>
+          }
+        >
+          {orgs.length === 0 ? (
+            <Empty description={t('暂未加入任何组织')} />
+          ) : (
+            <div className='flex flex-col gap-2'>
+              {orgs.map((org) => (
+                <div
+                  key={org.id}
+                  onClick={() => setSelectedId(org.id)}
+                  className={`cursor-pointer rounded-lg p-2 ${
+                    org.id === selectedId
+                      ? 'bg-[var(--semi-color-primary-light-default)]'
+                      : 'hover:bg-[var(--semi-color-fill-0)]'
+                  }`}
+                >
+                  <div className='font-medium'>{org.name}</div>
+                  <Text type='secondary' size='small'>
+                    {t(ROLE_LABELS[org.role] || org.role)} ·{' '}
+                    {renderQuota(org.quota)}
+                  </Text>
+                </div>
+              ))}
+            </div>
+          )}
+        </Card>
+        <div className='flex-1 min-w-0'>
+          {selectedId && (
+            <OrganizationDetail
+              key={selectedId}
+              orgId={selectedId}
+              onChanged={loadOrganizations}
+            />
+          )}
+        </div>
+      </div>
+
+      <Modal
+        title={t('创建组织')}
+        visible={createVisible}
+        onOk={submitCreate}
+        onCancel={() => setCreateVisible(false)}
+      >
+        <Input
+          value={name}
+          onChange={setName}
+          placeholder={t('组织名称')}
+          maxLength={64}
+        />
+      </Modal>
+      <Modal
+        title={t('加入组织')}
+        visible={joinVisible}
+        onOk={submitJoin}
+        onCancel={() => setJoinVisible(false)}
+      >
+        <Input value={code} onChange={setCode} placeholder={t('邀请码')} />
+      </Modal>
+    </div>
+  );
+};
+
+export default Organization;
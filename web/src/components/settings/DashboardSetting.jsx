@@ -48,6 +48,10 @@ const DashboardSetting = () => {
     DataExportEnabled: false,
     DataExportDefaultTime: 'hour',
     DataExportInterval: 5,
+    'usage_rollup_setting.enabled': true,
+    'usage_rollup_setting.serve_dashboard': false,
+    'usage_rollup_setting.flush_interval_seconds': 60,
+    'usage_rollup_setting.hourly_retention_days': 90,
   });
 
   let [loading, setLoading] = useState(false);
@@ -65,6 +69,12 @@ const DashboardSetting = () => {
         if (item.key.endsWith('Enabled') && item.key === 'DataExportEnabled') {
           newInputs[item.key] = toBoolean(item.value);
         }
+        if (
+          item.key === 'usage_rollup_setting.enabled' ||
+          item.key === 'usage_rollup_setting.serve_dashboard'
+        ) {
+          newInputs[item.key] = toBoolean(item.value);
+        }
       });
       setInputs(newInputs);
     } else {
@@ -59,7 +59,14 @@ const OperationSetting = () => {
     /* 敏感词设置 */
     CheckSensitiveEnabled: false,
     CheckSensitiveOnPromptEnabled: false,
+    CheckSensitiveOnCompletionEnabled: false,
     SensitiveWords: '',
+    'moderation_setting.regex_rules': '[]',
+    'moderation_setting.default_action': 'block',
+    'moderation_setting.group_actions': '{}',
+    'moderation_setting.model_enabled': false,
+    'moderation_setting.model_name': '',
+    'moderation_setting.model_group': '',
 
     /* 日志设置 */
     LogConsumeEnabled: false,
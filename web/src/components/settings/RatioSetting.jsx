@@ -37,6 +37,8 @@ const RatioSetting = () => {
     ModelRatio: '',
     CacheRatio: '',
     CreateCacheRatio: '',
+    BatchRatio: '',
+    ModelTieredRatio: '',
     CompletionRatio: '',
     GroupRatio: '',
     GroupGroupRatio: '',
@@ -80,6 +80,7 @@ const NotificationSettings = ({
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
       personal: true,
     },
     admin: {
@@ -165,7 +166,12 @@ const NotificationSettings = ({
         midjourney: true,
         task: true,
       },
-      personal: { enabled: true, topup: true, personal: true },
+      personal: {
+        enabled: true,
+        topup: true,
+        organization: true,
+        personal: true,
+      },
       admin: {
         enabled: true,
         channel: true,
@@ -279,6 +285,11 @@ const NotificationSettings = ({
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('组织管理'),
+          description: t('组织共享钱包与成员管理'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
@@ -204,6 +204,11 @@ const EditChannelModal = (props) => {
     allow_include_obfuscation: false,
     allow_inference_geo: false,
     claude_beta_query: false,
+    // 上游成本倍率，留空按官方价格计算
+    cost_ratio: null,
+    // 按模型配置的上游模型倍率与按次价格，JSON 字符串
+    cost_model_ratio: '',
+    cost_model_price: '',
     upstream_model_update_check_enabled: false,
     upstream_model_update_auto_sync_enabled: false,
     upstream_model_update_last_check_time: 0,
@@ -885,6 +890,16 @@ const EditChannelModal = (props) => {
           data.allow_inference_geo =
             parsedSettings.allow_inference_geo || false;
           data.claude_beta_query = parsedSettings.claude_beta_query || false;
+          data.cost_ratio =
+            typeof parsedSettings.cost_ratio === 'number'
+              ? parsedSettings.cost_ratio
+              : null;
+          data.cost_model_ratio = parsedSettings.cost_model_ratio
+            ? JSON.stringify(parsedSettings.cost_model_ratio, null, 2)
+            : '';
+          data.cost_model_price = parsedSettings.cost_model_price
+            ? JSON.stringify(parsedSettings.cost_model_price, null, 2)
+            : '';
           data.upstream_model_update_check_enabled =
             parsedSettings.upstream_model_update_check_enabled === true;
           data.upstream_model_update_auto_sync_enabled =
@@ -914,6 +929,9 @@ const EditChannelModal = (props) => {
           data.allow_include_obfuscation = false;
           data.allow_inference_geo = false;
           data.claude_beta_query = false;
+          data.cost_ratio = null;
+          data.cost_model_ratio = '';
+          data.cost_model_price = '';
           data.upstream_model_update_check_enabled = false;
           data.upstream_model_update_auto_sync_enabled = false;
           data.upstream_model_update_last_check_time = 0;
@@ -931,6 +949,9 @@ const EditChannelModal = (props) => {
         data.allow_include_obfuscation = false;
         data.allow_inference_geo = false;
         data.claude_beta_query = false;
+        data.cost_ratio = null;
+        data.cost_model_ratio = '';
+        data.cost_model_price = '';
         data.upstream_model_update_check_enabled = false;
         data.upstream_model_update_auto_sync_enabled = false;
         data.upstream_model_update_last_check_time = 0;
@@ -1738,6 +1759,30 @@ const EditChannelModal = (props) => {
       }
     }
 
+    // 上游成本倍率留空时删除，后端按 1 倍计算
+    if (
+      typeof localInputs.cost_ratio === 'number' &&
+      localInputs.cost_ratio >= 0
+    ) {
+      settings.cost_ratio = localInputs.cost_ratio;
+    } else {
+      delete settings.cost_ratio;
+    }
+
+    // 上游模型倍率与按次价格留空时删除，未配置的模型按上游成本倍率估算
+    for (const key of ['cost_model_ratio', 'cost_model_price']) {
+      const value = String(localInputs[key] || '').trim();
+      if (value === '') {
+        delete settings[key];
+        continue;
+      }
+      if (!verifyJSON(value)) {
+        showError(t('上游模型成本配置不是合法的 JSON'));
+        return;
+      }
+      settings[key] = JSON.parse(value);
+    }
+
     settings.upstream_model_update_check_enabled =
       localInputs.upstream_model_update_check_enabled === true;
     settings.upstream_model_update_auto_sync_enabled =
@@ -1782,6 +1827,7 @@ const EditChannelModal = (props) => {
     delete localInputs.allow_include_obfuscation;
     delete localInputs.allow_inference_geo;
     delete localInputs.claude_beta_query;
+    delete localInputs.cost_ratio;
     delete localInputs.upstream_model_update_check_enabled;
     delete localInputs.upstream_model_update_auto_sync_enabled;
     delete localInputs.upstream_model_update_last_check_time;
@@ -2740,6 +2786,10 @@ const EditChannelModal = (props) => {
                           optionList={[
                             { label: t('随机'), value: 'random' },
                             { label: t('轮询'), value: 'polling' },
+                            {
+                              label: t('最少负载（限流感知）'),
+                              value: 'least_loaded',
+                            },
                           ]}
                           style={{ width: '100%' }}
                           value={inputs.multi_key_mode || 'random'}
@@ -3443,6 +3493,61 @@ const EditChannelModal = (props) => {
                       </Col>
                     </Row>
 
+                    <Form.InputNumber
+                      field='cost_ratio'
+                      label={t('上游成本倍率')}
+                      placeholder={t('留空按官方价格计算，即 1')}
+                      min={0}
+                      step={0.1}
+                      onNumberChange={(value) =>
+                        handleInputChange('cost_ratio', value)
+                      }
+                      extraText={t(
+                        '该渠道相对官方价格的实际成本，如中转渠道为 0.3，用于统计上游成本与毛利',
+                      )}
+                      style={{ width: '100%' }}
+                    />
+
+                    <JSONEditor
+                      key={`cost_model_ratio-${isEdit ? channelId : 'new'}`}
+                      field='cost_model_ratio'
+                      label={t('上游模型倍率')}
+                      placeholder={
+                        t('此项可选，键为模型名称，值为上游的模型倍率，例如：') +
+                        '\n' +
+                        JSON.stringify({ 'gpt-4o': 1.25 }, null, 2)
+                      }
+                      value={inputs.cost_model_ratio || ''}
+                      onChange={(value) =>
+                        handleInputChange('cost_model_ratio', value)
+                      }
+                      editorType='keyValue'
+                      formApi={formApiRef.current}
+                      extraText={t(
+                        '按模型配置的上游实际倍率，用于计算上游成本，优先于上游成本倍率',
+                      )}
+                    />
+
+                    <JSONEditor
+                      key={`cost_model_price-${isEdit ? channelId : 'new'}`}
+                      field='cost_model_price'
+                      label={t('上游模型价格')}
+                      placeholder={
+                        t(
+                          '此项可选，键为模型名称，值为上游的按次价格（美元），例如：',
+                        ) +
+                        '\n' +
+                        JSON.stringify({ mj_imagine: 0.02 }, null, 2)
+                      }
+                      value={inputs.cost_model_price || ''}
+                      onChange={(value) =>
+                        handleInputChange('cost_model_price', value)
+                      }
+                      editorType='keyValue'
+                      formApi={formApiRef.current}
+                      extraText={t('按模型配置的上游按次价格，优先于上游模型倍率')}
+                    />
+
                     <Form.Switch
                       field='auto_ban'
                       label={t('是否自动禁用')}
@@ -406,6 +406,27 @@ const MultiKeyManageModal = ({ visible, onCancel, channel, onRefresh }) => {
         );
       },
     },
+    {
+      title: t('限流冷却至'),
+      dataIndex: 'cooldown_until',
+      render: (time, record) => {
+        if (!time) {
+          return <Text type='quaternary'>-</Text>;
+        }
+        return (
+          <Tooltip content={record.cooldown_reason || ''}>
+            <Tag color='amber' shape='circle' size='small'>
+              {timestamp2string(time)}
+            </Tag>
+          </Tooltip>
+        );
+      },
+    },
+    {
+      title: t('进行中请求'),
+      dataIndex: 'in_flight',
+      render: (count) => <Text>{count || 0}</Text>,
+    },
     {
       title: t('操作'),
       key: 'action',
@@ -469,7 +490,9 @@ const MultiKeyManageModal = ({ visible, onCancel, channel, onRefresh }) => {
             <Tag size='small' shape='circle' color='white'>
               {channel.channel_info.multi_key_mode === 'random'
                 ? t('随机模式')
-                : t('轮询模式')}
+                : channel.channel_info.multi_key_mode === 'least_loaded'
+                  ? t('最少负载模式')
+                  : t('轮询模式')}
             </Tag>
           )}
         </Space>
@@ -40,6 +40,22 @@ const ModelPricingTable = ({
     ? modelData.enable_groups
     : [];
   const autoChain = autoGroups.filter((g) => modelEnableGroups.includes(g));
+  const tieredRatios =
+    modelData?.quota_type === 0 && Array.isArray(modelData?.tiered_ratios)
+      ? modelData.tiered_ratios
+      : [];
+  const timePriceWindows = Array.isArray(modelData?.time_price_windows)
+    ? modelData.time_price_windows
+    : [];
+  // 分档计费只改变输入、补全与缓存读取价格
+  const tierItemKeys = [
+    'input',
+    'completion',
+    'cache',
+    'input-ratio',
+    'completion-ratio',
+    'cache-ratio',
+  ];
   const renderGroupPriceTable = () => {
     // 仅展示模型可用的分组：模型 enable_groups 与用户可用分组的交集
 
@@ -77,6 +93,28 @@ const ModelPricingTable = ({
               ? t('按次计费')
               : '-',
         priceItems: getModelPriceItems(priceData, t, siteDisplayType),
+        tiers: tieredRatios.map((tier) => ({
+          threshold: tier.threshold,
+          priceItems: getModelPriceItems(
+            calculateModelPrice({
+              record: {
+                ...modelData,
+                model_ratio: tier.model_ratio,
+                completion_ratio:
+                  tier.completion_ratio || modelData.completion_ratio,
+                cache_ratio: tier.cache_ratio || modelData.cache_ratio,
+              },
+              selectedGroup: group,
+              groupRatio,
+              tokenUnit,
+              displayPrice,
+              currency,
+              quotaDisplayType: siteDisplayType,
+            }),
+            t,
+            siteDisplayType,
+          ).filter((item) => tierItemKeys.includes(item.key)),
+        })),
       };
     });
 
@@ -126,7 +164,7 @@ const ModelPricingTable = ({
     columns.push({
       title: siteDisplayType === 'TOKENS' ? t('计费摘要') : t('价格摘要'),
       dataIndex: 'priceItems',
-      render: (items) => (
+      render: (items, record) => (
         <div className='space-y-1'>
           {items.map((item) => (
             <div key={item.key}>
@@ -136,6 +174,23 @@ const ModelPricingTable = ({
               <div className='text-xs text-gray-500'>{item.suffix}</div>
             </div>
           ))}
+          {record.tiers.map((tier) => (
+            <div key={tier.threshold} className='pt-1'>
+              <div className='text-xs text-gray-600'>
+                {t('提示词超过 {{threshold}} tokens', {
+                  threshold: tier.threshold,
+                })}
+              </div>
+              {tier.priceItems.map((item) => (
+                <div key={item.key}>
+                  <div className='font-semibold text-orange-600'>
+                    {item.label} {item.value}
+                  </div>
+                  <div className='text-xs text-gray-500'>{item.suffix}</div>
+                </div>
+              ))}
+            </div>
+          ))}
         </div>
       ),
     });
@@ -181,6 +236,27 @@ const ModelPricingTable = ({
         </div>
       )}
       {renderGroupPriceTable()}
+      {timePriceWindows.length > 0 && (
+        <div className='mt-4 space-y-1'>
+          <Text className='text-sm font-medium'>{t('分时价格')}</Text>
+          {timePriceWindows.map((window, idx) => (
+            <div key={idx} className='text-xs text-gray-600'>
+              {window.name ? `${window.name}: ` : ''}
+              {window.start} - {window.end} ({window.timezone})
+              {Array.isArray(window.weekdays) && window.weekdays.length > 0
+                ? ` ${t('星期')} ${window.weekdays.join(', ')}`
+                : ''}
+              {Array.isArray(window.groups) && window.groups.length > 0
+                ? ` ${window.groups.join(', ')}${t('分组')}`
+                : ''}
+              {' '}
+              <Tag color='green' size='small' shape='circle'>
+                {t('价格 ×{{ratio}}', { ratio: window.ratio })}
+              </Tag>
+            </div>
+          ))}
+        </div>
+      )}
     </Card>
   );
 };
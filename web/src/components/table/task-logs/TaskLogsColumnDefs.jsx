@@ -200,6 +200,12 @@ const renderStatus = (type, t) => {
           {t('执行中')}
         </Tag>
       );
+    case 'CANCELLING':
+      return (
+        <Tag color='orange' shape='circle' prefixIcon={<Pause size={14} />}>
+          {t('取消中')}
+        </Tag>
+      );
     case 'FAILURE':
       return (
         <Tag color='red' shape='circle' prefixIcon={<XCircle size={14} />}>
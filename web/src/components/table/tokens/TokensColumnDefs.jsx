@@ -306,6 +306,54 @@ const renderQuotaUsage = (text, record, t) => {
   );
 };
 
+const budgetPeriodLabels = {
+  day: '每日',
+  week: '每周',
+  month: '每月',
+};
+
+// Render recurring budget window usage column
+const renderBudgetUsage = (text, record, t) => {
+  const budget = parseInt(record.budget_quota) || 0;
+  if (!record.budget_period || budget <= 0) {
+    return <span>-</span>;
+  }
+  const used = parseInt(record.budget_used_quota) || 0;
+  const remain = Math.max(budget - used, 0);
+  const percent = (remain / budget) * 100;
+  const popoverContent = (
+    <div className='text-xs p-2'>
+      <div>
+        {t('周期')}: {t(budgetPeriodLabels[record.budget_period])}
+      </div>
+      <div>
+        {t('本周期已用')}: {renderQuota(used)}
+      </div>
+      <div>
+        {t('周期预算')}: {renderQuota(budget)}
+      </div>
+    </div>
+  );
+  return (
+    <Popover content={popoverContent} position='top'>
+      <Tag color='white' shape='circle'>
+        <div className='flex flex-col items-end'>
+          <span className='text-xs leading-none'>
+            {`${t(budgetPeriodLabels[record.budget_period])} ${renderQuota(used)} / ${renderQuota(budget)}`}
+          </span>
+          <Progress
+            percent={percent}
+            stroke={getProgressColor(percent)}
+            aria-label='budget usage'
+            format={() => `${percent.toFixed(0)}%`}
+            style={{ width: '100%', marginTop: '1px', marginBottom: 0 }}
+          />
+        </div>
+      </Tag>
+    </Popover>
+  );
+};
+
 // Render operations column
 const renderOperations = (
   text,
@@ -451,6 +499,11 @@ export const getTokensColumns = ({
       key: 'quota_usage',
       render: (text, record) => renderQuotaUsage(text, record, t),
     },
+    {
+      title: t('周期预算'),
+      key: 'budget_usage',
+      render: (text, record) => renderBudgetUsage(text, record, t),
+    },
     {
       title: t('分组'),
       dataIndex: 'group',
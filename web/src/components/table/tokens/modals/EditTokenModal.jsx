@@ -48,6 +48,7 @@ import {
   IconSave,
   IconClose,
   IconKey,
+  IconPulse,
 } from '@douyinfe/semi-icons';
 import { useTranslation } from 'react-i18next';
 import { StatusContext } from '../../../../context/Status';
@@ -62,6 +63,7 @@ const EditTokenModal = (props) => {
   const formApiRef = useRef(null);
   const [models, setModels] = useState([]);
   const [groups, setGroups] = useState([]);
+  const [organizations, setOrganizations] = useState([]);
   const isEdit = props.editingToken.id !== undefined;
 
   const getInitValues = () => ({
@@ -69,11 +71,18 @@ const EditTokenModal = (props) => {
     remain_quota: 0,
     expired_time: -1,
     unlimited_quota: true,
+    budget_period: '',
+    budget_quota: 0,
+    rate_limit_rpm: 0,
+    rate_limit_tpm: 0,
+    rate_limit_concurrency: 0,
+    model_rate_limits: '',
     model_limits_enabled: false,
     model_limits: [],
     allow_ips: '',
     group: '',
     cross_group_retry: false,
+    organization_id: 0,
     tokenCount: 1,
   });
 
@@ -149,6 +158,16 @@ const EditTokenModal = (props) => {
     }
   };
 
+  const loadOrganizations = async () => {
+    let res = await API.get(`/api/organization/self`);
+    const { success, data } = res.data;
+    if (success) {
+      setOrganizations(
+        (data || []).map((org) => ({ label: org.name, value: org.id })),
+      );
+    }
+  };
+
   const loadToken = async () => {
     setLoading(true);
     let res = await API.get(`/api/token/${props.editingToken.id}`);
@@ -179,6 +198,7 @@ const EditTokenModal = (props) => {
     }
     loadModels();
     loadGroups();
+    loadOrganizations();
   }, [props.editingToken.id]);
 
   useEffect(() => {
@@ -210,6 +230,11 @@ const EditTokenModal = (props) => {
     if (isEdit) {
       let { tokenCount: _tc, ...localInputs } = values;
       localInputs.remain_quota = parseInt(localInputs.remain_quota);
+      localInputs.budget_quota = parseInt(localInputs.budget_quota) || 0;
+      localInputs.rate_limit_rpm = parseInt(localInputs.rate_limit_rpm) || 0;
+      localInputs.rate_limit_tpm = parseInt(localInputs.rate_limit_tpm) || 0;
+      localInputs.rate_limit_concurrency =
+        parseInt(localInputs.rate_limit_concurrency) || 0;
       if (localInputs.expired_time !== -1) {
         let time = Date.parse(localInputs.expired_time);
         if (isNaN(time)) {
@@ -246,6 +271,11 @@ const EditTokenModal = (props) => {
           localInputs.name = baseName;
         }
         localInputs.remain_quota = parseInt(localInputs.remain_quota);
+        localInputs.budget_quota = parseInt(localInputs.budget_quota) || 0;
+        localInputs.rate_limit_rpm = parseInt(localInputs.rate_limit_rpm) || 0;
+        localInputs.rate_limit_tpm = parseInt(localInputs.rate_limit_tpm) || 0;
+        localInputs.rate_limit_concurrency =
+          parseInt(localInputs.rate_limit_concurrency) || 0;
 
         if (localInputs.expired_time !== -1) {
           let time = Date.parse(localInputs.expired_time);
@@ -393,6 +423,22 @@ const EditTokenModal = (props) => {
                       )}
                     />
                   </Col>
+                  {organizations.length > 0 && (
+                    <Col span={24}>
+                      <Form.Select
+                        field='organization_id'
+                        label={t('扣费钱包')}
+                        optionList={[
+                          { label: t('个人钱包'), value: 0 },
+                          ...organizations,
+                        ]}
+                        extraText={t(
+                          '选择组织后，该令牌的用量从组织共享钱包扣费，并计入你的成员额度',
+                        )}
+                        style={{ width: '100%' }}
+                      />
+                    </Col>
+                  )}
                   <Col xs={24} sm={24} md={24} lg={10} xl={10}>
                     <Form.DatePicker
                       field='expired_time'
@@ -521,6 +567,36 @@ const EditTokenModal = (props) => {
                       )}
                     />
                   </Col>
+                  <Col span={10}>
+                    <Form.Select
+                      field='budget_period'
+                      label={t('周期预算')}
+                      optionList={[
+                        { value: '', label: t('不限') },
+                        { value: 'day', label: t('每日') },
+                        { value: 'week', label: t('每周') },
+                        { value: 'month', label: t('每月') },
+                      ]}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={14}>
+                    <Form.InputNumber
+                      field='budget_quota'
+                      label={t('周期预算额度')}
+                      min={0}
+                      disabled={!values.budget_period}
+                      extraText={renderQuotaWithPrompt(values.budget_quota)}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Text type='tertiary' size='small'>
+                      {t(
+                        '周期预算按自然日/周/月限制令牌用量，周期开始时自动重置，与总额度同时生效',
+                      )}
+                    </Text>
+                  </Col>
                 </Row>
               </Card>
 
@@ -575,6 +651,77 @@ const EditTokenModal = (props) => {
                   </Col>
                 </Row>
               </Card>
+
+              {/* 限流设置 */}
+              <Card className='!rounded-2xl shadow-sm border-0'>
+                <div className='flex items-center mb-2'>
+                  <Avatar size='small' color='red' className='mr-2 shadow-md'>
+                    <IconPulse size={16} />
+                  </Avatar>
+                  <div>
+                    <Text className='text-lg font-medium'>{t('限流设置')}</Text>
+                    <div className='text-xs text-gray-600'>
+                      {t('限制令牌的请求频率、用量频率与并发数，0 表示不限制')}
+                    </div>
+                  </div>
+                </div>
+                <Row gutter={12}>
+                  <Col span={8}>
+                    <Form.InputNumber
+                      field='rate_limit_rpm'
+                      label={t('每分钟请求数')}
+                      min={0}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={8}>
+                    <Form.InputNumber
+                      field='rate_limit_tpm'
+                      label={t('每分钟 Tokens')}
+                      min={0}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={8}>
+                    <Form.InputNumber
+                      field='rate_limit_concurrency'
+                      label={t('最大并发数')}
+                      min={0}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Form.TextArea
+                      field='model_rate_limits'
+                      label={t('按模型限流')}
+                      placeholder={
+                        '{"gpt-4o": {"rpm": 60, "tpm": 100000, "concurrency": 5}}'
+                      }
+                      autosize
+                      rows={2}
+                      extraText={t(
+                        '按模型单独计数，与令牌级限流同时生效，留空则不限制',
+                      )}
+                      rules={[
+                        {
+                          validator: (rule, value) => {
+                            if (!value || value.trim() === '') return true;
+                            try {
+                              JSON.parse(value);
+                              return true;
+                            } catch (e) {
+                              return false;
+                            }
+                          },
+                          message: t('请输入有效的 JSON'),
+                        },
+                      ]}
+                      showClear
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                </Row>
+              </Card>
             </div>
           )}
         </Form>
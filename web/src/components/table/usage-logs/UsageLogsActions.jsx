@@ -18,7 +18,8 @@ For commercial licensing, please contact support@quantumnous.com
 */
 
 import React from 'react';
-import { Tag, Space, Skeleton } from '@douyinfe/semi-ui';
+import { Tag, Space, Skeleton, Dropdown, Button } from '@douyinfe/semi-ui';
+import { IconDownload } from '@douyinfe/semi-icons';
 import { renderQuota } from '../../../helpers';
 import CompactModeToggle from '../../common/ui/CompactModeToggle';
 import { useMinimumLoadingTime } from '../../../hooks/common/useMinimumLoadingTime';
@@ -29,6 +30,10 @@ const LogsActions = ({
   showStat,
   compactMode,
   setCompactMode,
+  isAdminUser,
+  exporting,
+  exportLogs,
+  downloadStatement,
   t,
 }) => {
   const showSkeleton = useMinimumLoadingTime(loadingStat);
@@ -83,11 +88,47 @@ const LogsActions = ({
         </Space>
       </Skeleton>
 
-      <CompactModeToggle
-        compactMode={compactMode}
-        setCompactMode={setCompactMode}
-        t={t}
-      />
+      <Space>
+        <Dropdown
+          trigger='click'
+          position='bottomRight'
+          render={
+            <Dropdown.Menu>
+              <Dropdown.Item onClick={() => exportLogs('csv')}>
+                {t('导出为')} CSV
+              </Dropdown.Item>
+              <Dropdown.Item onClick={() => exportLogs('xlsx')}>
+                {t('导出为')} XLSX
+              </Dropdown.Item>
+              <Dropdown.Item onClick={() => exportLogs('jsonl')}>
+                {t('导出为')} JSONL
+              </Dropdown.Item>
+              {!isAdminUser && (
+                <>
+                  <Dropdown.Divider />
+                  <Dropdown.Item onClick={downloadStatement}>
+                    {t('下载本月账单')}
+                  </Dropdown.Item>
+                </>
+              )}
+            </Dropdown.Menu>
+          }
+        >
+          <Button
+            type='tertiary'
+            size='small'
+            icon={<IconDownload />}
+            loading={exporting}
+          >
+            {t('导出')}
+          </Button>
+        </Dropdown>
+        <CompactModeToggle
+          compactMode={compactMode}
+          setCompactMode={setCompactMode}
+          t={t}
+        />
+      </Space>
     </div>
   );
 };
@@ -164,6 +164,17 @@ function renderIsStream(bool, t) {
   }
 }
 
+function renderResponseCacheHit(other, t) {
+  if (!other?.response_cache_hit) {
+    return null;
+  }
+  return (
+    <Tag color='cyan' shape='circle'>
+      {t('缓存命中')}
+    </Tag>
+  );
+}
+
 function renderUseTime(type, t) {
   const time = parseInt(type);
   if (time < 101) {
@@ -552,15 +563,18 @@ export const getLogsColumns = ({
                 {renderUseTime(text, t)}
                 {renderFirstUseTime(other?.frt, t)}
                 {renderIsStream(record.is_stream, t)}
+                {renderResponseCacheHit(other, t)}
               </Space>
             </>
           );
         } else {
+          let other = getLogOther(record.other);
           return (
             <>
               <Space>
                 {renderUseTime(text, t)}
                 {renderIsStream(record.is_stream, t)}
+                {renderResponseCacheHit(other, t)}
               </Space>
             </>
           );
@@ -0,0 +1,169 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useRef, useState } from 'react';
+import {
+  Modal,
+  Descriptions,
+  Spin,
+  Tabs,
+  TabPane,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { API, showError, timestamp2string } from '../../../../helpers';
+
+const { Text } = Typography;
+
+function formatBody(body) {
+  if (!body) return '';
+  try {
+    return JSON.stringify(JSON.parse(body), null, 2);
+  } catch (e) {
+    return body;
+  }
+}
+
+const PayloadBlock = ({ content, t }) => (
+  <pre
+    style={{
+      maxHeight: 420,
+      overflow: 'auto',
+      whiteSpace: 'pre-wrap',
+      wordBreak: 'break-all',
+      margin: 0,
+      padding: 12,
+      borderRadius: 8,
+      background: 'var(--semi-color-fill-0)',
+      fontSize: 12,
+    }}
+  >
+    {content || t('无')}
+  </pre>
+);
+
+const PayloadCaptureModal = ({
+  t,
+  showPayloadCaptureModal,
+  setShowPayloadCaptureModal,
+  payloadCaptureRequestId,
+}) => {
+  const [loading, setLoading] = useState(false);
+  const [captures, setCaptures] = useState([]);
+  const requestSeqRef = useRef(0);
+
+  useEffect(() => {
+    if (!showPayloadCaptureModal || !payloadCaptureRequestId) {
+      requestSeqRef.current += 1; // invalidate inflight request
+      setLoading(false);
+      setCaptures([]);
+      return;
+    }
+    const reqSeq = (requestSeqRef.current += 1);
+    setLoading(true);
+    (async () => {
+      try {
+        const res = await API.get(
+          `/api/log/capture/${encodeURIComponent(payloadCaptureRequestId)}`,
+        );
+        if (reqSeq !== requestSeqRef.current) return;
+        const { success, message, data } = res.data || {};
+        if (!success) {
+          showError(t(message || '请求失败'));
+          return;
+        }
+        setCaptures(data || []);
+      } catch (e) {
+        if (reqSeq !== requestSeqRef.current) return;
+        showError(t('请求失败'));
+      } finally {
+        if (reqSeq !== requestSeqRef.current) return;
+        setLoading(false);
+      }
+    })();
+  }, [showPayloadCaptureModal, payloadCaptureRequestId, t]);
+
+  const capture = captures[0];
+
+  return (
+    <Modal
+      title={t('请求载荷')}
+      visible={showPayloadCaptureModal}
+      onCancel={() => setShowPayloadCaptureModal(false)}
+      footer={null}
+      centered
+      closable
+      maskClosable
+      width={880}
+    >
+      <Spin spinning={loading} tip={t('加载中...')}>
+        {capture ? (
+          <div style={{ paddingBottom: 16 }}>
+            <Descriptions
+              row
+              size='small'
+              data={[
+                { key: t('时间'), value: timestamp2string(capture.created_at) },
+                { key: t('模型'), value: capture.model_name || '-' },
+                { key: t('渠道'), value: capture.channel_id || '-' },
+                { key: t('状态码'), value: capture.status_code },
+                { key: t('路径'), value: capture.path || '-' },
+              ]}
+            />
+            {capture.truncated ? (
+              <Tag color='orange' style={{ marginTop: 8 }}>
+                {t('内容过长，已截断')}
+              </Tag>
+            ) : null}
+            <Tabs type='line' style={{ marginTop: 8 }}>
+              <TabPane tab={t('请求')} itemKey='request'>
+                <PayloadBlock content={formatBody(capture.request_body)} t={t} />
+              </TabPane>
+              {capture.is_stream ? (
+                <TabPane tab={t('输出文本')} itemKey='text'>
+                  <PayloadBlock content={capture.response_text} t={t} />
+                </TabPane>
+              ) : null}
+              <TabPane tab={t('响应')} itemKey='response'>
+                <PayloadBlock
+                  content={
+                    capture.is_stream
+                      ? capture.response_body
+                      : formatBody(capture.response_body)
+                  }
+                  t={t}
+                />
+              </TabPane>
+            </Tabs>
+          </div>
+        ) : (
+          <div style={{ padding: '24px 0' }}>
+            <Text type='tertiary' size='small'>
+              {loading
+                ? t('加载中...')
+                : t('该请求没有载荷采集记录')}
+            </Text>
+          </div>
+        )}
+      </Spin>
+    </Modal>
+  );
+};
+
+export default PayloadCaptureModal;
@@ -67,6 +67,7 @@ import {
   Image as ImageIcon,
   CheckSquare,
   CreditCard,
+  Building2,
   Layers,
   Gift,
   User,
@@ -132,6 +133,8 @@ export function getLucideIcon(key, selected = false) {
       return <CheckSquare {...commonProps} color={iconColor} />;
     case 'topup':
       return <CreditCard {...commonProps} color={iconColor} />;
+    case 'organization':
+      return <Building2 {...commonProps} color={iconColor} />;
     case 'channel':
       return <Layers {...commonProps} color={iconColor} />;
     case 'redemption':
@@ -19,7 +19,7 @@ For commercial licensing, please contact support@quantumnous.com
 
 import { useState, useEffect } from 'react';
 import { useTranslation } from 'react-i18next';
-import { Modal } from '@douyinfe/semi-ui';
+import { Button, Modal, Space } from '@douyinfe/semi-ui';
 import {
   API,
   getTodayStartTimestamp,
@@ -182,6 +182,10 @@ export const useLogsData = () => {
   const [channelAffinityUsageCacheTarget, setChannelAffinityUsageCacheTarget] =
     useState(null);
 
+  // Payload capture modal state (admin only)
+  const [showPayloadCaptureModal, setShowPayloadCaptureModal] = useState(false);
+  const [payloadCaptureRequestId, setPayloadCaptureRequestId] = useState('');
+
   // Initialize default column visibility
   const initDefaultColumns = () => {
     const defaults = getDefaultColumnVisibility();
@@ -345,6 +349,11 @@ export const useLogsData = () => {
     setShowChannelAffinityUsageCacheModal(true);
   };
 
+  const openPayloadCaptureModal = (requestId) => {
+    setPayloadCaptureRequestId(requestId || '');
+    setShowPayloadCaptureModal(true);
+  };
+
   // Format logs data
   const setLogsFormat = (logs) => {
     const requestConversionDisplayValue = (conversionChain) => {
@@ -370,10 +379,35 @@ export const useLogsData = () => {
           value: `${logs[i].channel} - ${logs[i].channel_name || '[未知]'}`,
         });
       }
+      if (isAdminUser && logs[i].type === 2 && logs[i].upstream_cost > 0) {
+        expandDataLocal.push({
+          key: t('上游成本'),
+          value: other?.upstream_cost_estimated
+            ? `${renderQuota(logs[i].upstream_cost, 6)}（${t('按渠道成本倍率估算')}）`
+            : renderQuota(logs[i].upstream_cost, 6),
+        });
+      }
       if (logs[i].request_id) {
+        const requestId = logs[i].request_id;
         expandDataLocal.push({
           key: t('Request ID'),
-          value: logs[i].request_id,
+          value: isAdminUser ? (
+            <Space>
+              <span>{requestId}</span>
+              <Button
+                size='small'
+                theme='borderless'
+                onClick={(e) => {
+                  e.stopPropagation();
+                  openPayloadCaptureModal(requestId);
+                }}
+              >
+                {t('查看载荷')}
+              </Button>
+            </Space>
+          ) : (
+            requestId
+          ),
         });
       }
       if (other?.ws || other?.audio) {
@@ -406,6 +440,20 @@ export const useLogsData = () => {
           value: other.cache_creation_tokens,
         });
       }
+      if (other?.time_window) {
+        expandDataLocal.push({
+          key: t('分时价格'),
+          value: `${other.time_window} ×${other.time_ratio}`,
+        });
+      }
+      if (other?.tier_threshold !== undefined) {
+        expandDataLocal.push({
+          key: t('分档计费'),
+          value: t('提示词超过 {{threshold}} tokens', {
+            threshold: other.tier_threshold,
+          }),
+        });
+      }
       if (logs[i].type === 2) {
         expandDataLocal.push({
           key: t('日志详情'),
@@ -705,6 +753,67 @@ export const useLogsData = () => {
     setLoading(false);
   };
 
+  // 按当前筛选条件导出消费日志，服务端流式写出，浏览器收到完整文件后再保存
+  const [exporting, setExporting] = useState(false);
+  const saveBlob = (data, filename) => {
+    const url = URL.createObjectURL(data);
+    const a = document.createElement('a');
+    a.href = url;
+    a.download = filename;
+    a.click();
+    URL.revokeObjectURL(url);
+  };
+
+  const exportLogs = async (format) => {
+    const {
+      username,
+      token_name,
+      model_name,
+      start_timestamp,
+      end_timestamp,
+      channel,
+      group,
+    } = getFormValues();
+    const localStartTimestamp = Date.parse(start_timestamp) / 1000;
+    const localEndTimestamp = Date.parse(end_timestamp) / 1000;
+    const url = isAdminUser
+      ? `/api/log/export?format=${format}&username=${username}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&channel=${channel}&group=${group}`
+      : `/api/log/self/export?format=${format}&token_name=${token_name}&model_name=${model_name}&start_timestamp=${localStartTimestamp}&end_timestamp=${localEndTimestamp}&group=${group}`;
+    setExporting(true);
+    try {
+      const res = await API.get(encodeURI(url), {
+        responseType: 'blob',
+        disableDuplicate: true,
+      });
+      saveBlob(res.data, `consume-logs-${Date.now()}.${format}`);
+    } catch (error) {
+      showError(error.message);
+    } finally {
+      setExporting(false);
+    }
+  };
+
+  // 下载本月对账单（HTML，可直接打印为 PDF）
+  const downloadStatement = async () => {
+    const now = new Date();
+    const period = `${now.getFullYear()}-${String(now.getMonth() + 1).padStart(
+      2,
+      '0',
+    )}`;
+    setExporting(true);
+    try {
+      const res = await API.get(
+        `/api/user/self/statement?period=${period}&format=html`,
+        { responseType: 'blob', disableDuplicate: true },
+      );
+      saveBlob(res.data, `statement-${period}.html`);
+    } catch (error) {
+      showError(error.message);
+    } finally {
+      setExporting(false);
+    }
+  };
+
   // Page handlers
   const handlePageChange = (page) => {
     setActivePage(page);
@@ -778,6 +887,9 @@ export const useLogsData = () => {
     logType,
     stat,
     isAdminUser,
+    exporting,
+    exportLogs,
+    downloadStatement,
 
     // Form state
     formApi,
@@ -812,6 +924,12 @@ export const useLogsData = () => {
     channelAffinityUsageCacheTarget,
     openChannelAffinityUsageCacheModal,
 
+    // Payload capture modal
+    showPayloadCaptureModal,
+    setShowPayloadCaptureModal,
+    payloadCaptureRequestId,
+    openPayloadCaptureModal,
+
     // Functions
     loadLogs,
     handlePageChange,
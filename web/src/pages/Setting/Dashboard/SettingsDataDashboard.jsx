@@ -18,7 +18,15 @@ For commercial licensing, please contact support@quantumnous.com
 */
 
 import React, { useEffect, useState, useRef } from 'react';
-import { Button, Col, Form, Row, Spin } from '@douyinfe/semi-ui';
+import {
+  Button,
+  Col,
+  Form,
+  InputNumber,
+  Row,
+  Space,
+  Spin,
+} from '@douyinfe/semi-ui';
 import {
   compareObjects,
   API,
@@ -41,7 +49,12 @@ export default function DataDashboard(props) {
     DataExportEnabled: false,
     DataExportInterval: '',
     DataExportDefaultTime: '',
+    'usage_rollup_setting.enabled': true,
+    'usage_rollup_setting.serve_dashboard': false,
+    'usage_rollup_setting.flush_interval_seconds': 60,
+    'usage_rollup_setting.hourly_retention_days': 90,
   });
+  const [backfillDays, setBackfillDays] = useState(30);
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
 
@@ -80,6 +93,25 @@ export default function DataDashboard(props) {
       });
   }
 
+  // 由历史日志回填预聚合数据，范围按 UTC 天对齐且不包含今天
+  async function startBackfill() {
+    const today = Math.floor(Date.now() / 1000 / 86400) * 86400;
+    try {
+      const res = await API.post('/api/data/rollup/backfill', {
+        start_timestamp: today - backfillDays * 86400,
+        end_timestamp: today,
+      });
+      const { success, message } = res.data;
+      if (success) {
+        showSuccess(t('回填任务已开始，可稍后刷新数据看板查看'));
+      } else {
+        showError(message);
+      }
+    } catch (error) {
+      showError(error.message);
+    }
+  }
+
   useEffect(() => {
     const currentInputs = {};
     for (let key in props.options) {
@@ -157,6 +189,98 @@ export default function DataDashboard(props) {
                 />
               </Col>
             </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'usage_rollup_setting.enabled'}
+                  label={t('启用用量预聚合')}
+                  extraText={t(
+                    '按小时和天汇总用户、令牌、模型、渠道和分组维度的用量',
+                  )}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'usage_rollup_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'usage_rollup_setting.serve_dashboard'}
+                  label={t('数据看板使用预聚合数据')}
+                  extraText={t('开启前请先回填历史数据')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'usage_rollup_setting.serve_dashboard': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('预聚合写入间隔')}
+                  step={1}
+                  min={1}
+                  suffix={t('秒')}
+                  field={'usage_rollup_setting.flush_interval_seconds'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'usage_rollup_setting.flush_interval_seconds':
+                        String(value),
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('小时数据保留天数')}
+                  step={1}
+                  min={0}
+                  suffix={t('天')}
+                  extraText={t('0 表示不清理，天粒度数据始终保留')}
+                  field={'usage_rollup_setting.hourly_retention_days'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'usage_rollup_setting.hourly_retention_days':
+                        String(value),
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16} style={{ marginBottom: 12 }}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Slot label={t('回填天数')}>
+                  <Space>
+                    <InputNumber
+                      step={1}
+                      min={1}
+                      suffix={t('天')}
+                      value={backfillDays}
+                      onChange={(value) => setBackfillDays(value)}
+                    />
+                    <Button size='default' onClick={startBackfill}>
+                      {t('开始回填')}
+                    </Button>
+                  </Space>
+                  <div className='text-xs text-gray-500 mt-1'>
+                    {t('由历史日志重建预聚合数据，不包含今天')}
+                  </div>
+                </Form.Slot>
+              </Col>
+            </Row>
             <Row>
               <Button size='default' onClick={onSubmit}>
                 {t('保存数据看板设置')}
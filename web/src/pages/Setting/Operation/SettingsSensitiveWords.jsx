@@ -25,6 +25,7 @@ import {
   showError,
   showSuccess,
   showWarning,
+  verifyJSON,
 } from '../../../helpers';
 import { useTranslation } from 'react-i18next';
 
@@ -34,7 +35,14 @@ export default function SettingsSensitiveWords(props) {
   const [inputs, setInputs] = useState({
     CheckSensitiveEnabled: false,
     CheckSensitiveOnPromptEnabled: false,
+    CheckSensitiveOnCompletionEnabled: false,
     SensitiveWords: '',
+    'moderation_setting.regex_rules': '[]',
+    'moderation_setting.default_action': 'block',
+    'moderation_setting.group_actions': '{}',
+    'moderation_setting.model_enabled': false,
+    'moderation_setting.model_name': '',
+    'moderation_setting.model_group': '',
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -42,6 +50,14 @@ export default function SettingsSensitiveWords(props) {
   function onSubmit() {
     const updateArray = compareObjects(inputs, inputsRow);
     if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    for (const key of [
+      'moderation_setting.regex_rules',
+      'moderation_setting.group_actions',
+    ]) {
+      if (!verifyJSON(inputs[key] || '')) {
+        return showError(t('不是合法的 JSON 字符串'));
+      }
+    }
     const requestQueue = updateArray.map((item) => {
       let value = '';
       if (typeof inputs[item.key] === 'boolean') {
@@ -125,6 +141,21 @@ export default function SettingsSensitiveWords(props) {
                   }
                 />
               </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'CheckSensitiveOnCompletionEnabled'}
+                  label={t('启用流式输出检查')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      CheckSensitiveOnCompletionEnabled: value,
+                    })
+                  }
+                />
+              </Col>
             </Row>
             <Row>
               <Col xs={24} sm={12} md={8} lg={8} xl={8}>
@@ -143,6 +174,103 @@ export default function SettingsSensitiveWords(props) {
                   autosize={{ minRows: 6, maxRows: 12 }}
                 />
               </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.TextArea
+                  label={t('正则规则')}
+                  extraText={t('JSON 字符串数组，每项为一个正则表达式')}
+                  placeholder={'["\\\\d{3}-\\\\d{4}"]'}
+                  field={'moderation_setting.regex_rules'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'moderation_setting.regex_rules': value,
+                    })
+                  }
+                  style={{ fontFamily: 'JetBrains Mono, Consolas' }}
+                  autosize={{ minRows: 6, maxRows: 12 }}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Select
+                  field={'moderation_setting.default_action'}
+                  label={t('默认处理方式')}
+                  optionList={[
+                    { value: 'block', label: t('拦截') },
+                    { value: 'redact', label: t('替换命中内容') },
+                    { value: 'flag', label: t('仅记录') },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'moderation_setting.default_action': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={16} lg={16} xl={16}>
+                <Form.TextArea
+                  label={t('分组处理方式')}
+                  extraText={t(
+                    'JSON 对象，键为分组，值为 block、redact 或 flag，未配置的分组使用默认处理方式',
+                  )}
+                  placeholder={'{"vip": "flag"}'}
+                  field={'moderation_setting.group_actions'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'moderation_setting.group_actions': value,
+                    })
+                  }
+                  style={{ fontFamily: 'JetBrains Mono, Consolas' }}
+                  autosize={{ minRows: 2, maxRows: 8 }}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'moderation_setting.model_enabled'}
+                  label={t('启用审核模型')}
+                  extraText={t('通过内部渠道调用 moderations 接口，不计费')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'moderation_setting.model_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'moderation_setting.model_name'}
+                  label={t('审核模型')}
+                  placeholder={'omni-moderation-latest'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'moderation_setting.model_name': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'moderation_setting.model_group'}
+                  label={t('审核渠道分组')}
+                  placeholder={'default'}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'moderation_setting.model_group': value,
+                    })
+                  }
+                />
+              </Col>
             </Row>
             <Row>
               <Button size='default' onClick={onSubmit}>
@@ -56,6 +56,7 @@ export default function SettingsSidebarModulesAdmin(props) {
     personal: {
       enabled: true,
       topup: true,
+      organization: true,
       personal: true,
     },
     admin: {
@@ -117,6 +118,7 @@ export default function SettingsSidebarModulesAdmin(props) {
       personal: {
         enabled: true,
         topup: true,
+        organization: true,
         personal: true,
       },
       admin: {
@@ -187,7 +189,12 @@ export default function SettingsSidebarModulesAdmin(props) {
             midjourney: true,
             task: true,
           },
-          personal: { enabled: true, topup: true, personal: true },
+          personal: {
+            enabled: true,
+            topup: true,
+            organization: true,
+            personal: true,
+          },
           admin: {
             enabled: true,
             channel: true,
@@ -241,6 +248,11 @@ export default function SettingsSidebarModulesAdmin(props) {
       description: t('用户个人功能'),
       modules: [
         { key: 'topup', title: t('钱包管理'), description: t('余额充值管理') },
+        {
+          key: 'organization',
+          title: t('组织管理'),
+          description: t('组织共享钱包与成员管理'),
+        },
         {
           key: 'personal',
           title: t('个人设置'),
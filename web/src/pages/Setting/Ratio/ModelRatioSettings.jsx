@@ -44,6 +44,8 @@ export default function ModelRatioSettings(props) {
     ModelRatio: '',
     CacheRatio: '',
     CreateCacheRatio: '',
+    BatchRatio: '',
+    ModelTieredRatio: '',
     CompletionRatio: '',
     ImageRatio: '',
     AudioRatio: '',
@@ -225,6 +227,54 @@ export default function ModelRatioSettings(props) {
             />
           </Col>
         </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('批处理倍率')}
+              extraText={t(
+                '通过 /v1/batches 提交的请求在正常计费基础上额外乘以该倍率，键 default 对未单独配置的模型生效',
+              )}
+              placeholder={t('为一个 JSON 文本，键为模型名称，值为倍率')}
+              field={'BatchRatio'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) => setInputs({ ...inputs, BatchRatio: value })}
+            />
+          </Col>
+        </Row>
+        <Row gutter={16}>
+          <Col xs={24} sm={16}>
+            <Form.TextArea
+              label={t('分档倍率')}
+              extraText={t(
+                '提示词 tokens 超过阈值时使用该档位的模型倍率、补全倍率与缓存倍率，补全倍率与缓存倍率为 0 时沿用原有倍率',
+              )}
+              placeholder={t(
+                '为一个 JSON 文本，键为模型名称，值为档位列表，例如 {"gemini-2.5-pro":[{"threshold":200000,"model_ratio":1.25,"completion_ratio":6}]}',
+              )}
+              field={'ModelTieredRatio'}
+              autosize={{ minRows: 6, maxRows: 12 }}
+              trigger='blur'
+              stopValidateWithError
+              rules={[
+                {
+                  validator: (rule, value) => verifyJSON(value),
+                  message: '不是合法的 JSON 字符串',
+                },
+              ]}
+              onChange={(value) =>
+                setInputs({ ...inputs, ModelTieredRatio: value })
+              }
+            />
+          </Col>
+        </Row>
         <Row gutter={16}>
           <Col xs={24} sm={16}>
             <Form.TextArea